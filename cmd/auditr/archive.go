@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vaibhaw-/AuditR/internal/auditr/archive"
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+	"github.com/vaibhaw-/AuditR/internal/auditr/timestamp"
+	"github.com/vaibhaw-/AuditR/internal/auditr/verify"
+)
+
+var (
+	archiveFlagDir        string
+	archiveFlagInput      string
+	archiveFlagPrivateKey string
+	archiveFlagDryRun     bool
+	archiveFlagJSON       bool
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Store hashed events in sealed, retention-managed segments",
+	Long: `Store hashed events in an append-only archive of sealed segments.
+
+Hashed NDJSON (the output of verify in hash mode) is appended to the open
+segment of each named stream. A segment is sealed once it reaches
+archive.segment_max_events, archive.segment_max_bytes or archive.segment_max_age:
+its file is made read-only, its SHA-256 digest and Merkle root are recorded in
+archive.json and, when a signing key is configured, a signed checkpoint covers
+its last event. Sealed segments are purged once every event in them is past its
+retention (archive.retention, by retention class and regulation); archive.json
+keeps their hashes so the rest of the archive still verifies.
+
+Verify the whole archive with "auditr verify --archive DIR".`,
+}
+
+var archiveAppendCmd = &cobra.Command{
+	Use:   "append",
+	Short: "Append a hashed NDJSON file to the archive",
+	Long: `Append a hashed NDJSON file to the archive.
+
+The file must verify and each of its streams must continue exactly where the
+archive's stream ends; otherwise nothing is appended. Segments that reach a
+rotation limit are sealed.
+
+Example:
+  auditr verify --input enriched.jsonl --output hashed.jsonl
+  auditr archive append --input hashed.jsonl --dir ./archive`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if archiveFlagInput == "" {
+			return fmt.Errorf("--input is required")
+		}
+		s, err := openArchive(true)
+		if err != nil {
+			return err
+		}
+		defer closeArchive(s)
+		res, err := s.Append(archiveFlagInput)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "archived %d events in %d segments, sealed %d\n", res.Events, len(res.Segments), len(res.Sealed))
+		for _, seg := range res.Sealed {
+			printSealed(seg)
+		}
+		return nil
+	},
+}
+
+var archiveSealCmd = &cobra.Command{
+	Use:   "seal",
+	Short: "Seal every open segment",
+	Long: `Seal every open segment, whatever its size or age, e.g. before handing the
+archive over or at the end of a retention period's collection.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := openArchive(true)
+		if err != nil {
+			return err
+		}
+		defer closeArchive(s)
+		sealed, err := s.Seal()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "sealed %d segments\n", len(sealed))
+		for _, seg := range sealed {
+			printSealed(seg)
+		}
+		return nil
+	},
+}
+
+var archivePurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Remove sealed segments past their retention",
+	Long: `Remove the files of sealed segments whose retention has passed.
+
+A segment is kept until the last of its events to expire does: each event is
+kept for the longest retention of its retention_classes and regulations in
+archive.retention, or archive.retention.default. Purged segments stay in
+archive.json with their hashes and checkpoint.
+
+Example:
+  auditr archive purge --dir ./archive --dry-run`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, err := openArchive(false)
+		if err != nil {
+			return err
+		}
+		defer closeArchive(s)
+		purged, err := s.Purge(archiveFlagDryRun)
+		if err != nil {
+			return err
+		}
+		verb := "purged"
+		if archiveFlagDryRun {
+			verb = "would purge"
+		}
+		fmt.Fprintf(os.Stdout, "%s %d segments\n", verb, len(purged))
+		for _, seg := range purged {
+			fmt.Fprintf(os.Stdout, "  segment %d: chain index %d-%d, %d events, retained until %s (%s)\n",
+				seg.ID, seg.FirstIndex, seg.LastIndex, seg.Events, seg.RetainUntil.Format(time.RFC3339), strings.Join(seg.Classes, ","))
+		}
+		return nil
+	},
+}
+
+var archiveListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the segments of the archive",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if archiveDir() == "" {
+			return fmt.Errorf("archive directory not set (use --dir or archive.dir)")
+		}
+		m, err := archive.LoadManifest(archiveDir())
+		if err != nil {
+			return err
+		}
+		if archiveFlagJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(m)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSTREAM\tSTATE\tEVENTS\tCHAIN INDEX\tRETAIN UNTIL\tCLASSES\tCHECKPOINT")
+		for _, seg := range m.Segments {
+			stream, until, cp := seg.Stream, "forever", seg.Checkpoint
+			if stream == "" {
+				stream = "-"
+			}
+			if seg.RetainUntil != nil {
+				until = seg.RetainUntil.Format(time.RFC3339)
+			}
+			if cp == "" {
+				cp = "-"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d-%d\t%s\t%s\t%s\n", seg.ID, stream, seg.State, seg.Events,
+				seg.FirstIndex, seg.LastIndex, until, strings.Join(seg.Classes, ","), cp)
+		}
+		return w.Flush()
+	},
+}
+
+// archiveDir returns --dir, falling back to archive.dir
+func archiveDir() string {
+	if archiveFlagDir == "" {
+		return config.Get().Archive.Dir
+	}
+	return archiveFlagDir
+}
+
+// openArchive opens the archive for writing; with sign, sealed segments get a
+// checkpoint when a signing key (or external signer) is configured
+func openArchive(sign bool) (*archive.Store, error) {
+	cfg := config.Get()
+	opts, err := archive.NewOptions(cfg.Archive)
+	if err != nil {
+		return nil, err
+	}
+	if sign && (archiveFlagPrivateKey != "" || cfg.Signing.PrivateKeyPath != "" || cfg.Signing.External.Enabled()) {
+		if opts.Signer, err = verify.NewSigner(cfg, archiveFlagPrivateKey); err != nil {
+			return nil, err
+		}
+		if cfg.Timestamping.URL != "" {
+			opts.Timestamper = &timestamp.Client{URL: cfg.Timestamping.URL}
+		}
+	}
+	return archive.Open(archiveDir(), opts)
+}
+
+// closeArchive releases the archive lock, warning if it could not be released
+func closeArchive(s *archive.Store) {
+	if err := s.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not release archive lock: %v\n", err)
+	}
+}
+
+func printSealed(seg *archive.Segment) {
+	cp := seg.Checkpoint
+	if cp == "" {
+		cp = "none"
+	}
+	fmt.Fprintf(os.Stdout, "  segment %d: chain index %d-%d, %d events, checkpoint %s\n", seg.ID, seg.FirstIndex, seg.LastIndex, seg.Events, cp)
+}
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+	archiveCmd.AddCommand(archiveAppendCmd, archiveSealCmd, archivePurgeCmd, archiveListCmd)
+
+	archiveCmd.PersistentFlags().StringVar(&archiveFlagDir, "dir", "", "archive directory (default archive.dir)")
+	archiveAppendCmd.Flags().StringVar(&archiveFlagInput, "input", "", "hashed NDJSON file to archive")
+	for _, c := range []*cobra.Command{archiveAppendCmd, archiveSealCmd} {
+		c.Flags().StringVar(&archiveFlagPrivateKey, "private-key", "", "private key PEM for segment checkpoints (default signing.private_key_path)")
+	}
+	archivePurgeCmd.Flags().BoolVar(&archiveFlagDryRun, "dry-run", false, "only list the segments that would be purged")
+	archiveListCmd.Flags().BoolVar(&archiveFlagJSON, "json", false, "print archive.json")
+}
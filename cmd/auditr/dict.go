@@ -3,13 +3,17 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+	"github.com/vaibhaw-/AuditR/internal/auditr/enrich"
 )
 
 var dictFile string
 var riskFile string
+var dictOverridesFile string
+var dictSchemaFile string
 
 var dictCmd = &cobra.Command{
 	Use:   "dict",
@@ -19,10 +23,21 @@ var dictCmd = &cobra.Command{
 var dictValidateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate sensitivity dictionary and risk scoring JSON",
+	Long: `Validate sensitivity dictionary and risk scoring JSON.
+
+Categories are the top-level keys of the dictionary; "Negative" and "Metadata" are
+reserved. "Metadata" attaches regulations, a retention class and a default risk to a
+category; the default risk is used when risk scoring has no base entry for it.
+
+With --overrides, the column overrides file is validated as well. Adding --schema
+reports overrides that reference a db.schema.table.column missing from the schema CSV.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if dictFile == "" || riskFile == "" {
 			return fmt.Errorf("--dict and --risk are required")
 		}
+		if dictSchemaFile != "" && dictOverridesFile == "" {
+			return fmt.Errorf("--schema requires --overrides")
+		}
 
 		df, err := os.Open(dictFile)
 		if err != nil {
@@ -41,13 +56,67 @@ var dictValidateCmd = &cobra.Command{
 		}
 		defer rf.Close()
 
-		_, err = config.ValidateRiskScoring(rf, categories)
+		rs, err := config.ValidateRiskScoringWithMetadata(rf, categories, dict.Metadata)
 		if err != nil {
 			return fmt.Errorf("risk scoring validation failed: %w", err)
 		}
 
 		fmt.Fprintf(os.Stdout, "dictionary and risk scoring validated successfully\n")
 		fmt.Fprintf(os.Stdout, "categories: %v, negatives: %d\n", len(dict.Categories), len(dict.Negative))
+		for _, category := range dict.KnownCategories() {
+			m, _ := dict.MetadataFor(category)
+			risk, ok := rs.Base[category]
+			if !ok {
+				risk = rs.Default + " (default)"
+			}
+			fmt.Fprintf(os.Stdout, "  %s: risk=%s", category, risk)
+			if len(m.Regulations) > 0 {
+				fmt.Fprintf(os.Stdout, " regulations=%s", strings.Join(m.Regulations, ","))
+			}
+			if m.RetentionClass != "" {
+				fmt.Fprintf(os.Stdout, " retention=%s", m.RetentionClass)
+			}
+			if _, hasRules := dict.Categories[category]; !hasRules {
+				fmt.Fprintf(os.Stdout, " (metadata only)")
+			}
+			fmt.Fprintf(os.Stdout, "\n")
+		}
+
+		if dictOverridesFile == "" {
+			return nil
+		}
+
+		of, err := os.Open(dictOverridesFile)
+		if err != nil {
+			return fmt.Errorf("open overrides file: %w", err)
+		}
+		defer of.Close()
+
+		overrides, err := config.ValidateOverrides(of)
+		if err != nil {
+			return fmt.Errorf("overrides validation failed: %w", err)
+		}
+		fmt.Fprintf(os.Stdout, "overrides: %d\n", len(overrides.Overrides))
+		if custom := overrides.CustomCategories(dict.KnownCategories()); len(custom) > 0 {
+			fmt.Fprintf(os.Stdout, "custom categories: %s\n", strings.Join(custom, ", "))
+		}
+
+		if dictSchemaFile == "" {
+			return nil
+		}
+
+		catalog, err := enrich.LoadSchemaCatalogCSV(dictSchemaFile)
+		if err != nil {
+			return fmt.Errorf("load schema: %w", err)
+		}
+		missing := enrich.MissingOverrideColumns(catalog, overrides.Overrides)
+		for _, o := range missing {
+			fmt.Fprintf(os.Stdout, "override references missing column: %s\n", o.Column)
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%d override(s) reference columns missing from schema %s", len(missing), dictSchemaFile)
+		}
+		fmt.Fprintf(os.Stdout, "all overrides match schema columns\n")
 		return nil
 	},
 }
@@ -58,6 +127,8 @@ func init() {
 
 	dictValidateCmd.Flags().StringVar(&dictFile, "dict", "", "Path to sensitivity dictionary JSON file")
 	dictValidateCmd.Flags().StringVar(&riskFile, "risk", "", "Path to risk scoring JSON file")
+	dictValidateCmd.Flags().StringVar(&dictOverridesFile, "overrides", "", "Path to column overrides JSON file")
+	dictValidateCmd.Flags().StringVar(&dictSchemaFile, "schema", "", "Path to schema CSV used to check override columns exist")
 
 	_ = dictValidateCmd.MarkFlagRequired("dict")
 	_ = dictValidateCmd.MarkFlagRequired("risk")
@@ -3,9 +3,11 @@ package main
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -13,6 +15,7 @@ import (
 	"github.com/vaibhaw-/AuditR/internal/auditr/config"
 	"github.com/vaibhaw-/AuditR/internal/auditr/enrich"
 	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+	"github.com/vaibhaw-/AuditR/internal/auditr/output"
 )
 
 var enrichCmd = &cobra.Command{
@@ -27,28 +30,73 @@ The enrichment process uses:
 - Database schema (CSV format) to resolve column types
 - Sensitivity dictionary (JSON) with regex patterns for data classification
 - Risk scoring policy (JSON) for computing risk levels
+- Optional column overrides (JSON, --overrides) that force or suppress the
+  classification of specific db.schema.table.column locations
+
+Schema history:
+- --schema-snapshot <RFC3339 time>=<csv> adds a schema snapshot valid from that time
+  (repeatable); events resolve against the snapshot in effect at their timestamp
+- --replay-ddl applies CREATE/ALTER/DROP TABLE events from the input to the schema
+
+Views and routines:
+- --views <csv> loads view definitions (pg_views / information_schema.VIEWS)
+- --routines <csv> loads function/procedure to table mappings
+  Queries on views and calls to routines are attributed to the underlying columns
+
+Compliance:
+- --compliance <json> maps categories, query types and escalation types to
+  framework controls; matching events get compliance_controls (e.g.
+  "hipaa:164.312(b)") and are emitted even without sensitivity matches
+
+Multiple databases:
+- Tables are looked up in the event's db_name first, following the PostgreSQL
+  search_path (SET search_path or an event search_path field) or the MySQL current
+  database (USE); tables that still match different definitions are reported as
+  schema_status "ambiguous" instead of being guessed
+
+Output formats:
+- --format ndjson (default), json, csv, table or parquet; the default comes from
+  output.format in the config
+- --fields selects and orders the columns (e.g. timestamp,db_user,risk_level,sensitivity);
+  tabular formats flatten nested fields to dotted columns and join arrays with ";"
 
 Input: NDJSON stream of parsed audit events
-Output: NDJSON stream of enriched events with sensitivity and risk information`,
+Output: Stream of enriched events with sensitivity and risk information`,
 	RunE: runEnrich,
 }
 
 var (
 	enrichFlagSchema      string
+	enrichFlagSnapshots   []string
+	enrichFlagReplayDDL   bool
+	enrichFlagViews       string
+	enrichFlagRoutines    string
 	enrichFlagDict        string
+	enrichFlagOverrides   string
 	enrichFlagRisk        string
+	enrichFlagCompliance  string
 	enrichFlagInput       string
 	enrichFlagOutput      string
+	enrichFlagFormat      string
+	enrichFlagFields      []string
 	enrichFlagEmitUnknown bool
 	enrichFlagDebug       bool
 )
 
 func init() {
 	enrichCmd.Flags().StringVar(&enrichFlagSchema, "schema", "", "database schema CSV file (required)")
+	enrichCmd.Flags().StringArrayVar(&enrichFlagSnapshots, "schema-snapshot", nil, "additional schema CSV valid from a time, as <RFC3339 time>=<path> (repeatable)")
+	enrichCmd.Flags().BoolVar(&enrichFlagReplayDDL, "replay-ddl", false, "apply CREATE/ALTER/DROP TABLE events from the input to the schema")
+	enrichCmd.Flags().StringVar(&enrichFlagViews, "views", "", "view definitions CSV file (db_name,schema_name,view_name,view_definition)")
+	enrichCmd.Flags().StringVar(&enrichFlagRoutines, "routines", "", "routine to table mapping CSV file (db_name,schema_name,routine_name,table_schema,table_name,column_name)")
 	enrichCmd.Flags().StringVar(&enrichFlagDict, "dict", "", "sensitivity dictionary JSON file (required)")
+	enrichCmd.Flags().StringVar(&enrichFlagOverrides, "overrides", "", "column classification overrides JSON file")
 	enrichCmd.Flags().StringVar(&enrichFlagRisk, "risk", "", "risk scoring policy JSON file (required)")
+	enrichCmd.Flags().StringVar(&enrichFlagCompliance, "compliance", "", "compliance framework mapping JSON file")
 	enrichCmd.Flags().StringVar(&enrichFlagInput, "input", "", "input NDJSON file (default stdin)")
-	enrichCmd.Flags().StringVar(&enrichFlagOutput, "output", "", "output NDJSON file (default stdout)")
+	enrichCmd.Flags().StringVar(&enrichFlagOutput, "output", "", "output file (default stdout)")
+	enrichCmd.Flags().StringVar(&enrichFlagFormat, "format", "", "output format: ndjson, json, csv, table or parquet (default output.format from config, else ndjson)")
+	enrichCmd.Flags().StringSliceVar(&enrichFlagFields, "fields", nil, "columns to write, in order, e.g. timestamp,db_user,risk_level,sensitivity (default output.fields from config, else every field)")
 	enrichCmd.Flags().BoolVar(&enrichFlagEmitUnknown, "emit-unknown", false, "emit events with no sensitive data matches")
 	enrichCmd.Flags().BoolVar(&enrichFlagDebug, "debug", false, "include debug information in output")
 
@@ -63,8 +111,14 @@ func runEnrich(cmd *cobra.Command, args []string) error {
 
 	logger.L().Infow("Starting enrichment process",
 		"schema_file", enrichFlagSchema,
+		"schema_snapshots", len(enrichFlagSnapshots),
+		"replay_ddl", enrichFlagReplayDDL,
+		"views_file", enrichFlagViews,
+		"routines_file", enrichFlagRoutines,
 		"dict_file", enrichFlagDict,
+		"overrides_file", enrichFlagOverrides,
 		"risk_file", enrichFlagRisk,
+		"compliance_file", enrichFlagCompliance,
 		"input", enrichFlagInput,
 		"output", enrichFlagOutput,
 		"emit_unknown", enrichFlagEmitUnknown,
@@ -72,10 +126,23 @@ func runEnrich(cmd *cobra.Command, args []string) error {
 
 	// Load schema
 	logger.L().Debugw("Loading database schema", "file", enrichFlagSchema)
-	schema, err := enrich.LoadSchemaCSV(enrichFlagSchema)
+	catalog, err := enrich.LoadSchemaCatalogCSV(enrichFlagSchema)
 	if err != nil {
 		return fmt.Errorf("failed to load schema: %w", err)
 	}
+	schemas := enrich.NewSchemaHistoryFromCatalog(catalog)
+
+	// Load additional time-stamped schema snapshots
+	for _, spec := range enrichFlagSnapshots {
+		validFrom, path, err := enrich.ParseSchemaSnapshotSpec(spec)
+		if err != nil {
+			return err
+		}
+		logger.L().Debugw("Loading schema snapshot", "file", path, "valid_from", validFrom)
+		if err := schemas.LoadSchemaSnapshot(validFrom, path); err != nil {
+			return fmt.Errorf("failed to load schema snapshot: %w", err)
+		}
+	}
 
 	// Load sensitivity dictionary
 	logger.L().Debugw("Loading sensitivity dictionary", "file", enrichFlagDict)
@@ -84,9 +151,19 @@ func runEnrich(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to load sensitivity dictionary: %w", err)
 	}
 
+	// Load column overrides
+	if enrichFlagOverrides != "" {
+		logger.L().Debugw("Loading column overrides", "file", enrichFlagOverrides)
+		overrides, err := enrich.LoadOverrides(enrichFlagOverrides)
+		if err != nil {
+			return fmt.Errorf("failed to load column overrides: %w", err)
+		}
+		dict.SetOverrides(overrides)
+	}
+
 	// Load risk scoring
 	logger.L().Debugw("Loading risk scoring policy", "file", enrichFlagRisk)
-	riskScoring, err := enrich.LoadRisk(enrichFlagRisk, dict.CategoryNames)
+	riskScoring, err := enrich.LoadRiskForDict(enrichFlagRisk, dict)
 	if err != nil {
 		return fmt.Errorf("failed to load risk scoring: %w", err)
 	}
@@ -95,9 +172,39 @@ func runEnrich(cmd *cobra.Command, args []string) error {
 	enricherOptions := enrich.EnrichmentOptions{
 		EmitUnknown: enrichFlagEmitUnknown,
 		Debug:       enrichFlagDebug,
+		ReplayDDL:   enrichFlagReplayDDL,
 	}
 
-	enricher := enrich.NewEnricher(schema, dict, riskScoring, enricherOptions)
+	enricher := enrich.NewEnricherWithHistory(schemas, dict, riskScoring, enricherOptions)
+
+	// Load compliance framework mapping
+	if enrichFlagCompliance != "" {
+		logger.L().Debugw("Loading compliance mapping", "file", enrichFlagCompliance)
+		mapping, err := enrich.LoadCompliance(enrichFlagCompliance)
+		if err != nil {
+			return fmt.Errorf("failed to load compliance mapping: %w", err)
+		}
+		enricher.SetCompliance(mapping)
+	}
+
+	// Load view and routine lineage
+	if enrichFlagViews != "" || enrichFlagRoutines != "" {
+		var views []enrich.ViewDefinition
+		var routines []enrich.RoutineDefinition
+		if enrichFlagViews != "" {
+			logger.L().Debugw("Loading view definitions", "file", enrichFlagViews)
+			if views, err = enrich.LoadViewsCSV(enrichFlagViews); err != nil {
+				return fmt.Errorf("failed to load view definitions: %w", err)
+			}
+		}
+		if enrichFlagRoutines != "" {
+			logger.L().Debugw("Loading routine mappings", "file", enrichFlagRoutines)
+			if routines, err = enrich.LoadRoutinesCSV(enrichFlagRoutines); err != nil {
+				return fmt.Errorf("failed to load routine mappings: %w", err)
+			}
+		}
+		enricher.SetLineage(enrich.NewLineageCatalog(views, routines))
+	}
 
 	// Log enricher statistics
 	stats := enricher.GetStats()
@@ -118,24 +225,44 @@ func runEnrich(cmd *cobra.Command, args []string) error {
 		logger.L().Debug("Reading from stdin")
 	}
 
+	// Resolve the output format: flags first, then the config
+	format := enrichFlagFormat
+	if format == "" {
+		format = cfg.Output.Format
+	}
+	if format == "" {
+		format = output.FormatNDJSON
+	}
+	if !output.ValidFormat(format) {
+		return fmt.Errorf("invalid output format %q, expected %s", format, strings.Join(output.Formats, ", "))
+	}
+	fields := parseCommaSeparated(enrichFlagFields)
+	if len(fields) == 0 {
+		fields = cfg.Output.Fields
+	}
+
 	// Setup output writer
-	var output io.Writer = os.Stdout
+	var dest io.Writer = os.Stdout
 	if enrichFlagOutput != "" {
 		file, err := os.Create(enrichFlagOutput)
 		if err != nil {
 			return fmt.Errorf("failed to create output file %s: %w", enrichFlagOutput, err)
 		}
 		defer file.Close()
-		output = file
-		logger.L().Debugw("Writing to output file", "file", enrichFlagOutput)
+		dest = file
+		logger.L().Debugw("Writing to output file", "file", enrichFlagOutput, "format", format)
 	} else {
 		logger.L().Debug("Writing to stdout")
 	}
 
 	// Process events
 	scanner := bufio.NewScanner(input)
-	writer := bufio.NewWriter(output)
+	writer := bufio.NewWriter(dest)
 	defer writer.Flush()
+	out, err := output.NewWriter(writer, output.Options{Format: format, Fields: fields})
+	if err != nil {
+		return err
+	}
 
 	var metrics EnrichmentMetrics
 	metrics.init() // Initialize maps
@@ -161,12 +288,8 @@ func runEnrich(cmd *cobra.Command, args []string) error {
 
 			// Emit error event instead of dropping the line
 			errorEvent := createErrorEvent(line, "enrich", fmt.Sprintf("JSON parse error: %v", err))
-			if errorJSON, marshalErr := json.Marshal(errorEvent); marshalErr == nil {
-				if _, writeErr := writer.WriteString(string(errorJSON) + "\n"); writeErr != nil {
-					return fmt.Errorf("failed to write error event: %w", writeErr)
-				}
-				metrics.OutputEvents++
-				metrics.ErrorEvents++
+			if err := writeErrorEvent(out, errorEvent, &metrics); err != nil {
+				return err
 			}
 			continue
 		}
@@ -184,12 +307,8 @@ func runEnrich(cmd *cobra.Command, args []string) error {
 
 			// Emit error event instead of dropping the event
 			errorEvent := createErrorEventFromEvent(event, "enrich", fmt.Sprintf("Enrichment error: %v", result.Error))
-			if errorJSON, marshalErr := json.Marshal(errorEvent); marshalErr == nil {
-				if _, writeErr := writer.WriteString(string(errorJSON) + "\n"); writeErr != nil {
-					return fmt.Errorf("failed to write error event: %w", writeErr)
-				}
-				metrics.OutputEvents++
-				metrics.ErrorEvents++
+			if err := writeErrorEvent(out, errorEvent, &metrics); err != nil {
+				return err
 			}
 			continue
 		}
@@ -215,8 +334,11 @@ func runEnrich(cmd *cobra.Command, args []string) error {
 			}
 
 			// Write enriched event
-			enrichedJSON, err := json.Marshal(result.EnrichedEvent)
-			if err != nil {
+			if err := out.Write(result.EnrichedEvent); err != nil {
+				var marshalErr *output.MarshalError
+				if !errors.As(err, &marshalErr) {
+					return fmt.Errorf("failed to write output: %w", err)
+				}
 				metrics.SerializationErrors++
 				logger.L().Errorw("Failed to serialize enriched event",
 					"line", lineNumber,
@@ -224,20 +346,12 @@ func runEnrich(cmd *cobra.Command, args []string) error {
 					"error", err)
 
 				// Emit error event instead of dropping the event
-				errorEvent := createErrorEventFromEvent(event, "enrich", fmt.Sprintf("Serialization error: %v", err))
-				if errorJSON, marshalErr := json.Marshal(errorEvent); marshalErr == nil {
-					if _, writeErr := writer.WriteString(string(errorJSON) + "\n"); writeErr != nil {
-						return fmt.Errorf("failed to write error event: %w", writeErr)
-					}
-					metrics.OutputEvents++
-					metrics.ErrorEvents++
+				errorEvent := createErrorEventFromEvent(event, "enrich", fmt.Sprintf("Serialization error: %v", marshalErr.Err))
+				if err := writeErrorEvent(out, errorEvent, &metrics); err != nil {
+					return err
 				}
 				continue
 			}
-
-			if _, err := writer.WriteString(string(enrichedJSON) + "\n"); err != nil {
-				return fmt.Errorf("failed to write output: %w", err)
-			}
 		} else {
 			metrics.DroppedEvents++
 		}
@@ -247,7 +361,10 @@ func runEnrich(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("error reading input: %w", err)
 	}
 
-	// Flush output
+	// Tabular formats are written once every event is seen, then flushed
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
 	if err := writer.Flush(); err != nil {
 		return fmt.Errorf("failed to flush output: %w", err)
 	}
@@ -331,13 +448,18 @@ func writeRunLogSummary(cfg *config.Config, metrics EnrichmentMetrics, duration
 		summary["duration_ms"] = duration.Seconds() * 1000 // Convert to fractional milliseconds
 		summary["detailed_metrics"] = metrics
 		summary["config"] = map[string]interface{}{
-			"schema_file":  enrichFlagSchema,
-			"dict_file":    enrichFlagDict,
-			"risk_file":    enrichFlagRisk,
-			"input_file":   enrichFlagInput,
-			"output_file":  enrichFlagOutput,
-			"emit_unknown": enrichFlagEmitUnknown,
-			"debug":        enrichFlagDebug,
+			"schema_file":    enrichFlagSchema,
+			"snapshots":      enrichFlagSnapshots,
+			"replay_ddl":     enrichFlagReplayDDL,
+			"views_file":     enrichFlagViews,
+			"routines_file":  enrichFlagRoutines,
+			"dict_file":      enrichFlagDict,
+			"overrides_file": enrichFlagOverrides,
+			"risk_file":      enrichFlagRisk,
+			"input_file":     enrichFlagInput,
+			"output_file":    enrichFlagOutput,
+			"emit_unknown":   enrichFlagEmitUnknown,
+			"debug":          enrichFlagDebug,
 		}
 	}
 
@@ -371,6 +493,21 @@ func getEventID(event map[string]interface{}) string {
 	return ""
 }
 
+// writeErrorEvent writes an error event and counts it in the metrics.
+// An error event that can't be serialized itself is skipped.
+func writeErrorEvent(out output.Writer, errorEvent map[string]interface{}, metrics *EnrichmentMetrics) error {
+	if err := out.Write(errorEvent); err != nil {
+		var marshalErr *output.MarshalError
+		if errors.As(err, &marshalErr) {
+			return nil
+		}
+		return fmt.Errorf("failed to write error event: %w", err)
+	}
+	metrics.OutputEvents++
+	metrics.ErrorEvents++
+	return nil
+}
+
 // createErrorEvent creates an error event from a raw line that failed to parse
 func createErrorEvent(rawLine, phase, message string) map[string]interface{} {
 	return map[string]interface{}{
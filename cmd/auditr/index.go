@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/vaibhaw-/AuditR/internal/auditr/query"
+)
+
+var (
+	indexFlagInput       []string
+	indexFlagBlockEvents int
+)
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Build sidecar indexes that let query skip unrelated parts of large files",
+	Long: `Build sidecar indexes of NDJSON files for query.
+
+An index (<file>.index.json) splits the file into blocks of consecutive events
+and records each block's byte range and time range, and which blocks hold events
+of each db_user and sensitivity category. Queries filtering by time (--since,
+--until, --last, --between, --month, --last-business-day), --user or
+--sensitivity then read only the blocks that can match; other queries, files
+without an index and files changed since they were indexed are scanned in full.`,
+}
+
+var indexBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Write the index of each input file",
+	Long: `Write the index of each input file next to it, as <file>.index.json.
+
+Rebuild the index whenever the file changes (e.g. after appending to it): query
+ignores an index once the file's size or modification time differs.
+
+Example:
+  auditr index build --input ./archive/*.ndjson
+  auditr query --input ./archive/*.ndjson --sensitivity PHI --last 7d`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(indexFlagInput) == 0 {
+			return fmt.Errorf("--input is required")
+		}
+		if indexFlagBlockEvents <= 0 {
+			return fmt.Errorf("invalid --block-events %d, expected a positive number", indexFlagBlockEvents)
+		}
+		for _, file := range indexFlagInput {
+			ix, err := query.BuildIndex(file, indexFlagBlockEvents)
+			if err != nil {
+				return err
+			}
+			if err := query.WriteIndex(ix, file); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stdout, "indexed %s: %d events in %d blocks, %d users, %d categories -> %s\n",
+				file, ix.Events, len(ix.Blocks), len(ix.Users), len(ix.Categories), query.IndexPath(file))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(indexCmd)
+	indexCmd.AddCommand(indexBuildCmd)
+
+	indexBuildCmd.Flags().StringSliceVar(&indexFlagInput, "input", nil, "NDJSON file(s) to index (shell glob expansion supported)")
+	indexBuildCmd.Flags().IntVar(&indexFlagBlockEvents, "block-events", query.DefaultIndexBlockEvents, "events per block; smaller blocks skip more precisely but make larger indexes")
+}
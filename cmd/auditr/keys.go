@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vaibhaw-/AuditR/internal/auditr/verify"
+)
+
+var (
+	keysFlagKeyring    string
+	keysFlagAlgorithm  string
+	keysFlagOutDir     string
+	keysFlagName       string
+	keysFlagPrivateKey string
+	keysFlagJSON       bool
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Generate, rotate and list checkpoint signing keys",
+	Long: `Manage checkpoint signing keys and the keyring that publishes their public keys.
+
+The keyring (JSON) lists every public key with its key ID, algorithm and validity
+window. Pass it to --public-key instead of a single PEM key: each checkpoint is
+then verified with the key named by its key ID that was valid when the checkpoint
+was created, so checkpoints signed before a rotation stay verifiable.`,
+}
+
+var keysGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a key pair and publish it as the first key of a keyring",
+	Long: `Generate a key pair and publish it as the first key of a keyring.
+
+The private key is written as <out-dir>/<name>-<key id>.pem (mode 0600) and the
+public key next to it as .pub.pem. Use the private key as --private-key or
+signing.private_key_path.
+
+Example:
+  auditr keys generate --algorithm ed25519 --out-dir ./keys --keyring ./keys/keyring.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kr := &verify.Keyring{}
+		if _, err := os.Stat(keysFlagKeyring); err == nil {
+			if kr, err = verify.LoadKeyring(keysFlagKeyring); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("stat keyring: %w", err)
+		}
+
+		key, err := verify.GenerateKey(keysFlagAlgorithm)
+		if err != nil {
+			return err
+		}
+		entry, err := kr.Add(key.Public(), time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		privPath, pubPath, err := verify.WriteKeyPair(keysFlagOutDir, keysFlagName, key)
+		if err != nil {
+			return err
+		}
+		if err := verify.SaveKeyringWithKeyPair(keysFlagKeyring, kr, privPath, pubPath); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stdout, "generated %s key %s\n", entry.Algorithm, entry.KeyID)
+		fmt.Fprintf(os.Stdout, "  private key: %s\n", privPath)
+		fmt.Fprintf(os.Stdout, "  public key:  %s\n", pubPath)
+		fmt.Fprintf(os.Stdout, "  keyring:     %s\n", keysFlagKeyring)
+		return nil
+	},
+}
+
+var keysRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Replace the active key with a new key endorsed by it",
+	Long: `Replace the active key with a new key endorsed by it.
+
+The current private key (--private-key) signs the new key's ID, algorithm, public
+key and start of validity; the old key's validity ends at the rotation time. The
+algorithm defaults to the current key's. Point signing.private_key_path at the new
+private key afterwards; keep the keyring to verify older checkpoints.
+
+Example:
+  auditr keys rotate --keyring ./keys/keyring.json --private-key ./keys/auditr-1a2b3c4d5e6f.pem --out-dir ./keys`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kr, err := verify.LoadKeyring(keysFlagKeyring)
+		if err != nil {
+			return err
+		}
+		current, err := verify.LoadFileSigner(keysFlagPrivateKey)
+		if err != nil {
+			return err
+		}
+		alg := keysFlagAlgorithm
+		if !cmd.Flags().Changed("algorithm") {
+			alg = current.Algorithm()
+		}
+
+		key, err := verify.GenerateKey(alg)
+		if err != nil {
+			return err
+		}
+		entry, err := kr.Rotate(current, key.Public(), time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		privPath, pubPath, err := verify.WriteKeyPair(keysFlagOutDir, keysFlagName, key)
+		if err != nil {
+			return err
+		}
+		if err := verify.SaveKeyringWithKeyPair(keysFlagKeyring, kr, privPath, pubPath); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stdout, "rotated %s -> %s (%s)\n", current.KeyID(), entry.KeyID, entry.Algorithm)
+		fmt.Fprintf(os.Stdout, "  private key: %s\n", privPath)
+		fmt.Fprintf(os.Stdout, "  public key:  %s\n", pubPath)
+		fmt.Fprintf(os.Stdout, "update --private-key / signing.private_key_path to the new private key\n")
+		return nil
+	},
+}
+
+var keysListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the keys of a keyring",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kr, err := verify.LoadKeyring(keysFlagKeyring)
+		if err != nil {
+			return err
+		}
+		if keysFlagJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(kr)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY ID\tALGORITHM\tNOT BEFORE\tNOT AFTER\tSTATUS\tENDORSED BY")
+		for _, k := range kr.Keys {
+			notAfter, status := "-", "active"
+			if k.NotAfter != nil {
+				notAfter, status = k.NotAfter.Format(time.RFC3339), "retired"
+			}
+			endorsedBy := "-"
+			if k.Endorsement != nil {
+				endorsedBy = k.Endorsement.KeyID
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", k.KeyID, k.Algorithm, k.NotBefore.Format(time.RFC3339), notAfter, status, endorsedBy)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if err := kr.VerifyEndorsements(); err != nil {
+			return fmt.Errorf("keyring does not verify: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(keysGenerateCmd, keysRotateCmd, keysListCmd)
+
+	keysCmd.PersistentFlags().StringVar(&keysFlagKeyring, "keyring", "keyring.json", "keyring file publishing the public keys")
+
+	for _, c := range []*cobra.Command{keysGenerateCmd, keysRotateCmd} {
+		c.Flags().StringVar(&keysFlagAlgorithm, "algorithm", verify.AlgECDSAP256SHA256, "key algorithm: ecdsa-p256-sha256, ed25519 or rsa-pss-sha256")
+		c.Flags().StringVar(&keysFlagOutDir, "out-dir", ".", "directory for the generated key files")
+		c.Flags().StringVar(&keysFlagName, "name", "auditr", "file name prefix of the generated key files")
+	}
+	keysRotateCmd.Flags().StringVar(&keysFlagPrivateKey, "private-key", "", "private key of the active key (endorses the new key)")
+	_ = keysRotateCmd.MarkFlagRequired("private-key")
+	keysListCmd.Flags().BoolVar(&keysFlagJSON, "json", false, "print the keyring as JSON")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+	"github.com/vaibhaw-/AuditR/internal/auditr/verify"
+)
+
+var (
+	proveFlagInput          string
+	proveFlagEventID        string
+	proveFlagCheckpointDir  string
+	proveFlagCheckpointPath string
+	proveFlagOutput         string
+	checkProofFlagProof     string
+	checkProofFlagPublicKey string
+)
+
+var verifyProveCmd = &cobra.Command{
+	Use:   "prove",
+	Short: "Write an inclusion proof for one event of a Merkle mode log",
+	Long: `Write a compact inclusion proof that one event is part of a log hashed in
+Merkle mode (hashing.merkle_batch_size) and covered by a signed checkpoint.
+
+The proof contains the event, its Merkle audit path, the roots of the later
+batches up to the checkpoint and the signed checkpoint itself, so an auditor can
+check it with "auditr verify check-proof" and the public key alone, without the
+rest of the log.
+
+Examples:
+  auditr verify prove --input hashed.jsonl --event-id 5f0c... --output proof.json
+  auditr verify prove --input hashed.jsonl --event-id 5f0c... --checkpoint-path ./checkpoints/checkpoint-20250101-120000-4096.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var paths []string
+		if proveFlagCheckpointPath != "" {
+			paths = []string{proveFlagCheckpointPath}
+		} else {
+			dir := proveFlagCheckpointDir
+			if dir == "" {
+				if cfg := config.Get(); cfg != nil {
+					dir = cfg.Hashing.CheckpointDir
+				}
+			}
+			if dir == "" {
+				return fmt.Errorf("--checkpoint-dir or --checkpoint-path is required (or set hashing.checkpoint_dir)")
+			}
+			var err error
+			if paths, err = filepath.Glob(filepath.Join(dir, "checkpoint-*.json")); err != nil {
+				return fmt.Errorf("list checkpoints: %w", err)
+			}
+		}
+
+		proof, err := verify.BuildInclusionProof(proveFlagInput, proveFlagEventID, paths)
+		if err != nil {
+			return err
+		}
+		b, err := json.MarshalIndent(proof, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal proof: %w", err)
+		}
+		b = append(b, '\n')
+		if proveFlagOutput == "" {
+			_, err = os.Stdout.Write(b)
+			return err
+		}
+		if err := os.WriteFile(proveFlagOutput, b, 0644); err != nil {
+			return fmt.Errorf("write proof: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "inclusion proof for event %s (chain index %d) written to %s\n", proveFlagEventID, proof.ChainIndex, proveFlagOutput)
+		return nil
+	},
+}
+
+var verifyCheckProofCmd = &cobra.Command{
+	Use:   "check-proof",
+	Short: "Check an inclusion proof written by verify prove",
+	Long: `Check an inclusion proof written by "auditr verify prove": the event must lead
+through its audit path and the later batch roots to the head of the checkpoint,
+and the checkpoint signature must verify with the public key (or keyring).
+
+Example:
+  auditr verify check-proof --proof proof.json --public-key pub.pem`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		proof, err := verify.LoadInclusionProof(checkProofFlagProof)
+		if err != nil {
+			return err
+		}
+		if err := verify.VerifyInclusionProof(proof, checkProofFlagPublicKey); err != nil {
+			return fmt.Errorf("inclusion proof does not verify: %w", err)
+		}
+		eventID, _ := proof.Event["event_id"].(string)
+		fmt.Fprintf(os.Stdout, "inclusion proof valid: event %s (chain index %d) is covered by the checkpoint at chain index %d\n",
+			eventID, proof.ChainIndex, proof.Checkpoint.Checkpoint.ChainIndex)
+		return nil
+	},
+}
+
+func init() {
+	verifyCmd.AddCommand(verifyProveCmd, verifyCheckProofCmd)
+
+	verifyProveCmd.Flags().StringVar(&proveFlagInput, "input", "", "hashed NDJSON file written in Merkle mode")
+	verifyProveCmd.Flags().StringVar(&proveFlagEventID, "event-id", "", "event_id of the event to prove")
+	verifyProveCmd.Flags().StringVar(&proveFlagCheckpointDir, "checkpoint-dir", "", "directory of signed checkpoints (default hashing.checkpoint_dir)")
+	verifyProveCmd.Flags().StringVar(&proveFlagCheckpointPath, "checkpoint-path", "", "use this checkpoint instead of searching a directory")
+	verifyProveCmd.Flags().StringVar(&proveFlagOutput, "output", "", "proof file (default stdout)")
+	_ = verifyProveCmd.MarkFlagRequired("input")
+	_ = verifyProveCmd.MarkFlagRequired("event-id")
+
+	verifyCheckProofCmd.Flags().StringVar(&checkProofFlagProof, "proof", "", "inclusion proof JSON")
+	verifyCheckProofCmd.Flags().StringVar(&checkProofFlagPublicKey, "public-key", "", "public key PEM or keyring of the checkpoint signer")
+	_ = verifyCheckProofCmd.MarkFlagRequired("proof")
+	_ = verifyCheckProofCmd.MarkFlagRequired("public-key")
+}
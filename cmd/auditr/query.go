@@ -7,6 +7,8 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/vaibhaw-/AuditR/internal/auditr/enrich"
+	"github.com/vaibhaw-/AuditR/internal/auditr/output"
 	"github.com/vaibhaw-/AuditR/internal/auditr/query"
 )
 
@@ -19,18 +21,43 @@ import (
 var (
 	queryFlagInput         []string // Input NDJSON file(s) - supports multiple files
 	queryFlagOutput        string   // Output file path - empty means stdout
-	queryFlagSensitivity   []string // Sensitivity categories to filter (PII, PHI, Financial)
+	queryFlagSensitivity   []string // Sensitivity categories to filter (PII, PHI, Financial, or custom)
+	queryFlagRegulation    []string // Regulation tags to filter (HIPAA, GDPR-Art9, etc.)
 	queryFlagUser          string   // Database user to filter by
 	queryFlagIP            string   // Client IP address to filter by
 	queryFlagTypes         []string // Query types to filter (SELECT, INSERT, UPDATE, etc.)
 	queryFlagBulk          bool     // Filter for bulk operations only
 	queryFlagBulkType      string   // Filter by specific bulk operation type (export, import, etc.)
+	queryFlagRisk          []string // Risk levels to filter (low, medium, high, critical)
+	queryFlagMinRisk       string   // Minimum risk level to include
+	queryFlagWhere         string   // Filter expression over any event field
 	queryFlagFilter        []string // Field names to filter in sensitivity entries
 	queryFlagSince         string   // Absolute time filter (ISO 8601 UTC)
 	queryFlagLast          string   // Relative time filter (7d, 24h, etc.)
+	queryFlagUntil         string   // End of the time window (exclusive)
+	queryFlagBetween       string   // Closed time window "start,end"
+	queryFlagMonth         string   // Calendar month window (2025-09)
+	queryFlagLastBusDay    bool     // Window of the last business day
+	queryFlagTZ            string   // Zone for interpreting and displaying times
+	queryFlagCalendar      string   // Business calendar JSON
+	queryFlagBusinessHours bool     // Only events within business hours
+	queryFlagOffHours      bool     // Only events outside business hours
 	queryFlagExcludeErrors bool     // Exclude ERROR events from results
 	queryFlagSummary       bool     // Print summary statistics instead of events
 	queryFlagLimit         int      // Limit number of output events
+	queryFlagFormat        string   // Output format (ndjson, json, csv, table, parquet)
+	queryFlagFields        []string // Columns to write, in order
+	queryFlagNoIndex       bool     // Scan files even when they have an index
+	queryFlagGroupBy       []string // Fields to group matched events by
+	queryFlagDistinct      []string // Fields to count distinct values of per group
+	queryFlagBucket        string   // Time bucket width for groups (1h, 1d, ...)
+	queryFlagTop           int      // Keep the N largest groups (per bucket)
+
+	queryFlagAttest         bool   // Verify the input chain and write an attestation
+	queryFlagCheckpoint     string // Signed checkpoint checked against the input
+	queryFlagPublicKey      string // Public key for the checkpoint signature
+	queryFlagTSACert        string // Trusted TSA certificate for the checkpoint timestamp
+	queryFlagAttestationOut string // Attestation output path
 )
 
 // queryCmd is the Cobra command definition for the query phase
@@ -41,23 +68,65 @@ var queryCmd = &cobra.Command{
 It is designed for local analysis, compliance verification, and lightweight data reduction.
 
 The command reads one or more NDJSON files generated by earlier AuditR phases (enrich, verify) and applies filters on structured metadata fields.
-It outputs matching events or summary counts in NDJSON format.
+It outputs matching events (NDJSON by default; JSON, CSV, table or Parquet with --format) or summary counts.
 
 Examples:
   # All PII-related events from last 7 days
   auditr query --input ./out/enriched_pg.ndjson --sensitivity PII --last 7d
 
+  # Events touching a custom category, or any category tagged HIPAA
+  auditr query --input ./out/enriched_pg.ndjson --sensitivity Credentials
+  auditr query --input ./out/enriched_pg.ndjson --regulation HIPAA
+
   # Filter all bulk operations by appuser3
   auditr query --input ./out/*.ndjson --user appuser3 --bulk
 
   # Filter only export operations
   auditr query --input ./out/*.ndjson --bulk-type export
 
+  # High and critical risk events, with risk by user and query type
+  auditr query --input ./out/enriched_pg.ndjson --min-risk high --summary
+
+  # Expressions over any field, including nested enrichment/meta keys
+  auditr query --input ./out/enriched_pg.ndjson \
+    --where '(sensitivity contains PHI and db_user = appuser1) or bulk_type = export'
+  auditr query --input ./out/enriched_pg.ndjson \
+    --where 'risk_level >= high and not db_user in (postgres, replicator) and timestamp >= @now-24h'
+
+  # September in Berlin time, and weekday 09:00-17:00 access per a holiday calendar
+  auditr query --input ./out/enriched_pg.ndjson --month 2025-09 --tz Europe/Berlin --summary
+  auditr query --input ./out/enriched_pg.ndjson --between 2025-09-01,2025-09-15 --off-hours \
+    --calendar cmd/auditr/config/business_calendar.json
+
+  # Off-hours access on the last business day (for the daily access review)
+  auditr query --input ./out/enriched_pg.ndjson --last-business-day --off-hours --calendar cmd/auditr/config/business_calendar.json
+
+  # Top 10 users reading PHI per day, and the fields each user touched
+  auditr query --input ./out/enriched_pg.ndjson --sensitivity PHI --type SELECT \
+    --group-by db_user --bucket 1d --top 10
+  auditr query --input ./out/enriched_pg.ndjson --group-by db_user,sensitivity.field \
+    --distinct client_ip --format csv --output fields_by_user.csv
+
+  # High-risk events as CSV with selected columns, or everything as Parquet
+  auditr query --input ./out/enriched_pg.ndjson --min-risk high --format csv \
+    --fields timestamp,db_user,risk_level,sensitivity --output high.csv
+  auditr query --input ./out/enriched_pg.ndjson --format parquet --output events.parquet
+
+  # Index months of hashed files once; later queries by time, user or category
+  # only read the matching blocks
+  auditr index build --input ./archive/*.ndjson
+  auditr query --input ./archive/*.ndjson --user appuser3 --month 2025-09
+
   # Filter events touching 'email' or 'card_last4' fields
   auditr query --input ./out/enriched_pg.ndjson --filter email,card_last4
 
   # Summary of PII and PHI queries
-  auditr query --input ./out/enriched_pg.ndjson --sensitivity PII,PHI --summary`,
+  auditr query --input ./out/enriched_pg.ndjson --sensitivity PII,PHI --summary
+
+  # PHI events from a hashed file, with a verification attestation written to
+  # phi.ndjson.attestation.json
+  auditr query --input ./out/hashed.ndjson --sensitivity PHI --output phi.ndjson \
+    --checkpoint-path ./checkpoints/checkpoint.json --public-key public.pem`,
 	RunE: runQuery, // Main execution function
 }
 
@@ -65,10 +134,12 @@ Examples:
 func init() {
 	// Input/Output flags
 	queryCmd.Flags().StringSliceVar(&queryFlagInput, "input", []string{}, "Input NDJSON file(s). Multiple files separated by space (shell glob expansion supported). Default: stdin")
-	queryCmd.Flags().StringVar(&queryFlagOutput, "output", "", "Output NDJSON file path. Default: stdout")
+	queryCmd.Flags().StringVar(&queryFlagOutput, "output", "", "Output file path. Default: stdout")
+	queryCmd.Flags().BoolVar(&queryFlagNoIndex, "no-index", false, "Scan every input file in full, ignoring indexes written by 'auditr index build'")
 
 	// Sensitivity filtering flags
-	queryCmd.Flags().StringSliceVar(&queryFlagSensitivity, "sensitivity", []string{}, "Filter by sensitivity categories (e.g., PII, PHI, Financial). Case-insensitive")
+	queryCmd.Flags().StringSliceVar(&queryFlagSensitivity, "sensitivity", []string{}, "Filter by sensitivity categories (e.g., PII, PHI, Financial, or custom dictionary categories). Case-insensitive")
+	queryCmd.Flags().StringSliceVar(&queryFlagRegulation, "regulation", []string{}, "Filter by regulation tags from dictionary category metadata (e.g., HIPAA, PCI-DSS). Case-insensitive")
 	queryCmd.Flags().StringSliceVar(&queryFlagFilter, "filter", []string{}, "Comma-separated field names to match in sensitivity array entries (e.g., email, ssn)")
 
 	// User and connection filtering flags
@@ -80,14 +151,46 @@ func init() {
 	queryCmd.Flags().BoolVar(&queryFlagBulk, "bulk", false, "Show only bulk operations (bulk == true)")
 	queryCmd.Flags().StringVar(&queryFlagBulkType, "bulk-type", "", "Filter by specific bulk operation type (export, import, backup, etc.)")
 
+	// Risk filtering flags
+	queryCmd.Flags().StringSliceVar(&queryFlagRisk, "risk", []string{}, "Filter by risk levels (low, medium, high, critical)")
+	queryCmd.Flags().StringVar(&queryFlagMinRisk, "min-risk", "", "Include events at or above a risk level (low < medium < high < critical)")
+
+	// Expression filtering flag
+	queryCmd.Flags().StringVar(&queryFlagWhere, "where", "", "Filter expression over any event field, e.g. 'risk_level >= high and (db_user in (a, b) or query ~ \"(?i)ssn\")'")
+
 	// Time-based filtering flags
-	queryCmd.Flags().StringVar(&queryFlagSince, "since", "", "Include events on or after the given time (ISO 8601 UTC)")
+	queryCmd.Flags().StringVar(&queryFlagSince, "since", "", "Include events on or after the given time (RFC3339, or a date/time without zone in --tz)")
 	queryCmd.Flags().StringVar(&queryFlagLast, "last", "", "Include events from the last N days/hours (e.g., 7d, 24h). Supports d and h units")
+	queryCmd.Flags().StringVar(&queryFlagUntil, "until", "", "Include events before the given time; a date on its own includes that day")
+	queryCmd.Flags().StringVar(&queryFlagBetween, "between", "", "Include events in a window \"start,end\" (e.g., 2025-09-01,2025-09-15); same formats as --since and --until")
+	queryCmd.Flags().StringVar(&queryFlagMonth, "month", "", "Include events in a calendar month (e.g., 2025-09)")
+	queryCmd.Flags().BoolVar(&queryFlagLastBusDay, "last-business-day", false, "Include events of the last business day before today (see --calendar)")
+	queryCmd.Flags().StringVar(&queryFlagTZ, "tz", "", "IANA time zone for times without a zone and for displayed times (e.g., Europe/Berlin). Default: UTC")
+
+	// Business calendar flags
+	queryCmd.Flags().StringVar(&queryFlagCalendar, "calendar", "", "Business calendar JSON (workdays, business hours, holidays). Default: Mon-Fri 09:00-17:00 in --tz")
+	queryCmd.Flags().BoolVar(&queryFlagBusinessHours, "business-hours", false, "Include only events within business hours")
+	queryCmd.Flags().BoolVar(&queryFlagOffHours, "off-hours", false, "Include only events outside business hours (nights, weekends, holidays)")
 
 	// Error handling and output flags
 	queryCmd.Flags().BoolVar(&queryFlagExcludeErrors, "exclude-errors", false, "Exclude events with query_type == \"ERROR\" from results")
 	queryCmd.Flags().BoolVar(&queryFlagSummary, "summary", false, "Print summary counts instead of full events")
 	queryCmd.Flags().IntVar(&queryFlagLimit, "limit", 0, "Limit number of output events")
+	queryCmd.Flags().StringVar(&queryFlagFormat, "format", "", "Output format: ndjson, json, csv, table or parquet. Default: ndjson, or table with --group-by")
+	queryCmd.Flags().StringSliceVar(&queryFlagFields, "fields", []string{}, "Columns to write, in order (e.g., timestamp,db_user,risk_level,sensitivity); nested fields as dotted paths. Default: every field")
+
+	// Group-by flags
+	queryCmd.Flags().StringSliceVar(&queryFlagGroupBy, "group-by", []string{}, "Group matched events by fields (e.g., db_user,client_ip,sensitivity.field) and write one row per group instead of events")
+	queryCmd.Flags().StringSliceVar(&queryFlagDistinct, "distinct", []string{}, "Count distinct values of fields per group (e.g., client_ip,sensitivity.field)")
+	queryCmd.Flags().StringVar(&queryFlagBucket, "bucket", "", "Group into time buckets (e.g., 1h, 1d); whole days start at midnight in --tz")
+	queryCmd.Flags().IntVar(&queryFlagTop, "top", 0, "Keep only the N largest groups (per bucket with --bucket)")
+
+	// Verification attestation flags
+	queryCmd.Flags().BoolVar(&queryFlagAttest, "attest", false, "Verify the input hash chain and write a verification attestation")
+	queryCmd.Flags().StringVar(&queryFlagCheckpoint, "checkpoint-path", "", "Signed checkpoint checked against the last input file (implies --attest)")
+	queryCmd.Flags().StringVar(&queryFlagPublicKey, "public-key", "", "Public key PEM or keyring used to check the checkpoint signature")
+	queryCmd.Flags().StringVar(&queryFlagTSACert, "tsa-cert", "", "TSA certificate (PEM) the checkpoint timestamp must verify against (default timestamping.tsa_cert)")
+	queryCmd.Flags().StringVar(&queryFlagAttestationOut, "attestation-output", "", "Attestation JSON path. Default: <output>.attestation.json, or stderr without --output")
 
 	// Add to root command
 	rootCmd.AddCommand(queryCmd)
@@ -97,11 +200,12 @@ func init() {
 // It parses CLI flags, validates input, and delegates to the query package.
 //
 // Processing steps:
-// 1. Parse comma-separated flags (--sensitivity, --type, --filter)
-// 2. Parse time filters (--since, --last) with validation
-// 3. Validate conflicting options (--since vs --last)
-// 4. Build QueryOptions struct
-// 5. Delegate to query.RunQuery()
+// 1. Parse comma-separated flags (--sensitivity, --type, --filter, --risk)
+// 2. Validate risk levels (--risk, --min-risk)
+// 3. Parse time filters (--since, --last) with validation
+// 4. Validate conflicting options (--since vs --last)
+// 5. Build QueryOptions struct
+// 6. Delegate to query.RunQuery()
 //
 // Error handling:
 // - Invalid time formats return descriptive errors
@@ -113,17 +217,64 @@ func runQuery(cmd *cobra.Command, args []string) error {
 	queryFlagSensitivity = parseCommaSeparated(queryFlagSensitivity)
 	queryFlagTypes = parseCommaSeparated(queryFlagTypes)
 	queryFlagFilter = parseCommaSeparated(queryFlagFilter)
+	queryFlagRegulation = parseCommaSeparated(queryFlagRegulation)
+	queryFlagRisk = parseCommaSeparated(queryFlagRisk)
+
+	// Risk levels must be known so that a typo doesn't silently match nothing
+	for _, level := range append(append([]string{}, queryFlagRisk...), queryFlagMinRisk) {
+		if level != "" && !enrich.ValidateRiskLevel(strings.ToLower(level)) {
+			return fmt.Errorf("invalid risk level %q, expected low, medium, high or critical", level)
+		}
+	}
+
+	// Compile --where up front so a syntax error is reported before any input is read
+	if queryFlagWhere != "" {
+		if _, err := query.CompileWhere(queryFlagWhere); err != nil {
+			return fmt.Errorf("invalid --where expression: %w", err)
+		}
+	}
+
+	// Parse --tz first: it decides how the times below are read
+	var loc *time.Location
+	var err error
+	if queryFlagTZ != "" {
+		if loc, err = time.LoadLocation(queryFlagTZ); err != nil {
+			return fmt.Errorf("invalid --tz, expected an IANA time zone (e.g., Europe/Berlin): %w", err)
+		}
+	}
+
+	// Load the business calendar; without --calendar it is Mon-Fri 09:00-17:00 in --tz
+	var calendar *query.Calendar
+	if queryFlagCalendar != "" {
+		if calendar, err = query.LoadCalendar(queryFlagCalendar, loc); err != nil {
+			return err
+		}
+	} else if queryFlagLastBusDay || queryFlagBusinessHours || queryFlagOffHours {
+		if calendar, err = query.NewCalendar(nil, loc); err != nil {
+			return err
+		}
+	}
+	if queryFlagBusinessHours && queryFlagOffHours {
+		return fmt.Errorf("cannot specify both --business-hours and --off-hours")
+	}
 
 	// Parse time filters with proper validation
-	var since time.Time
+	var since, from, until time.Time
 	var lastDuration time.Duration
-	var err error
 
 	// Parse --since flag (absolute time)
 	if queryFlagSince != "" {
-		since, err = time.Parse(time.RFC3339, queryFlagSince)
+		since, err = query.ParseTimeIn(queryFlagSince, loc)
 		if err != nil {
-			return fmt.Errorf("invalid --since format, expected RFC3339 (e.g., 2025-10-01T00:00:00Z): %w", err)
+			return fmt.Errorf("invalid --since format: %w", err)
+		}
+	}
+
+	// Parse --until flag (exclusive end)
+	if queryFlagUntil != "" {
+		until, err = query.ParseUntil(queryFlagUntil, loc)
+		if err != nil {
+			return fmt.Errorf("invalid --until format: %w", err)
 		}
 	}
 
@@ -141,22 +292,127 @@ func runQuery(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("cannot specify both --since and --last; --last takes precedence")
 	}
 
+	// Closed windows replace --since/--until/--last, so only one may be given
+	windows := 0
+	for _, set := range []bool{queryFlagSince != "" || queryFlagUntil != "" || queryFlagLast != "", queryFlagBetween != "", queryFlagMonth != "", queryFlagLastBusDay} {
+		if set {
+			windows++
+		}
+	}
+	if windows > 1 {
+		return fmt.Errorf("--between, --month and --last-business-day cannot be combined with each other or with --since, --until or --last")
+	}
+	if queryFlagUntil != "" && lastDuration > 0 {
+		return fmt.Errorf("cannot specify both --until and --last")
+	}
+
+	// Parse --between flag ("start,end")
+	if queryFlagBetween != "" {
+		parts := strings.Split(queryFlagBetween, ",")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid --between format, expected \"start,end\" (e.g., 2025-09-01,2025-09-15)")
+		}
+		if from, err = query.ParseTimeIn(parts[0], loc); err != nil {
+			return fmt.Errorf("invalid --between start: %w", err)
+		}
+		if until, err = query.ParseUntil(parts[1], loc); err != nil {
+			return fmt.Errorf("invalid --between end: %w", err)
+		}
+		if !from.Before(until) {
+			return fmt.Errorf("invalid --between window: start %s is not before end %s", parts[0], parts[1])
+		}
+	}
+
+	// Parse --month flag (calendar month in --tz)
+	if queryFlagMonth != "" {
+		if from, until, err = query.ParseMonth(queryFlagMonth, loc); err != nil {
+			return fmt.Errorf("invalid --month format: %w", err)
+		}
+	}
+
+	// --last-business-day is midnight to midnight in the calendar's zone
+	if queryFlagLastBusDay {
+		from, until = calendar.LastBusinessDay(time.Now())
+	}
+
+	// Output format and columns
+	queryFlagFields = parseCommaSeparated(queryFlagFields)
+	if queryFlagFormat != "" && !output.ValidFormat(queryFlagFormat) {
+		return fmt.Errorf("invalid --format %q, expected %s", queryFlagFormat, strings.Join(output.Formats, ", "))
+	}
+
+	// Group-by flags
+	queryFlagGroupBy = parseCommaSeparated(queryFlagGroupBy)
+	queryFlagDistinct = parseCommaSeparated(queryFlagDistinct)
+	var bucket time.Duration
+	if len(queryFlagGroupBy) == 0 {
+		if len(queryFlagDistinct) > 0 || queryFlagBucket != "" || queryFlagTop != 0 {
+			return fmt.Errorf("--distinct, --bucket and --top require --group-by")
+		}
+	} else {
+		if queryFlagBucket != "" {
+			if bucket, err = query.ParseDuration(queryFlagBucket); err != nil || bucket <= 0 {
+				return fmt.Errorf("invalid --bucket, expected a duration like 1h or 1d")
+			}
+		}
+		if queryFlagTop < 0 {
+			return fmt.Errorf("invalid --top %d, expected a positive number", queryFlagTop)
+		}
+		if len(queryFlagFields) > 0 {
+			return fmt.Errorf("--fields cannot be combined with --group-by; groups have fixed columns")
+		}
+	}
+
+	// Attestation flags only make sense together
+	if queryFlagPublicKey != "" && queryFlagCheckpoint == "" {
+		return fmt.Errorf("--public-key requires --checkpoint-path")
+	}
+	if queryFlagTSACert != "" && queryFlagCheckpoint == "" {
+		return fmt.Errorf("--tsa-cert requires --checkpoint-path")
+	}
+	if queryFlagAttestationOut != "" && !queryFlagAttest && queryFlagCheckpoint == "" {
+		return fmt.Errorf("--attestation-output requires --attest or --checkpoint-path")
+	}
+
 	// Build QueryOptions struct from parsed flags
 	opts := query.QueryOptions{
 		InputFiles:    queryFlagInput,
 		OutputFile:    queryFlagOutput,
 		Sensitivity:   queryFlagSensitivity,
+		Regulations:   queryFlagRegulation,
 		User:          queryFlagUser,
 		IP:            queryFlagIP,
 		Types:         queryFlagTypes,
 		Bulk:          queryFlagBulk,
 		BulkType:      queryFlagBulkType,
+		RiskLevels:    queryFlagRisk,
+		MinRisk:       queryFlagMinRisk,
+		Where:         queryFlagWhere,
 		FilterFields:  queryFlagFilter,
 		Since:         since,
 		LastDuration:  lastDuration,
+		From:          from,
+		Until:         until,
+		Location:      loc,
+		Calendar:      calendar,
+		BusinessHours: queryFlagBusinessHours,
+		OffHours:      queryFlagOffHours,
 		ExcludeErrors: queryFlagExcludeErrors,
 		Summary:       queryFlagSummary,
 		Limit:         queryFlagLimit,
+		Format:        queryFlagFormat,
+		Fields:        queryFlagFields,
+		NoIndex:       queryFlagNoIndex,
+		GroupBy:       queryFlagGroupBy,
+		Distinct:      queryFlagDistinct,
+		Bucket:        bucket,
+		Top:           queryFlagTop,
+
+		Attest:          queryFlagAttest,
+		CheckpointPath:  queryFlagCheckpoint,
+		PublicKeyPath:   queryFlagPublicKey,
+		TSACertPath:     tsaCertFor(queryFlagTSACert, queryFlagCheckpoint),
+		AttestationFile: queryFlagAttestationOut,
 	}
 
 	// Delegate to the query package for actual processing
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/enrich"
+	"github.com/vaibhaw-/AuditR/internal/auditr/query"
+	"github.com/vaibhaw-/AuditR/internal/auditr/report"
+)
+
+// CLI flag variables for the report command
+var (
+	reportFlagInput     []string // Input NDJSON file(s) - supports multiple files
+	reportFlagOutput    string   // Output file path - empty means stdout
+	reportFlagFramework string   // Framework key from the mapping file (hipaa, pci-dss, ...)
+	reportFlagMapping   string   // Compliance mapping JSON file
+	reportFlagSince     string   // Window start (RFC3339)
+	reportFlagUntil     string   // Window end, exclusive (RFC3339)
+	reportFlagLast      string   // Relative window (7d, 24h)
+	reportFlagMaxRows   int      // Evidence rows listed per control/section
+
+	reportFlagFormat        string // Output format: text, markdown or html
+	reportFlagTemplate      string // Custom Go template file
+	reportFlagPrintTemplate bool   // Print the built-in template for --format and exit
+	reportFlagTitle         string // Report title
+	reportFlagBucket        string // Risk timeline bucket: hour, day or week
+	reportFlagTop           int    // Users listed in the top users table
+	reportFlagCheckpoint    string // Signed checkpoint JSON for the checkpoint evidence section
+	reportFlagPublicKey     string // Public key to check the checkpoint signature
+	reportFlagTSACert       string // Trusted TSA certificate for the checkpoint timestamp
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Generate audit and compliance reports from enriched or hashed audit logs",
+	Long: `Report summarizes enriched or hashed audit events for a time window as a text,
+Markdown or self-contained HTML document. The report contains:
+
+  - an executive summary (event counts, sensitive and high-risk activity)
+  - risk distribution over time (--bucket hour|day|week)
+  - top users by sensitive access
+  - bulk exports and privilege escalations
+  - hash chain verification status of each input file
+  - checkpoint evidence (--checkpoint-path, signature checked with --public-key)
+
+With --framework and --mapping the report also groups events by the controls of a
+compliance framework (HIPAA, PCI-DSS, GDPR, SOX, ...) with per-control evidence
+tables. The mapping file defines each framework's controls in terms of sensitivity
+categories, query types and privilege escalation types. Events that carry
+compliance_controls (enrich --compliance) are reported under those controls; other
+events are mapped from their sensitivity categories and query type.
+
+Reports are rendered with Go templates. Use --print-template to get the built-in
+template for a format and --template to render with a customized copy; HTML
+templates are escaped with html/template.
+
+Examples:
+  # HTML report for the last 30 days, with checkpoint evidence
+  auditr report --input ./out/hashed.ndjson --last 30d --format html --output report.html \
+    --checkpoint-path ./out/checkpoint.json --public-key ./keys/public.pem
+
+  # HIPAA evidence for the last 30 days
+  auditr report --framework hipaa --mapping cmd/auditr/config/compliance_mapping.json \
+    --input ./out/enriched_pg.ndjson --last 30d
+
+  # Customize the Markdown report
+  auditr report --format markdown --print-template > my_report.md.tmpl
+  auditr report --format markdown --template my_report.md.tmpl --input hashed.ndjson
+
+  # PCI-DSS evidence for September 2025
+  auditr report --framework pci-dss --mapping compliance_mapping.json --input hashed.ndjson \
+    --since 2025-09-01T00:00:00Z --until 2025-10-01T00:00:00Z`,
+	RunE: runReport,
+}
+
+func init() {
+	reportCmd.Flags().StringSliceVar(&reportFlagInput, "input", []string{}, "Input NDJSON file(s). Default: stdin")
+	reportCmd.Flags().StringVar(&reportFlagOutput, "output", "", "Output report file path. Default: stdout")
+	reportCmd.Flags().StringVar(&reportFlagFramework, "framework", "", "Framework key from the mapping file (e.g., hipaa, pci-dss, gdpr, sox)")
+	reportCmd.Flags().StringVar(&reportFlagMapping, "mapping", "", "Compliance framework mapping JSON file")
+	reportCmd.Flags().StringVar(&reportFlagSince, "since", "", "Include events on or after the given time (RFC3339)")
+	reportCmd.Flags().StringVar(&reportFlagUntil, "until", "", "Include events before the given time (RFC3339)")
+	reportCmd.Flags().StringVar(&reportFlagLast, "last", "", "Include events from the last N days/hours (e.g., 30d, 24h)")
+	reportCmd.Flags().IntVar(&reportFlagMaxRows, "max-rows", 50, "Rows listed per evidence table (0 = all)")
+	reportCmd.Flags().StringVar(&reportFlagFormat, "format", report.FormatText, "Output format: text, markdown or html")
+	reportCmd.Flags().StringVar(&reportFlagTemplate, "template", "", "Custom Go template file used instead of the built-in one")
+	reportCmd.Flags().BoolVar(&reportFlagPrintTemplate, "print-template", false, "Print the built-in template for --format and exit")
+	reportCmd.Flags().StringVar(&reportFlagTitle, "title", "AuditR audit report", "Report title")
+	reportCmd.Flags().StringVar(&reportFlagBucket, "bucket", report.BucketDay, "Risk timeline bucket: hour, day or week")
+	reportCmd.Flags().IntVar(&reportFlagTop, "top", 10, "Users listed in the top users table (0 = all)")
+	reportCmd.Flags().StringVar(&reportFlagCheckpoint, "checkpoint-path", "", "Signed checkpoint JSON to include as evidence")
+	reportCmd.Flags().StringVar(&reportFlagPublicKey, "public-key", "", "Public key PEM or keyring used to check the checkpoint signature")
+	reportCmd.Flags().StringVar(&reportFlagTSACert, "tsa-cert", "", "TSA certificate (PEM) the checkpoint timestamp must verify against (default timestamping.tsa_cert)")
+
+	rootCmd.AddCommand(reportCmd)
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	if reportFlagPrintTemplate {
+		src, err := report.BuiltinTemplate(reportFlagFormat)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(os.Stdout, src)
+		return err
+	}
+
+	since, until, err := parseReportWindow(reportFlagSince, reportFlagUntil, reportFlagLast, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	opts := report.AuditOptions{
+		InputFiles:     reportFlagInput,
+		Title:          reportFlagTitle,
+		Since:          since,
+		Until:          until,
+		Bucket:         reportFlagBucket,
+		TopUsers:       reportFlagTop,
+		MaxRows:        reportFlagMaxRows,
+		Framework:      reportFlagFramework,
+		CheckpointPath: reportFlagCheckpoint,
+		PublicKeyPath:  reportFlagPublicKey,
+		TSACertPath:    tsaCertFor(reportFlagTSACert, reportFlagCheckpoint),
+	}
+	if reportFlagFramework != "" && reportFlagMapping == "" {
+		return fmt.Errorf("--framework requires --mapping")
+	}
+	if reportFlagMapping != "" {
+		if reportFlagFramework == "" {
+			return fmt.Errorf("--mapping requires --framework")
+		}
+		opts.Mapping, err = enrich.LoadCompliance(reportFlagMapping)
+		if err != nil {
+			return fmt.Errorf("failed to load compliance mapping: %w", err)
+		}
+	}
+	if reportFlagPublicKey != "" && reportFlagCheckpoint == "" {
+		return fmt.Errorf("--public-key requires --checkpoint-path")
+	}
+	if reportFlagTSACert != "" && reportFlagCheckpoint == "" {
+		return fmt.Errorf("--tsa-cert requires --checkpoint-path")
+	}
+
+	rep, err := report.BuildAuditReport(opts)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if reportFlagOutput != "" {
+		f, err := os.Create(reportFlagOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create output file %s: %w", reportFlagOutput, err)
+		}
+		defer f.Close()
+		out = f
+	}
+	return rep.Render(out, reportFlagFormat, reportFlagTemplate)
+}
+
+// parseReportWindow turns --since/--until/--last into a [since, until) window.
+// --last is relative to now and can't be combined with --since.
+func parseReportWindow(sinceFlag, untilFlag, lastFlag string, now time.Time) (time.Time, time.Time, error) {
+	var since, until time.Time
+	var err error
+
+	if sinceFlag != "" {
+		since, err = time.Parse(time.RFC3339, sinceFlag)
+		if err != nil {
+			return since, until, fmt.Errorf("invalid --since format, expected RFC3339 (e.g., 2025-10-01T00:00:00Z): %w", err)
+		}
+	}
+	if untilFlag != "" {
+		until, err = time.Parse(time.RFC3339, untilFlag)
+		if err != nil {
+			return since, until, fmt.Errorf("invalid --until format, expected RFC3339 (e.g., 2025-10-01T00:00:00Z): %w", err)
+		}
+	}
+	if lastFlag != "" {
+		if sinceFlag != "" {
+			return since, until, fmt.Errorf("cannot specify both --since and --last")
+		}
+		d, err := query.ParseDuration(lastFlag)
+		if err != nil {
+			return since, until, fmt.Errorf("invalid --last format, expected duration like 7d or 24h: %w", err)
+		}
+		since = now.Add(-d)
+	}
+	return since, until, nil
+}
@@ -22,7 +22,10 @@ var (
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			// Skip config loading for commands that don't need it
 			cmdName := cmd.Name()
-			if cmdName == "version" || cmdName == "dict" || cmdName == "validate" || cmdName == "query" || cmdName == "help" {
+			if cmdName == "version" || cmdName == "dict" || cmdName == "validate" || cmdName == "query" || cmdName == "report" || cmdName == "help" || cmdName == "check-proof" {
+				return nil
+			}
+			if cmd.HasParent() && (cmd.Parent().Name() == "keys" || cmd.Parent().Name() == "tsa" || cmd.Parent().Name() == "index") {
 				return nil
 			}
 
@@ -4,19 +4,26 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/vaibhaw-/AuditR/internal/auditr/archive"
 	"github.com/vaibhaw-/AuditR/internal/auditr/config"
 	"github.com/vaibhaw-/AuditR/internal/auditr/verify"
 )
 
 var (
-	verifyFlagInput        string
-	verifyFlagOutput       string
-	verifyFlagCheckpoint   bool
-	verifyFlagPrivateKey   string
-	verifyFlagPublicKey    string
-	verifyFlagSummaryOnly  bool
-	verifyFlagDetailed     bool
-	verifyFlagCheckpointIn string
+	verifyFlagInput         string
+	verifyFlagOutput        string
+	verifyFlagCheckpoint    bool
+	verifyFlagPrivateKey    string
+	verifyFlagPublicKey     string
+	verifyFlagSummaryOnly   bool
+	verifyFlagDetailed      bool
+	verifyFlagCheckpointIn  string
+	verifyFlagManifest      string
+	verifyFlagCheckpointDir string
+	verifyFlagTSACert       string
+	verifyFlagMerkleBatch   int
+	verifyFlagStream        string
+	verifyFlagArchive       string
 )
 
 var verifyCmd = &cobra.Command{
@@ -28,11 +35,32 @@ Hash Mode (--output provided):
   Computes hash chains for events and optionally creates checkpoints
   Requires: --input, --output
   Optional: --checkpoint, --private-key
+  With --merkle-batch N (or hashing.merkle_batch_size) every N events form a
+  Merkle tree whose root is chained, so single events can be proven with
+  "auditr verify prove".
+  Checkpoints follow hashing.checkpoint_interval: file_end, every N events
+  ("10000"), every T duration ("15m"), or both ("10000,15m"). Every checkpoint
+  is listed in <checkpoint_dir>/manifest.json.
+  With --stream NAME (or hashing.stream_by fields) events are hashed onto
+  independent named chains, each with its own state and checkpoints under
+  <checkpoint_dir>/streams/.
 
 Verify Mode (no --output):
-  Verifies existing hash chains and optionally validates checkpoints  
+  Verifies existing hash chains and optionally validates checkpoints
   Requires: --input
-  Optional: --checkpoint-path, --public-key
+  Optional: --checkpoint-path, --manifest or --checkpoint-dir, --public-key
+  With --manifest or --checkpoint-dir every checkpoint is checked against the
+  recomputed chain head at its index, and the last good checkpoint before the
+  first divergence is reported.
+  With --tsa-cert every checked checkpoint must carry an RFC 3161 timestamp token
+  from that TSA (written when timestamping.url is configured in hash mode).
+  With --stream NAME every event must belong to that named chain; events of
+  other streams are reported as spliced.
+
+Archive Mode (--archive DIR):
+  Verifies an archive written by "auditr archive" end to end: segment
+  continuity, every segment's events, digest and Merkle root against
+  archive.json, and the segment checkpoints (signatures with --public-key).
 
 Examples:
   # Hash mode: compute hash chains
@@ -42,8 +70,25 @@ Examples:
   auditr verify --input hashed.jsonl
   
   # Verify mode: verify with checkpoint
-  auditr verify --input hashed.jsonl --checkpoint-path checkpoint.json --public-key pub.pem`,
+  auditr verify --input hashed.jsonl --checkpoint-path checkpoint.json --public-key pub.pem
+
+  # Verify mode: verify against every checkpoint in the file's chain index range
+  auditr verify --input hashed.jsonl --manifest ./checkpoints/manifest.json --public-key pub.pem
+
+  # Verify mode: check every signed checkpoint in a directory, reporting the
+  # last good checkpoint before the first divergence
+  auditr verify --input hashed.jsonl --checkpoint-dir ./checkpoints --public-key pub.pem
+
+  # Verify mode: also require trusted TSA timestamps on the checkpoints
+  auditr verify --input hashed.jsonl --checkpoint-dir ./checkpoints --public-key pub.pem --tsa-cert tsa.pem
+
+  # Archive mode: verify a whole archive
+  auditr verify --archive ./archive --public-key pub.pem`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if verifyFlagArchive != "" {
+			return runVerifyArchive()
+		}
+
 		// Validate required arguments
 		if verifyFlagInput == "" {
 			return fmt.Errorf("--input is required")
@@ -59,15 +104,26 @@ Examples:
 		}
 
 		cfg := config.Get()
+		// timestamping.tsa_cert applies when checkpoints are being verified
+		tsaCert := verifyFlagTSACert
+		checkpointsGiven := verifyFlagCheckpointIn != "" || verifyFlagManifest != "" || verifyFlagCheckpointDir != ""
+		if tsaCert == "" && verifyFlagOutput == "" && checkpointsGiven && cfg != nil {
+			tsaCert = cfg.Timestamping.TSACert
+		}
 		argsV := verify.VerifyArgs{
-			InputFile:      verifyFlagInput,
-			OutputFile:     verifyFlagOutput,
-			Checkpoint:     verifyFlagCheckpoint,
-			PrivateKeyPath: verifyFlagPrivateKey,
-			PublicKeyPath:  verifyFlagPublicKey,
-			SummaryOnly:    verifyFlagSummaryOnly,
-			Detailed:       verifyFlagDetailed,
-			CheckpointPath: verifyFlagCheckpointIn,
+			InputFile:       verifyFlagInput,
+			OutputFile:      verifyFlagOutput,
+			Checkpoint:      verifyFlagCheckpoint,
+			PrivateKeyPath:  verifyFlagPrivateKey,
+			PublicKeyPath:   verifyFlagPublicKey,
+			SummaryOnly:     verifyFlagSummaryOnly,
+			Detailed:        verifyFlagDetailed,
+			CheckpointPath:  verifyFlagCheckpointIn,
+			ManifestPath:    verifyFlagManifest,
+			CheckpointDir:   verifyFlagCheckpointDir,
+			TSACertPath:     tsaCert,
+			MerkleBatchSize: verifyFlagMerkleBatch,
+			Stream:          verifyFlagStream,
 		}
 		return verify.RunVerifyPhase(cfg, argsV)
 	},
@@ -76,12 +132,18 @@ Examples:
 func init() {
 	verifyCmd.Flags().StringVar(&verifyFlagInput, "input", "", "input NDJSON file (default stdin)")
 	verifyCmd.Flags().StringVar(&verifyFlagOutput, "output", "", "output NDJSON file (default stdout; only in hash mode)")
-	verifyCmd.Flags().BoolVar(&verifyFlagCheckpoint, "checkpoint", false, "write checkpoint JSON at end of run (hash mode)")
+	verifyCmd.Flags().BoolVar(&verifyFlagCheckpoint, "checkpoint", false, "write checkpoint JSON at end of run, in addition to hashing.checkpoint_interval (hash mode)")
 	verifyCmd.Flags().StringVar(&verifyFlagPrivateKey, "private-key", "", "private key PEM path for signing checkpoint (hash mode)")
-	verifyCmd.Flags().StringVar(&verifyFlagPublicKey, "public-key", "", "public key PEM path for verifying checkpoint (verify mode)")
+	verifyCmd.Flags().StringVar(&verifyFlagPublicKey, "public-key", "", "public key PEM or keyring (auditr keys) for verifying checkpoints (verify mode)")
 	verifyCmd.Flags().BoolVar(&verifyFlagSummaryOnly, "summary", false, "print summary only")
 	verifyCmd.Flags().BoolVar(&verifyFlagDetailed, "detailed", false, "include per-event details where applicable")
 	verifyCmd.Flags().StringVar(&verifyFlagCheckpointIn, "checkpoint-path", "", "checkpoint file to verify (verify mode)")
+	verifyCmd.Flags().StringVar(&verifyFlagManifest, "manifest", "", "checkpoint manifest; verifies every checkpoint in the input's chain index range (verify mode)")
+	verifyCmd.Flags().StringVar(&verifyFlagCheckpointDir, "checkpoint-dir", "", "checkpoint directory; verifies every signed checkpoint in it and reports the last good one before a divergence (verify mode)")
+	verifyCmd.Flags().IntVar(&verifyFlagMerkleBatch, "merkle-batch", 0, "events per Merkle tree; hashes in Merkle mode (hash mode; default hashing.merkle_batch_size)")
+	verifyCmd.Flags().StringVar(&verifyFlagStream, "stream", "", "named chain: hash every event onto it (hash mode; overrides hashing.stream_by) or require every event to belong to it (verify mode)")
+	verifyCmd.Flags().StringVar(&verifyFlagArchive, "archive", "", "archive directory (auditr archive) to verify end to end instead of --input")
+	verifyCmd.Flags().StringVar(&verifyFlagTSACert, "tsa-cert", "", "TSA certificate (PEM); checked checkpoints must carry a timestamp token it issued (verify mode; default timestamping.tsa_cert)")
 
 	// add to root in root.go's init
 	if rootCmd == nil {
@@ -89,3 +151,26 @@ func init() {
 		fmt.Println("warning: rootCmd not initialized")
 	}
 }
+
+// runVerifyArchive verifies an archive directory (verify --archive)
+func runVerifyArchive() error {
+	if verifyFlagInput != "" || verifyFlagOutput != "" {
+		return fmt.Errorf("--archive can't be combined with --input or --output")
+	}
+	r, err := archive.Verify(verifyFlagArchive, verifyFlagPublicKey)
+	if err != nil {
+		return err
+	}
+	status := "pass"
+	if !r.OK() {
+		status = "fail"
+	}
+	fmt.Printf("verify archive: %s (segments=%d sealed=%d open=%d purged=%d events=%d checkpoints=%d issues=%d)\n",
+		status, r.Segments, r.Sealed, r.Open, r.Purged, r.Events, r.Checkpoints, len(r.Issues))
+	if verifyFlagDetailed || !r.OK() {
+		for _, issue := range r.Issues {
+			fmt.Printf("  %s\n", issue)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+	"github.com/vaibhaw-/AuditR/internal/auditr/verify"
+)
+
+// hashRun hashes n events continuing state and writes them to a file
+func hashRun(t *testing.T, dir, name string, state *verify.ChainState, n int, extra string) (string, *verify.ChainState) {
+	t.Helper()
+	var in strings.Builder
+	start := 0
+	if state != nil {
+		start = state.LastChainIndex
+	}
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&in, `{"event_id":"e%d","timestamp":"2024-01-01T00:00:00Z"%s}`+"\n", start+i, extra)
+	}
+	var out bytes.Buffer
+	st, _, err := verify.ComputeChain(strings.NewReader(in.String()), &out, state)
+	if err != nil {
+		t.Fatalf("compute chain: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+	return path, st
+}
+
+// testSigner generates a signing key and returns it with its public key path
+func testSigner(t *testing.T, dir string) (verify.Signer, string) {
+	t.Helper()
+	key, err := verify.GenerateKey(verify.AlgEd25519)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	priv, pub, err := verify.WriteKeyPair(dir, "test", key)
+	if err != nil {
+		t.Fatalf("write key pair: %v", err)
+	}
+	signer, err := verify.LoadFileSigner(priv)
+	if err != nil {
+		t.Fatalf("load signer: %v", err)
+	}
+	return signer, pub
+}
+
+func TestStore_AppendRotateVerify(t *testing.T) {
+	dir := t.TempDir()
+	signer, pub := testSigner(t, dir)
+	arch := filepath.Join(dir, "archive")
+
+	in1, st := hashRun(t, dir, "run1.jsonl", nil, 5, "")
+	in2, _ := hashRun(t, dir, "run2.jsonl", st, 6, "")
+
+	s, err := Open(arch, Options{MaxEvents: 4, Signer: signer})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := s.Append(in1); err != nil {
+		t.Fatalf("append run1: %v", err)
+	}
+	res, err := s.Append(in2)
+	if err != nil {
+		t.Fatalf("append run2: %v", err)
+	}
+	if res.Events != 6 || len(res.Sealed) != 1 {
+		t.Fatalf("append run2: got %d events, %d sealed; want 6, 1", res.Events, len(res.Sealed))
+	}
+
+	// Appending the same run again doesn't continue the archive
+	if _, err := s.Append(in2); err == nil {
+		t.Fatalf("expected re-append to be rejected")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	m, err := LoadManifest(arch)
+	if err != nil {
+		t.Fatalf("load manifest: %v", err)
+	}
+	if len(m.Segments) != 3 {
+		t.Fatalf("segments: got %d want 3", len(m.Segments))
+	}
+	for i, want := range [][2]int{{1, 4}, {5, 8}, {9, 11}} {
+		seg := m.Segments[i]
+		if seg.FirstIndex != want[0] || seg.LastIndex != want[1] {
+			t.Errorf("segment %d: covers %d-%d want %d-%d", seg.ID, seg.FirstIndex, seg.LastIndex, want[0], want[1])
+		}
+	}
+	if m.Segments[1].PrevHash != m.Segments[0].HeadHash || m.Segments[2].State != StateOpen || m.Segments[0].Checkpoint == "" {
+		t.Fatalf("unexpected segments: %+v %+v %+v", m.Segments[0], m.Segments[1], m.Segments[2])
+	}
+
+	r, err := Verify(arch, pub)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !r.OK() || r.Events != 11 || r.Checkpoints != 2 {
+		t.Fatalf("verify: %+v", r)
+	}
+}
+
+func TestVerify_DetectsTamperedSegment(t *testing.T) {
+	dir := t.TempDir()
+	arch := filepath.Join(dir, "archive")
+	in, _ := hashRun(t, dir, "run.jsonl", nil, 6, "")
+
+	s, err := Open(arch, Options{MaxEvents: 3})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := s.Append(in); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	s.Close()
+
+	// Rewrite an event of the first sealed segment
+	path := filepath.Join(arch, SegmentsDir, "segment-000001.ndjson")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read segment: %v", err)
+	}
+	os.Chmod(path, 0644)
+	if err := os.WriteFile(path, bytes.Replace(data, []byte(`"e2"`), []byte(`"x2"`), 1), 0644); err != nil {
+		t.Fatalf("write segment: %v", err)
+	}
+
+	r, err := Verify(arch, "")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if r.OK() {
+		t.Fatalf("expected tampered segment to fail verification")
+	}
+	for _, issue := range r.Issues {
+		if issue.Segment != 1 {
+			t.Errorf("unexpected issue: %s", issue)
+		}
+	}
+}
+
+func TestStore_PurgeKeepsChainVerifiable(t *testing.T) {
+	dir := t.TempDir()
+	arch := filepath.Join(dir, "archive")
+	in1, st := hashRun(t, dir, "run1.jsonl", nil, 3, "")
+	in2, _ := hashRun(t, dir, "run2.jsonl", st, 3, `,"retention_classes":["PII"]`)
+
+	policy, err := NewPolicy(config.RetentionCfg{Default: "30d", Classes: map[string]string{"pii": "forever"}})
+	if err != nil {
+		t.Fatalf("policy: %v", err)
+	}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s, err := Open(arch, Options{MaxEvents: 3, Retention: policy, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+	for _, in := range []string{in1, in2} {
+		if _, err := s.Append(in); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	purged, err := s.Purge(false)
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if len(purged) != 1 || purged[0].ID != 1 {
+		t.Fatalf("purged: got %d segments, want segment 1", len(purged))
+	}
+	if _, err := os.Stat(filepath.Join(arch, purged[0].File)); !os.IsNotExist(err) {
+		t.Fatalf("purged segment file still present")
+	}
+	if seg := s.Manifest().Segments[1]; seg.RetainUntil != nil || seg.Classes[0] != "class:pii" {
+		t.Fatalf("segment 2: retain until %v, classes %v; want forever, class:pii", seg.RetainUntil, seg.Classes)
+	}
+
+	r, err := Verify(arch, "")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !r.OK() || r.Purged != 1 || r.Events != 3 {
+		t.Fatalf("verify after purge: %+v", r)
+	}
+}
+
+func TestOpen_RollsBackInterruptedAppend(t *testing.T) {
+	dir := t.TempDir()
+	arch := filepath.Join(dir, "archive")
+	in, _ := hashRun(t, dir, "run.jsonl", nil, 2, "")
+
+	s, err := Open(arch, Options{})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := s.Append(in); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	// The archive is locked while open
+	if _, err := Open(arch, Options{}); err == nil {
+		t.Fatalf("expected second open to fail while locked")
+	}
+	s.Close()
+
+	// Simulate an append that wrote events but never saved archive.json
+	path := filepath.Join(arch, SegmentsDir, "segment-000001.ndjson")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("open segment: %v", err)
+	}
+	f.WriteString(`{"event_id":"partial"`)
+	f.Close()
+	stray := filepath.Join(arch, SegmentsDir, "segment-000002.ndjson")
+	if err := os.WriteFile(stray, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("write stray segment: %v", err)
+	}
+
+	s, err = Open(arch, Options{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	s.Close()
+	if _, err := os.Stat(stray); !os.IsNotExist(err) {
+		t.Fatalf("stray segment not removed")
+	}
+	r, err := Verify(arch, "")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !r.OK() {
+		t.Fatalf("verify after rollback: %v", r.Issues)
+	}
+}
+
+func TestStore_FailedAppendLeavesNoSeal(t *testing.T) {
+	dir := t.TempDir()
+	signer, pub := testSigner(t, dir)
+	arch := filepath.Join(dir, "archive")
+	in1, st := hashRun(t, dir, "run1.jsonl", nil, 3, "")
+	in2, _ := hashRun(t, dir, "run2.jsonl", st, 6, "")
+
+	s, err := Open(arch, Options{MaxEvents: 4, Signer: signer})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := s.Append(in1); err != nil {
+		t.Fatalf("append run1: %v", err)
+	}
+
+	// archive.json can't be replaced: the append that would seal two segments fails
+	blocker := filepath.Join(arch, ManifestFile+".tmp")
+	if err := os.Mkdir(blocker, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if _, err := s.Append(in2); err == nil {
+		t.Fatalf("expected append to fail when archive.json can't be saved")
+	}
+	s.Close()
+	os.Remove(blocker)
+
+	// No segment was left read-only and no checkpoint written
+	if cps, _ := filepath.Glob(filepath.Join(arch, CheckpointsDir, "checkpoint-*.json")); len(cps) != 0 {
+		t.Fatalf("checkpoints left by a failed append: %v", cps)
+	}
+	if m, _ := verify.LoadManifest(filepath.Join(arch, CheckpointsDir, verify.ManifestFile)); len(m.Checkpoints) != 0 {
+		t.Fatalf("checkpoint manifest entries left by a failed append: %+v", m.Checkpoints)
+	}
+	files, _ := segmentFiles(arch)
+	for _, rel := range files {
+		if fi, err := os.Stat(filepath.Join(arch, rel)); err == nil && fi.Mode().Perm()&0200 == 0 {
+			t.Fatalf("segment %s left read-only", rel)
+		}
+	}
+
+	// The archive rolls back to run1 and verifies; run1 can be continued
+	s, err = Open(arch, Options{MaxEvents: 4, Signer: signer})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if r, err := Verify(arch, pub); err != nil || !r.OK() || r.Events != 3 {
+		t.Fatalf("verify after failed append: %+v, %v", r, err)
+	}
+	res, err := s.Append(in2)
+	if err != nil || len(res.Sealed) != 2 {
+		t.Fatalf("append run2 again: %+v, %v", res, err)
+	}
+	s.Close()
+	if r, err := Verify(arch, pub); err != nil || !r.OK() || r.Events != 9 || r.Checkpoints != 2 {
+		t.Fatalf("verify: %+v, %v", r, err)
+	}
+}
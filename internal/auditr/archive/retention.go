@@ -0,0 +1,149 @@
+package archive
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+	"github.com/vaibhaw-/AuditR/internal/auditr/event"
+)
+
+// Forever is the retention value that never expires
+const Forever = "forever"
+
+// DefaultClass labels segments holding events without a configured class
+const DefaultClass = "default"
+
+// Policy decides how long archived events are kept.
+//
+// Enriched events carry retention_classes and regulations (from the sensitivity
+// dictionary metadata). An event is kept for the longest retention of its
+// configured classes and regulations, or the default when none is configured.
+// A segment is kept until its last event to expire does (see Segment.RetainUntil).
+//
+// Fields:
+//   - Default: Retention of events without a configured class (nil: forever)
+//   - Classes / Regulations: Retention by lower-cased class or regulation (nil value: forever)
+type Policy struct {
+	Default     *time.Duration
+	Classes     map[string]*time.Duration
+	Regulations map[string]*time.Duration
+}
+
+// NewPolicy parses the archive.retention configuration.
+//
+// Args:
+//   - cfg: Retention configuration
+//
+// Returns:
+//   - The policy (an empty configuration keeps everything forever)
+//   - Error if a retention value can't be parsed
+func NewPolicy(cfg config.RetentionCfg) (*Policy, error) {
+	p := &Policy{Classes: make(map[string]*time.Duration), Regulations: make(map[string]*time.Duration)}
+	var err error
+	if p.Default, err = parseRetention(cfg.Default); err != nil {
+		return nil, fmt.Errorf("archive.retention.default: %w", err)
+	}
+	for _, m := range []struct {
+		name string
+		in   map[string]string
+		out  map[string]*time.Duration
+	}{{"classes", cfg.Classes, p.Classes}, {"regulations", cfg.Regulations, p.Regulations}} {
+		for k, v := range m.in {
+			if v == "" {
+				return nil, fmt.Errorf("archive.retention.%s.%s: empty retention (use %q to keep forever)", m.name, k, Forever)
+			}
+			d, err := parseRetention(v)
+			if err != nil {
+				return nil, fmt.Errorf("archive.retention.%s.%s: %w", m.name, k, err)
+			}
+			m.out[strings.ToLower(k)] = d
+		}
+	}
+	return p, nil
+}
+
+// parseRetention parses a retention value; empty and "forever" mean forever
+func parseRetention(s string) (*time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, Forever) {
+		return nil, nil
+	}
+	d, err := event.ParseDuration(s)
+	if err != nil {
+		return nil, err
+	}
+	if d <= 0 {
+		return nil, fmt.Errorf("retention must be positive: %s", s)
+	}
+	return &d, nil
+}
+
+// Retention returns how long an event is kept and the classes that decided it.
+//
+// Args:
+//   - evt: Enriched, hashed event
+//
+// Returns:
+//   - Retention (nil: forever)
+//   - Classes matched, as "class:<name>" / "regulation:<name>", or "default"
+func (p *Policy) Retention(evt map[string]interface{}) (*time.Duration, []string) {
+	var keep *time.Duration
+	var classes []string
+	matched := false
+	for _, m := range []struct {
+		field, label string
+		rules        map[string]*time.Duration
+	}{{"retention_classes", "class", p.Classes}, {"regulations", "regulation", p.Regulations}} {
+		values, _ := event.GetStringSlice(evt, m.field)
+		for _, v := range values {
+			d, ok := m.rules[strings.ToLower(v)]
+			if !ok {
+				continue
+			}
+			classes = append(classes, m.label+":"+strings.ToLower(v))
+			switch {
+			case !matched:
+				keep = d
+			case keep != nil && (d == nil || *d > *keep):
+				keep = d
+			}
+			matched = true
+		}
+	}
+	if !matched {
+		return p.Default, []string{DefaultClass}
+	}
+	sort.Strings(classes)
+	return keep, classes
+}
+
+// retainEvent extends a segment's retention and classes to cover one more event.
+// The event's own timestamp starts its retention; events without one start at now.
+func (p *Policy) retainEvent(seg *Segment, evt map[string]interface{}, now time.Time) {
+	keep, classes := p.Retention(evt)
+	for _, c := range classes {
+		i := sort.SearchStrings(seg.Classes, c)
+		if i == len(seg.Classes) || seg.Classes[i] != c {
+			seg.Classes = append(seg.Classes[:i], append([]string{c}, seg.Classes[i:]...)...)
+		}
+	}
+
+	var until *time.Time
+	if keep != nil {
+		at := now
+		if t, err := event.ParseTimestamp(evt["timestamp"]); err == nil {
+			at = t
+		}
+		u := at.Add(*keep).UTC()
+		until = &u
+	}
+	switch {
+	case seg.Events == 0:
+		seg.RetainUntil = until
+	case seg.RetainUntil != nil && (until == nil || until.After(*seg.RetainUntil)):
+		seg.RetainUntil = until
+	}
+}
@@ -0,0 +1,697 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+	"github.com/vaibhaw-/AuditR/internal/auditr/event"
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+	"github.com/vaibhaw-/AuditR/internal/auditr/verify"
+)
+
+// Options configures segment rotation, retention and sealing.
+//
+// Fields:
+//   - MaxEvents / MaxBytes / MaxAge: Seal a segment once it reaches any of these (0 disables a limit)
+//   - Retention: Retention policy (nil keeps everything forever)
+//   - Signer: Signs a checkpoint for each sealed segment (nil for none)
+//   - Timestamper: Timestamps those checkpoints (nil for none)
+//   - Now: Clock (nil for time.Now)
+type Options struct {
+	MaxEvents   int
+	MaxBytes    int64
+	MaxAge      time.Duration
+	Retention   *Policy
+	Signer      verify.Signer
+	Timestamper verify.Timestamper
+	Now         func() time.Time
+}
+
+// NewOptions builds the rotation and retention options of the archive
+// configuration; Signer and Timestamper are left to the caller.
+//
+// Args:
+//   - cfg: archive configuration
+//
+// Returns:
+//   - The options
+//   - Error if segment_max_age or a retention value can't be parsed
+func NewOptions(cfg config.ArchiveCfg) (Options, error) {
+	opts := Options{MaxEvents: cfg.SegmentMaxEvents, MaxBytes: cfg.SegmentMaxBytes}
+	if cfg.SegmentMaxAge != "" {
+		d, err := event.ParseDuration(cfg.SegmentMaxAge)
+		if err != nil {
+			return opts, fmt.Errorf("archive.segment_max_age: %w", err)
+		}
+		opts.MaxAge = d
+	}
+	p, err := NewPolicy(cfg.Retention)
+	if err != nil {
+		return opts, err
+	}
+	opts.Retention = p
+	return opts, nil
+}
+
+// Store is an archive directory opened for writing.
+//
+// Open takes an exclusive lock on the archive (archive.json.lock) that Close
+// releases, so appends, seals and purges never interleave.
+type Store struct {
+	dir  string
+	opts Options
+	lock *verify.StateLock
+	m    *Manifest
+}
+
+// Open opens (or creates) an archive directory for writing.
+//
+// An append interrupted by a crash is rolled back first: segment files not yet
+// in archive.json are removed and open segments are cut back to their recorded
+// size, so the archive always matches its manifest.
+//
+// Args:
+//   - dir: Archive directory
+//   - opts: Rotation, retention and sealing options
+//
+// Returns:
+//   - The store (call Close)
+//   - Error if the archive is locked, unreadable or can't be repaired
+func Open(dir string, opts Options) (*Store, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("archive directory not set (use --dir or archive.dir)")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create archive directory: %w", err)
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Retention == nil {
+		opts.Retention = &Policy{}
+	}
+	lock, err := verify.LockState(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("archive %s: %w", dir, err)
+	}
+	m, err := LoadManifest(dir)
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	s := &Store{dir: dir, opts: opts, lock: lock, m: m}
+	if err := s.repair(); err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close releases the archive lock
+func (s *Store) Close() error {
+	return s.lock.Unlock()
+}
+
+// Manifest returns the archive's segment index
+func (s *Store) Manifest() *Manifest {
+	return s.m
+}
+
+// LoadManifest reads the archive.json of an archive directory; a missing file
+// yields an empty manifest.
+func LoadManifest(dir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Manifest{Version: manifestVersion}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read archive manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("decode archive manifest: %w", err)
+	}
+	if m.Version > manifestVersion {
+		return nil, fmt.Errorf("archive manifest version %d is newer than this auditr supports (%d)", m.Version, manifestVersion)
+	}
+	return &m, nil
+}
+
+// save writes archive.json through a synced temporary file and rename
+func (s *Store) save() error {
+	b, err := json.MarshalIndent(s.m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode archive manifest: %w", err)
+	}
+	path := filepath.Join(s.dir, ManifestFile)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("write archive manifest: %w", err)
+	}
+	if _, err := f.Write(append(b, '\n')); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write archive manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace archive manifest: %w", err)
+	}
+	return nil
+}
+
+// repair rolls back an append that was interrupted before archive.json was saved
+func (s *Store) repair() error {
+	log := logger.L()
+	listed := make(map[string]bool, len(s.m.Segments))
+	maxID := 0
+	for _, seg := range s.m.Segments {
+		listed[filepath.Clean(seg.File)] = true
+		if seg.ID > maxID {
+			maxID = seg.ID
+		}
+		if seg.State != StateOpen {
+			continue
+		}
+		path := filepath.Join(s.dir, seg.File)
+		fi, err := os.Stat(path)
+		if err != nil || fi.Size() <= seg.Bytes {
+			continue // Missing or short segments are for Verify to report
+		}
+		os.Chmod(path, 0644) // An interrupted seal may have made it read-only
+		if err := os.Truncate(path, seg.Bytes); err != nil {
+			return fmt.Errorf("roll back interrupted append of segment %d: %w", seg.ID, err)
+		}
+		log.Warnw("archive: interrupted append rolled back", "segment", seg.ID, "removed_bytes", fi.Size()-seg.Bytes)
+	}
+
+	files, err := segmentFiles(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, rel := range files {
+		if listed[rel] {
+			continue
+		}
+		// Only segments numbered after the last listed one were written by an
+		// interrupted append; anything else is left for Verify to report
+		var id int
+		if _, err := fmt.Sscanf(filepath.Base(rel), "segment-%d.ndjson", &id); err != nil || id <= maxID {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, rel)); err != nil {
+			return fmt.Errorf("remove segment of interrupted append: %w", err)
+		}
+		log.Warnw("archive: segment of interrupted append removed", "file", rel)
+	}
+	return nil
+}
+
+// segmentFiles lists the segment files under the archive, relative to it
+func segmentFiles(dir string) ([]string, error) {
+	var files []string
+	for _, pattern := range []string{
+		filepath.Join(dir, SegmentsDir, "segment-*.ndjson"),
+		filepath.Join(dir, SegmentsDir, verify.StreamsDir, "*", "segment-*.ndjson"),
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("list segments: %w", err)
+		}
+		for _, p := range matches {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, rel)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// last returns the last segment of a stream, or nil
+func (s *Store) last(stream string) *Segment {
+	for i := len(s.m.Segments) - 1; i >= 0; i-- {
+		if s.m.Segments[i].Stream == stream {
+			return s.m.Segments[i]
+		}
+	}
+	return nil
+}
+
+// due reports whether a segment has reached a rotation limit
+func (s *Store) due(seg *Segment) bool {
+	return (s.opts.MaxEvents > 0 && seg.Events >= s.opts.MaxEvents) ||
+		(s.opts.MaxBytes > 0 && seg.Bytes >= s.opts.MaxBytes) ||
+		(s.opts.MaxAge > 0 && s.opts.Now().Sub(seg.CreatedAt) >= s.opts.MaxAge)
+}
+
+// segmentWriter appends to one segment file
+type segmentWriter struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+// Append archives a hashed NDJSON file.
+//
+// The file is verified first (see verify.CheckChainFrom): every event must be
+// intact and each stream must continue exactly where its last segment ends, so
+// the archive only ever holds one unbroken chain per stream. Events are then
+// appended byte for byte to the open segment of their stream, sealing segments
+// that reach a rotation limit. In Merkle mode segments are only cut between
+// batches, so every segment head is a batch head that checkpoints can sign.
+//
+// The append is all or nothing: archive.json is saved once at the end, and Open
+// rolls back an append that didn't get there. Segments are sealed just before
+// that save and unsealed again if it fails, so a failed append leaves no sealed
+// file or checkpoint behind that archive.json doesn't list.
+//
+// Args:
+//   - input: Hashed NDJSON file (the output of verify in hash mode)
+//
+// Returns:
+//   - What was appended and sealed
+//   - Error if the input doesn't verify or doesn't continue the archive
+func (s *Store) Append(input string) (*AppendResult, error) {
+	log := logger.L()
+	start := time.Now()
+
+	// Each stream continues from the head of its last segment
+	startHeads := make(map[string]string)
+	next := make(map[string]int)
+	for _, seg := range s.m.Segments {
+		startHeads[seg.Stream] = seg.HeadHash
+		next[seg.Stream] = seg.LastIndex + 1
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("open input: %w", err)
+	}
+	check, err := verify.CheckChainFrom(f, nil, "", startHeads)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(check.Tampered) > 0 {
+		detail := fmt.Sprintf("%d events failed verification", len(check.Tampered))
+		if len(check.Findings) > 0 {
+			detail += " (first: " + check.Findings[0].String() + ")"
+		}
+		if first := check.Tampered[0]; len(check.Findings) == 0 || check.Findings[0].ChainIndex != first {
+			detail += fmt.Sprintf("; the first event that doesn't verify or continue the archive is chain index %d", first)
+		}
+		return nil, fmt.Errorf("input not archived: %s", detail)
+	}
+	for name, sc := range check.Streams {
+		if n, ok := next[name]; ok && sc.First != n {
+			return nil, fmt.Errorf("input not archived: stream %q starts at chain index %d but the archive continues at %d", name, sc.First, n)
+		}
+	}
+
+	// Append the events as they are, line by line
+	f, err = os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("open input: %w", err)
+	}
+	defer f.Close()
+	res := &AppendResult{}
+	writers := make(map[int]*segmentWriter)
+	defer func() {
+		for _, sw := range writers {
+			sw.f.Close()
+		}
+	}()
+	lastBatch := make(map[int]int) // Merkle batch of each segment's last event in this append
+	touched := make(map[int]bool)
+	rotated := make(map[int]bool) // Segments closed by this append, sealed once it is saved
+	var toSeal []*Segment
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var evt map[string]interface{}
+		if err := json.Unmarshal(line, &evt); err != nil {
+			return nil, fmt.Errorf("decode event: %w", err)
+		}
+		name, _ := evt["hash_stream"].(string)
+		prev, _ := evt["hash_prev"].(string)
+		got, _ := evt["hash"].(string)
+		idxFloat, _ := evt["hash_chain_index"].(float64)
+		idx := int(idxFloat)
+		batchFloat, merkle := evt["merkle_batch"].(float64)
+		batch := int(batchFloat)
+
+		seg := s.last(name)
+		if seg != nil && seg.State == StateOpen && !rotated[seg.ID] && s.due(seg) {
+			// Merkle mode: a new batch starts here and links to the segment's head
+			b, seen := lastBatch[seg.ID]
+			if !seg.Merkle || !seen || b != batch {
+				if seg.Merkle {
+					seg.HeadHash = prev
+				}
+				if err := closeWriter(seg, writers); err != nil {
+					return nil, err
+				}
+				rotated[seg.ID] = true
+				toSeal = append(toSeal, seg)
+			}
+		}
+		if seg == nil || seg.State != StateOpen || rotated[seg.ID] {
+			if seg, err = s.newSegment(name, idx, prev, merkle); err != nil {
+				return nil, err
+			}
+		}
+
+		sw, err := s.writer(seg, writers)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := sw.w.Write(append(line, '\n')); err != nil {
+			return nil, fmt.Errorf("write segment %d: %w", seg.ID, err)
+		}
+		s.opts.Retention.retainEvent(seg, evt, s.opts.Now())
+		seg.Events++
+		seg.Bytes += int64(len(line)) + 1
+		seg.LastIndex = idx
+		if !merkle {
+			seg.HeadHash = got
+		}
+		lastBatch[seg.ID] = batch
+		touched[seg.ID] = true
+		res.Events++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan input: %w", err)
+	}
+
+	// Each stream's last segment ends where the verified input ends: in Merkle
+	// mode the run closed its last batch, whose head the check computed
+	for name, sc := range check.Streams {
+		if seg := s.last(name); seg != nil && seg.State == StateOpen {
+			seg.HeadHash = sc.Head
+		}
+	}
+	for _, seg := range s.m.Segments {
+		if !touched[seg.ID] {
+			continue
+		}
+		res.Segments = append(res.Segments, seg)
+		if seg.State == StateOpen && !rotated[seg.ID] && s.due(seg) {
+			toSeal = append(toSeal, seg)
+		}
+	}
+	for id, sw := range writers {
+		if err := sw.flush(); err != nil {
+			return nil, fmt.Errorf("write segment %d: %w", id, err)
+		}
+	}
+
+	// Seal last: sealing makes files read-only and writes checkpoints, which
+	// must not outlive an append whose archive.json isn't saved
+	for _, seg := range toSeal {
+		if err := s.seal(seg, writers); err != nil {
+			s.unseal(append(res.Sealed, seg))
+			return nil, err
+		}
+		res.Sealed = append(res.Sealed, seg)
+	}
+	if err := s.save(); err != nil {
+		s.unseal(res.Sealed)
+		return nil, err
+	}
+	log.Infow("archive: append done", "input", input, "events", res.Events, "segments", len(res.Segments), "sealed", len(res.Sealed), "duration", time.Since(start))
+	return res, nil
+}
+
+// newSegment starts the open segment of a stream
+func (s *Store) newSegment(stream string, first int, prev string, merkle bool) (*Segment, error) {
+	id := 1
+	if n := len(s.m.Segments); n > 0 {
+		id = s.m.Segments[n-1].ID + 1
+	}
+	dir := verify.StreamDir(filepath.Join(s.dir, SegmentsDir), stream)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create segment directory: %w", err)
+	}
+	rel, err := filepath.Rel(s.dir, filepath.Join(dir, fmt.Sprintf("segment-%06d.ndjson", id)))
+	if err != nil {
+		return nil, err
+	}
+	seg := &Segment{
+		ID:         id,
+		Stream:     stream,
+		File:       rel,
+		State:      StateOpen,
+		FirstIndex: first,
+		LastIndex:  first - 1,
+		PrevHash:   prev,
+		HeadHash:   prev,
+		Merkle:     merkle,
+		CreatedAt:  s.opts.Now().UTC(),
+	}
+	s.m.Segments = append(s.m.Segments, seg)
+	logger.L().Debugw("archive: segment opened", "segment", id, "stream", stream, "first_index", first)
+	return seg, nil
+}
+
+// writer returns the appending writer of a segment, opening it on first use
+func (s *Store) writer(seg *Segment, writers map[int]*segmentWriter) (*segmentWriter, error) {
+	if sw, ok := writers[seg.ID]; ok {
+		return sw, nil
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, seg.File), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open segment %d: %w", seg.ID, err)
+	}
+	sw := &segmentWriter{f: f, w: bufio.NewWriter(f)}
+	writers[seg.ID] = sw
+	return sw, nil
+}
+
+// flush writes buffered events and syncs the segment file
+func (sw *segmentWriter) flush() error {
+	if err := sw.w.Flush(); err != nil {
+		return err
+	}
+	return sw.f.Sync()
+}
+
+// Seal seals every open segment, whatever its size or age. Like Append it is
+// all or nothing: if a segment can't be sealed or the manifest saved, the
+// segments sealed so far are open again.
+//
+// Returns:
+//   - The segments sealed
+//   - Error if a segment can't be sealed or the manifest saved
+func (s *Store) Seal() ([]*Segment, error) {
+	var sealed []*Segment
+	for _, seg := range s.m.Segments {
+		if seg.State != StateOpen {
+			continue
+		}
+		if err := s.seal(seg, nil); err != nil {
+			s.unseal(append(sealed, seg))
+			return nil, err
+		}
+		sealed = append(sealed, seg)
+	}
+	if len(sealed) == 0 {
+		return nil, nil
+	}
+	if err := s.save(); err != nil {
+		s.unseal(sealed)
+		return nil, err
+	}
+	return sealed, nil
+}
+
+// closeWriter flushes and closes the writer of a segment, if it has one
+func closeWriter(seg *Segment, writers map[int]*segmentWriter) error {
+	sw, ok := writers[seg.ID]
+	if !ok {
+		return nil
+	}
+	delete(writers, seg.ID)
+	err := sw.flush()
+	sw.f.Close()
+	if err != nil {
+		return fmt.Errorf("write segment %d: %w", seg.ID, err)
+	}
+	return nil
+}
+
+// seal makes a segment immutable: its file is synced and made read-only, its
+// digest and Merkle root recorded and its last event covered by a checkpoint
+func (s *Store) seal(seg *Segment, writers map[int]*segmentWriter) error {
+	if err := closeWriter(seg, writers); err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, seg.File)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read segment %d: %w", seg.ID, err)
+	}
+	if int64(len(data)) != seg.Bytes {
+		return fmt.Errorf("segment %d holds %d bytes, expected %d", seg.ID, len(data), seg.Bytes)
+	}
+	digest, root, err := segmentDigest(data)
+	if err != nil {
+		return fmt.Errorf("segment %d: %w", seg.ID, err)
+	}
+	if err := os.Chmod(path, 0444); err != nil {
+		return fmt.Errorf("make segment %d read-only: %w", seg.ID, err)
+	}
+	seg.SHA256, seg.MerkleRoot = digest, root
+
+	if s.opts.Signer != nil {
+		dir := verify.StreamDir(filepath.Join(s.dir, CheckpointsDir), seg.Stream)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("create checkpoint directory: %w", err)
+		}
+		cp, err := verify.WriteStreamCheckpoint(dir, seg.Stream, seg.LastIndex, seg.HeadHash, s.opts.Signer, s.opts.Timestamper)
+		if err != nil {
+			return fmt.Errorf("checkpoint of segment %d: %w", seg.ID, err)
+		}
+		if seg.Checkpoint, err = filepath.Rel(s.dir, cp); err != nil {
+			return err
+		}
+	}
+
+	now := s.opts.Now().UTC()
+	seg.State, seg.SealedAt = StateSealed, &now
+	logger.L().Infow("archive: segment sealed", "segment", seg.ID, "stream", seg.Stream, "events", seg.Events,
+		"first_index", seg.FirstIndex, "last_index", seg.LastIndex, "checkpoint", seg.Checkpoint)
+	return nil
+}
+
+// unseal undoes the seals of an append that failed before archive.json was
+// saved: the files are made writable again and their checkpoints removed, so
+// the segments are open again both on disk and in the manifest in memory
+func (s *Store) unseal(sealed []*Segment) {
+	log := logger.L()
+	for _, seg := range sealed {
+		if err := os.Chmod(filepath.Join(s.dir, seg.File), 0644); err != nil {
+			log.Warnw("archive: can't undo seal of segment", "segment", seg.ID, "error", err)
+		}
+		if seg.Checkpoint != "" {
+			if err := removeCheckpoint(filepath.Join(s.dir, seg.Checkpoint)); err != nil {
+				log.Warnw("archive: can't remove checkpoint of unsealed segment", "segment", seg.ID, "checkpoint", seg.Checkpoint, "error", err)
+			}
+		}
+		seg.State, seg.SealedAt = StateOpen, nil
+		seg.SHA256, seg.MerkleRoot, seg.Checkpoint = "", "", ""
+		log.Warnw("archive: seal undone", "segment", seg.ID, "stream", seg.Stream)
+	}
+}
+
+// removeCheckpoint removes a checkpoint file written by seal and its entry in
+// the manifest of its directory. A checkpoint that is already gone is not an
+// error.
+func removeCheckpoint(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove checkpoint: %w", err)
+	}
+	manifestPath := filepath.Join(filepath.Dir(path), verify.ManifestFile)
+	m, err := verify.LoadManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	kept := m.Checkpoints[:0]
+	for _, e := range m.Checkpoints {
+		if e.File != filepath.Base(path) {
+			kept = append(kept, e)
+		}
+	}
+	m.Checkpoints = kept
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	tmp := manifestPath + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, manifestPath); err != nil {
+		return fmt.Errorf("replace manifest: %w", err)
+	}
+	return nil
+}
+
+// segmentDigest returns the hex SHA-256 digest of a segment file and the RFC
+// 6962 Merkle root over its events
+func segmentDigest(data []byte) (string, string, error) {
+	sum := sha256.Sum256(data)
+	var leaves [][]byte
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		var evt map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &evt); err != nil {
+			return "", "", fmt.Errorf("decode event: %w", err)
+		}
+		canon, err := verify.Canonicalize(evt)
+		if err != nil {
+			return "", "", fmt.Errorf("canonicalize: %w", err)
+		}
+		leaves = append(leaves, verify.MerkleLeaf(canon))
+	}
+	return hex.EncodeToString(sum[:]), verify.MerkleTreeHash(leaves), nil
+}
+
+// Purge removes the files of sealed segments whose retention has passed.
+//
+// Purged segments stay in archive.json with their chain range, hashes and
+// checkpoint, so the segments after them still verify (see Verify).
+//
+// Args:
+//   - dryRun: Only report what would be purged
+//
+// Returns:
+//   - The segments purged (or due, with dryRun)
+//   - Error if a file can't be removed or the manifest saved
+func (s *Store) Purge(dryRun bool) ([]*Segment, error) {
+	log := logger.L()
+	now := s.opts.Now().UTC()
+	var purged []*Segment
+	for _, seg := range s.m.Segments {
+		if seg.State != StateSealed || seg.RetainUntil == nil || now.Before(*seg.RetainUntil) {
+			continue
+		}
+		purged = append(purged, seg)
+		if dryRun {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, seg.File)); err != nil && !os.IsNotExist(err) {
+			return purged, fmt.Errorf("purge segment %d: %w", seg.ID, err)
+		}
+		at := now
+		seg.State, seg.PurgedAt = StatePurged, &at
+		log.Infow("archive: segment purged", "segment", seg.ID, "stream", seg.Stream, "retain_until", seg.RetainUntil, "classes", seg.Classes)
+	}
+	if dryRun || len(purged) == 0 {
+		return purged, nil
+	}
+	return purged, s.save()
+}
@@ -0,0 +1,89 @@
+// Package archive stores hashed events in an append-only store of sealed
+// segments.
+//
+// Events are appended in chain order to the open segment of their stream. Once
+// a segment reaches its size, event count or age limit it is sealed: its file is
+// made read-only, its SHA-256 digest and Merkle root are recorded and a signed
+// checkpoint covers its last event. archive.json lists every segment with the
+// chain range and hashes it covers, so the whole store can be verified end to
+// end (see Verify) and segments past their retention can be purged while their
+// hashes keep the chain verifiable.
+package archive
+
+import "time"
+
+// Layout of an archive directory
+const (
+	ManifestFile   = "archive.json" // Segment index
+	SegmentsDir    = "segments"     // Segment files, per stream as in verify.StreamDir
+	CheckpointsDir = "checkpoints"  // Segment checkpoints, per stream as in verify.StreamDir
+)
+
+// Segment states
+const (
+	StateOpen   = "open"   // Still receiving events
+	StateSealed = "sealed" // Read-only, digest and checkpoint recorded
+	StatePurged = "purged" // File removed by retention; the entry keeps its hashes
+)
+
+// Segment describes one segment file of the archive.
+//
+// Fields:
+//   - ID: Segment number, increasing across all streams
+//   - Stream: Named stream of the events (empty for the unnamed chain)
+//   - File: Segment file, relative to the archive directory
+//   - State: open, sealed or purged
+//   - Events / Bytes: Events and bytes in the file
+//   - FirstIndex / LastIndex: hash_chain_index of the first and last event
+//   - PrevHash: hash_prev of the first event (the previous segment's HeadHash)
+//   - HeadHash: Chain head after the last event (batch head in Merkle mode)
+//   - Merkle: Whether the events were hashed in Merkle mode
+//   - MerkleRoot: RFC 6962 root over the segment's events (set when sealed)
+//   - SHA256: Digest of the segment file (set when sealed)
+//   - Checkpoint: Signed checkpoint of LastIndex/HeadHash, relative to the archive directory
+//   - Classes: Retention classes and regulations of the events ("default" for none)
+//   - RetainUntil: When the segment may be purged (nil: kept forever)
+//   - CreatedAt / SealedAt / PurgedAt: State change times
+type Segment struct {
+	ID          int        `json:"id"`
+	Stream      string     `json:"stream,omitempty"`
+	File        string     `json:"file"`
+	State       string     `json:"state"`
+	Events      int        `json:"events"`
+	Bytes       int64      `json:"bytes"`
+	FirstIndex  int        `json:"first_index"`
+	LastIndex   int        `json:"last_index"`
+	PrevHash    string     `json:"prev_hash"`
+	HeadHash    string     `json:"head_hash"`
+	Merkle      bool       `json:"merkle,omitempty"`
+	MerkleRoot  string     `json:"merkle_root,omitempty"`
+	SHA256      string     `json:"sha256,omitempty"`
+	Checkpoint  string     `json:"checkpoint,omitempty"`
+	Classes     []string   `json:"classes,omitempty"`
+	RetainUntil *time.Time `json:"retain_until,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	SealedAt    *time.Time `json:"sealed_at,omitempty"`
+	PurgedAt    *time.Time `json:"purged_at,omitempty"`
+}
+
+// Manifest is the content of archive.json: every segment ever written, in
+// order. Purged segments stay listed so the chain stays verifiable across them.
+type Manifest struct {
+	Version  int        `json:"version"`
+	Segments []*Segment `json:"segments"`
+}
+
+// manifestVersion is the archive.json format written by this version
+const manifestVersion = 1
+
+// AppendResult summarizes one Append.
+//
+// Fields:
+//   - Events: Events appended
+//   - Sealed: Segments sealed during the append
+//   - Segments: Segments that received events
+type AppendResult struct {
+	Events   int
+	Sealed   []*Segment
+	Segments []*Segment
+}
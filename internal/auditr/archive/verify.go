@@ -0,0 +1,307 @@
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+	"github.com/vaibhaw-/AuditR/internal/auditr/verify"
+)
+
+// Issue is one problem found by Verify.
+//
+// Fields:
+//   - Segment: Segment ID (0 for files not in the manifest)
+//   - Stream: Stream of the segment
+//   - File: File concerned, relative to the archive directory
+//   - Problem: What is wrong
+type Issue struct {
+	Segment int    `json:"segment,omitempty"`
+	Stream  string `json:"stream,omitempty"`
+	File    string `json:"file,omitempty"`
+	Problem string `json:"problem"`
+}
+
+func (i Issue) String() string {
+	s := i.Problem
+	if i.File != "" {
+		s = i.File + ": " + s
+	}
+	if i.Segment > 0 {
+		s = fmt.Sprintf("segment %d (%s)", i.Segment, s)
+	}
+	return s
+}
+
+// Report is the result of verifying an archive.
+//
+// Fields:
+//   - Segments / Sealed / Open / Purged: Segments in the manifest, by state
+//   - Events: Events in the segments still on disk
+//   - Checkpoints: Segment checkpoints verified
+//   - Streams: Streams in the archive ("" for the unnamed chain)
+//   - Issues: Problems found (none when the archive verifies)
+type Report struct {
+	Segments    int      `json:"segments"`
+	Sealed      int      `json:"sealed"`
+	Open        int      `json:"open"`
+	Purged      int      `json:"purged"`
+	Events      int      `json:"events"`
+	Checkpoints int      `json:"checkpoints"`
+	Streams     []string `json:"streams,omitempty"`
+	Issues      []Issue  `json:"issues,omitempty"`
+}
+
+// OK reports whether the archive verified without issues
+func (r *Report) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// Verify validates a whole archive end to end.
+//
+// Per stream, segments must continue each other: each starts at the chain
+// index after the previous one's last and links to its head. Each segment on
+// disk is verified as a chain continuing from that head (see
+// verify.CheckChainFrom) and must hold exactly the events, index range and head
+// the manifest records; sealed segments must also match their recorded size,
+// SHA-256 digest and Merkle root. Segment checkpoints must cover the segment's
+// last index and head, and with a public key their signatures are verified (a
+// sealed segment without a checkpoint is then an issue too). Purged segments
+// are only checked for continuity and checkpoint, which is what keeps the
+// segments after them verifiable.
+//
+// Args:
+//   - dir: Archive directory
+//   - publicKeyPath: PEM public key or keyring for checkpoint signatures (empty to skip them)
+//
+// Returns:
+//   - The report (see Report.OK)
+//   - Error if the manifest can't be read
+func Verify(dir, publicKeyPath string) (*Report, error) {
+	log := logger.L()
+	start := time.Now()
+	if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err != nil {
+		return nil, fmt.Errorf("not an archive: %w", err)
+	}
+	m, err := LoadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{Segments: len(m.Segments)}
+	issue := func(seg *Segment, format string, a ...interface{}) {
+		r.Issues = append(r.Issues, Issue{Segment: seg.ID, Stream: seg.Stream, File: seg.File, Problem: fmt.Sprintf(format, a...)})
+	}
+
+	byStream := make(map[string][]*Segment)
+	listed := make(map[string]bool)
+	checkpoints := make(map[string]bool)
+	lastID := 0
+	for _, seg := range m.Segments {
+		if seg.ID <= lastID {
+			issue(seg, "segment IDs out of order (after %d)", lastID)
+		}
+		lastID = seg.ID
+		if _, ok := byStream[seg.Stream]; !ok {
+			r.Streams = append(r.Streams, seg.Stream)
+		}
+		byStream[seg.Stream] = append(byStream[seg.Stream], seg)
+		listed[filepath.Clean(seg.File)] = true
+		if seg.Checkpoint != "" {
+			checkpoints[filepath.Clean(seg.Checkpoint)] = true
+		}
+	}
+	sort.Strings(r.Streams)
+
+	for _, name := range r.Streams {
+		var prev *Segment
+		for _, seg := range byStream[name] {
+			switch seg.State {
+			case StateSealed:
+				r.Sealed++
+			case StateOpen:
+				r.Open++
+			case StatePurged:
+				r.Purged++
+			default:
+				issue(seg, "unknown state %q", seg.State)
+			}
+			if prev != nil {
+				if prev.State == StateOpen {
+					issue(seg, "follows open segment %d", prev.ID)
+				}
+				if seg.FirstIndex != prev.LastIndex+1 {
+					issue(seg, "starts at chain index %d, segment %d ends at %d", seg.FirstIndex, prev.ID, prev.LastIndex)
+				}
+				if seg.PrevHash != prev.HeadHash {
+					issue(seg, "does not link to the head of segment %d", prev.ID)
+				}
+			}
+			if seg.State != StatePurged {
+				r.Events += verifySegmentFile(dir, seg, issue)
+			} else if _, err := os.Stat(filepath.Join(dir, seg.File)); err == nil {
+				issue(seg, "purged segment file still present")
+			}
+			if verifySegmentCheckpoint(dir, seg, publicKeyPath, issue) {
+				r.Checkpoints++
+			}
+			prev = seg
+		}
+	}
+
+	// Files the manifest doesn't know about were added behind its back
+	files, err := segmentFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range files {
+		if !listed[rel] {
+			r.Issues = append(r.Issues, Issue{File: rel, Problem: "segment file not in the manifest"})
+		}
+	}
+	// A checkpoint past the end of its stream means segments were removed from
+	// the manifest along with their files
+	cps, err := checkpointFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range cps {
+		if checkpoints[rel] {
+			continue
+		}
+		sc, err := verify.LoadCheckpoint(filepath.Join(dir, rel))
+		if err != nil {
+			r.Issues = append(r.Issues, Issue{File: rel, Problem: err.Error()})
+			continue
+		}
+		segs := byStream[sc.Checkpoint.Stream]
+		if len(segs) == 0 || sc.Checkpoint.ChainIndex > segs[len(segs)-1].LastIndex {
+			r.Issues = append(r.Issues, Issue{Stream: sc.Checkpoint.Stream, File: rel,
+				Problem: fmt.Sprintf("checkpoint of chain index %d is past the end of the archive", sc.Checkpoint.ChainIndex)})
+		}
+	}
+
+	log.Infow("archive: verify done", "dir", dir, "segments", r.Segments, "events", r.Events, "checkpoints", r.Checkpoints,
+		"issues", len(r.Issues), "duration", time.Since(start))
+	return r, nil
+}
+
+// verifySegmentFile verifies the events of a segment on disk and returns how
+// many there are
+func verifySegmentFile(dir string, seg *Segment, issue func(*Segment, string, ...interface{})) int {
+	data, err := os.ReadFile(filepath.Join(dir, seg.File))
+	if err != nil {
+		issue(seg, "read: %v", err)
+		return 0
+	}
+	if int64(len(data)) != seg.Bytes {
+		issue(seg, "holds %d bytes, manifest records %d", len(data), seg.Bytes)
+	}
+	if seg.State == StateSealed {
+		digest, root, err := segmentDigest(data)
+		switch {
+		case err != nil:
+			issue(seg, "%v", err)
+		case digest != seg.SHA256:
+			issue(seg, "SHA-256 digest does not match the manifest")
+		case root != seg.MerkleRoot:
+			issue(seg, "Merkle root does not match the manifest")
+		}
+	}
+
+	check, err := verify.CheckChainFrom(bytes.NewReader(data), map[int]bool{seg.LastIndex: true}, seg.Stream,
+		map[string]string{seg.Stream: seg.PrevHash})
+	if err != nil {
+		issue(seg, "%v", err)
+		return check.Processed
+	}
+	if len(check.Tampered) > 0 {
+		detail := fmt.Sprintf("%d events failed verification", len(check.Tampered))
+		if len(check.Findings) > 0 {
+			detail += " (first: " + check.Findings[0].String() + ")"
+		}
+		issue(seg, "%s", detail)
+	}
+	if len(check.Streams) > 1 {
+		issue(seg, "holds events of %d streams", len(check.Streams))
+	}
+	sc := check.Streams[seg.Stream]
+	if sc == nil {
+		if seg.Events > 0 {
+			issue(seg, "holds no events of its stream, manifest records %d", seg.Events)
+		}
+		return check.Processed
+	}
+	if check.Processed != seg.Events {
+		issue(seg, "holds %d events, manifest records %d", check.Processed, seg.Events)
+	}
+	if sc.First != seg.FirstIndex || sc.Last != seg.LastIndex {
+		issue(seg, "covers chain indices %d-%d, manifest records %d-%d", sc.First, sc.Last, seg.FirstIndex, seg.LastIndex)
+	}
+	if head, ok := sc.Heads[seg.LastIndex]; !ok || head != seg.HeadHash {
+		issue(seg, "recomputed head does not match the manifest")
+	}
+	return check.Processed
+}
+
+// verifySegmentCheckpoint checks the checkpoint of a segment and reports whether
+// one was verified
+func verifySegmentCheckpoint(dir string, seg *Segment, publicKeyPath string, issue func(*Segment, string, ...interface{})) bool {
+	if seg.Checkpoint == "" {
+		if publicKeyPath != "" && seg.State != StateOpen {
+			issue(seg, "no checkpoint")
+		}
+		return false
+	}
+	path := filepath.Join(dir, seg.Checkpoint)
+	sc, err := verify.LoadCheckpoint(path)
+	if err != nil {
+		issue(seg, "checkpoint: %v", err)
+		return false
+	}
+	cp := sc.Checkpoint
+	if cp.Stream != seg.Stream || cp.ChainIndex != seg.LastIndex || cp.HeadHash != seg.HeadHash {
+		issue(seg, "checkpoint %s does not cover the segment's last index and head", seg.Checkpoint)
+		return false
+	}
+	if publicKeyPath == "" {
+		return true
+	}
+	valid, err := verify.VerifyCheckpoint(path, publicKeyPath, seg.HeadHash)
+	if err != nil {
+		issue(seg, "checkpoint %s: %v", seg.Checkpoint, err)
+		return false
+	}
+	if !valid {
+		issue(seg, "checkpoint %s: invalid signature", seg.Checkpoint)
+		return false
+	}
+	return true
+}
+
+// checkpointFiles lists the segment checkpoints under the archive, relative to it
+func checkpointFiles(dir string) ([]string, error) {
+	var files []string
+	for _, pattern := range []string{
+		filepath.Join(dir, CheckpointsDir, "checkpoint-*.json"),
+		filepath.Join(dir, CheckpointsDir, verify.StreamsDir, "*", "checkpoint-*.json"),
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("list checkpoints: %w", err)
+		}
+		for _, p := range matches {
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, rel)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
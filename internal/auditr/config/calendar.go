@@ -0,0 +1,116 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Holiday = one non-business day of the calendar. Date is either a full date
+// (2025-12-25) or a month and day (12-25) for a holiday on the same date every year.
+type Holiday struct {
+	Date string `json:"date"`
+	Name string `json:"name,omitempty"`
+}
+
+// BusinessHours = the working day, as "15:04" times of day; End is exclusive
+type BusinessHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// BusinessCalendar = the business calendar used for business-hours and off-hours
+// queries. Empty fields fall back to DefaultBusinessCalendar.
+type BusinessCalendar struct {
+	Timezone      string        `json:"timezone,omitempty"` // IANA zone business hours are in (e.g. Europe/Berlin)
+	Workdays      []string      `json:"workdays,omitempty"` // mon, tue, ... (or full names)
+	BusinessHours BusinessHours `json:"business_hours,omitempty"`
+	Holidays      []Holiday     `json:"holidays,omitempty"`
+}
+
+// DefaultBusinessCalendar = Monday to Friday, 09:00 to 17:00, no holidays
+var DefaultBusinessCalendar = BusinessCalendar{
+	Workdays:      []string{"mon", "tue", "wed", "thu", "fri"},
+	BusinessHours: BusinessHours{Start: "09:00", End: "17:00"},
+}
+
+// weekdays maps workday names to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseWeekday parses a workday name: mon, tue, ... or a full name (Monday), case-insensitive
+func ParseWeekday(name string) (time.Weekday, bool) {
+	n := strings.ToLower(strings.TrimSpace(name))
+	if len(n) < 3 {
+		return 0, false
+	}
+	d, ok := weekdays[n[:3]]
+	if !ok || (len(n) > 3 && !strings.EqualFold(n, d.String())) {
+		return 0, false
+	}
+	return d, true
+}
+
+// ParseTimeOfDay parses a "15:04" time of day into minutes after midnight; "24:00" ends a day
+func ParseTimeOfDay(s string) (int, error) {
+	if strings.TrimSpace(s) == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ValidateCalendar validates the business calendar JSON and fills in defaults
+func ValidateCalendar(r io.Reader) (*BusinessCalendar, error) {
+	var bc BusinessCalendar
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&bc); err != nil {
+		return nil, fmt.Errorf("failed to decode business calendar JSON: %w", err)
+	}
+
+	if bc.Timezone != "" {
+		if _, err := time.LoadLocation(bc.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", bc.Timezone, err)
+		}
+	}
+	if len(bc.Workdays) == 0 {
+		bc.Workdays = DefaultBusinessCalendar.Workdays
+	}
+	for _, day := range bc.Workdays {
+		if _, ok := ParseWeekday(day); !ok {
+			return nil, fmt.Errorf("invalid workday %q, expected mon, tue, ... sun", day)
+		}
+	}
+	if bc.BusinessHours.Start == "" && bc.BusinessHours.End == "" {
+		bc.BusinessHours = DefaultBusinessCalendar.BusinessHours
+	}
+	start, err := ParseTimeOfDay(bc.BusinessHours.Start)
+	if err != nil {
+		return nil, fmt.Errorf("business_hours.start: %w", err)
+	}
+	end, err := ParseTimeOfDay(bc.BusinessHours.End)
+	if err != nil {
+		return nil, fmt.Errorf("business_hours.end: %w", err)
+	}
+	if start >= end {
+		return nil, fmt.Errorf("business_hours.start %s must be before business_hours.end %s", bc.BusinessHours.Start, bc.BusinessHours.End)
+	}
+	for i, h := range bc.Holidays {
+		if _, err := time.Parse("2006-01-02", h.Date); err == nil {
+			continue
+		}
+		if _, err := time.Parse("01-02", h.Date); err == nil {
+			continue
+		}
+		return nil, fmt.Errorf("holiday %d: invalid date %q, expected YYYY-MM-DD or MM-DD", i, h.Date)
+	}
+	return &bc, nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateCalendar(t *testing.T) {
+	bc, err := ValidateCalendar(strings.NewReader(`{
+		"timezone": "Europe/Berlin",
+		"holidays": [{ "date": "12-25", "name": "Christmas" }, { "date": "2025-10-03" }]
+	}`))
+	if err != nil {
+		t.Fatalf("calendar validation failed: %v", err)
+	}
+	if strings.Join(bc.Workdays, ",") != "mon,tue,wed,thu,fri" || bc.BusinessHours.Start != "09:00" || bc.BusinessHours.End != "17:00" {
+		t.Errorf("defaults not applied: %+v", bc)
+	}
+
+	invalid := map[string]string{
+		`{"timezone": "Nowhere/City"}`:                           "invalid timezone",
+		`{"workdays": ["mon", "funday"]}`:                        "invalid workday",
+		`{"business_hours": {"start": "18:00", "end": "09:00"}}`: "must be before",
+		`{"business_hours": {"start": "9am", "end": "17:00"}}`:   "business_hours.start",
+		`{"holidays": [{"date": "25.12.2025"}]}`:                 "invalid date",
+		`{"holidays": [], "weekend": ["sat"]}`:                   "unknown field",
+	}
+	for input, want := range invalid {
+		if _, err := ValidateCalendar(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ValidateCalendar(%s) error = %v, want %q", input, err, want)
+		}
+	}
+}
+
+func TestParseWeekday(t *testing.T) {
+	for _, name := range []string{"mon", "Mon", "MONDAY", "monday"} {
+		if d, ok := ParseWeekday(name); !ok || d.String() != "Monday" {
+			t.Errorf("ParseWeekday(%q) = %v, %v", name, d, ok)
+		}
+	}
+	for _, name := range []string{"mo", "mond", "monkey", ""} {
+		if _, ok := ParseWeekday(name); ok {
+			t.Errorf("ParseWeekday(%q) accepted", name)
+		}
+	}
+}
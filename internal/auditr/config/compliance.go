@@ -0,0 +1,157 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ComplianceControl = one control of a framework (e.g. HIPAA 164.312(b)).
+// See Matches for how the criteria combine.
+type ComplianceControl struct {
+	ID              string   `json:"id"`
+	Title           string   `json:"title"`
+	Categories      []string `json:"categories,omitempty"`       // Sensitivity categories (PHI, PCI-CHD, ...)
+	QueryTypes      []string `json:"query_types,omitempty"`      // Canonical query types (SELECT, DELETE, ...)
+	EscalationTypes []string `json:"escalation_types,omitempty"` // GRANT, ALTER_ROLE, ... or "*" for any *_ESCALATION type
+}
+
+// ComplianceFramework = a named set of controls
+type ComplianceFramework struct {
+	Name     string              `json:"name"`
+	Controls []ComplianceControl `json:"controls"`
+}
+
+// ComplianceMapping = the full mapping file, keyed by framework key (hipaa, pci-dss, ...)
+type ComplianceMapping struct {
+	Frameworks map[string]ComplianceFramework `json:"frameworks"`
+}
+
+// ValidateCompliance validates the compliance mapping JSON
+func ValidateCompliance(r io.Reader) (*ComplianceMapping, error) {
+	var cm ComplianceMapping
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cm); err != nil {
+		return nil, fmt.Errorf("failed to decode compliance mapping JSON: %w", err)
+	}
+
+	if len(cm.Frameworks) == 0 {
+		return nil, fmt.Errorf("compliance mapping must define at least one framework")
+	}
+	for key, fw := range cm.Frameworks {
+		if strings.TrimSpace(key) == "" || strings.Contains(key, ":") {
+			return nil, fmt.Errorf("invalid framework key %q: must be non-empty and not contain ':'", key)
+		}
+		if len(fw.Controls) == 0 {
+			return nil, fmt.Errorf("framework %q has no controls", key)
+		}
+		seen := make(map[string]bool)
+		for i, c := range fw.Controls {
+			if strings.TrimSpace(c.ID) == "" {
+				return nil, fmt.Errorf("framework %q control %d missing id", key, i)
+			}
+			if seen[c.ID] {
+				return nil, fmt.Errorf("framework %q: duplicate control id %q", key, c.ID)
+			}
+			seen[c.ID] = true
+			if len(c.Categories) == 0 && len(c.QueryTypes) == 0 && len(c.EscalationTypes) == 0 {
+				return nil, fmt.Errorf("framework %q control %s: set categories, query_types or escalation_types", key, c.ID)
+			}
+		}
+	}
+
+	return &cm, nil
+}
+
+// Framework returns a framework by key, case-insensitively.
+func (cm *ComplianceMapping) Framework(key string) (string, ComplianceFramework, bool) {
+	if fw, ok := cm.Frameworks[key]; ok {
+		return key, fw, true
+	}
+	for k, fw := range cm.Frameworks {
+		if strings.EqualFold(k, key) {
+			return k, fw, true
+		}
+	}
+	return "", ComplianceFramework{}, false
+}
+
+// FrameworkKeys returns the sorted framework keys.
+func (cm *ComplianceMapping) FrameworkKeys() []string {
+	keys := make([]string, 0, len(cm.Frameworks))
+	for k := range cm.Frameworks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Matches reports whether an event with the given categories and query type is
+// relevant to the control. Categories and query types must both match when both
+// are set (e.g. PHI touched by UPDATE or DELETE); an escalation type match is
+// sufficient on its own.
+func (c ComplianceControl) Matches(categories []string, queryType string) bool {
+	if c.matchesEscalation(queryType) {
+		return true
+	}
+	if len(c.Categories) == 0 && len(c.QueryTypes) == 0 {
+		return false
+	}
+	if len(c.Categories) > 0 && !containsFold(c.Categories, categories...) {
+		return false
+	}
+	if len(c.QueryTypes) > 0 && !containsFold(c.QueryTypes, queryType) {
+		return false
+	}
+	return true
+}
+
+func (c ComplianceControl) matchesEscalation(queryType string) bool {
+	if !strings.HasSuffix(strings.ToUpper(queryType), "_ESCALATION") {
+		return false
+	}
+	for _, want := range c.EscalationTypes {
+		if want == "*" || strings.EqualFold(want, queryType) || strings.EqualFold(want+"_ESCALATION", queryType) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether any value is in list, case-insensitively
+func containsFold(list []string, values ...string) bool {
+	for _, v := range values {
+		for _, item := range list {
+			if strings.EqualFold(item, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Match returns the controls relevant to an event as sorted "framework:control_id" strings.
+func (cm *ComplianceMapping) Match(categories []string, queryType string) []string {
+	var controls []string
+	for key, fw := range cm.Frameworks {
+		for _, c := range fw.Controls {
+			if c.Matches(categories, queryType) {
+				controls = append(controls, key+":"+c.ID)
+			}
+		}
+	}
+	sort.Strings(controls)
+	return controls
+}
+
+// ParseControlRef splits a "framework:control_id" reference.
+func ParseControlRef(ref string) (framework, controlID string) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 {
+		return "", ref
+	}
+	return parts[0], parts[1]
+}
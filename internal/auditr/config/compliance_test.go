@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCompliance = `{
+	"frameworks": {
+		"hipaa": {
+			"name": "HIPAA",
+			"controls": [
+				{ "id": "164.312(b)", "title": "Audit controls", "categories": ["PHI"] },
+				{ "id": "164.312(c)(1)", "title": "Integrity", "categories": ["PHI"], "query_types": ["UPDATE", "DELETE"] },
+				{ "id": "164.308(a)(4)", "title": "Access management", "escalation_types": ["*"] }
+			]
+		},
+		"pci-dss": {
+			"controls": [
+				{ "id": "7.2.2", "title": "Least privilege", "escalation_types": ["GRANT"] }
+			]
+		}
+	}
+}`
+
+func TestValidateCompliance(t *testing.T) {
+	cm, err := ValidateCompliance(strings.NewReader(testCompliance))
+	if err != nil {
+		t.Fatalf("compliance validation failed: %v", err)
+	}
+	if got := strings.Join(cm.FrameworkKeys(), ","); got != "hipaa,pci-dss" {
+		t.Errorf("FrameworkKeys() = %s", got)
+	}
+	key, fw, ok := cm.Framework("HIPAA")
+	if !ok || key != "hipaa" || len(fw.Controls) != 3 {
+		t.Errorf("Framework(HIPAA) = %q, %d controls, %v", key, len(fw.Controls), ok)
+	}
+}
+
+func TestValidateComplianceInvalid(t *testing.T) {
+	tests := map[string]string{
+		"no frameworks":  `{"frameworks": {}}`,
+		"no controls":    `{"frameworks": {"hipaa": {"controls": []}}}`,
+		"missing id":     `{"frameworks": {"hipaa": {"controls": [{"categories": ["PHI"]}]}}}`,
+		"duplicate id":   `{"frameworks": {"hipaa": {"controls": [{"id": "a", "categories": ["PHI"]}, {"id": "a", "categories": ["PII"]}]}}}`,
+		"no criteria":    `{"frameworks": {"hipaa": {"controls": [{"id": "a"}]}}}`,
+		"colon in key":   `{"frameworks": {"hipaa:2013": {"controls": [{"id": "a", "categories": ["PHI"]}]}}}`,
+		"unknown field":  `{"frameworks": {"hipaa": {"controls": [{"id": "a", "category": ["PHI"]}]}}}`,
+		"malformed json": `{"frameworks": `,
+	}
+	for name, mapping := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ValidateCompliance(strings.NewReader(mapping)); err == nil {
+				t.Errorf("expected error for %s", name)
+			}
+		})
+	}
+}
+
+func TestComplianceMappingMatch(t *testing.T) {
+	cm, err := ValidateCompliance(strings.NewReader(testCompliance))
+	if err != nil {
+		t.Fatalf("compliance validation failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		categories []string
+		queryType  string
+		want       string
+	}{
+		{"phi read", []string{"PHI", "PII"}, "SELECT", "hipaa:164.312(b)"},
+		{"phi update needs both", []string{"phi"}, "UPDATE", "hipaa:164.312(b),hipaa:164.312(c)(1)"},
+		{"update without phi", []string{"PII"}, "UPDATE", ""},
+		{"grant escalation", nil, "GRANT_ESCALATION", "hipaa:164.308(a)(4),pci-dss:7.2.2"},
+		{"other escalation", nil, "ALTER_ROLE_ESCALATION", "hipaa:164.308(a)(4)"},
+		{"plain grant is not escalation", nil, "GRANT", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(cm.Match(tt.categories, tt.queryType), ","); got != tt.want {
+				t.Errorf("Match() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if fw, id := ParseControlRef("hipaa:164.312(b)"); fw != "hipaa" || id != "164.312(b)" {
+		t.Errorf("ParseControlRef() = %q, %q", fw, id)
+	}
+}
+
+func TestValidateCompliance_RealFile(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "..", "..", "cmd", "auditr", "config", "compliance_mapping.json"))
+	if err != nil {
+		t.Skipf("compliance mapping not found: %v", err)
+	}
+	defer f.Close()
+
+	cm, err := ValidateCompliance(f)
+	if err != nil {
+		t.Fatalf("bundled compliance mapping invalid: %v", err)
+	}
+	for _, key := range []string{"hipaa", "pci-dss", "gdpr", "sox"} {
+		if _, _, ok := cm.Framework(key); !ok {
+			t.Errorf("bundled mapping missing framework %s", key)
+		}
+	}
+}
@@ -25,6 +25,69 @@ type HashingCfg struct {
 	StateFile          string `mapstructure:"state_file"`
 	CheckpointDir      string `mapstructure:"checkpoint_dir"`
 	CheckpointInterval string `mapstructure:"checkpoint_interval"`
+	// MerkleBatchSize > 0 hashes in Merkle mode: every N events form a Merkle tree
+	// whose root is chained, so single events can be proven (verify prove)
+	MerkleBatchSize int `mapstructure:"merkle_batch_size"`
+	// Canonicalization of newly hashed events: legacy (default) or jcs (RFC 8785)
+	Canonicalization string `mapstructure:"canonicalization"`
+	// StreamBy hashes events into independent named chains keyed by these event
+	// fields (e.g. db_system, host, source), each with its own state and checkpoints
+	StreamBy []string `mapstructure:"stream_by"`
+}
+
+// ExternalSignerCfg configures a checkpoint signer outside AuditR (HSM, KMS or
+// signing daemon). Exactly one of Command or Socket is set when it is used.
+type ExternalSignerCfg struct {
+	// Command is run per checkpoint with the payload on stdin; prints the base64 signature
+	Command []string `mapstructure:"command"`
+	// Socket is a Unix socket speaking the JSON line signing protocol
+	Socket string `mapstructure:"socket"`
+	// Algorithm of the signatures produced: ecdsa-p256-sha256, ed25519 or rsa-pss-sha256
+	Algorithm string `mapstructure:"algorithm"`
+	// KeyID recorded in each checkpoint
+	KeyID string `mapstructure:"key_id"`
+}
+
+// Enabled reports whether an external signer is configured
+func (e ExternalSignerCfg) Enabled() bool {
+	return len(e.Command) > 0 || e.Socket != ""
+}
+
+type SigningCfg struct {
+	PrivateKeyPath string            `mapstructure:"private_key_path"`
+	External       ExternalSignerCfg `mapstructure:"external"`
+}
+
+// TimestampingCfg configures RFC 3161 timestamping of checkpoints
+type TimestampingCfg struct {
+	// URL of the time-stamping authority; checkpoints carry a token when set
+	URL string `mapstructure:"url"`
+	// TSACert is the PEM certificate (TSA or its CA) tokens are verified against
+	TSACert string `mapstructure:"tsa_cert"`
+}
+
+// ArchiveCfg configures the sealed segment store of auditr archive
+type ArchiveCfg struct {
+	// Dir is the archive directory (segments, checkpoints and archive.json)
+	Dir string `mapstructure:"dir"`
+	// A segment is sealed once it holds SegmentMaxEvents events or SegmentMaxBytes
+	// bytes, or SegmentMaxAge (e.g. "24h", "7d") after it was opened; 0 / empty disables a limit
+	SegmentMaxEvents int    `mapstructure:"segment_max_events"`
+	SegmentMaxBytes  int64  `mapstructure:"segment_max_bytes"`
+	SegmentMaxAge    string `mapstructure:"segment_max_age"`
+	// Retention decides when sealed segments may be purged
+	Retention RetentionCfg `mapstructure:"retention"`
+}
+
+// RetentionCfg maps the retention classes and regulations of enriched events
+// to how long they are kept ("90d", "8760h", or "forever")
+type RetentionCfg struct {
+	// Default applies to events without a configured class or regulation (empty keeps them forever)
+	Default string `mapstructure:"default"`
+	// Classes by retention_classes value (e.g. short, standard, extended)
+	Classes map[string]string `mapstructure:"classes"`
+	// Regulations by regulations value (e.g. HIPAA, PCI-DSS)
+	Regulations map[string]string `mapstructure:"regulations"`
 }
 
 type EnrichmentCfg struct {
@@ -39,21 +102,22 @@ type InputCfg struct {
 }
 
 type OutputCfg struct {
-	Format     string `mapstructure:"format"`
-	Dir        string `mapstructure:"dir"`
-	RejectFile string `mapstructure:"reject_file"` // Path to file for storing rejected/skipped log entries
+	Format     string   `mapstructure:"format"` // Default output format of enrich: ndjson, json, csv, table or parquet
+	Fields     []string `mapstructure:"fields"` // Default enrich output columns, in order; empty writes every field
+	Dir        string   `mapstructure:"dir"`
+	RejectFile string   `mapstructure:"reject_file"` // Path to file for storing rejected/skipped log entries
 }
 
 type Config struct {
-	Version    string        `mapstructure:"version"`
-	Enrichment EnrichmentCfg `mapstructure:"enrichment"`
-	Hashing    HashingCfg    `mapstructure:"hashing"`
-	Signing    struct {
-		PrivateKeyPath string `mapstructure:"private_key_path"`
-	} `mapstructure:"signing"`
-	Output  OutputCfg  `mapstructure:"output"`
-	Input   InputCfg   `mapstructure:"input"`
-	Logging LoggingCfg `mapstructure:"logging"`
+	Version      string          `mapstructure:"version"`
+	Enrichment   EnrichmentCfg   `mapstructure:"enrichment"`
+	Hashing      HashingCfg      `mapstructure:"hashing"`
+	Signing      SigningCfg      `mapstructure:"signing"`
+	Timestamping TimestampingCfg `mapstructure:"timestamping"`
+	Archive      ArchiveCfg      `mapstructure:"archive"`
+	Output       OutputCfg       `mapstructure:"output"`
+	Input        InputCfg        `mapstructure:"input"`
+	Logging      LoggingCfg      `mapstructure:"logging"`
 }
 
 var cfg *Config
@@ -38,6 +38,7 @@ func TestLoad_FullConfig(t *testing.T) {
 	v.Set("hashing.checkpoint_interval", "1h")
 	v.Set("signing.private_key_path", "./private.pem")
 	v.Set("output.format", "csv")
+	v.Set("output.fields", []string{"timestamp", "db_user"})
 	v.Set("output.dir", "./output")
 	v.Set("output.reject_file", "./rejected.jsonl")
 	v.Set("input.mode", "file")
@@ -88,6 +89,9 @@ func TestLoad_FullConfig(t *testing.T) {
 	if cfg.Output.Format != "csv" {
 		t.Errorf("Format = %v, want csv", cfg.Output.Format)
 	}
+	if len(cfg.Output.Fields) != 2 || cfg.Output.Fields[1] != "db_user" {
+		t.Errorf("Fields = %v, want [timestamp db_user]", cfg.Output.Fields)
+	}
 	if cfg.Output.Dir != "./output" {
 		t.Errorf("Dir = %v, want ./output", cfg.Output.Dir)
 	}
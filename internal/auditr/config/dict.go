@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"regexp"
+	"sort"
+	"strings"
 )
 
 // PositiveRule = rule in sensitivity categories like PII/PHI/Financial
@@ -20,11 +22,43 @@ type NegativeRule struct {
 	Reason string `json:"reason"`
 }
 
+// CategoryMetadata = descriptive metadata for one sensitivity category, from the
+// reserved "Metadata" key of the dictionary. A category may have metadata without
+// rules (e.g. one only assigned through column overrides).
+type CategoryMetadata struct {
+	Description    string   `json:"description,omitempty"`
+	Regulations    []string `json:"regulations,omitempty"`     // e.g. GDPR-Art9, HIPAA, PCI-DSS
+	RetentionClass string   `json:"retention_class,omitempty"` // e.g. short, standard, extended
+	DefaultRisk    string   `json:"default_risk,omitempty"`    // Base risk used when risk scoring has none
+}
+
 // SensitivityDict = the full dictionary
-// Categories = PII, PHI, Financial, etc.
+// Categories = PII, PHI, Financial, Credentials, etc.
 type SensitivityDict struct {
 	Categories map[string][]PositiveRule
 	Negative   []NegativeRule
+	Metadata   map[string]CategoryMetadata
+}
+
+// MetadataFor returns the metadata of a category, if any.
+func (d *SensitivityDict) MetadataFor(category string) (CategoryMetadata, bool) {
+	m, ok := d.Metadata[category]
+	return m, ok
+}
+
+// KnownCategories returns the rule categories plus any metadata-only categories.
+func (d *SensitivityDict) KnownCategories() []string {
+	var names []string
+	for name := range d.Categories {
+		names = append(names, name)
+	}
+	for name := range d.Metadata {
+		if _, ok := d.Categories[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
 
 // RiskScoring model
@@ -50,7 +84,13 @@ func ValidateDict(r io.Reader) (*SensitivityDict, []string, error) {
 	var categories []string
 
 	for category, msg := range raw {
-		if category == "Negative" {
+		if category == "Metadata" {
+			metadata, err := validateMetadata(msg)
+			if err != nil {
+				return nil, nil, err
+			}
+			dict.Metadata = metadata
+		} else if category == "Negative" {
 			var rules []NegativeRule
 			if err := json.Unmarshal(msg, &rules); err != nil {
 				return nil, nil, fmt.Errorf("decode Negative rules: %w", err)
@@ -71,6 +111,9 @@ func ValidateDict(r io.Reader) (*SensitivityDict, []string, error) {
 			}
 			dict.Negative = rules
 		} else {
+			if err := checkCategoryName(category); err != nil {
+				return nil, nil, err
+			}
 			var rules []PositiveRule
 			if err := json.Unmarshal(msg, &rules); err != nil {
 				return nil, nil, fmt.Errorf("decode %s rules: %w", category, err)
@@ -101,13 +144,71 @@ func ValidateDict(r io.Reader) (*SensitivityDict, []string, error) {
 	return dict, categories, nil
 }
 
+// checkCategoryName rejects names that would break "Category:column" sensitivity
+// entries or "A+B" combination keys.
+func checkCategoryName(name string) error {
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(name, "+:") {
+		return fmt.Errorf("invalid category name %q: must be non-empty and not contain '+' or ':'", name)
+	}
+	return nil
+}
+
+// validateMetadata decodes and validates the reserved "Metadata" dictionary key
+func validateMetadata(msg json.RawMessage) (map[string]CategoryMetadata, error) {
+	var metadata map[string]CategoryMetadata
+	dec := json.NewDecoder(strings.NewReader(string(msg)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("decode Metadata: %w", err)
+	}
+	for category, m := range metadata {
+		if err := checkCategoryName(category); err != nil {
+			return nil, fmt.Errorf("Metadata: %w", err)
+		}
+		if m.DefaultRisk != "" {
+			if _, ok := allowedRisks[m.DefaultRisk]; !ok {
+				return nil, fmt.Errorf("invalid risk level %q in Metadata[%s].default_risk", m.DefaultRisk, category)
+			}
+		}
+		for i, reg := range m.Regulations {
+			if strings.TrimSpace(reg) == "" {
+				return nil, fmt.Errorf("Metadata[%s]: regulation %d is empty", category, i)
+			}
+		}
+	}
+	return metadata, nil
+}
+
 // ValidateRiskScoring validates risk_scoring.json and cross-checks with dict categories
 func ValidateRiskScoring(r io.Reader, categories []string) (*RiskScoring, error) {
 	var rs RiskScoring
 	if err := json.NewDecoder(r).Decode(&rs); err != nil {
 		return nil, fmt.Errorf("failed to decode risk scoring JSON: %w", err)
 	}
+	return validateRiskScoring(&rs, categories)
+}
+
+// ValidateRiskScoringWithMetadata validates risk_scoring.json like ValidateRiskScoring,
+// but categories without a base entry take their default_risk from the dictionary
+// metadata. Only categories with neither are reported as missing.
+func ValidateRiskScoringWithMetadata(r io.Reader, categories []string, metadata map[string]CategoryMetadata) (*RiskScoring, error) {
+	var rs RiskScoring
+	if err := json.NewDecoder(r).Decode(&rs); err != nil {
+		return nil, fmt.Errorf("failed to decode risk scoring JSON: %w", err)
+	}
+	if rs.Base == nil {
+		rs.Base = map[string]string{}
+	}
+	for category, m := range metadata {
+		if _, ok := rs.Base[category]; !ok && m.DefaultRisk != "" {
+			rs.Base[category] = m.DefaultRisk
+		}
+	}
+	return validateRiskScoring(&rs, categories)
+}
 
+// validateRiskScoring checks risk levels and that every category has a base risk
+func validateRiskScoring(rs *RiskScoring, categories []string) (*RiskScoring, error) {
 	if len(rs.Base) == 0 {
 		return nil, fmt.Errorf("risk scoring 'base' must not be empty")
 	}
@@ -143,5 +244,5 @@ func ValidateRiskScoring(r io.Reader, categories []string) (*RiskScoring, error)
 		}
 	}
 
-	return &rs, nil
+	return rs, nil
 }
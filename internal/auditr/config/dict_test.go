@@ -56,3 +56,67 @@ func TestInvalidRiskScoringMissingCategory(t *testing.T) {
 		t.Errorf("expected error for missing category in risk scoring")
 	}
 }
+
+func TestValidateDictMetadata(t *testing.T) {
+	dict := `{
+		"Credentials": [{ "regex": "(?i)^api_key$", "expected_types": ["VARCHAR"] }],
+		"PII": [{ "regex": "(?i)^ssn$", "expected_types": ["VARCHAR"] }],
+		"Metadata": {
+			"Credentials": { "regulations": ["SOC2"], "retention_class": "short", "default_risk": "high" },
+			"GDPR-Special": { "regulations": ["GDPR-Art9"], "default_risk": "critical" }
+		}
+	}`
+
+	d, cats, err := ValidateDict(strings.NewReader(dict))
+	if err != nil {
+		t.Fatalf("dict validation failed: %v", err)
+	}
+	if len(cats) != 2 {
+		t.Errorf("expected 2 rule categories, got %v", cats)
+	}
+	m, ok := d.MetadataFor("Credentials")
+	if !ok || m.RetentionClass != "short" || m.Regulations[0] != "SOC2" {
+		t.Errorf("unexpected Credentials metadata: %+v", m)
+	}
+	known := strings.Join(d.KnownCategories(), ",")
+	if known != "Credentials,GDPR-Special,PII" {
+		t.Errorf("KnownCategories() = %s", known)
+	}
+
+	// default_risk fills missing base entries; explicit base entries win
+	risk := `{"base":{"PII":"medium","Credentials":"medium"},"default":"low"}`
+	rs, err := ValidateRiskScoringWithMetadata(strings.NewReader(risk), cats, d.Metadata)
+	if err != nil {
+		t.Fatalf("risk validation failed: %v", err)
+	}
+	if rs.Base["Credentials"] != "medium" {
+		t.Errorf("base Credentials = %s, want medium", rs.Base["Credentials"])
+	}
+	if rs.Base["GDPR-Special"] != "critical" {
+		t.Errorf("base GDPR-Special = %s, want critical", rs.Base["GDPR-Special"])
+	}
+
+	// Without a base entry or default_risk the category is still reported missing
+	_, err = ValidateRiskScoringWithMetadata(strings.NewReader(`{"base":{"Credentials":"high"},"default":"low"}`), cats, d.Metadata)
+	if err == nil {
+		t.Errorf("expected error for PII without base risk or default_risk")
+	}
+}
+
+func TestValidateDictMetadataInvalid(t *testing.T) {
+	rules := `"PII": [{ "regex": "x", "expected_types": ["VARCHAR"] }]`
+	tests := map[string]string{
+		"invalid default risk": `{` + rules + `, "Metadata": {"PII": {"default_risk": "severe"}}}`,
+		"unknown field":        `{` + rules + `, "Metadata": {"PII": {"retention": "short"}}}`,
+		"empty regulation":     `{` + rules + `, "Metadata": {"PII": {"regulations": [""]}}}`,
+		"plus in metadata":     `{` + rules + `, "Metadata": {"PII+PHI": {}}}`,
+		"colon in category":    `{"PII:x": [{ "regex": "x", "expected_types": ["VARCHAR"] }]}`,
+	}
+	for name, dict := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := ValidateDict(strings.NewReader(dict)); err == nil {
+				t.Errorf("expected error for %s", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ColumnOverride forces the classification of one column, bypassing the regex rules.
+// Column is "db.schema.table.column"; any segment may be "*" to match all values.
+type ColumnOverride struct {
+	Column        string   `json:"column"`
+	Categories    []string `json:"categories,omitempty"`    // Forced categories (dictionary or custom)
+	NotSensitive  bool     `json:"not_sensitive,omitempty"` // Column is never sensitive
+	Justification string   `json:"justification"`
+}
+
+// ColumnOverrides = the full overrides file
+type ColumnOverrides struct {
+	Overrides []ColumnOverride `json:"overrides"`
+}
+
+// Parts splits the override column key into db, schema, table and column.
+func (o ColumnOverride) Parts() []string {
+	return strings.Split(o.Column, ".")
+}
+
+// CustomCategories returns override categories that are not in the dictionary categories, sorted by first use.
+func (co *ColumnOverrides) CustomCategories(categories []string) []string {
+	known := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		known[c] = true
+	}
+	var custom []string
+	for _, o := range co.Overrides {
+		for _, c := range o.Categories {
+			if !known[c] {
+				known[c] = true
+				custom = append(custom, c)
+			}
+		}
+	}
+	return custom
+}
+
+// ValidateOverrides validates the column overrides JSON
+func ValidateOverrides(r io.Reader) (*ColumnOverrides, error) {
+	var co ColumnOverrides
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&co); err != nil {
+		return nil, fmt.Errorf("failed to decode overrides JSON: %w", err)
+	}
+
+	seen := make(map[string]int)
+	for i, o := range co.Overrides {
+		parts := o.Parts()
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("override %d: column %q must be db.schema.table.column", i, o.Column)
+		}
+		for _, p := range parts {
+			if strings.TrimSpace(p) == "" {
+				return nil, fmt.Errorf("override %d: column %q has an empty segment", i, o.Column)
+			}
+		}
+		if o.NotSensitive == (len(o.Categories) > 0) {
+			return nil, fmt.Errorf("override %d (%s): set either categories or not_sensitive", i, o.Column)
+		}
+		for _, c := range o.Categories {
+			if strings.TrimSpace(c) == "" || strings.ContainsAny(c, "+:") {
+				return nil, fmt.Errorf("override %d (%s): invalid category %q", i, o.Column, c)
+			}
+		}
+		if strings.TrimSpace(o.Justification) == "" {
+			return nil, fmt.Errorf("override %d (%s): missing justification", i, o.Column)
+		}
+
+		key := strings.ToLower(o.Column)
+		if prev, dup := seen[key]; dup {
+			return nil, fmt.Errorf("override %d: column %q already overridden by override %d", i, o.Column, prev)
+		}
+		seen[key] = i
+	}
+
+	return &co, nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateOverrides(t *testing.T) {
+	valid := `{
+		"overrides": [
+			{"column": "db.network.sessions.ip_address", "not_sensitive": true, "justification": "not a postal address"},
+			{"column": "*.auth.users.pwd", "categories": ["Credentials"], "justification": "password hashes"},
+			{"column": "db.hc.encounter.notes", "categories": ["PHI"], "justification": "clinical notes"}
+		]
+	}`
+
+	overrides, err := ValidateOverrides(strings.NewReader(valid))
+	if err != nil {
+		t.Fatalf("overrides validation failed: %v", err)
+	}
+	if len(overrides.Overrides) != 3 {
+		t.Errorf("expected 3 overrides, got %d", len(overrides.Overrides))
+	}
+
+	custom := overrides.CustomCategories([]string{"PII", "PHI", "Financial"})
+	if len(custom) != 1 || custom[0] != "Credentials" {
+		t.Errorf("expected custom category Credentials, got %v", custom)
+	}
+}
+
+func TestValidateOverridesInvalid(t *testing.T) {
+	tests := map[string]string{
+		"short_key":        `{"overrides":[{"column":"users.ssn","categories":["PII"],"justification":"x"}]}`,
+		"empty_segment":    `{"overrides":[{"column":"db..users.ssn","categories":["PII"],"justification":"x"}]}`,
+		"both_set":         `{"overrides":[{"column":"db.s.users.ssn","categories":["PII"],"not_sensitive":true,"justification":"x"}]}`,
+		"neither_set":      `{"overrides":[{"column":"db.s.users.ssn","justification":"x"}]}`,
+		"no_justification": `{"overrides":[{"column":"db.s.users.ssn","categories":["PII"]}]}`,
+		"bad_category":     `{"overrides":[{"column":"db.s.users.ssn","categories":["PII+PHI"],"justification":"x"}]}`,
+		"duplicate":        `{"overrides":[{"column":"db.s.users.ssn","categories":["PII"],"justification":"x"},{"column":"DB.s.users.ssn","not_sensitive":true,"justification":"y"}]}`,
+		"unknown_field":    `{"overrides":[{"column":"db.s.users.ssn","category":"PII","justification":"x"}]}`,
+		"not_json":         `overrides`,
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ValidateOverrides(strings.NewReader(input)); err == nil {
+				t.Errorf("expected error for %s", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package enrich
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+)
+
+// DDL patterns used to replay table changes seen in the audit stream.
+// Like ParseQuery, these are regex heuristics for common statements rather than a full SQL parser.
+var (
+	createTablePattern = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:(?:GLOBAL\s+|LOCAL\s+)?(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(]+)\s*\((.*)\)`)
+	alterTablePattern  = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?([^\s]+)\s+(.*)$`)
+	dropTablePattern   = regexp.MustCompile(`(?is)^\s*DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?(.*?)(?:\s+(?:CASCADE|RESTRICT))?\s*$`)
+	renameTablePattern = regexp.MustCompile(`(?is)^\s*RENAME\s+TABLE\s+(.*)$`)
+
+	alterAddPattern          = regexp.MustCompile(`(?is)^ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(\S+)\s+(.+)$`)
+	alterDropPattern         = regexp.MustCompile(`(?is)^DROP\s+(?:COLUMN\s+)?(?:IF\s+EXISTS\s+)?(\S+)`)
+	alterRenameColumnPattern = regexp.MustCompile(`(?is)^RENAME\s+(?:COLUMN\s+)?(\S+)\s+TO\s+(\S+)$`)
+	alterRenameTablePattern  = regexp.MustCompile(`(?is)^RENAME\s+(?:TO|AS)\s+(\S+)$`)
+	alterTypePattern         = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?(\S+)\s+(?:SET\s+DATA\s+)?TYPE\s+(.+)$`)
+	alterModifyPattern       = regexp.MustCompile(`(?is)^MODIFY\s+(?:COLUMN\s+)?(\S+)\s+(.+)$`)
+	alterChangePattern       = regexp.MustCompile(`(?is)^CHANGE\s+(?:COLUMN\s+)?(\S+)\s+(\S+)\s+(.+)$`)
+)
+
+// columnConstraintWords terminate a column type in a column definition.
+var columnConstraintWords = map[string]bool{
+	"NOT": true, "NULL": true, "DEFAULT": true, "PRIMARY": true, "REFERENCES": true,
+	"UNIQUE": true, "CHECK": true, "CONSTRAINT": true, "COLLATE": true, "GENERATED": true,
+	"AUTO_INCREMENT": true, "COMMENT": true, "USING": true,
+}
+
+// tableConstraintWords start table-level constraints inside CREATE TABLE bodies.
+var tableConstraintWords = map[string]bool{
+	"CONSTRAINT": true, "PRIMARY": true, "FOREIGN": true, "UNIQUE": true,
+	"CHECK": true, "INDEX": true, "KEY": true, "EXCLUDE": true, "FULLTEXT": true, "SPATIAL": true, "LIKE": true,
+}
+
+// applyDDL applies a CREATE/ALTER/DROP/RENAME TABLE statement to the schema in place.
+// Returns true if the schema was changed.
+func applyDDL(schema SchemaMap, query, defaultSchema string) bool {
+	stmt := strings.TrimSuffix(strings.TrimSpace(cleanSQLComments(query)), ";")
+	if stmt == "" {
+		return false
+	}
+
+	if m := createTablePattern.FindStringSubmatch(stmt); m != nil {
+		return applyCreateTable(schema, m[1], m[2], defaultSchema)
+	}
+	if m := alterTablePattern.FindStringSubmatch(stmt); m != nil {
+		return applyAlterTable(schema, m[1], m[2])
+	}
+	if m := dropTablePattern.FindStringSubmatch(stmt); m != nil {
+		changed := false
+		for _, name := range splitTopLevel(m[1]) {
+			schemaName, tableName, ok := locateTable(schema, name)
+			if !ok {
+				continue
+			}
+			delete(schema[schemaName], tableName)
+			changed = true
+			logger.L().Debugw("DDL replay: dropped table", "schema", schemaName, "table", tableName)
+		}
+		return changed
+	}
+	if m := renameTablePattern.FindStringSubmatch(stmt); m != nil {
+		// MySQL: RENAME TABLE a TO b [, c TO d]
+		changed := false
+		for _, pair := range splitTopLevel(m[1]) {
+			fields := strings.Fields(pair)
+			if len(fields) != 3 || !strings.EqualFold(fields[1], "TO") {
+				continue
+			}
+			if renameTable(schema, fields[0], fields[2]) {
+				changed = true
+			}
+		}
+		return changed
+	}
+
+	return false
+}
+
+// applyCreateTable registers a new table with the columns from its definition body.
+func applyCreateTable(schema SchemaMap, name, body, defaultSchema string) bool {
+	schemaName, tableName := splitQualifiedName(name)
+	if schemaName == "" {
+		schemaName = defaultSchema
+	}
+
+	columns := make(map[string]string)
+	for _, def := range splitTopLevel(body) {
+		colName, colType, ok := parseColumnDefinition(def)
+		if !ok {
+			continue
+		}
+		columns[colName] = colType
+	}
+	if len(columns) == 0 {
+		return false
+	}
+
+	if schema[schemaName] == nil {
+		schema[schemaName] = make(map[string]map[string]string)
+	}
+	schema[schemaName][tableName] = columns
+
+	logger.L().Debugw("DDL replay: created table",
+		"schema", schemaName,
+		"table", tableName,
+		"columns", len(columns))
+	return true
+}
+
+// applyAlterTable applies each comma-separated action of an ALTER TABLE statement.
+func applyAlterTable(schema SchemaMap, name, actions string) bool {
+	schemaName, tableName, ok := locateTable(schema, name)
+	if !ok {
+		logger.L().Debugw("DDL replay: ALTER TABLE on unknown table", "table", name)
+		return false
+	}
+
+	changed := false
+	for _, action := range splitTopLevel(actions) {
+		action = strings.TrimSpace(action)
+		columns := schema[schemaName][tableName]
+
+		switch {
+		case alterRenameTablePattern.MatchString(action):
+			m := alterRenameTablePattern.FindStringSubmatch(action)
+			if renameTable(schema, schemaName+"."+tableName, m[1]) {
+				newSchema, newTable := splitQualifiedName(m[1])
+				if newSchema != "" {
+					schemaName = newSchema
+				}
+				tableName = newTable
+				changed = true
+			}
+		case alterRenameColumnPattern.MatchString(action):
+			m := alterRenameColumnPattern.FindStringSubmatch(action)
+			oldName, newName := unquoteIdent(m[1]), unquoteIdent(m[2])
+			if typ, exists := columns[oldName]; exists {
+				delete(columns, oldName)
+				columns[newName] = typ
+				changed = true
+			}
+		case alterTypePattern.MatchString(action):
+			m := alterTypePattern.FindStringSubmatch(action)
+			colName := unquoteIdent(m[1])
+			if _, exists := columns[colName]; exists {
+				columns[colName] = extractColumnType(m[2])
+				changed = true
+			}
+		case alterChangePattern.MatchString(action):
+			m := alterChangePattern.FindStringSubmatch(action)
+			oldName, newName := unquoteIdent(m[1]), unquoteIdent(m[2])
+			if _, exists := columns[oldName]; exists {
+				delete(columns, oldName)
+				columns[newName] = extractColumnType(m[3])
+				changed = true
+			}
+		case alterModifyPattern.MatchString(action):
+			m := alterModifyPattern.FindStringSubmatch(action)
+			colName := unquoteIdent(m[1])
+			if _, exists := columns[colName]; exists {
+				columns[colName] = extractColumnType(m[2])
+				changed = true
+			}
+		case alterDropPattern.MatchString(action):
+			m := alterDropPattern.FindStringSubmatch(action)
+			colName := unquoteIdent(m[1])
+			if tableConstraintWords[strings.ToUpper(colName)] {
+				continue // DROP CONSTRAINT / DROP PRIMARY KEY etc.
+			}
+			if _, exists := columns[colName]; exists {
+				delete(columns, colName)
+				changed = true
+			}
+		case alterAddPattern.MatchString(action):
+			m := alterAddPattern.FindStringSubmatch(action)
+			colName := unquoteIdent(m[1])
+			if tableConstraintWords[strings.ToUpper(colName)] {
+				continue // ADD CONSTRAINT / ADD PRIMARY KEY etc.
+			}
+			columns[colName] = extractColumnType(m[2])
+			changed = true
+		}
+	}
+
+	if changed {
+		logger.L().Debugw("DDL replay: altered table",
+			"schema", schemaName,
+			"table", tableName,
+			"actions", actions)
+	}
+	return changed
+}
+
+// renameTable moves a table to a new name, keeping its columns.
+func renameTable(schema SchemaMap, from, to string) bool {
+	schemaName, tableName, ok := locateTable(schema, from)
+	if !ok {
+		return false
+	}
+	newSchema, newTable := splitQualifiedName(to)
+	if newSchema == "" {
+		newSchema = schemaName
+	}
+	if schema[newSchema] == nil {
+		schema[newSchema] = make(map[string]map[string]string)
+	}
+	schema[newSchema][newTable] = schema[schemaName][tableName]
+	delete(schema[schemaName], tableName)
+
+	logger.L().Debugw("DDL replay: renamed table",
+		"from", schemaName+"."+tableName,
+		"to", newSchema+"."+newTable)
+	return true
+}
+
+// locateTable finds the schema containing a (possibly schema-qualified) table name.
+func locateTable(schema SchemaMap, name string) (string, string, bool) {
+	schemaName, tableName := splitQualifiedName(name)
+	if schemaName != "" {
+		if _, exists := schema[schemaName][tableName]; exists {
+			return schemaName, tableName, true
+		}
+		return "", "", false
+	}
+	for s, tables := range schema {
+		if _, exists := tables[tableName]; exists {
+			return s, tableName, true
+		}
+	}
+	return "", "", false
+}
+
+// parseColumnDefinition extracts the column name and normalized type from a
+// CREATE TABLE column definition. Table-level constraints are rejected.
+func parseColumnDefinition(def string) (string, string, bool) {
+	fields := strings.Fields(strings.TrimSpace(def))
+	if len(fields) < 2 {
+		return "", "", false
+	}
+	if tableConstraintWords[strings.ToUpper(fields[0])] {
+		return "", "", false
+	}
+	name := unquoteIdent(fields[0])
+	colType := extractColumnType(strings.Join(fields[1:], " "))
+	if name == "" || colType == "" {
+		return "", "", false
+	}
+	return name, colType, true
+}
+
+// extractColumnType takes the type portion of a column definition (stopping at
+// constraint keywords) and normalizes it with normalizeColumnType.
+func extractColumnType(def string) string {
+	var typeWords []string
+	depth := 0
+	for _, word := range strings.Fields(def) {
+		if depth == 0 && columnConstraintWords[strings.ToUpper(word)] {
+			break
+		}
+		depth += strings.Count(word, "(") - strings.Count(word, ")")
+		typeWords = append(typeWords, word)
+	}
+	return normalizeColumnType(strings.Join(typeWords, " "))
+}
+
+// splitTopLevel splits s on commas that are not nested inside parentheses or quotes.
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth := 0
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(s[start:]); rest != "" {
+		parts = append(parts, rest)
+	}
+	return parts
+}
+
+// splitQualifiedName splits "schema.table" into its parts, stripping identifier quotes.
+// Unqualified names return an empty schema.
+func splitQualifiedName(name string) (string, string) {
+	parts := strings.Split(strings.TrimSpace(name), ".")
+	if len(parts) == 1 {
+		return "", unquoteIdent(parts[0])
+	}
+	return unquoteIdent(parts[len(parts)-2]), unquoteIdent(parts[len(parts)-1])
+}
+
+// unquoteIdent strips double quotes and backticks around an SQL identifier.
+func unquoteIdent(s string) string {
+	return strings.Trim(strings.TrimSpace(s), "\"`")
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/vaibhaw-/AuditR/internal/auditr/config"
@@ -19,6 +20,9 @@ type CompiledRule struct {
 
 	// Compiled regex for efficient matching
 	CompiledRegex *regexp.Regexp `json:"-"`
+
+	// Override is set when the match comes from a column override instead of the regex
+	Override *config.ColumnOverride `json:"-"`
 }
 
 // CompiledNegativeRule represents a negative (exclusion) rule with compiled regex
@@ -32,7 +36,7 @@ type CompiledNegativeRule struct {
 
 // CompiledSensitivityDict represents the sensitivity dictionary with compiled regexes
 type CompiledSensitivityDict struct {
-	// Categories maps category names (PII, PHI, Financial) to their rules
+	// Categories maps category names (PII, PHI, Financial, ...) to their rules
 	Categories map[string][]CompiledRule
 
 	// Negative rules for exclusions
@@ -40,6 +44,12 @@ type CompiledSensitivityDict struct {
 
 	// CategoryNames provides a sorted list of category names for consistent processing
 	CategoryNames []string
+
+	// Metadata holds per-category regulations, retention class and default risk
+	Metadata map[string]config.CategoryMetadata
+
+	// Overrides force the classification of specific columns (see SetOverrides)
+	overrides []config.ColumnOverride
 }
 
 // LoadDict loads and validates the sensitivity dictionary from a file path.
@@ -68,6 +78,15 @@ func LoadDict(path string) (*CompiledSensitivityDict, error) {
 	compiledDict := &CompiledSensitivityDict{
 		Categories:    make(map[string][]CompiledRule),
 		CategoryNames: categoryNames,
+		Metadata:      dict.Metadata,
+	}
+
+	for categoryName, m := range dict.Metadata {
+		logger.L().Debugw("Loaded category metadata",
+			"category", categoryName,
+			"regulations", strings.Join(m.Regulations, ","),
+			"retention_class", m.RetentionClass,
+			"default_risk", m.DefaultRisk)
 	}
 
 	// Compile positive rules for each category
@@ -134,6 +153,17 @@ func LoadDict(path string) (*CompiledSensitivityDict, error) {
 // LoadRisk loads and validates the risk scoring configuration from a file path.
 // It uses the existing config.ValidateRiskScoring function.
 func LoadRisk(path string, categoryNames []string) (*config.RiskScoring, error) {
+	return loadRisk(path, categoryNames, nil)
+}
+
+// LoadRiskForDict loads the risk scoring configuration for a compiled dictionary.
+// Categories missing from the risk "base" mapping use the default_risk from the
+// dictionary metadata, so new categories don't require a risk_scoring.json change.
+func LoadRiskForDict(path string, dict *CompiledSensitivityDict) (*config.RiskScoring, error) {
+	return loadRisk(path, dict.CategoryNames, dict.Metadata)
+}
+
+func loadRisk(path string, categoryNames []string, metadata map[string]config.CategoryMetadata) (*config.RiskScoring, error) {
 	logger.L().Debugw("Loading risk scoring configuration", "path", path)
 
 	// Open and read the risk scoring file
@@ -144,7 +174,7 @@ func LoadRisk(path string, categoryNames []string) (*config.RiskScoring, error)
 	defer file.Close()
 
 	// Use the existing validation function from config package
-	riskScoring, err := config.ValidateRiskScoring(file, categoryNames)
+	riskScoring, err := config.ValidateRiskScoringWithMetadata(file, categoryNames, metadata)
 	if err != nil {
 		return nil, fmt.Errorf("failed to validate risk scoring: %w", err)
 	}
@@ -170,6 +200,138 @@ func LoadRisk(path string, categoryNames []string) (*config.RiskScoring, error)
 	return riskScoring, nil
 }
 
+// LoadOverrides loads and validates a column overrides file from a file path.
+// It uses the config.ValidateOverrides function.
+func LoadOverrides(path string) ([]config.ColumnOverride, error) {
+	logger.L().Debugw("Loading column overrides", "path", path)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open overrides file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	overrides, err := config.ValidateOverrides(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate overrides: %w", err)
+	}
+
+	for _, o := range overrides.Overrides {
+		logger.L().Debugw("Loaded column override",
+			"column", o.Column,
+			"categories", strings.Join(o.Categories, ","),
+			"not_sensitive", o.NotSensitive,
+			"justification", o.Justification)
+	}
+
+	return overrides.Overrides, nil
+}
+
+// LoadCompliance loads and validates a compliance framework mapping file from a file path.
+// It uses the config.ValidateCompliance function.
+func LoadCompliance(path string) (*config.ComplianceMapping, error) {
+	logger.L().Debugw("Loading compliance mapping", "path", path)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open compliance mapping file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	mapping, err := config.ValidateCompliance(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate compliance mapping: %w", err)
+	}
+
+	for _, key := range mapping.FrameworkKeys() {
+		logger.L().Debugw("Loaded compliance framework",
+			"framework", key,
+			"name", mapping.Frameworks[key].Name,
+			"controls", len(mapping.Frameworks[key].Controls))
+	}
+
+	return mapping, nil
+}
+
+// SetOverrides installs column overrides. They are checked by FindMatches before any
+// regex rule: a not_sensitive override suppresses all matches, a categories override
+// replaces them. Categories that are not in the dictionary (custom categories) are allowed.
+func (cd *CompiledSensitivityDict) SetOverrides(overrides []config.ColumnOverride) {
+	cd.overrides = overrides
+}
+
+// OverrideFor returns the override applying to a column location, if any.
+// Exact segments take precedence over "*" segments; on a tie the first override wins.
+// An empty database (schema loaded without db_name) matches any database segment.
+func (cd *CompiledSensitivityDict) OverrideFor(column ColumnSource) (*config.ColumnOverride, bool) {
+	location := []string{column.Database, column.Schema, column.Table, column.Column}
+
+	var best *config.ColumnOverride
+	bestWildcards := 0
+	for i := range cd.overrides {
+		o := &cd.overrides[i]
+		parts := o.Parts()
+		wildcards := 0
+		matched := true
+		for j, part := range parts {
+			switch {
+			case part == "*":
+				wildcards++
+			case j == 0 && location[0] == "":
+				// Unknown database: the override's database can't be contradicted
+			case !strings.EqualFold(part, location[j]):
+				matched = false
+			}
+			if !matched {
+				break
+			}
+		}
+		if matched && (best == nil || wildcards < bestWildcards) {
+			best, bestWildcards = o, wildcards
+		}
+	}
+	return best, best != nil
+}
+
+// MissingOverrideColumns returns the overrides whose db.schema.table.column location
+// matches no column in the catalog, e.g. after a column was renamed or dropped.
+// "*" segments match any value.
+func MissingOverrideColumns(catalog SchemaCatalog, overrides []config.ColumnOverride) []config.ColumnOverride {
+	segmentMatches := func(pattern, value string) bool {
+		return pattern == "*" || strings.EqualFold(pattern, value)
+	}
+
+	var missing []config.ColumnOverride
+	for _, o := range overrides {
+		parts := o.Parts()
+		found := false
+		for dbName, schema := range catalog {
+			if !segmentMatches(parts[0], dbName) && dbName != "" {
+				continue
+			}
+			for schemaName, tables := range schema {
+				if !segmentMatches(parts[1], schemaName) {
+					continue
+				}
+				for tableName, columns := range tables {
+					if !segmentMatches(parts[2], tableName) {
+						continue
+					}
+					for columnName := range columns {
+						if segmentMatches(parts[3], columnName) {
+							found = true
+						}
+					}
+				}
+			}
+		}
+		if !found {
+			missing = append(missing, o)
+		}
+	}
+	return missing
+}
+
 // MatchColumn checks if a column name matches any sensitivity rules for a given category.
 // It returns true if the column matches and the column type is in the expected types.
 func (cd *CompiledSensitivityDict) MatchColumn(categoryName, columnName, columnType string) bool {
@@ -212,9 +374,25 @@ func (cd *CompiledSensitivityDict) IsNegativeMatch(columnName string) (bool, str
 
 // FindMatches finds all sensitivity categories that match a given column.
 // It returns a map of category names to the specific rules that matched.
-// Negative rules are applied to exclude matches.
-func (cd *CompiledSensitivityDict) FindMatches(columnName, columnType string) map[string][]CompiledRule {
+// Column overrides for the column's db.schema.table.column location are applied
+// first; otherwise negative rules are applied to exclude regex matches.
+func (cd *CompiledSensitivityDict) FindMatches(column ColumnSource, columnType string) map[string][]CompiledRule {
 	matches := make(map[string][]CompiledRule)
+	columnName := column.Column
+
+	// Explicit overrides win over all rules
+	if override, ok := cd.OverrideFor(column); ok {
+		for _, category := range override.Categories {
+			matches[category] = append(matches[category], CompiledRule{Override: override})
+		}
+		logger.L().Debugw("Column classified by override",
+			"column", columnName,
+			"override", override.Column,
+			"categories", strings.Join(override.Categories, ","),
+			"not_sensitive", override.NotSensitive,
+			"justification", override.Justification)
+		return matches
+	}
 
 	// First check if this column should be excluded by negative rules
 	if isNegative, reason := cd.IsNegativeMatch(columnName); isNegative {
@@ -265,6 +443,43 @@ func (cd *CompiledSensitivityDict) FindMatches(columnName, columnType string) ma
 	return matches
 }
 
+// Regulations returns the sorted, de-duplicated regulation tags of the given categories.
+// Categories without metadata contribute nothing.
+func (cd *CompiledSensitivityDict) Regulations(categories []string) []string {
+	return cd.collectMetadata(categories, func(m config.CategoryMetadata) []string {
+		return m.Regulations
+	})
+}
+
+// RetentionClasses returns the sorted, de-duplicated retention classes of the given categories.
+func (cd *CompiledSensitivityDict) RetentionClasses(categories []string) []string {
+	return cd.collectMetadata(categories, func(m config.CategoryMetadata) []string {
+		if m.RetentionClass == "" {
+			return nil
+		}
+		return []string{m.RetentionClass}
+	})
+}
+
+func (cd *CompiledSensitivityDict) collectMetadata(categories []string, values func(config.CategoryMetadata) []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, category := range categories {
+		m, ok := cd.Metadata[category]
+		if !ok {
+			continue
+		}
+		for _, v := range values(m) {
+			if !seen[v] {
+				seen[v] = true
+				result = append(result, v)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
 // GetCategoryNames returns the list of category names in the dictionary.
 func (cd *CompiledSensitivityDict) GetCategoryNames() []string {
 	return cd.CategoryNames
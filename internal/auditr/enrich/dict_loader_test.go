@@ -5,10 +5,13 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
 )
 
 func TestLoadDict(t *testing.T) {
@@ -132,7 +135,7 @@ func TestLoadDict_RealFile(t *testing.T) {
 	require.NotNil(t, dict)
 
 	// Check that expected categories exist
-	expectedCategories := []string{"PII", "PHI", "Financial"}
+	expectedCategories := []string{"PII", "PHI", "Financial", "Credentials", "Biometric", "GDPR-Special", "PCI-CHD"}
 	for _, category := range expectedCategories {
 		assert.Contains(t, dict.Categories, category, "Expected category %s not found", category)
 		assert.NotEmpty(t, dict.Categories[category], "Category %s has no rules", category)
@@ -168,7 +171,20 @@ func TestLoadDict_RealFile(t *testing.T) {
 
 		// Test card_last4 matching (Financial)
 		assert.True(t, dict.MatchColumn("Financial", "card_last4", "CHAR"))
+
+		// Custom categories
+		assert.True(t, dict.MatchColumn("Credentials", "password_hash", "VARCHAR"))
+		assert.True(t, dict.MatchColumn("PCI-CHD", "card_number", "VARCHAR"))
 	})
+
+	// Every category carries metadata, and the real risk file covers all of them
+	for _, category := range dict.CategoryNames {
+		assert.Contains(t, dict.Metadata, category)
+	}
+	risk, err := LoadRiskForDict(filepath.Join("..", "..", "..", "cmd", "auditr", "config", "risk_scoring.json"), dict)
+	require.NoError(t, err)
+	assert.Equal(t, "critical", risk.Base["PCI-CHD"])
+	assert.Equal(t, "medium", risk.Base["PII"])
 }
 
 func TestLoadRisk(t *testing.T) {
@@ -450,7 +466,7 @@ func TestCompiledSensitivityDict_FindMatches(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			matches := dict.FindMatches(tt.columnName, tt.columnType)
+			matches := dict.FindMatches(ColumnSource{Column: tt.columnName}, tt.columnType)
 			assert.Len(t, matches, tt.expectedCount)
 
 			for _, expectedCategory := range tt.expectedCategories {
@@ -472,3 +488,196 @@ func TestLoadRisk_FileNotFound(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to open risk scoring file")
 }
+
+func TestCompiledSensitivityDict_Overrides(t *testing.T) {
+	dict := &CompiledSensitivityDict{
+		Categories: map[string][]CompiledRule{
+			"PII": {{Regex: "(?i)address", ExpectedTypes: []string{"VARCHAR", "INET"}, CompiledRegex: regexp.MustCompile("(?i)address")}},
+		},
+		CategoryNames: []string{"PII"},
+	}
+	dict.SetOverrides([]config.ColumnOverride{
+		{Column: "db.network.sessions.ip_address", NotSensitive: true, Justification: "not a postal address"},
+		{Column: "*.auth.*.pwd", Categories: []string{"Credentials"}, Justification: "password hashes"},
+		{Column: "*.crm.*.notes", Categories: []string{"PII"}, Justification: "free text"},
+		{Column: "db.crm.contact.notes", NotSensitive: true, Justification: "always empty in this database"},
+	})
+
+	tests := []struct {
+		name       string
+		column     ColumnSource
+		columnType string
+		expected   []string
+	}{
+		{"not_sensitive_beats_regex", ColumnSource{Database: "db", Schema: "network", Table: "sessions", Column: "ip_address"}, "INET", nil},
+		{"regex_elsewhere", ColumnSource{Database: "db", Schema: "crm", Table: "contact", Column: "home_address"}, "VARCHAR", []string{"PII"}},
+		{"other_database_not_overridden", ColumnSource{Database: "db2", Schema: "network", Table: "sessions", Column: "ip_address"}, "INET", []string{"PII"}},
+		{"unknown_database_matches", ColumnSource{Schema: "network", Table: "sessions", Column: "ip_address"}, "INET", nil},
+		{"custom_category", ColumnSource{Database: "tenant_a", Schema: "auth", Table: "users", Column: "pwd"}, "TEXT", []string{"Credentials"}},
+		{"forced_category_ignores_type", ColumnSource{Database: "db2", Schema: "crm", Table: "lead", Column: "notes"}, "TEXT", []string{"PII"}},
+		{"exact_beats_wildcard", ColumnSource{Database: "db", Schema: "crm", Table: "contact", Column: "notes"}, "TEXT", nil},
+		{"name_only_lookup", ColumnSource{Column: "ip_address"}, "INET", []string{"PII"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := dict.FindMatches(tt.column, tt.columnType)
+			var categories []string
+			for category := range matches {
+				categories = append(categories, category)
+			}
+			assert.ElementsMatch(t, tt.expected, categories)
+		})
+	}
+
+	override, ok := dict.OverrideFor(ColumnSource{Database: "x", Schema: "auth", Table: "t", Column: "pwd"})
+	require.True(t, ok)
+	assert.Equal(t, "password hashes", override.Justification)
+}
+
+func TestLoadOverrides(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "overrides.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"overrides":[{"column":"db.s.t.c","not_sensitive":true,"justification":"x"}]}`), 0644))
+
+	overrides, err := LoadOverrides(path)
+	require.NoError(t, err)
+	assert.Len(t, overrides, 1)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"overrides":[{"column":"t.c","not_sensitive":true,"justification":"x"}]}`), 0644))
+	_, err = LoadOverrides(path)
+	assert.Error(t, err)
+
+	_, err = LoadOverrides(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestMissingOverrideColumns(t *testing.T) {
+	catalog := SchemaCatalog{
+		"db": {"crm": {"contact": {"notes": "TEXT", "email": "TEXT"}}},
+	}
+	overrides := []config.ColumnOverride{
+		{Column: "db.crm.contact.notes"},
+		{Column: "*.crm.*.email"},
+		{Column: "db.crm.contact.fax"},
+		{Column: "other.crm.contact.notes"},
+	}
+
+	missing := MissingOverrideColumns(catalog, overrides)
+	require.Len(t, missing, 2)
+	assert.Equal(t, "db.crm.contact.fax", missing[0].Column)
+	assert.Equal(t, "other.crm.contact.notes", missing[1].Column)
+}
+
+func TestEnricher_ColumnOverrides(t *testing.T) {
+	base := createTestEnricher(false, true)
+	catalog := SchemaCatalog{"db": base.schemas.Latest()}
+	dict := *base.dict
+	dict.SetOverrides([]config.ColumnOverride{
+		{Column: "db.healthcare.patient.ssn", NotSensitive: true, Justification: "tokenized"},
+		{Column: "db.healthcare.patient.first_name", Categories: []string{"PII"}, Justification: "name"},
+	})
+	enricher := NewEnricherWithHistory(NewSchemaHistoryFromCatalog(catalog), &dict, base.riskScoring, EnrichmentOptions{Debug: true})
+
+	result := enricher.ProcessEvent(map[string]interface{}{
+		"event_id":   "e1",
+		"db_system":  "postgres",
+		"db_name":    "db",
+		"query_type": "SELECT",
+		"raw_query":  "SELECT ssn, first_name FROM healthcare.patient",
+	})
+	require.True(t, result.ShouldEmit)
+	assert.Equal(t, []string{"PII:first_name"}, result.EnrichedEvent["sensitivity"])
+
+	debugInfo := result.EnrichedEvent["debug_info"].(map[string]interface{})
+	assert.Equal(t, map[string]string{
+		"patient.ssn":        "tokenized",
+		"patient.first_name": "name",
+	}, debugInfo["overrides"])
+}
+
+func TestCompiledSensitivityDict_Metadata(t *testing.T) {
+	dict := &CompiledSensitivityDict{
+		Metadata: map[string]config.CategoryMetadata{
+			"PHI":          {Regulations: []string{"HIPAA"}, RetentionClass: "extended"},
+			"GDPR-Special": {Regulations: []string{"GDPR-Art9", "GDPR"}, RetentionClass: "restricted"},
+			"PII":          {Regulations: []string{"GDPR"}},
+		},
+	}
+
+	assert.Equal(t, []string{"GDPR", "GDPR-Art9", "HIPAA"}, dict.Regulations([]string{"PHI", "GDPR-Special", "PII", "Financial"}))
+	assert.Equal(t, []string{"extended", "restricted"}, dict.RetentionClasses([]string{"PII", "PHI", "GDPR-Special"}))
+	assert.Empty(t, dict.Regulations([]string{"Financial"}))
+}
+
+func TestEnricher_CategoryMetadata(t *testing.T) {
+	base := createTestEnricher(false, false)
+	dict := *base.dict
+	dict.Metadata = map[string]config.CategoryMetadata{
+		"PII":         {Regulations: []string{"GDPR"}, RetentionClass: "standard"},
+		"Credentials": {Regulations: []string{"SOC2"}, RetentionClass: "short", DefaultRisk: "high"},
+	}
+	dict.SetOverrides([]config.ColumnOverride{
+		{Column: "*.healthcare.patient.email", Categories: []string{"Credentials"}, Justification: "login identifier"},
+	})
+	riskScoring := *base.riskScoring
+	riskScoring.Base = map[string]string{"PII": "medium", "Credentials": "high"}
+	riskScoring.Combinations = map[string]string{"Credentials+PII": "critical"}
+	enricher := NewEnricher(base.schemas.Latest(), &dict, &riskScoring, EnrichmentOptions{})
+
+	result := enricher.ProcessEvent(map[string]interface{}{
+		"event_id":   "e1",
+		"query_type": "SELECT",
+		"raw_query":  "SELECT ssn, email FROM healthcare.patient",
+	})
+	require.True(t, result.ShouldEmit)
+	assert.ElementsMatch(t, []string{"PII", "Credentials"}, result.Categories)
+	assert.Equal(t, "critical", result.RiskLevel)
+	assert.Equal(t, []string{"GDPR", "SOC2"}, result.EnrichedEvent["regulations"])
+	assert.Equal(t, []string{"short", "standard"}, result.EnrichedEvent["retention_classes"])
+
+	// No metadata for the matched category: no regulation fields
+	plain := createTestEnricher(false, false).ProcessEvent(map[string]interface{}{
+		"event_id":   "e2",
+		"query_type": "SELECT",
+		"raw_query":  "SELECT ssn FROM healthcare.patient",
+	})
+	assert.NotContains(t, plain.EnrichedEvent, "regulations")
+}
+
+func TestEnricher_ComplianceControls(t *testing.T) {
+	mapping, err := config.ValidateCompliance(strings.NewReader(`{"frameworks": {
+		"hipaa": {"controls": [
+			{"id": "164.312(b)", "title": "Audit controls", "categories": ["PHI"]},
+			{"id": "164.308(a)(4)", "title": "Access management", "escalation_types": ["*"]}
+		]}
+	}}`))
+	require.NoError(t, err)
+
+	enricher := createTestEnricher(false, false)
+	enricher.SetCompliance(mapping)
+
+	result := enricher.ProcessEvent(map[string]interface{}{
+		"event_id":   "e1",
+		"query_type": "SELECT",
+		"raw_query":  "SELECT diagnosis FROM healthcare.encounter",
+	})
+	require.True(t, result.ShouldEmit)
+	assert.Equal(t, []string{"hipaa:164.312(b)"}, result.EnrichedEvent["compliance_controls"])
+
+	// Escalations are evidence even without sensitive columns
+	grant := enricher.ProcessEvent(map[string]interface{}{
+		"event_id":   "e2",
+		"query_type": "GRANT_ESCALATION",
+		"raw_query":  "GRANT ROLE admin TO user1",
+	})
+	require.True(t, grant.ShouldEmit)
+	assert.Equal(t, []string{"hipaa:164.308(a)(4)"}, grant.EnrichedEvent["compliance_controls"])
+
+	plain := enricher.ProcessEvent(map[string]interface{}{
+		"event_id":   "e3",
+		"query_type": "SELECT",
+		"raw_query":  "SELECT name FROM public.system_table",
+	})
+	assert.False(t, plain.ShouldEmit)
+}
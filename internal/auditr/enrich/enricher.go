@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/vaibhaw-/AuditR/internal/auditr/config"
 	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
@@ -16,11 +17,18 @@ type EnrichmentOptions struct {
 
 	// Debug enables debug information in the output
 	Debug bool
+
+	// ReplayDDL applies CREATE/ALTER/DROP TABLE events from the input stream to the
+	// schema history, so later events resolve against the schema in effect at their timestamp
+	ReplayDDL bool
 }
 
 // Enricher handles the enrichment of audit events with sensitivity and risk information
 type Enricher struct {
-	schema      SchemaMap
+	schemas     *SchemaHistory
+	sessions    *sessionTracker
+	lineage     *LineageCatalog
+	compliance  *config.ComplianceMapping
 	dict        *CompiledSensitivityDict
 	riskScoring *config.RiskScoring
 	options     EnrichmentOptions
@@ -44,22 +52,45 @@ type EnrichmentResult struct {
 	Error error
 }
 
-// NewEnricher creates a new enricher with the provided components
+// NewEnricher creates a new enricher with the provided components.
+// The schema is used for all events regardless of their timestamp.
 func NewEnricher(schema SchemaMap, dict *CompiledSensitivityDict, riskScoring *config.RiskScoring, options EnrichmentOptions) *Enricher {
+	return NewEnricherWithHistory(NewSchemaHistory(schema), dict, riskScoring, options)
+}
+
+// NewEnricherWithHistory creates a new enricher that resolves columns against the
+// schema version in effect at each event's timestamp.
+func NewEnricherWithHistory(schemas *SchemaHistory, dict *CompiledSensitivityDict, riskScoring *config.RiskScoring, options EnrichmentOptions) *Enricher {
 	logger.L().Debugw("Creating new enricher",
-		"schema_entries", len(schema),
+		"schema_entries", len(schemas.Latest()),
+		"schema_versions", schemas.Versions(),
 		"dict_categories", len(dict.Categories),
 		"emit_unknown", options.EmitUnknown,
-		"debug", options.Debug)
+		"debug", options.Debug,
+		"replay_ddl", options.ReplayDDL)
 
 	return &Enricher{
-		schema:      schema,
+		schemas:     schemas,
+		sessions:    newSessionTracker(),
 		dict:        dict,
 		riskScoring: riskScoring,
 		options:     options,
 	}
 }
 
+// SetLineage enables following views, functions and stored procedures to the
+// base table columns behind them.
+func (e *Enricher) SetLineage(lineage *LineageCatalog) {
+	e.lineage = lineage
+}
+
+// SetCompliance enables stamping each emitted event with the framework controls
+// (e.g. "hipaa:164.312(b)") its sensitivity categories and query type map to.
+// Events relevant to a control are emitted even without sensitivity matches.
+func (e *Enricher) SetCompliance(mapping *config.ComplianceMapping) {
+	e.compliance = mapping
+}
+
 // ProcessEvent enriches a single audit event with sensitivity and risk information.
 // The input event should be a parsed NDJSON event (map[string]interface{}).
 // Returns an EnrichmentResult containing the enriched event and metadata.
@@ -68,6 +99,8 @@ func (e *Enricher) ProcessEvent(event map[string]interface{}) EnrichmentResult {
 	eventID, _ := event["event_id"].(string)
 	rawQuery, _ := event["raw_query"].(string)
 	dbSystem, _ := event["db_system"].(string)
+	queryType, _ := event["query_type"].(string)
+	eventTime := eventTimestamp(event)
 
 	logger.L().Debugw("Processing event for enrichment",
 		"event_id", eventID,
@@ -80,6 +113,19 @@ func (e *Enricher) ProcessEvent(event map[string]interface{}) EnrichmentResult {
 		enrichedEvent[k] = v
 	}
 
+	// Track the session's current database and search_path for table resolution
+	resolutionCtx := e.sessions.Context(event, rawQuery)
+
+	// Step 0: Replay DDL so this and later events see the updated schema
+	if e.options.ReplayDDL && isDDLQueryType(queryType) {
+		if e.schemas.ApplyDDLInDatabase(eventTime, resolutionCtx.Database, rawQuery, defaultSchemaName(dbSystem)) {
+			logger.L().Debugw("Schema updated from DDL event",
+				"event_id", eventID,
+				"timestamp", eventTime.Format(time.RFC3339),
+				"schema_versions", e.schemas.Versions())
+		}
+	}
+
 	// Step 1: Parse the SQL query to extract table and column references
 	queryRefs := ParseQuery(rawQuery)
 
@@ -90,30 +136,43 @@ func (e *Enricher) ProcessEvent(event map[string]interface{}) EnrichmentResult {
 		"is_bulk", queryRefs.IsBulk,
 		"bulk_type", queryRefs.BulkType)
 
-	// Step 2: Resolve column references against the schema
-	resolvedColumns := queryRefs.ResolveColumns(e.schema)
+	// Step 2: Resolve column references against the schema in effect at the event time,
+	// using the event's database and search_path to pick between same-named tables
+	schemaVersion := e.schemas.VersionAt(eventTime)
+	catalog := schemaVersion.Catalog
+	if e.lineage != nil {
+		catalog = e.lineage.Catalog(schemaVersion)
+	}
+	resolution := queryRefs.ResolveColumnsInContext(catalog, resolutionCtx)
+
+	// Follow views and routines to the base columns behind them
+	if e.lineage != nil {
+		e.lineage.Resolve(schemaVersion, rawQuery, resolutionCtx, &resolution)
+	}
+	resolvedColumns := resolution.Columns
+	ambiguous := len(resolution.AmbiguousTables) > 0
 
 	logger.L().Debugw("Column resolution completed",
 		"event_id", eventID,
-		"resolved_columns", len(resolvedColumns))
+		"resolved_columns", len(resolvedColumns),
+		"ambiguous_tables", len(resolution.AmbiguousTables))
 
 	// Step 3: Match resolved columns against sensitivity dictionary
 	categoryMatches := make(map[string][]string) // category -> list of matched columns
 	allMatchedColumns := make([]string, 0)
+	appliedOverrides := make(map[string]string) // column -> override justification
 
 	for qualifiedColumn, columnType := range resolvedColumns {
-		// Extract just the column name for matching (remove table prefix)
-		columnName := qualifiedColumn
-		if strings.Contains(qualifiedColumn, ".") {
-			parts := strings.SplitN(qualifiedColumn, ".", 2)
-			if len(parts) == 2 {
-				columnName = parts[1]
-			}
+		// Match on the base column (not the table prefix or a view alias)
+		source := resolution.Sources[qualifiedColumn]
+		columnName := source.Column
+
+		// Find matches for this column (overrides first, then regex rules)
+		matches := e.dict.FindMatches(source, columnType)
+		if override, ok := e.dict.OverrideFor(source); ok {
+			appliedOverrides[qualifiedColumn] = override.Justification
 		}
 
-		// Find matches for this column
-		matches := e.dict.FindMatches(columnName, columnType)
-
 		for category, rules := range matches {
 			if categoryMatches[category] == nil {
 				categoryMatches[category] = make([]string, 0)
@@ -144,8 +203,16 @@ func (e *Enricher) ProcessEvent(event map[string]interface{}) EnrichmentResult {
 		"categories", strings.Join(categories, ","),
 		"risk_level", riskLevel)
 
-	// Step 5: Determine if event should be emitted
-	shouldEmit := len(categories) > 0 || e.options.EmitUnknown
+	// Map categories and query type to compliance framework controls
+	var complianceControls []string
+	if e.compliance != nil {
+		complianceControls = e.compliance.Match(categories, queryType)
+	}
+
+	// Step 5: Determine if event should be emitted.
+	// Ambiguous events are always emitted: their sensitivity is unknown, not absent.
+	// Events relevant to a compliance control are evidence and are always emitted too.
+	shouldEmit := len(categories) > 0 || e.options.EmitUnknown || ambiguous || len(complianceControls) > 0
 
 	// Step 6: Build enrichment fields
 	if shouldEmit {
@@ -155,18 +222,20 @@ func (e *Enricher) ProcessEvent(event map[string]interface{}) EnrichmentResult {
 			sensitivityArray := make([]string, 0)
 			for category, columns := range categoryMatches {
 				for _, column := range columns {
-					// Extract just the column name for the sensitivity label
-					columnName := column
-					if strings.Contains(column, ".") {
-						parts := strings.SplitN(column, ".", 2)
-						if len(parts) == 2 {
-							columnName = parts[1]
-						}
-					}
+					// Label with the base column name
+					columnName := resolution.Sources[column].Column
 					sensitivityArray = append(sensitivityArray, fmt.Sprintf("%s:%s", category, columnName))
 				}
 			}
 			enrichedEvent["sensitivity"] = sensitivityArray
+
+			// Carry category metadata (regulations, retention) for downstream queries and reports
+			if regulations := e.dict.Regulations(categories); len(regulations) > 0 {
+				enrichedEvent["regulations"] = regulations
+			}
+			if retention := e.dict.RetentionClasses(categories); len(retention) > 0 {
+				enrichedEvent["retention_classes"] = retention
+			}
 		} else {
 			// No matches found, but emitting unknown
 			enrichedEvent["sensitivity"] = []string{}
@@ -175,6 +244,16 @@ func (e *Enricher) ProcessEvent(event map[string]interface{}) EnrichmentResult {
 		// Add risk level
 		enrichedEvent["risk_level"] = riskLevel
 
+		if len(complianceControls) > 0 {
+			enrichedEvent["compliance_controls"] = complianceControls
+		}
+
+		// Flag tables that could not be attributed to a single database/schema
+		if ambiguous {
+			enrichedEvent["schema_status"] = "ambiguous"
+			enrichedEvent["ambiguous_tables"] = resolution.AmbiguousTables
+		}
+
 		// Add debug information if requested
 		if e.options.Debug {
 			debugInfo := map[string]interface{}{
@@ -186,31 +265,41 @@ func (e *Enricher) ProcessEvent(event map[string]interface{}) EnrichmentResult {
 			}
 
 			// Add schema status
-			if len(resolvedColumns) > 0 {
+			switch {
+			case ambiguous:
+				debugInfo["schema_status"] = "ambiguous"
+				debugInfo["ambiguous_tables"] = resolution.AmbiguousTables
+			case len(resolvedColumns) > 0:
 				debugInfo["schema_status"] = "matched"
-			} else if len(queryRefs.Columns) > 0 {
+			case len(queryRefs.Columns) > 0:
 				debugInfo["schema_status"] = "unresolved"
-				// Add list of unresolved tables
-				unresolvedTables := make([]string, 0)
-				for _, tableName := range queryRefs.Tables {
-					// Check if table exists in any schema
-					tableFound := false
-					for _, tables := range e.schema {
-						if _, ok := tables[tableName]; ok {
-							tableFound = true
-							break
-						}
-					}
-					if !tableFound {
-						unresolvedTables = append(unresolvedTables, tableName)
-					}
-				}
-				if len(unresolvedTables) > 0 {
-					debugInfo["unresolved_tables"] = unresolvedTables
-				}
-			} else {
+			default:
 				debugInfo["schema_status"] = "no_columns"
 			}
+			if len(resolution.UnresolvedTables) > 0 && len(queryRefs.Columns) > 0 {
+				debugInfo["unresolved_tables"] = resolution.UnresolvedTables
+			}
+			if len(appliedOverrides) > 0 {
+				debugInfo["overrides"] = appliedOverrides
+			}
+			if len(resolution.Via) > 0 {
+				debugInfo["lineage"] = resolution.Via
+			}
+			if resolutionCtx.Database != "" {
+				debugInfo["resolution_database"] = resolutionCtx.Database
+			}
+			if len(resolutionCtx.SearchPath) > 0 {
+				debugInfo["search_path"] = resolutionCtx.SearchPath
+			}
+
+			// Record which schema version was used when more than one is loaded
+			if e.schemas.Versions() > 1 {
+				versionInfo := map[string]interface{}{"source": schemaVersion.Source}
+				if !schemaVersion.ValidFrom.IsZero() {
+					versionInfo["valid_from"] = schemaVersion.ValidFrom.Format(time.RFC3339)
+				}
+				debugInfo["schema_version"] = versionInfo
+			}
 
 			enrichedEvent["debug_info"] = debugInfo
 		}
@@ -266,8 +355,10 @@ func (e *Enricher) ProcessEventJSON(eventJSON string) (string, bool, error) {
 
 // GetStats returns statistics about the enricher configuration
 func (e *Enricher) GetStats() map[string]interface{} {
+	schema := e.schemas.Latest()
 	stats := map[string]interface{}{
-		"schema_schemas":      len(e.schema),
+		"schema_schemas":      len(schema),
+		"schema_versions":     e.schemas.Versions(),
 		"dict_categories":     len(e.dict.Categories),
 		"dict_negative_rules": len(e.dict.Negative),
 		"risk_base_rules":     len(e.riskScoring.Base),
@@ -286,7 +377,7 @@ func (e *Enricher) GetStats() map[string]interface{} {
 	// Count schema tables and columns
 	totalTables := 0
 	totalColumns := 0
-	for _, tables := range e.schema {
+	for _, tables := range schema {
 		totalTables += len(tables)
 		for _, columns := range tables {
 			totalColumns += len(columns)
@@ -297,3 +388,35 @@ func (e *Enricher) GetStats() map[string]interface{} {
 
 	return stats
 }
+
+// eventTimestamp parses the event's RFC3339 timestamp.
+// Returns the zero time if the timestamp is missing or invalid.
+func eventTimestamp(event map[string]interface{}) time.Time {
+	ts, _ := event["timestamp"].(string)
+	if ts == "" {
+		return time.Time{}
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}
+	}
+	return parsed.UTC()
+}
+
+// isDDLQueryType reports whether a canonical query type may change table definitions.
+func isDDLQueryType(queryType string) bool {
+	switch strings.ToUpper(queryType) {
+	case "CREATE", "ALTER", "DROP", "RENAME":
+		return true
+	}
+	return false
+}
+
+// defaultSchemaName returns the schema used for unqualified tables created by DDL.
+// MySQL schema CSVs use "default" as the schema name (see README).
+func defaultSchemaName(dbSystem string) string {
+	if strings.EqualFold(dbSystem, "mysql") {
+		return "default"
+	}
+	return "public"
+}
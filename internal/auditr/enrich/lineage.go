@@ -0,0 +1,602 @@
+package enrich
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+)
+
+// ViewDefinition is a view and the SELECT statement that defines it
+// (pg_views.definition / information_schema.VIEWS.VIEW_DEFINITION).
+type ViewDefinition struct {
+	Database   string
+	Schema     string
+	Name       string
+	Definition string
+}
+
+// RoutineTarget is a table (or a single column of it) read by a function or stored procedure.
+type RoutineTarget struct {
+	Schema string
+	Table  string
+	Column string // Empty means every column of the table
+}
+
+// RoutineDefinition maps a function or stored procedure to the tables it reads.
+type RoutineDefinition struct {
+	Database string
+	Schema   string
+	Name     string
+	Targets  []RoutineTarget
+}
+
+// LineageCatalog follows views, functions and stored procedures to the base table
+// columns behind them, so that `SELECT * FROM reporting.patient_summary` or
+// `CALL export_patients()` is classified by the PII/PHI/Financial columns it exposes.
+//
+// Views are added to the schema catalog as virtual tables whose columns point back
+// to the underlying columns. Routines are matched by name anywhere in the query.
+// Like ParseQuery, view definitions are analysed with regex heuristics: plain and
+// aliased select lists, JOINs and nested views are followed; CTEs and subqueries are not.
+type LineageCatalog struct {
+	views    []ViewDefinition
+	routines []RoutineDefinition
+
+	// Expanded catalogs per schema version (see SchemaVersion.id)
+	expansions map[uint64]*lineageExpansion
+}
+
+// lineageColumn is one base column behind a view column.
+type lineageColumn struct {
+	Source ColumnSource
+	Type   string
+}
+
+// lineageExpansion is a schema catalog with views added as virtual tables.
+type lineageExpansion struct {
+	catalog SchemaCatalog
+	sources map[ColumnSource][]lineageColumn // view column -> base columns
+}
+
+// NewLineageCatalog creates a lineage catalog from view and routine definitions.
+func NewLineageCatalog(views []ViewDefinition, routines []RoutineDefinition) *LineageCatalog {
+	logger.L().Debugw("Creating lineage catalog",
+		"views", len(views),
+		"routines", len(routines))
+
+	return &LineageCatalog{
+		views:      views,
+		routines:   routines,
+		expansions: make(map[uint64]*lineageExpansion),
+	}
+}
+
+// LoadViewsCSV loads view definitions from CSV.
+// CSV format expected: db_name,schema_name,view_name,view_definition
+func LoadViewsCSV(path string) ([]ViewDefinition, error) {
+	var views []ViewDefinition
+	err := readLineageCSV(path, []string{"db_name", "schema_name", "view_name", "view_definition"}, func(record []string) bool {
+		view := ViewDefinition{
+			Database:   record[0],
+			Schema:     record[1],
+			Name:       record[2],
+			Definition: record[3],
+		}
+		if view.Schema == "" || view.Name == "" || view.Definition == "" {
+			return false
+		}
+		views = append(views, view)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return views, nil
+}
+
+// LoadRoutinesCSV loads routine to table mappings from CSV, one row per table or column read.
+// CSV format expected: db_name,schema_name,routine_name,table_schema,table_name,column_name
+// An empty column_name attributes every column of the table to the routine.
+func LoadRoutinesCSV(path string) ([]RoutineDefinition, error) {
+	index := make(map[string]int)
+	var routines []RoutineDefinition
+	header := []string{"db_name", "schema_name", "routine_name", "table_schema", "table_name", "column_name"}
+	err := readLineageCSV(path, header, func(record []string) bool {
+		if record[1] == "" || record[2] == "" || record[4] == "" {
+			return false
+		}
+		key := record[0] + "." + record[1] + "." + record[2]
+		i, exists := index[key]
+		if !exists {
+			i = len(routines)
+			index[key] = i
+			routines = append(routines, RoutineDefinition{Database: record[0], Schema: record[1], Name: record[2]})
+		}
+		target := RoutineTarget{Schema: record[3], Table: record[4], Column: record[5]}
+		if target.Schema == "" {
+			target.Schema = record[1]
+		}
+		routines[i].Targets = append(routines[i].Targets, target)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return routines, nil
+}
+
+// readLineageCSV reads a CSV file with the given header and calls add for every row.
+// add returns false for rows that should be skipped.
+func readLineageCSV(path string, expectedHeader []string, add func(record []string) bool) error {
+	logger.L().Debugw("Loading lineage from CSV", "path", path)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open lineage file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	if len(header) != len(expectedHeader) {
+		return fmt.Errorf("invalid CSV header: expected %v, got %v", expectedHeader, header)
+	}
+	for i, col := range header {
+		if strings.TrimSpace(col) != expectedHeader[i] {
+			return fmt.Errorf("invalid CSV header at position %d: expected %s, got %s", i, expectedHeader[i], col)
+		}
+	}
+
+	rowCount := 0
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read CSV row %d: %w", line, err)
+		}
+		if len(record) != len(expectedHeader) {
+			logger.L().Warnw("Skipping malformed lineage row",
+				"row", line,
+				"columns", len(record))
+			continue
+		}
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		if !add(record) {
+			logger.L().Warnw("Skipping lineage row with empty fields",
+				"row", line,
+				"record", strings.Join(record, ","))
+			continue
+		}
+		rowCount++
+	}
+
+	logger.L().Debugw("Lineage loading completed",
+		"path", path,
+		"rows", rowCount)
+	return nil
+}
+
+// Resolve expands view references in the query and adds the columns read by any
+// routine it calls. It must be used with the catalog returned by Catalog for the
+// same schema version.
+func (l *LineageCatalog) Resolve(version SchemaVersion, rawQuery string, ctx ResolutionContext, resolution *ColumnResolution) {
+	exp := l.expand(version)
+	exp.attribute(resolution)
+	l.resolveRoutines(exp, rawQuery, ctx, resolution)
+}
+
+// Catalog returns the schema version's catalog with views added as virtual tables.
+// Real tables take precedence over views of the same name.
+func (l *LineageCatalog) Catalog(version SchemaVersion) SchemaCatalog {
+	return l.expand(version).catalog
+}
+
+// expand builds (or returns the cached) lineage expansion for a schema version.
+func (l *LineageCatalog) expand(version SchemaVersion) *lineageExpansion {
+	if exp, ok := l.expansions[version.id]; ok {
+		return exp
+	}
+
+	// Shallow copy down to the table level so views can be added without touching the version
+	catalog := make(SchemaCatalog, len(version.Catalog))
+	for dbName, schema := range version.Catalog {
+		catalog[dbName] = make(SchemaMap, len(schema))
+		for schemaName, tables := range schema {
+			catalog[dbName][schemaName] = make(map[string]map[string]string, len(tables))
+			for tableName, columns := range tables {
+				catalog[dbName][schemaName][tableName] = columns
+			}
+		}
+	}
+	exp := &lineageExpansion{
+		catalog: catalog,
+		sources: make(map[ColumnSource][]lineageColumn),
+	}
+
+	// Views can select from other views: expand dependencies first
+	state := make(map[int]int) // 0 = pending, 1 = in progress, 2 = done
+	var visit func(i int)
+	visit = func(i int) {
+		if state[i] != 0 {
+			return // done, or a cycle
+		}
+		state[i] = 1
+		view := l.views[i]
+		refs := viewTableRefs(view.Definition)
+		for _, tableName := range refs.Tables {
+			for j, dep := range l.views {
+				if j != i && dep.Name == tableName {
+					visit(j)
+				}
+			}
+		}
+		exp.addView(view, refs)
+		state[i] = 2
+	}
+	for i := range l.views {
+		visit(i)
+	}
+
+	l.expansions[version.id] = exp
+
+	logger.L().Debugw("Expanded view lineage for schema version",
+		"source", version.Source,
+		"views", len(l.views),
+		"view_columns", len(exp.sources))
+	return exp
+}
+
+// Select-list helpers for view definitions
+var (
+	viewAliasPattern    = regexp.MustCompile(`(?is)^(.*?)\s+AS\s+"?([A-Za-z_][A-Za-z0-9_$]*)"?$`)
+	viewImplicitAlias   = regexp.MustCompile(`(?is)^(.*[)\w"])\s+"?([A-Za-z_][A-Za-z0-9_$]*)"?$`)
+	viewIdentPattern    = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_$]*(?:\.[A-Za-z_][A-Za-z0-9_$]*)?)(\s*\()?`)
+	viewLiteralPattern  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	viewCastPattern     = regexp.MustCompile(`::\s*[A-Za-z_][A-Za-z0-9_ ]*(?:\[\])?`)
+	viewFromPattern     = regexp.MustCompile(`(?i)\bFROM\b`)
+	viewSelectPrefix    = regexp.MustCompile(`(?is)^\s*SELECT\s+(?:DISTINCT\s+(?:ON\s*\([^)]*\)\s*)?|ALL\s+)?`)
+	viewPlainIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(?:\.[A-Za-z_][A-Za-z0-9_$]*)*$`)
+)
+
+// viewTableRefs parses the FROM part of a view definition. pg_views wraps joins in
+// parentheses ("FROM (a JOIN b ON ((...)))"), which ParseQuery's FROM pattern does not
+// expect, so parentheses after FROM are dropped first.
+func viewTableRefs(definition string) QueryRefs {
+	def := cleanSQLComments(definition)
+	loc := viewFromPattern.FindStringIndex(def)
+	if loc == nil {
+		return QueryRefs{Tables: map[string]string{}, Qualifiers: map[string]string{}}
+	}
+	from := strings.NewReplacer("(", " ", ")", " ").Replace(def[loc[1]:])
+	return ParseQuery("SELECT * FROM " + from)
+}
+
+// viewSelectList returns the items of the view's top-level select list.
+func viewSelectList(definition string) []string {
+	def := cleanSQLComments(definition)
+	loc := viewFromPattern.FindStringIndex(def)
+	if loc == nil {
+		return nil
+	}
+	list := viewSelectPrefix.ReplaceAllString(def[:loc[0]], "")
+	return splitTopLevel(list)
+}
+
+// addView resolves the view's select list and registers it as a virtual table.
+func (exp *lineageExpansion) addView(view ViewDefinition, refs QueryRefs) {
+	if _, exists := exp.catalog[view.Database][view.Schema][view.Name]; exists {
+		logger.L().Debugw("View name shadowed by a table, skipping lineage",
+			"database", view.Database,
+			"schema", view.Schema,
+			"view", view.Name)
+		return
+	}
+
+	ctx := ResolutionContext{Database: view.Database, SearchPath: []string{view.Schema}}
+	resolve := func(columnRef string) ColumnResolution {
+		qr := QueryRefs{Tables: refs.Tables, Qualifiers: refs.Qualifiers, Columns: []string{columnRef}}
+		res := qr.ResolveColumnsInContext(exp.catalog, ctx)
+		exp.attribute(&res)
+		return res
+	}
+
+	columns := make(map[string]string)
+	add := func(output string, res ColumnResolution) {
+		var base []lineageColumn
+		for _, key := range sortedKeys(res.Columns) {
+			base = append(base, lineageColumn{Source: res.Sources[key], Type: res.Columns[key]})
+		}
+		if len(base) == 0 {
+			return
+		}
+		viewColumn := ColumnSource{Database: view.Database, Schema: view.Schema, Table: view.Name, Column: output}
+		exp.sources[viewColumn] = append(exp.sources[viewColumn], base...)
+		if _, exists := columns[output]; !exists {
+			columns[output] = base[0].Type
+		}
+	}
+
+	for _, item := range viewSelectList(view.Definition) {
+		expr, output := splitSelectItem(item)
+
+		// Wildcards: every column of the referenced table(s), keeping their names
+		if expr == "*" || strings.HasSuffix(expr, ".*") {
+			res := resolve("*")
+			for _, key := range sortedKeys(res.Columns) {
+				alias := strings.SplitN(key, ".", 2)[0]
+				if expr != "*" && alias != strings.TrimSuffix(expr, ".*") {
+					continue
+				}
+				single := ColumnResolution{
+					Columns: map[string]string{key: res.Columns[key]},
+					Sources: map[string]ColumnSource{key: res.Sources[key]},
+				}
+				add(res.Sources[key].Column, single)
+			}
+			continue
+		}
+
+		// Expressions: every column referenced in the expression feeds the output column
+		merged := ColumnResolution{Columns: map[string]string{}, Sources: map[string]ColumnSource{}}
+		for _, ref := range expressionColumnRefs(expr) {
+			res := resolve(ref)
+			for key, typ := range res.Columns {
+				merged.Columns[key] = typ
+				merged.Sources[key] = res.Sources[key]
+			}
+		}
+		add(output, merged)
+	}
+
+	if len(columns) == 0 {
+		logger.L().Debugw("View has no resolvable columns",
+			"database", view.Database,
+			"schema", view.Schema,
+			"view", view.Name)
+		return
+	}
+
+	if exp.catalog[view.Database] == nil {
+		exp.catalog[view.Database] = make(SchemaMap)
+	}
+	if exp.catalog[view.Database][view.Schema] == nil {
+		exp.catalog[view.Database][view.Schema] = make(map[string]map[string]string)
+	}
+	exp.catalog[view.Database][view.Schema][view.Name] = columns
+
+	logger.L().Debugw("Added view lineage",
+		"database", view.Database,
+		"schema", view.Schema,
+		"view", view.Name,
+		"columns", len(columns))
+}
+
+// splitSelectItem splits a select-list item into its expression and output column name.
+func splitSelectItem(item string) (string, string) {
+	item = strings.TrimSpace(item)
+	if m := viewAliasPattern.FindStringSubmatch(item); m != nil {
+		return strings.TrimSpace(m[1]), m[2]
+	}
+	if m := viewImplicitAlias.FindStringSubmatch(item); m != nil && !isReservedWord(m[2]) {
+		return strings.TrimSpace(m[1]), m[2]
+	}
+
+	// Unaliased plain column: output name is the column name
+	expr := unquoteIdent(item)
+	if viewPlainIdentifier.MatchString(expr) {
+		parts := strings.Split(expr, ".")
+		return expr, parts[len(parts)-1]
+	}
+	refs := expressionColumnRefs(expr)
+	if len(refs) > 0 {
+		parts := strings.Split(refs[0], ".")
+		return expr, parts[len(parts)-1]
+	}
+	return expr, expr
+}
+
+// expressionColumnRefs returns the identifiers in an expression that may be columns,
+// skipping string literals, type casts, function names and SQL keywords.
+func expressionColumnRefs(expr string) []string {
+	expr = viewLiteralPattern.ReplaceAllString(expr, " ")
+	expr = viewCastPattern.ReplaceAllString(expr, " ")
+	expr = strings.ReplaceAll(expr, `"`, "")
+
+	var refs []string
+	for _, m := range viewIdentPattern.FindAllStringSubmatch(expr, -1) {
+		ident := m[1]
+		if m[2] != "" || isReservedWord(ident) || isExpressionKeyword(ident) {
+			continue
+		}
+		refs = append(refs, ident)
+	}
+	return refs
+}
+
+// isExpressionKeyword reports SQL words that appear inside select expressions but
+// are not covered by isReservedWord.
+func isExpressionKeyword(word string) bool {
+	switch strings.ToUpper(word) {
+	case "TRUE", "FALSE", "INTERVAL", "CAST", "FILTER", "OVER", "PARTITION", "ASC", "DESC", "USING":
+		return true
+	}
+	return false
+}
+
+// attribute replaces resolved view columns with the base columns behind them.
+// A view column fed by several base columns is split into one entry per base column.
+func (exp *lineageExpansion) attribute(resolution *ColumnResolution) {
+	for _, key := range sortedKeys(resolution.Columns) {
+		source := resolution.Sources[key]
+		base, isView := exp.sources[source]
+		if !isView {
+			continue
+		}
+
+		// Keep the outermost object the column was reached through (e.g. a routine reading a view)
+		via := source.Schema + "." + source.Table
+		if prev, exists := resolution.Via[key]; exists {
+			via = prev
+			delete(resolution.Via, key)
+		}
+
+		delete(resolution.Columns, key)
+		delete(resolution.Sources, key)
+		for _, col := range base {
+			baseKey := key
+			if len(base) > 1 {
+				baseKey = key + "->" + col.Source.Table + "." + col.Source.Column
+			}
+			resolution.Columns[baseKey] = col.Type
+			resolution.Sources[baseKey] = col.Source
+			resolution.setVia(baseKey, via)
+		}
+
+		logger.L().Debugw("Attributed view column to base columns",
+			"column", key,
+			"view", source.Schema+"."+source.Table,
+			"base_columns", len(base))
+	}
+}
+
+// Identifiers followed by "(" are candidate function/procedure calls
+var routineCallPattern = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_$]*(?:\.[A-Za-z_][A-Za-z0-9_$]*){0,2})\s*\(`)
+
+// resolveRoutines adds the columns read by routines called in the query.
+func (l *LineageCatalog) resolveRoutines(exp *lineageExpansion, rawQuery string, ctx ResolutionContext, resolution *ColumnResolution) {
+	if len(l.routines) == 0 {
+		return
+	}
+
+	query := viewLiteralPattern.ReplaceAllString(cleanSQLComments(rawQuery), " ")
+	seen := make(map[string]bool)
+	for _, m := range routineCallPattern.FindAllStringSubmatch(query, -1) {
+		qualifier, name := "", m[1]
+		if idx := strings.LastIndex(m[1], "."); idx > 0 {
+			qualifier, name = m[1][:idx], m[1][idx+1:]
+		}
+		for _, routine := range l.findRoutines(ctx, qualifier, name) {
+			key := routine.Database + "." + routine.Schema + "." + routine.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			exp.addRoutineColumns(routine, resolution)
+			resolution.UnresolvedTables = removeString(resolution.UnresolvedTables, routine.Name) // SELECT * FROM fn()
+		}
+	}
+}
+
+// findRoutines returns the routines matching a called name, preferring the event's database.
+func (l *LineageCatalog) findRoutines(ctx ResolutionContext, qualifier, name string) []RoutineDefinition {
+	var all, inDatabase []RoutineDefinition
+	for _, routine := range l.routines {
+		if !strings.EqualFold(routine.Name, name) {
+			continue
+		}
+		if qualifier != "" {
+			parts := strings.Split(qualifier, ".")
+			schemaOK := strings.EqualFold(parts[len(parts)-1], routine.Schema)
+			dbOK := len(parts) < 2 || strings.EqualFold(parts[len(parts)-2], routine.Database)
+			mysqlDB := len(parts) == 1 && strings.EqualFold(ctx.DBSystem, "mysql") && strings.EqualFold(parts[0], routine.Database)
+			if !(schemaOK && dbOK) && !mysqlDB {
+				continue
+			}
+		}
+		all = append(all, routine)
+		if ctx.Database != "" && strings.EqualFold(routine.Database, ctx.Database) {
+			inDatabase = append(inDatabase, routine)
+		}
+	}
+	if len(inDatabase) > 0 {
+		return inDatabase
+	}
+	return all
+}
+
+// addRoutineColumns resolves a routine's targets and adds them to the resolution.
+func (exp *lineageExpansion) addRoutineColumns(routine RoutineDefinition, resolution *ColumnResolution) {
+	ctx := ResolutionContext{Database: routine.Database, SearchPath: []string{routine.Schema}}
+	via := routine.Schema + "." + routine.Name
+
+	added := 0
+	for _, target := range routine.Targets {
+		matches, status := exp.catalog.LookupTable(ctx, target.Schema, target.Table)
+		if status != TableFound {
+			logger.L().Warnw("Routine target table not found",
+				"routine", via,
+				"table", target.Schema+"."+target.Table,
+				"status", status)
+			continue
+		}
+		table := matches[0]
+
+		columns := []string{target.Column}
+		if target.Column == "" {
+			columns = sortedKeys(table.Columns)
+		}
+		for _, col := range columns {
+			colType, exists := table.Columns[col]
+			if !exists {
+				continue
+			}
+			key := routine.Name + ":" + table.Table + "." + col
+			resolution.Columns[key] = colType
+			resolution.Sources[key] = ColumnSource{Database: table.Database, Schema: table.Schema, Table: table.Table, Column: col}
+			resolution.setVia(key, via)
+			added++
+		}
+	}
+	exp.attribute(resolution) // Routine targets may be views
+
+	logger.L().Debugw("Added routine lineage",
+		"routine", via,
+		"columns", added)
+}
+
+// setVia records the view or routine a resolved column was reached through.
+func (r *ColumnResolution) setVia(key, via string) {
+	if r.Via == nil {
+		r.Via = make(map[string]string)
+	}
+	if _, exists := r.Via[key]; !exists {
+		r.Via[key] = via
+	}
+}
+
+// removeString returns the slice without occurrences of s.
+func removeString(list []string, s string) []string {
+	out := list[:0]
+	for _, item := range list {
+		if item != s {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// sortedKeys returns the keys of a string map in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,231 @@
+package enrich
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createLineageCatalog() *LineageCatalog {
+	views := []ViewDefinition{
+		{
+			// pg_views style: explicit aliases, parenthesised joins, casts
+			Database: "practicumdb",
+			Schema:   "reporting",
+			Name:     "patient_summary",
+			Definition: ` SELECT p.patient_id,
+    p.ssn AS social,
+    (p.first_name::text || ' '::text) || p.last_name::text AS full_name,
+    e.diagnosis
+   FROM (healthcare.patient p
+     JOIN healthcare.encounter e ON ((e.patient_id = p.patient_id)));`,
+		},
+		{
+			// View on a view
+			Database:   "practicumdb",
+			Schema:     "reporting",
+			Name:       "diagnoses",
+			Definition: "SELECT s.diagnosis, s.social FROM reporting.patient_summary s",
+		},
+		{
+			Database:   "practicumdb",
+			Schema:     "reporting",
+			Name:       "cards",
+			Definition: "SELECT * FROM payments.payment_method",
+		},
+	}
+	routines := []RoutineDefinition{
+		{
+			Database: "practicumdb",
+			Schema:   "public",
+			Name:     "export_patients",
+			Targets: []RoutineTarget{
+				{Schema: "healthcare", Table: "patient", Column: "email"},
+				{Schema: "reporting", Table: "cards"},
+			},
+		},
+	}
+	return NewLineageCatalog(views, routines)
+}
+
+func createLineageHistory() *SchemaHistory {
+	return NewSchemaHistoryFromCatalog(SchemaCatalog{
+		"practicumdb": {
+			"healthcare": {
+				"patient":   {"patient_id": "UUID", "ssn": "VARCHAR", "email": "TEXT", "first_name": "VARCHAR", "last_name": "VARCHAR"},
+				"encounter": {"encounter_id": "UUID", "patient_id": "UUID", "diagnosis": "TEXT"},
+			},
+			"payments": {
+				"payment_method": {"payment_method_id": "UUID", "card_last4": "CHAR"},
+			},
+		},
+	})
+}
+
+func TestLineageCatalog_ViewColumns(t *testing.T) {
+	lineage := createLineageCatalog()
+	version := createLineageHistory().VersionAt(time.Time{})
+
+	catalog := lineage.Catalog(version)
+	assert.Equal(t, map[string]string{
+		"patient_id": "UUID",
+		"social":     "VARCHAR",
+		"full_name":  "VARCHAR",
+		"diagnosis":  "TEXT",
+	}, catalog["practicumdb"]["reporting"]["patient_summary"])
+	assert.Equal(t, map[string]string{"diagnosis": "TEXT", "social": "VARCHAR"}, catalog["practicumdb"]["reporting"]["diagnoses"])
+	assert.Equal(t, map[string]string{"payment_method_id": "UUID", "card_last4": "CHAR"}, catalog["practicumdb"]["reporting"]["cards"])
+
+	// The schema version itself is not modified
+	assert.NotContains(t, version.Catalog["practicumdb"], "reporting")
+}
+
+func TestLineageCatalog_Resolve(t *testing.T) {
+	lineage := createLineageCatalog()
+	version := createLineageHistory().VersionAt(time.Time{})
+	ctx := ResolutionContext{DBSystem: "postgres", Database: "practicumdb"}
+
+	resolve := func(query string) ColumnResolution {
+		refs := ParseQuery(query)
+		res := refs.ResolveColumnsInContext(lineage.Catalog(version), ctx)
+		lineage.Resolve(version, query, ctx, &res)
+		return res
+	}
+
+	t.Run("aliased_view_column", func(t *testing.T) {
+		res := resolve("SELECT social FROM reporting.patient_summary")
+		require.Contains(t, res.Sources, "patient_summary.social")
+		assert.Equal(t, ColumnSource{Database: "practicumdb", Schema: "healthcare", Table: "patient", Column: "ssn"}, res.Sources["patient_summary.social"])
+		assert.Equal(t, "reporting.patient_summary", res.Via["patient_summary.social"])
+	})
+
+	t.Run("expression_column_splits_into_base_columns", func(t *testing.T) {
+		res := resolve("SELECT full_name FROM reporting.patient_summary")
+		assert.Len(t, res.Columns, 2)
+		assert.Contains(t, res.Columns, "patient_summary.full_name->patient.first_name")
+		assert.Contains(t, res.Columns, "patient_summary.full_name->patient.last_name")
+	})
+
+	t.Run("nested_view", func(t *testing.T) {
+		res := resolve("SELECT * FROM reporting.diagnoses")
+		assert.Equal(t, "ssn", res.Sources["diagnoses.social"].Column)
+		assert.Equal(t, "encounter", res.Sources["diagnoses.diagnosis"].Table)
+	})
+
+	t.Run("routine_call", func(t *testing.T) {
+		res := resolve("CALL export_patients()")
+		assert.Equal(t, map[string]string{
+			"export_patients:patient.email":           "TEXT",
+			"export_patients:cards.card_last4":        "CHAR",
+			"export_patients:cards.payment_method_id": "UUID",
+		}, res.Columns)
+		assert.Equal(t, "payment_method", res.Sources["export_patients:cards.card_last4"].Table)
+		assert.Equal(t, "public.export_patients", res.Via["export_patients:cards.card_last4"])
+	})
+
+	t.Run("function_in_from_clause", func(t *testing.T) {
+		res := resolve("SELECT * FROM public.export_patients()")
+		assert.Contains(t, res.Columns, "export_patients:patient.email")
+		assert.Empty(t, res.UnresolvedTables)
+	})
+
+	t.Run("routine_name_in_string_literal_is_ignored", func(t *testing.T) {
+		res := resolve("SELECT 'export_patients()' AS label")
+		assert.Empty(t, res.Columns)
+	})
+}
+
+func TestSplitSelectItem(t *testing.T) {
+	tests := []struct {
+		item   string
+		expr   string
+		output string
+	}{
+		{"p.ssn", "p.ssn", "ssn"},
+		{"p.ssn AS social", "p.ssn", "social"},
+		{`p.ssn AS "Social"`, "p.ssn", "Social"},
+		{"upper(p.email) email_upper", "upper(p.email)", "email_upper"},
+		{"count(*)", "count(*)", "count(*)"},
+		{"lower(p.email)", "lower(p.email)", "email"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.item, func(t *testing.T) {
+			expr, output := splitSelectItem(tt.item)
+			assert.Equal(t, tt.expr, expr)
+			assert.Equal(t, tt.output, output)
+		})
+	}
+}
+
+func TestLoadLineageCSV(t *testing.T) {
+	dir := t.TempDir()
+
+	viewsPath := filepath.Join(dir, "views.csv")
+	require.NoError(t, os.WriteFile(viewsPath, []byte(`db_name,schema_name,view_name,view_definition
+practicumdb,reporting,patient_summary,"SELECT p.ssn, p.email FROM healthcare.patient p"
+practicumdb,reporting,,SELECT 1
+`), 0644))
+	views, err := LoadViewsCSV(viewsPath)
+	require.NoError(t, err)
+	require.Len(t, views, 1)
+	assert.Equal(t, "SELECT p.ssn, p.email FROM healthcare.patient p", views[0].Definition)
+
+	routinesPath := filepath.Join(dir, "routines.csv")
+	require.NoError(t, os.WriteFile(routinesPath, []byte(`db_name,schema_name,routine_name,table_schema,table_name,column_name
+practicumdb,public,export_patients,healthcare,patient,ssn
+practicumdb,public,export_patients,,encounter,
+practicumdb,public,audit_cleanup,audit,log,
+`), 0644))
+	routines, err := LoadRoutinesCSV(routinesPath)
+	require.NoError(t, err)
+	require.Len(t, routines, 2)
+	assert.Equal(t, []RoutineTarget{
+		{Schema: "healthcare", Table: "patient", Column: "ssn"},
+		{Schema: "public", Table: "encounter"},
+	}, routines[0].Targets)
+
+	badPath := filepath.Join(dir, "bad.csv")
+	require.NoError(t, os.WriteFile(badPath, []byte("schema_name,view_name\n"), 0644))
+	_, err = LoadViewsCSV(badPath)
+	assert.Error(t, err)
+	_, err = LoadRoutinesCSV(filepath.Join(dir, "missing.csv"))
+	assert.Error(t, err)
+}
+
+func TestEnricher_Lineage(t *testing.T) {
+	base := createTestEnricher(false, true)
+	enricher := NewEnricherWithHistory(createLineageHistory(), base.dict, base.riskScoring, EnrichmentOptions{Debug: true})
+
+	event := func(query string) map[string]interface{} {
+		return map[string]interface{}{
+			"event_id":   "e1",
+			"timestamp":  "2025-09-01T10:00:00Z",
+			"db_system":  "postgres",
+			"db_name":    "practicumdb",
+			"query_type": "SELECT",
+			"raw_query":  query,
+		}
+	}
+
+	// Without lineage the view is unknown
+	result := enricher.ProcessEvent(event("SELECT * FROM reporting.patient_summary"))
+	assert.False(t, result.ShouldEmit)
+
+	enricher.SetLineage(createLineageCatalog())
+
+	result = enricher.ProcessEvent(event("SELECT * FROM reporting.patient_summary"))
+	require.True(t, result.ShouldEmit)
+	assert.ElementsMatch(t, []string{"PII", "PHI"}, result.Categories)
+	assert.Contains(t, result.EnrichedEvent["sensitivity"], "PII:ssn")
+	debugInfo := result.EnrichedEvent["debug_info"].(map[string]interface{})
+	assert.Contains(t, debugInfo, "lineage")
+
+	result = enricher.ProcessEvent(event("CALL export_patients()"))
+	require.True(t, result.ShouldEmit)
+	assert.ElementsMatch(t, []string{"PII", "Financial"}, result.Categories)
+	assert.ElementsMatch(t, []string{"PII:email", "Financial:card_last4"}, result.EnrichedEvent["sensitivity"])
+}
@@ -2,6 +2,7 @@ package enrich
 
 import (
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
@@ -13,6 +14,12 @@ type QueryRefs struct {
 	// Key: alias or table name used in query, Value: actual table name
 	Tables map[string]string
 
+	// Qualifiers records the schema/database prefix written before a table name
+	// Key: alias or table name as in Tables, Value: "schema", "db" (MySQL) or "db.schema"
+	// Tables referenced without a prefix have no entry. Aliases keep same-named
+	// tables of different schemas apart ("billing.users a JOIN public.users b").
+	Qualifiers map[string]string
+
 	// Columns contains all column references found in the query
 	// Format: "table.column" or just "column" if no table prefix
 	Columns []string
@@ -59,8 +66,9 @@ func ParseQuery(rawQuery string) QueryRefs {
 	logger.L().Debugw("Parsing SQL query", "query", rawQuery)
 
 	refs := QueryRefs{
-		Tables:  make(map[string]string),
-		Columns: []string{},
+		Tables:     make(map[string]string),
+		Qualifiers: make(map[string]string),
+		Columns:    []string{},
 	}
 
 	// Normalize the query: remove extra whitespace, convert to uppercase for pattern matching
@@ -149,6 +157,8 @@ func extractTables(query string, refs *QueryRefs) {
 				parts := strings.Split(fullTableName, ".")
 				tableName = parts[len(parts)-1]
 			}
+			refs.recordQualifier(alias, fullTableName)
+			refs.recordQualifier(tableName, fullTableName)
 			// Table has an alias
 			refs.Tables[alias] = tableName
 			refs.Tables[tableName] = tableName // Also map table name to itself
@@ -172,6 +182,7 @@ func extractTables(query string, refs *QueryRefs) {
 				parts := strings.Split(fullTableName, ".")
 				tableName = parts[len(parts)-1]
 			}
+			refs.recordQualifier(tableName, fullTableName)
 			refs.Tables[tableName] = tableName
 			logger.L().Debugw("Found FROM table without alias",
 				"full_table", fullTableName,
@@ -195,6 +206,8 @@ func extractTables(query string, refs *QueryRefs) {
 				parts := strings.Split(fullTableName, ".")
 				tableName = parts[len(parts)-1]
 			}
+			refs.recordQualifier(alias, fullTableName)
+			refs.recordQualifier(tableName, fullTableName)
 			refs.Tables[alias] = tableName
 			refs.Tables[tableName] = tableName
 			joinTablesFound[tableName] = true
@@ -218,6 +231,7 @@ func extractTables(query string, refs *QueryRefs) {
 		}
 		// Only add if we didn't already find this table with an alias
 		if !joinTablesFound[tableName] {
+			refs.recordQualifier(tableName, fullTableName)
 			refs.Tables[tableName] = tableName
 			logger.L().Debugw("Found JOIN table without alias",
 				"full_table", fullTableName,
@@ -237,6 +251,7 @@ func extractTables(query string, refs *QueryRefs) {
 				parts := strings.Split(fullTableName, ".")
 				tableName = parts[len(parts)-1]
 			}
+			refs.recordQualifier(tableName, fullTableName)
 			refs.Tables[tableName] = tableName
 			logger.L().Debugw("Found INSERT table", "table", tableName, "full_name", fullTableName)
 		}
@@ -259,6 +274,8 @@ func extractTables(query string, refs *QueryRefs) {
 					parts := strings.Split(fullTableName, ".")
 					tableName = parts[len(parts)-1]
 				}
+				refs.recordQualifier(alias, fullTableName)
+				refs.recordQualifier(tableName, fullTableName)
 				refs.Tables[alias] = tableName
 				refs.Tables[tableName] = tableName
 				logger.L().Debugw("Found UPDATE table with alias",
@@ -278,6 +295,7 @@ func extractTables(query string, refs *QueryRefs) {
 					parts := strings.Split(fullTableName, ".")
 					tableName = parts[len(parts)-1]
 				}
+				refs.recordQualifier(tableName, fullTableName)
 				refs.Tables[tableName] = tableName
 				logger.L().Debugw("Found UPDATE table without alias",
 					"full_table", fullTableName,
@@ -298,6 +316,7 @@ func extractTables(query string, refs *QueryRefs) {
 				parts := strings.Split(fullTableName, ".")
 				tableName = parts[len(parts)-1]
 			}
+			refs.recordQualifier(tableName, fullTableName)
 			refs.Tables[tableName] = tableName
 			logger.L().Debugw("Found DELETE table",
 				"full_table", fullTableName,
@@ -435,34 +454,135 @@ func isReservedWord(word string) bool {
 	return reserved[strings.ToUpper(word)]
 }
 
+// ColumnSource identifies the catalog location a resolved column came from.
+type ColumnSource struct {
+	Database string
+	Schema   string
+	Table    string
+	Column   string
+}
+
+// ColumnResolution is the result of resolving a query's columns in context.
+type ColumnResolution struct {
+	// Columns maps qualified column names ("alias.column") to normalized types
+	Columns map[string]string
+
+	// Sources maps the same qualified column names to their catalog location
+	Sources map[string]ColumnSource
+
+	// AmbiguousTables maps tables that matched several different definitions
+	// to their candidate "db.schema" locations. Their columns are not resolved.
+	AmbiguousTables map[string][]string
+
+	// UnresolvedTables lists referenced tables not found in the catalog
+	UnresolvedTables []string
+
+	// Via maps qualified column names reached through a view or routine to that
+	// object ("schema.name"); see LineageCatalog. Nil when no lineage was used.
+	Via map[string]string
+}
+
 // ResolveColumns resolves column references against a schema map and returns
 // a map of resolved columns with their types.
 // Uses smart schema resolution - searches all schemas automatically.
 // Returns: map[qualified_column_name]normalized_type
 func (qr *QueryRefs) ResolveColumns(schema SchemaMap) map[string]string {
-	resolved := make(map[string]string)
+	return qr.ResolveColumnsInContext(SchemaCatalog{"": schema}, ResolutionContext{}).Columns
+}
 
-	logger.L().Debugw("Resolving columns with smart schema resolution",
+// ResolveColumnsInContext resolves column references against a per-database catalog.
+// Each table is looked up once using its written qualifier and the event context
+// (database, search_path); see SchemaCatalog.LookupTable. Tables that are ambiguous
+// in that context are reported instead of being resolved against an arbitrary match.
+func (qr *QueryRefs) ResolveColumnsInContext(catalog SchemaCatalog, ctx ResolutionContext) ColumnResolution {
+	result := ColumnResolution{
+		Columns:         make(map[string]string),
+		Sources:         make(map[string]ColumnSource),
+		AmbiguousTables: make(map[string][]string),
+	}
+
+	logger.L().Debugw("Resolving columns with context-aware schema resolution",
 		"num_parsed_columns", len(qr.Columns),
-		"parsed_tables", qr.Tables)
+		"parsed_tables", qr.Tables,
+		"database", ctx.Database,
+		"search_path", strings.Join(ctx.SearchPath, ","))
+
+	// Iterate aliases in a stable order so unqualified columns resolve deterministically
+	aliases := make([]string, 0, len(qr.Tables))
+	for alias := range qr.Tables {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	// Look up each referenced table once per qualified name; lookups is keyed by
+	// alias so same-named tables of different schemas stay apart
+	lookups := make(map[string]*TableMatch)
+	byName := make(map[string]*TableMatch)
+	reported := make(map[string]bool)
+	for _, alias := range aliases {
+		tableName, qualifier := qr.Tables[alias], qr.Qualifiers[alias]
+		name := tableName
+		if qualifier != "" {
+			name = qualifier + "." + tableName
+		}
+		if match, done := byName[name]; done {
+			lookups[alias] = match
+			continue
+		}
+		matches, status := catalog.LookupTable(ctx, qualifier, tableName)
+		switch status {
+		case TableFound:
+			byName[name] = &matches[0]
+			logger.L().Debugw("Found table in schema",
+				"table", name,
+				"database", matches[0].Database,
+				"schema", matches[0].Schema,
+				"columns", len(matches[0].Columns))
+		case TableAmbiguous:
+			byName[name] = nil
+			locations := make([]string, 0, len(matches))
+			for _, m := range matches {
+				locations = append(locations, m.Location())
+			}
+			result.AmbiguousTables[tableName] = locations
+		default:
+			byName[name] = nil
+			if !reported[tableName] {
+				reported[tableName] = true
+				result.UnresolvedTables = append(result.UnresolvedTables, tableName)
+			}
+		}
+		lookups[alias] = byName[name]
+	}
+	sort.Strings(result.UnresolvedTables)
+
+	add := func(qualifiedName string, table *TableMatch, columnName string) {
+		result.Columns[qualifiedName] = table.Columns[columnName]
+		result.Sources[qualifiedName] = ColumnSource{
+			Database: table.Database,
+			Schema:   table.Schema,
+			Table:    table.Table,
+			Column:   columnName,
+		}
+	}
 
 	for _, columnRef := range qr.Columns {
 		if columnRef == "*" {
 			// Wildcard - resolve all columns from all referenced tables
-			for alias, tableName := range qr.Tables {
-				tableColumns := qr.findTableInAnySchema(schema, tableName)
-				if tableColumns != nil {
-					for colName, colType := range tableColumns {
-						qualifiedName := alias + "." + colName
-						resolved[qualifiedName] = colType
-						logger.L().Debugw("Resolved wildcard column",
-							"table", tableName,
-							"column", colName,
-							"type", colType)
-					}
-				} else {
+			for _, alias := range aliases {
+				tableName := qr.Tables[alias]
+				table := lookups[alias]
+				if table == nil {
 					logger.L().Warnw("Could not resolve wildcard table in any schema",
 						"table", tableName)
+					continue
+				}
+				for colName, colType := range table.Columns {
+					add(alias+"."+colName, table, colName)
+					logger.L().Debugw("Resolved wildcard column",
+						"table", tableName,
+						"column", colName,
+						"type", colType)
 				}
 			}
 			continue
@@ -477,22 +597,22 @@ func (qr *QueryRefs) ResolveColumns(schema SchemaMap) map[string]string {
 
 				// Resolve table alias to actual table name
 				if actualTable, exists := qr.Tables[tableAlias]; exists {
-					tableColumns := qr.findTableInAnySchema(schema, actualTable)
-					if tableColumns != nil {
-						if colType, exists := tableColumns[columnName]; exists {
-							resolved[columnRef] = colType
-							logger.L().Debugw("Resolved qualified column",
-								"column", columnRef,
-								"table", actualTable,
-								"type", colType)
-						} else {
-							logger.L().Warnw("Column not found in table",
-								"column", columnName,
-								"table", actualTable)
-						}
-					} else {
+					table := lookups[tableAlias]
+					if table == nil {
 						logger.L().Warnw("Could not resolve qualified table in any schema",
 							"table", actualTable)
+						continue
+					}
+					if colType, exists := table.Columns[columnName]; exists {
+						add(columnRef, table, columnName)
+						logger.L().Debugw("Resolved qualified column",
+							"column", columnRef,
+							"table", actualTable,
+							"type", colType)
+					} else {
+						logger.L().Warnw("Column not found in table",
+							"column", columnName,
+							"table", actualTable)
 					}
 				}
 			}
@@ -500,19 +620,20 @@ func (qr *QueryRefs) ResolveColumns(schema SchemaMap) map[string]string {
 			// Unqualified column reference - try to resolve against all known tables
 			columnName := columnRef
 			found := false
-			for alias, tableName := range qr.Tables {
-				tableColumns := qr.findTableInAnySchema(schema, tableName)
-				if tableColumns != nil {
-					if colType, exists := tableColumns[columnName]; exists {
-						qualifiedName := alias + "." + columnName
-						resolved[qualifiedName] = colType
-						found = true
-						logger.L().Debugw("Resolved unqualified column",
-							"column", columnName,
-							"table", tableName,
-							"type", colType)
-						break // Stop after first match to avoid duplicates
-					}
+			for _, alias := range aliases {
+				tableName := qr.Tables[alias]
+				table := lookups[alias]
+				if table == nil {
+					continue
+				}
+				if colType, exists := table.Columns[columnName]; exists {
+					add(alias+"."+columnName, table, columnName)
+					found = true
+					logger.L().Debugw("Resolved unqualified column",
+						"column", columnName,
+						"table", tableName,
+						"type", colType)
+					break // Stop after first match to avoid duplicates
 				}
 			}
 			if !found {
@@ -524,24 +645,24 @@ func (qr *QueryRefs) ResolveColumns(schema SchemaMap) map[string]string {
 
 	logger.L().Debugw("Column resolution completed",
 		"input_columns", len(qr.Columns),
-		"resolved_columns", len(resolved))
+		"resolved_columns", len(result.Columns),
+		"ambiguous_tables", len(result.AmbiguousTables),
+		"unresolved_tables", len(result.UnresolvedTables))
 
-	return resolved
+	return result
 }
 
-// findTableInAnySchema searches for a table across all schemas
-// Returns the table's column map if found, nil otherwise
-func (qr *QueryRefs) findTableInAnySchema(schema SchemaMap, tableName string) map[string]string {
-	for schemaName, tables := range schema {
-		if tableColumns, exists := tables[tableName]; exists {
-			logger.L().Debugw("Found table in schema",
-				"table", tableName,
-				"schema", schemaName,
-				"columns", len(tableColumns))
-			return tableColumns
-		}
+// recordQualifier stores the schema/database prefix of a table reference, if
+// any, under the name the query refers to the table by (alias or table name).
+func (qr *QueryRefs) recordQualifier(key, fullTableName string) {
+	idx := strings.LastIndex(fullTableName, ".")
+	if idx <= 0 {
+		return
+	}
+	if qr.Qualifiers == nil {
+		qr.Qualifiers = make(map[string]string)
 	}
-	return nil
+	qr.Qualifiers[key] = fullTableName[:idx]
 }
 
 // isNumeric checks if a string is a numeric literal.
@@ -0,0 +1,339 @@
+package enrich
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+)
+
+// SchemaCatalog maps database names to their schemas: [db_name][schema_name][table_name][column_name] = type.
+// Schemas loaded without database information (e.g. via LoadSchemaCSV) live under the empty database name.
+type SchemaCatalog map[string]SchemaMap
+
+// Table lookup statuses
+const (
+	TableFound     = "found"     // Exactly one table (or several identical ones) matched
+	TableMissing   = "missing"   // No table matched
+	TableAmbiguous = "ambiguous" // Several different tables matched and the context can't tell them apart
+)
+
+// ResolutionContext carries the per-event information used to pick the right table
+// when the same name exists in several databases or schemas.
+type ResolutionContext struct {
+	DBSystem   string   // "postgres" or "mysql"
+	Database   string   // Event db_name; for MySQL this is the current database
+	User       string   // Event db_user, substituted for "$user" in the search path
+	SearchPath []string // PostgreSQL search_path in order (empty when unknown)
+}
+
+// TableMatch identifies a table found in the catalog.
+type TableMatch struct {
+	Database string
+	Schema   string
+	Table    string
+	Columns  map[string]string
+}
+
+// Location returns the "db.schema" location of the match (or just "schema" without a database).
+func (m TableMatch) Location() string {
+	if m.Database == "" {
+		return m.Schema
+	}
+	return m.Database + "." + m.Schema
+}
+
+// Merged flattens the catalog into a single schema map, ignoring database names.
+// Tables with the same schema and name in several databases have their columns combined.
+func (c SchemaCatalog) Merged() SchemaMap {
+	merged := make(SchemaMap)
+	for _, dbName := range c.DatabaseNames() {
+		for schemaName, tables := range c[dbName] {
+			if merged[schemaName] == nil {
+				merged[schemaName] = make(map[string]map[string]string)
+			}
+			for tableName, columns := range tables {
+				if merged[schemaName][tableName] == nil {
+					merged[schemaName][tableName] = make(map[string]string)
+				}
+				for col, typ := range columns {
+					merged[schemaName][tableName][col] = typ
+				}
+			}
+		}
+	}
+	return merged
+}
+
+// DatabaseNames returns the database names in the catalog, sorted.
+func (c SchemaCatalog) DatabaseNames() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// clone returns a deep copy of the catalog.
+func (c SchemaCatalog) clone() SchemaCatalog {
+	out := make(SchemaCatalog, len(c))
+	for dbName, schema := range c {
+		out[dbName] = schema.clone()
+	}
+	return out
+}
+
+// database returns the catalog entry name matching dbName (exact, then case-insensitive).
+func (c SchemaCatalog) database(dbName string) (string, bool) {
+	if dbName == "" {
+		return "", false
+	}
+	if _, ok := c[dbName]; ok {
+		return dbName, true
+	}
+	for name := range c {
+		if strings.EqualFold(name, dbName) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// LookupTable finds a table by name using the event context.
+//
+// The qualifier is the prefix written in the query ("schema", "db" for MySQL, or "db.schema").
+// Candidate databases are narrowed to the event's database when the catalog knows it.
+// Unqualified names follow the search path when one is known; otherwise every schema is searched.
+//
+// Several matches with identical columns are not ambiguous because any of them yields the
+// same classification. Different matches are reported as TableAmbiguous rather than guessed.
+func (c SchemaCatalog) LookupTable(ctx ResolutionContext, qualifier, tableName string) ([]TableMatch, string) {
+	var matches []TableMatch
+
+	parts := []string{}
+	if qualifier != "" {
+		parts = strings.Split(qualifier, ".")
+	}
+
+	switch len(parts) {
+	case 0:
+		for _, dbName := range c.candidateDatabases(ctx.Database) {
+			matches = append(matches, c.lookupUnqualified(ctx, dbName, tableName)...)
+		}
+	case 1:
+		// MySQL "db.table": the qualifier names a database
+		if strings.EqualFold(ctx.DBSystem, "mysql") {
+			if dbName, ok := c.database(parts[0]); ok {
+				matches = append(matches, c.lookupInSchemas(dbName, nil, tableName)...)
+				break
+			}
+		}
+		for _, dbName := range c.candidateDatabases(ctx.Database) {
+			matches = append(matches, c.lookupInSchemas(dbName, []string{parts[0]}, tableName)...)
+		}
+	default:
+		dbName, ok := c.database(parts[len(parts)-2])
+		if ok {
+			matches = append(matches, c.lookupInSchemas(dbName, []string{parts[len(parts)-1]}, tableName)...)
+		}
+	}
+
+	switch {
+	case len(matches) == 0:
+		return nil, TableMissing
+	case len(matches) == 1:
+		return matches, TableFound
+	}
+
+	for _, m := range matches[1:] {
+		if !reflect.DeepEqual(m.Columns, matches[0].Columns) {
+			logger.L().Debugw("Ambiguous table reference",
+				"table", tableName,
+				"qualifier", qualifier,
+				"database", ctx.Database,
+				"candidates", len(matches))
+			return matches, TableAmbiguous
+		}
+	}
+	return matches[:1], TableFound
+}
+
+// candidateDatabases returns the databases to search: the event database when the
+// catalog knows it, otherwise all databases.
+func (c SchemaCatalog) candidateDatabases(eventDB string) []string {
+	if dbName, ok := c.database(eventDB); ok {
+		return []string{dbName}
+	}
+	return c.DatabaseNames()
+}
+
+// lookupUnqualified resolves an unqualified table in one database. The first schema
+// on the search path containing the table wins; without a usable search path all
+// schemas are searched.
+func (c SchemaCatalog) lookupUnqualified(ctx ResolutionContext, dbName, tableName string) []TableMatch {
+	if len(ctx.SearchPath) > 0 {
+		for _, schemaName := range ctx.SearchPath {
+			if schemaName == "$user" {
+				schemaName = ctx.User
+			}
+			if found := c.lookupInSchemas(dbName, []string{schemaName}, tableName); len(found) > 0 {
+				return found
+			}
+		}
+		logger.L().Debugw("Table not on search path, searching all schemas",
+			"table", tableName,
+			"database", dbName,
+			"search_path", strings.Join(ctx.SearchPath, ","))
+	}
+	return c.lookupInSchemas(dbName, nil, tableName)
+}
+
+// lookupInSchemas finds the table in the given schemas of a database (all schemas when nil).
+func (c SchemaCatalog) lookupInSchemas(dbName string, schemas []string, tableName string) []TableMatch {
+	schema := c[dbName]
+	if schemas == nil {
+		schemas = make([]string, 0, len(schema))
+		for name := range schema {
+			schemas = append(schemas, name)
+		}
+		sort.Strings(schemas)
+	}
+
+	var matches []TableMatch
+	for _, schemaName := range schemas {
+		if columns, ok := schema[schemaName][tableName]; ok {
+			matches = append(matches, TableMatch{
+				Database: dbName,
+				Schema:   schemaName,
+				Table:    tableName,
+				Columns:  columns,
+			})
+		}
+	}
+	return matches
+}
+
+// Session statements that change name resolution for later statements
+var (
+	setSearchPathPattern = regexp.MustCompile(`(?i)^\s*SET\s+(?:SESSION\s+|LOCAL\s+)?search_path\s*(?:TO|=)\s*(.+?)\s*;?\s*$`)
+	setSchemaPattern     = regexp.MustCompile(`(?i)^\s*SET\s+SCHEMA\s+'?([A-Za-z_][A-Za-z0-9_$]*)'?\s*;?\s*$`)
+	useDatabasePattern   = regexp.MustCompile("(?i)^\\s*USE\\s+`?([A-Za-z0-9_$]+)`?\\s*;?\\s*$")
+)
+
+// sessionState is the name resolution state of one database session.
+type sessionState struct {
+	Database   string
+	SearchPath []string
+}
+
+// sessionTracker follows SET search_path / USE statements per session so later
+// events in the same session resolve tables the way the database did.
+// Sessions are keyed by connection_id when present, otherwise by db_system, db_name and db_user.
+type sessionTracker struct {
+	sessions map[string]*sessionState
+}
+
+func newSessionTracker() *sessionTracker {
+	return &sessionTracker{sessions: make(map[string]*sessionState)}
+}
+
+// Context observes the event's query for session changes and returns its resolution context.
+func (t *sessionTracker) Context(event map[string]interface{}, rawQuery string) ResolutionContext {
+	dbSystem, _ := event["db_system"].(string)
+	dbName, _ := event["db_name"].(string)
+	dbUser, _ := event["db_user"].(string)
+
+	key := sessionKey(event)
+	state := t.sessions[key]
+
+	if m := setSearchPathPattern.FindStringSubmatch(rawQuery); m != nil {
+		state = t.ensure(key, state)
+		state.SearchPath = parseSearchPath(m[1])
+	} else if m := setSchemaPattern.FindStringSubmatch(rawQuery); m != nil {
+		state = t.ensure(key, state)
+		state.SearchPath = []string{m[1]}
+	} else if m := useDatabasePattern.FindStringSubmatch(rawQuery); m != nil {
+		state = t.ensure(key, state)
+		state.Database = m[1]
+	}
+
+	ctx := ResolutionContext{DBSystem: dbSystem, Database: dbName, User: dbUser}
+	if state != nil {
+		if state.Database != "" {
+			ctx.Database = state.Database
+		}
+		ctx.SearchPath = state.SearchPath
+	}
+
+	// An explicit search_path on the event (top level or meta) wins
+	if path := eventSearchPath(event); len(path) > 0 {
+		ctx.SearchPath = path
+	}
+	return ctx
+}
+
+func (t *sessionTracker) ensure(key string, state *sessionState) *sessionState {
+	if state == nil {
+		state = &sessionState{}
+		t.sessions[key] = state
+	}
+	return state
+}
+
+// sessionKey identifies the session an event belongs to.
+func sessionKey(event map[string]interface{}) string {
+	dbSystem, _ := event["db_system"].(string)
+	if connID, ok := event["connection_id"]; ok && connID != nil {
+		return fmt.Sprintf("%s|conn|%v", dbSystem, connID)
+	}
+	dbName, _ := event["db_name"].(string)
+	dbUser, _ := event["db_user"].(string)
+	return dbSystem + "|" + dbName + "|" + dbUser
+}
+
+// eventSearchPath reads a search_path carried on the event itself, either at the top
+// level or under meta. Both comma-separated strings and arrays are accepted.
+func eventSearchPath(event map[string]interface{}) []string {
+	raw, ok := event["search_path"]
+	if !ok {
+		if meta, isMap := event["meta"].(map[string]interface{}); isMap {
+			raw, ok = meta["search_path"]
+		}
+	}
+	if !ok || raw == nil {
+		return nil
+	}
+
+	switch v := raw.(type) {
+	case string:
+		return parseSearchPath(v)
+	case []interface{}:
+		path := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				path = append(path, s)
+			}
+		}
+		return path
+	case []string:
+		return v
+	}
+	return nil
+}
+
+// parseSearchPath splits a search_path value like `"$user", public, 'billing'`.
+func parseSearchPath(value string) []string {
+	var path []string
+	for _, part := range strings.Split(value, ",") {
+		name := strings.Trim(strings.TrimSpace(part), `"'`)
+		if name == "" || strings.EqualFold(name, "DEFAULT") {
+			continue
+		}
+		path = append(path, name)
+	}
+	return path
+}
@@ -0,0 +1,278 @@
+package enrich
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestCatalog returns two tenant databases with identical table names but
+// different columns, plus a database where "users" exists in two schemas.
+func createTestCatalog() SchemaCatalog {
+	return SchemaCatalog{
+		"tenant_a": {
+			"public": {"users": {"id": "INT", "ssn": "VARCHAR"}},
+		},
+		"tenant_b": {
+			"public": {"users": {"id": "INT", "nickname": "TEXT"}},
+		},
+		"shared": {
+			"public":  {"users": {"id": "INT", "email": "TEXT"}},
+			"billing": {"users": {"id": "INT", "card_last4": "CHAR"}},
+			"audit":   {"log": {"id": "INT"}},
+		},
+	}
+}
+
+func TestSchemaCatalog_LookupTable(t *testing.T) {
+	catalog := createTestCatalog()
+
+	tests := []struct {
+		name      string
+		ctx       ResolutionContext
+		qualifier string
+		table     string
+		status    string
+		locations []string
+	}{
+		{
+			name:      "event_database_selects_tenant",
+			ctx:       ResolutionContext{DBSystem: "postgres", Database: "tenant_a"},
+			table:     "users",
+			status:    TableFound,
+			locations: []string{"tenant_a.public"},
+		},
+		{
+			name:      "unknown_database_is_ambiguous",
+			ctx:       ResolutionContext{DBSystem: "postgres", Database: "tenant_z"},
+			table:     "users",
+			status:    TableAmbiguous,
+			locations: []string{"shared.billing", "shared.public", "tenant_a.public", "tenant_b.public"},
+		},
+		{
+			name:      "two_schemas_without_search_path_are_ambiguous",
+			ctx:       ResolutionContext{DBSystem: "postgres", Database: "shared"},
+			table:     "users",
+			status:    TableAmbiguous,
+			locations: []string{"shared.billing", "shared.public"},
+		},
+		{
+			name:      "search_path_picks_first_schema",
+			ctx:       ResolutionContext{DBSystem: "postgres", Database: "shared", SearchPath: []string{"billing", "public"}},
+			table:     "users",
+			status:    TableFound,
+			locations: []string{"shared.billing"},
+		},
+		{
+			name:      "search_path_user_substitution",
+			ctx:       ResolutionContext{DBSystem: "postgres", Database: "shared", User: "billing", SearchPath: []string{"$user", "public"}},
+			table:     "users",
+			status:    TableFound,
+			locations: []string{"shared.billing"},
+		},
+		{
+			name:      "search_path_miss_falls_back_to_all_schemas",
+			ctx:       ResolutionContext{DBSystem: "postgres", Database: "shared", SearchPath: []string{"public"}},
+			table:     "log",
+			status:    TableFound,
+			locations: []string{"shared.audit"},
+		},
+		{
+			name:      "schema_qualifier",
+			ctx:       ResolutionContext{DBSystem: "postgres", Database: "shared"},
+			qualifier: "public",
+			table:     "users",
+			status:    TableFound,
+			locations: []string{"shared.public"},
+		},
+		{
+			name:      "database_and_schema_qualifier",
+			ctx:       ResolutionContext{DBSystem: "postgres"},
+			qualifier: "tenant_b.public",
+			table:     "users",
+			status:    TableFound,
+			locations: []string{"tenant_b.public"},
+		},
+		{
+			name:      "mysql_database_qualifier",
+			ctx:       ResolutionContext{DBSystem: "mysql", Database: "tenant_a"},
+			qualifier: "tenant_b",
+			table:     "users",
+			status:    TableFound,
+			locations: []string{"tenant_b.public"},
+		},
+		{
+			name:   "missing_table",
+			ctx:    ResolutionContext{DBSystem: "postgres", Database: "tenant_a"},
+			table:  "orders",
+			status: TableMissing,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, status := catalog.LookupTable(tt.ctx, tt.qualifier, tt.table)
+			assert.Equal(t, tt.status, status)
+
+			var locations []string
+			for _, m := range matches {
+				locations = append(locations, m.Location())
+			}
+			assert.Equal(t, tt.locations, locations)
+		})
+	}
+}
+
+func TestSchemaCatalog_IdenticalTablesAreNotAmbiguous(t *testing.T) {
+	catalog := SchemaCatalog{
+		"db1": {"public": {"users": {"id": "INT", "ssn": "VARCHAR"}}},
+		"db2": {"public": {"users": {"id": "INT", "ssn": "VARCHAR"}}},
+	}
+
+	matches, status := catalog.LookupTable(ResolutionContext{}, "", "users")
+	assert.Equal(t, TableFound, status)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "db1", matches[0].Database)
+}
+
+func TestSessionTracker_Context(t *testing.T) {
+	tracker := newSessionTracker()
+	session := func(query string) map[string]interface{} {
+		return map[string]interface{}{
+			"db_system":     "postgres",
+			"db_name":       "shared",
+			"db_user":       "app",
+			"connection_id": "42",
+			"raw_query":     query,
+		}
+	}
+
+	ctx := tracker.Context(session("SELECT 1"), "SELECT 1")
+	assert.Equal(t, "shared", ctx.Database)
+	assert.Empty(t, ctx.SearchPath)
+
+	tracker.Context(session(""), `SET search_path TO billing, "$user", public;`)
+	ctx = tracker.Context(session(""), "SELECT id FROM users")
+	assert.Equal(t, []string{"billing", "$user", "public"}, ctx.SearchPath)
+
+	// Other connections are not affected
+	other := session("")
+	other["connection_id"] = "43"
+	assert.Empty(t, tracker.Context(other, "SELECT id FROM users").SearchPath)
+
+	// An explicit search_path on the event wins
+	withPath := session("")
+	withPath["meta"] = map[string]interface{}{"search_path": []interface{}{"audit"}}
+	assert.Equal(t, []string{"audit"}, tracker.Context(withPath, "SELECT 1").SearchPath)
+
+	// MySQL USE changes the current database for the session
+	mysql := map[string]interface{}{"db_system": "mysql", "db_name": "tenant_a", "db_user": "app"}
+	tracker.Context(mysql, "USE `tenant_b`")
+	assert.Equal(t, "tenant_b", tracker.Context(mysql, "SELECT id FROM users").Database)
+}
+
+func TestParseQuery_Qualifiers(t *testing.T) {
+	refs := ParseQuery("SELECT u.ssn, o.total FROM tenant_a.public.users u JOIN billing.orders o ON u.id = o.user_id JOIN items i ON i.id = o.item_id")
+	assert.Equal(t, "tenant_a.public", refs.Qualifiers["users"])
+	assert.Equal(t, "billing", refs.Qualifiers["orders"])
+	_, hasItems := refs.Qualifiers["items"]
+	assert.False(t, hasItems)
+}
+
+func TestQueryRefs_ResolveColumnsInContext(t *testing.T) {
+	catalog := createTestCatalog()
+	refs := ParseQuery("SELECT u.ssn, u.nickname FROM users u")
+
+	resA := refs.ResolveColumnsInContext(catalog, ResolutionContext{DBSystem: "postgres", Database: "tenant_a"})
+	assert.Equal(t, map[string]string{"u.ssn": "VARCHAR"}, resA.Columns)
+	assert.Equal(t, ColumnSource{Database: "tenant_a", Schema: "public", Table: "users", Column: "ssn"}, resA.Sources["u.ssn"])
+	assert.Empty(t, resA.AmbiguousTables)
+
+	resB := refs.ResolveColumnsInContext(catalog, ResolutionContext{DBSystem: "postgres", Database: "tenant_b"})
+	assert.Equal(t, map[string]string{"u.nickname": "TEXT"}, resB.Columns)
+
+	resUnknown := refs.ResolveColumnsInContext(catalog, ResolutionContext{DBSystem: "postgres"})
+	assert.Empty(t, resUnknown.Columns)
+	assert.Len(t, resUnknown.AmbiguousTables["users"], 4)
+
+	missingRefs := ParseQuery("SELECT total FROM orders")
+	missing := missingRefs.ResolveColumnsInContext(catalog, ResolutionContext{Database: "tenant_a"})
+	assert.Equal(t, []string{"orders"}, missing.UnresolvedTables)
+}
+
+func TestQueryRefs_ResolveColumnsSameTableTwoSchemas(t *testing.T) {
+	catalog := createTestCatalog()
+	ctx := ResolutionContext{DBSystem: "postgres", Database: "shared"}
+
+	refs := ParseQuery("SELECT a.card_last4, b.email FROM billing.users a JOIN public.users b ON a.id = b.id")
+	assert.Equal(t, "billing", refs.Qualifiers["a"])
+	assert.Equal(t, "public", refs.Qualifiers["b"])
+
+	res := refs.ResolveColumnsInContext(catalog, ctx)
+	assert.Equal(t, "CHAR", res.Columns["a.card_last4"])
+	assert.Equal(t, "TEXT", res.Columns["b.email"])
+	assert.Equal(t, ColumnSource{Database: "shared", Schema: "billing", Table: "users", Column: "card_last4"}, res.Sources["a.card_last4"])
+	assert.Equal(t, ColumnSource{Database: "shared", Schema: "public", Table: "users", Column: "email"}, res.Sources["b.email"])
+
+	// The schemas written first and second don't matter
+	swapped := ParseQuery("SELECT a.card_last4, b.email FROM public.users b JOIN billing.users a ON a.id = b.id")
+	res = swapped.ResolveColumnsInContext(catalog, ctx)
+	assert.Equal(t, "CHAR", res.Columns["a.card_last4"])
+	assert.Equal(t, "TEXT", res.Columns["b.email"])
+}
+
+func TestEnricher_MultiDatabaseResolution(t *testing.T) {
+	base := createTestEnricher(false, true)
+	enricher := NewEnricherWithHistory(NewSchemaHistoryFromCatalog(createTestCatalog()), base.dict, base.riskScoring,
+		EnrichmentOptions{Debug: true})
+
+	event := func(id, dbName, query string) map[string]interface{} {
+		return map[string]interface{}{
+			"event_id":   id,
+			"timestamp":  "2025-09-01T10:00:00Z",
+			"db_system":  "postgres",
+			"db_name":    dbName,
+			"db_user":    "app",
+			"query_type": "SELECT",
+			"raw_query":  query,
+		}
+	}
+
+	// Same query, different tenant databases
+	a := enricher.ProcessEvent(event("a", "tenant_a", "SELECT ssn FROM users"))
+	assert.True(t, a.ShouldEmit)
+	assert.Equal(t, []string{"PII"}, a.Categories)
+	assert.NotContains(t, a.EnrichedEvent, "schema_status")
+
+	b := enricher.ProcessEvent(event("b", "tenant_b", "SELECT ssn FROM users"))
+	assert.False(t, b.ShouldEmit)
+
+	// Unknown database: not guessed, emitted as ambiguous
+	amb := enricher.ProcessEvent(event("amb", "", "SELECT ssn FROM users"))
+	assert.True(t, amb.ShouldEmit)
+	assert.Empty(t, amb.Categories)
+	assert.Equal(t, "ambiguous", amb.EnrichedEvent["schema_status"])
+	assert.Contains(t, amb.EnrichedEvent["ambiguous_tables"], "users")
+	debugInfo := amb.EnrichedEvent["debug_info"].(map[string]interface{})
+	assert.Equal(t, "ambiguous", debugInfo["schema_status"])
+
+	// search_path set earlier in the session disambiguates the shared database
+	enricher.ProcessEvent(event("set", "shared", "SET search_path TO billing, public"))
+	card := enricher.ProcessEvent(event("card", "shared", "SELECT card_last4 FROM users"))
+	assert.Equal(t, []string{"Financial"}, card.Categories)
+	assert.NotContains(t, card.EnrichedEvent, "schema_status")
+}
+
+func TestSchemaHistory_ApplyDDLInDatabase(t *testing.T) {
+	h := NewSchemaHistoryFromCatalog(createTestCatalog())
+	ts := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	require.True(t, h.ApplyDDLInDatabase(ts, "tenant_b", "ALTER TABLE users ADD COLUMN ssn varchar(11)", "public"))
+
+	catalog := h.VersionAt(ts).Catalog
+	assert.Equal(t, "VARCHAR", catalog["tenant_b"]["public"]["users"]["ssn"])
+	assert.NotContains(t, catalog["shared"]["public"]["users"], "ssn")
+	assert.NotContains(t, h.VersionAt(ts.Add(-time.Second)).Catalog["tenant_b"]["public"]["users"], "ssn")
+}
@@ -0,0 +1,322 @@
+package enrich
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+)
+
+// SchemaVersion is a schema snapshot together with the time from which it is valid.
+// A zero ValidFrom means the snapshot applies to all events before the next version.
+type SchemaVersion struct {
+	ValidFrom time.Time
+	Source    string        // Origin of the version: CSV path or "ddl" for replayed statements
+	Schema    SchemaMap     // All databases merged; used where the database doesn't matter
+	Catalog   SchemaCatalog // Per-database schemas; used for context-aware table resolution
+
+	id      uint64 // Unique per history; lets derived data (e.g. view lineage) be cached per version
+	fromDDL bool   // Derived by replaying DDL; rebuilt when DDL arrives out of order
+}
+
+// SchemaHistory holds a time-ordered list of schema versions.
+// Audit logs often span months while a schema CSV is a single snapshot, so
+// column resolution needs the schema that was in effect when each event happened.
+// Versions come either from explicit snapshots (LoadSchemaSnapshot) or from
+// DDL statements replayed from the audit stream itself (ApplyDDL).
+//
+// Snapshots are authoritative: replayed DDL never changes them. The versions
+// derived from DDL between two snapshots are rebuilt from the earlier snapshot
+// whenever a statement arrives out of order, so they always reflect the
+// statements in timestamp order.
+type SchemaHistory struct {
+	versions []SchemaVersion // Sorted by ValidFrom (ascending)
+	ddl      []ddlStatement  // Statements seen, sorted by time (ascending)
+	lastID   uint64
+}
+
+// ddlStatement is a DDL statement recorded for replay
+type ddlStatement struct {
+	ts            time.Time
+	database      string
+	query         string
+	defaultSchema string
+}
+
+// NewSchemaHistory creates a history with a single base schema valid for all time.
+// The schema carries no database information; see NewSchemaHistoryFromCatalog.
+func NewSchemaHistory(base SchemaMap) *SchemaHistory {
+	if base == nil {
+		base = make(SchemaMap)
+	}
+	return &SchemaHistory{
+		versions: []SchemaVersion{{Source: "base", Schema: base, Catalog: SchemaCatalog{"": base}, id: 1}},
+		lastID:   1,
+	}
+}
+
+// NewSchemaHistoryFromCatalog creates a history with a single per-database base catalog
+// valid for all time.
+func NewSchemaHistoryFromCatalog(base SchemaCatalog) *SchemaHistory {
+	if base == nil {
+		base = make(SchemaCatalog)
+	}
+	return &SchemaHistory{
+		versions: []SchemaVersion{{Source: "base", Schema: base.Merged(), Catalog: base, id: 1}},
+		lastID:   1,
+	}
+}
+
+// AddVersion inserts a schema snapshot without database information valid from the given time.
+// A version with the same ValidFrom replaces the existing one.
+func (h *SchemaHistory) AddVersion(validFrom time.Time, source string, schema SchemaMap) {
+	h.addSnapshot(SchemaVersion{ValidFrom: validFrom, Source: source, Schema: schema, Catalog: SchemaCatalog{"": schema}})
+}
+
+// AddCatalogVersion inserts a per-database schema snapshot valid from the given time.
+// A version with the same ValidFrom replaces the existing one.
+func (h *SchemaHistory) AddCatalogVersion(validFrom time.Time, source string, catalog SchemaCatalog) {
+	h.addSnapshot(SchemaVersion{ValidFrom: validFrom, Source: source, Schema: catalog.Merged(), Catalog: catalog})
+}
+
+// addSnapshot inserts an authoritative version. DDL already replayed after it
+// is replayed again from the snapshot.
+func (h *SchemaHistory) addSnapshot(v SchemaVersion) {
+	i := h.addVersion(v)
+	if len(h.ddl) > 0 {
+		h.rebuild(i, -1)
+	}
+}
+
+// addVersion inserts a version and returns its index
+func (h *SchemaHistory) addVersion(v SchemaVersion) int {
+	v.ValidFrom = v.ValidFrom.UTC()
+	h.lastID++
+	v.id = h.lastID
+
+	i := sort.Search(len(h.versions), func(i int) bool {
+		return !h.versions[i].ValidFrom.Before(v.ValidFrom)
+	})
+	if i < len(h.versions) && h.versions[i].ValidFrom.Equal(v.ValidFrom) {
+		h.versions[i] = v
+	} else {
+		h.versions = append(h.versions, SchemaVersion{})
+		copy(h.versions[i+1:], h.versions[i:])
+		h.versions[i] = v
+	}
+
+	logger.L().Debugw("Added schema version",
+		"valid_from", v.ValidFrom.Format(time.RFC3339),
+		"source", v.Source,
+		"databases", len(v.Catalog),
+		"schemas", len(v.Schema),
+		"versions", len(h.versions))
+	return i
+}
+
+// LoadSchemaSnapshot loads a schema CSV and registers it as valid from the given time.
+func (h *SchemaHistory) LoadSchemaSnapshot(validFrom time.Time, path string) error {
+	catalog, err := LoadSchemaCatalogCSV(path)
+	if err != nil {
+		return err
+	}
+	h.AddCatalogVersion(validFrom, path, catalog)
+	return nil
+}
+
+// VersionAt returns the schema version in effect at the given time.
+// A zero time (unknown event timestamp) resolves to the latest version.
+// Times before the first version resolve to the earliest version.
+func (h *SchemaHistory) VersionAt(ts time.Time) SchemaVersion {
+	if len(h.versions) == 0 {
+		return SchemaVersion{Schema: SchemaMap{}, Catalog: SchemaCatalog{}}
+	}
+	if ts.IsZero() {
+		return h.versions[len(h.versions)-1]
+	}
+
+	// Index of the first version strictly after ts; the one before it is in effect
+	i := sort.Search(len(h.versions), func(i int) bool {
+		return h.versions[i].ValidFrom.After(ts)
+	})
+	if i == 0 {
+		return h.versions[0]
+	}
+	return h.versions[i-1]
+}
+
+// At returns the schema map in effect at the given time.
+func (h *SchemaHistory) At(ts time.Time) SchemaMap {
+	return h.VersionAt(ts).Schema
+}
+
+// Latest returns the most recent schema map.
+func (h *SchemaHistory) Latest() SchemaMap {
+	return h.VersionAt(time.Time{}).Schema
+}
+
+// Versions returns the number of schema versions in the history.
+func (h *SchemaHistory) Versions() int {
+	return len(h.versions)
+}
+
+// ApplyDDL replays a CREATE/ALTER/DROP TABLE statement seen at the given time.
+// The schema in effect at ts is copied, modified and stored as a new version valid from ts.
+// defaultSchema is used for unqualified CREATE TABLE statements (e.g. "public" for
+// PostgreSQL, "default" for MySQL).
+//
+// Returns true if the statement changed the schema.
+func (h *SchemaHistory) ApplyDDL(ts time.Time, query, defaultSchema string) bool {
+	return h.ApplyDDLInDatabase(ts, "", query, defaultSchema)
+}
+
+// ApplyDDLInDatabase is ApplyDDL for a statement executed against a specific database.
+// The statement is applied to that database's schemas when the catalog knows it,
+// otherwise to the only database in the catalog, otherwise to the schemas without
+// database information.
+//
+// A statement older than DDL already replayed (e.g. from files enriched out of
+// order) makes the versions derived since the snapshot in effect be replayed
+// again in timestamp order, up to the next snapshot. Snapshots themselves are
+// never changed, and a statement at the exact time of a snapshot is taken to be
+// part of it.
+func (h *SchemaHistory) ApplyDDLInDatabase(ts time.Time, database, query, defaultSchema string) bool {
+	if ts.IsZero() {
+		return false
+	}
+	stmt := ddlStatement{ts: ts.UTC(), database: database, query: query, defaultSchema: defaultSchema}
+
+	// Record the statement after those at the same time, keeping arrival order among them
+	n := sort.Search(len(h.ddl), func(i int) bool { return h.ddl[i].ts.After(stmt.ts) })
+	h.ddl = append(h.ddl, ddlStatement{})
+	copy(h.ddl[n+1:], h.ddl[n:])
+	h.ddl[n] = stmt
+
+	snapshot := h.snapshotAt(stmt.ts)
+	if h.versions[snapshot].ValidFrom.Equal(stmt.ts) {
+		return false
+	}
+	if n < len(h.ddl)-1 {
+		logger.L().Debugw("Replaying DDL after out-of-order statement",
+			"ddl_time", stmt.ts.Format(time.RFC3339),
+			"snapshot", h.versions[snapshot].Source)
+		return h.rebuild(snapshot, n)
+	}
+
+	// In order: the statement extends the version in effect at its time
+	next, ok := applyDDLToCatalog(h.VersionAt(stmt.ts).Catalog, database, query, defaultSchema)
+	if !ok {
+		return false
+	}
+	h.addVersion(SchemaVersion{ValidFrom: stmt.ts, Source: "ddl", Schema: next.Merged(), Catalog: next, fromDDL: true})
+	return true
+}
+
+// snapshotAt returns the index of the authoritative version in effect at ts
+func (h *SchemaHistory) snapshotAt(ts time.Time) int {
+	for i := len(h.versions) - 1; i > 0; i-- {
+		if !h.versions[i].fromDDL && !h.versions[i].ValidFrom.After(ts) {
+			return i
+		}
+	}
+	return 0
+}
+
+// rebuild replaces the versions derived from DDL after the snapshot at index
+// snapshot, up to the next snapshot, by replaying the recorded statements of
+// that period in timestamp order.
+//
+// Args:
+//   - snapshot: Index of the authoritative version to replay from
+//   - target: Index in h.ddl of the statement of interest, or -1
+//
+// Returns true if the target statement changed the schema.
+func (h *SchemaHistory) rebuild(snapshot, target int) bool {
+	from := h.versions[snapshot]
+	end := snapshot + 1
+	for end < len(h.versions) && h.versions[end].fromDDL {
+		end++
+	}
+	var until time.Time
+	if end < len(h.versions) {
+		until = h.versions[end].ValidFrom
+	}
+	h.versions = append(h.versions[:snapshot+1], h.versions[end:]...)
+
+	changed := false
+	cur := from.Catalog
+	for i, stmt := range h.ddl {
+		if !stmt.ts.After(from.ValidFrom) {
+			continue
+		}
+		if !until.IsZero() && !stmt.ts.Before(until) {
+			break
+		}
+		next, ok := applyDDLToCatalog(cur, stmt.database, stmt.query, stmt.defaultSchema)
+		if !ok {
+			continue
+		}
+		cur = next
+		h.addVersion(SchemaVersion{ValidFrom: stmt.ts, Source: "ddl", Schema: next.Merged(), Catalog: next, fromDDL: true})
+		if i == target {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// applyDDLToCatalog applies a statement to a copy of the catalog, in the
+// database chosen as described in ApplyDDLInDatabase.
+//
+// Returns:
+//   - The modified copy
+//   - false if the statement didn't change the schema
+func applyDDLToCatalog(catalog SchemaCatalog, database, query, defaultSchema string) (SchemaCatalog, bool) {
+	next := catalog.clone()
+	target, ok := next.database(database)
+	if !ok {
+		target = ""
+		if names := next.DatabaseNames(); len(names) == 1 {
+			target = names[0]
+		}
+	}
+	if next[target] == nil {
+		next[target] = make(SchemaMap)
+	}
+	if !applyDDL(next[target], query, defaultSchema) {
+		return nil, false
+	}
+	return next, true
+}
+
+// ParseSchemaSnapshotSpec parses a "<RFC3339 time>=<path>" snapshot specification
+// as used by the enrich --schema-snapshot flag.
+func ParseSchemaSnapshotSpec(spec string) (time.Time, string, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+		return time.Time{}, "", fmt.Errorf("invalid schema snapshot %q: expected <RFC3339 time>=<path>", spec)
+	}
+	ts, err := time.Parse(time.RFC3339, strings.TrimSpace(parts[0]))
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid schema snapshot time %q: %w", parts[0], err)
+	}
+	return ts, strings.TrimSpace(parts[1]), nil
+}
+
+// clone returns a deep copy of the schema map.
+func (sm SchemaMap) clone() SchemaMap {
+	out := make(SchemaMap, len(sm))
+	for schemaName, tables := range sm {
+		out[schemaName] = make(map[string]map[string]string, len(tables))
+		for tableName, columns := range tables {
+			cols := make(map[string]string, len(columns))
+			for col, typ := range columns {
+				cols[col] = typ
+			}
+			out[schemaName][tableName] = cols
+		}
+	}
+	return out
+}
@@ -0,0 +1,353 @@
+package enrich
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaHistory_VersionAt(t *testing.T) {
+	q1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	q3 := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	base := SchemaMap{"public": {"patient": {"ssn": "VARCHAR"}}}
+	v1 := SchemaMap{"public": {"patient": {"ssn": "VARCHAR", "email": "TEXT"}}}
+	v3 := SchemaMap{"public": {"patient": {"email": "TEXT"}}}
+
+	h := NewSchemaHistory(base)
+	h.AddVersion(q3, "q3.csv", v3)
+	h.AddVersion(q1, "q1.csv", v1)
+	require.Equal(t, 3, h.Versions())
+
+	tests := []struct {
+		name     string
+		ts       time.Time
+		expected SchemaMap
+		source   string
+	}{
+		{"before_first_snapshot", q1.Add(-time.Hour), base, "base"},
+		{"exactly_at_snapshot", q1, v1, "q1.csv"},
+		{"between_snapshots", q1.Add(30 * 24 * time.Hour), v1, "q1.csv"},
+		{"after_last_snapshot", q3.Add(time.Hour), v3, "q3.csv"},
+		{"zero_time_uses_latest", time.Time{}, v3, "q3.csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := h.VersionAt(tt.ts)
+			assert.Equal(t, tt.expected, v.Schema)
+			assert.Equal(t, tt.source, v.Source)
+		})
+	}
+}
+
+func TestSchemaHistory_AddVersionReplacesSameTime(t *testing.T) {
+	ts := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	h := NewSchemaHistory(nil)
+	h.AddVersion(ts, "a", SchemaMap{"a": {}})
+	h.AddVersion(ts, "b", SchemaMap{"b": {}})
+
+	assert.Equal(t, 2, h.Versions())
+	assert.Equal(t, "b", h.VersionAt(ts).Source)
+}
+
+func TestSchemaHistory_LoadSchemaSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "snapshot.csv")
+	content := `db_name,schema_name,table_name,column_name,column_type
+practicumdb,healthcare,patient,ssn,varchar(11)`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	ts := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	h := NewSchemaHistory(SchemaMap{})
+	require.NoError(t, h.LoadSchemaSnapshot(ts, path))
+
+	assert.Equal(t, "VARCHAR", h.At(ts).GetColumnType("healthcare", "patient", "ssn"))
+	assert.False(t, h.At(ts.Add(-time.Second)).HasColumn("healthcare", "patient", "ssn"))
+
+	assert.Error(t, h.LoadSchemaSnapshot(ts, filepath.Join(dir, "missing.csv")))
+}
+
+func TestParseSchemaSnapshotSpec(t *testing.T) {
+	ts, path, err := ParseSchemaSnapshotSpec("2025-07-01T00:00:00Z=schema_q3.csv")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), ts.UTC())
+	assert.Equal(t, "schema_q3.csv", path)
+
+	for _, bad := range []string{"schema.csv", "2025-07-01=schema.csv", "2025-07-01T00:00:00Z="} {
+		_, _, err := ParseSchemaSnapshotSpec(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestApplyDDL(t *testing.T) {
+	newSchema := func() SchemaMap {
+		return SchemaMap{
+			"healthcare": {
+				"patient": {"patient_id": "UUID", "ssn": "VARCHAR", "email": "TEXT"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		defaultSchema string
+		changed       bool
+		check         func(t *testing.T, s SchemaMap)
+	}{
+		{
+			name:          "create_table_unqualified",
+			query:         "CREATE TABLE billing (id SERIAL PRIMARY KEY, card_last4 CHAR(4) NOT NULL, amount DECIMAL(12,2), CONSTRAINT fk FOREIGN KEY (id) REFERENCES x(id));",
+			defaultSchema: "public",
+			changed:       true,
+			check: func(t *testing.T, s SchemaMap) {
+				assert.Equal(t, map[string]string{"id": "SERIAL", "card_last4": "CHAR", "amount": "DECIMAL"}, s["public"]["billing"])
+			},
+		},
+		{
+			name:    "create_table_qualified_if_not_exists",
+			query:   `CREATE TABLE IF NOT EXISTS healthcare."visit" ("diagnosis" text, visit_time timestamp with time zone DEFAULT now())`,
+			changed: true,
+			check: func(t *testing.T, s SchemaMap) {
+				assert.Equal(t, "TEXT", s.GetColumnType("healthcare", "visit", "diagnosis"))
+				assert.Equal(t, "TIMESTAMPTZ", s.GetColumnType("healthcare", "visit", "visit_time"))
+			},
+		},
+		{
+			name:    "alter_add_and_drop_column",
+			query:   "ALTER TABLE healthcare.patient ADD COLUMN phone varchar(20), DROP COLUMN email",
+			changed: true,
+			check: func(t *testing.T, s SchemaMap) {
+				assert.Equal(t, "VARCHAR", s.GetColumnType("healthcare", "patient", "phone"))
+				assert.False(t, s.HasColumn("healthcare", "patient", "email"))
+			},
+		},
+		{
+			name:    "alter_rename_column",
+			query:   "ALTER TABLE patient RENAME COLUMN ssn TO national_id",
+			changed: true,
+			check: func(t *testing.T, s SchemaMap) {
+				assert.Equal(t, "VARCHAR", s.GetColumnType("healthcare", "patient", "national_id"))
+				assert.False(t, s.HasColumn("healthcare", "patient", "ssn"))
+			},
+		},
+		{
+			name:    "alter_column_type",
+			query:   "ALTER TABLE patient ALTER COLUMN email TYPE varchar(255)",
+			changed: true,
+			check: func(t *testing.T, s SchemaMap) {
+				assert.Equal(t, "VARCHAR", s.GetColumnType("healthcare", "patient", "email"))
+			},
+		},
+		{
+			name:    "mysql_change_column",
+			query:   "ALTER TABLE patient CHANGE ssn tax_id char(11) NOT NULL",
+			changed: true,
+			check: func(t *testing.T, s SchemaMap) {
+				assert.Equal(t, "CHAR", s.GetColumnType("healthcare", "patient", "tax_id"))
+				assert.False(t, s.HasColumn("healthcare", "patient", "ssn"))
+			},
+		},
+		{
+			name:    "alter_rename_table",
+			query:   "ALTER TABLE healthcare.patient RENAME TO person",
+			changed: true,
+			check: func(t *testing.T, s SchemaMap) {
+				assert.True(t, s.HasColumn("healthcare", "person", "ssn"))
+				assert.Nil(t, s.GetTableColumns("healthcare", "patient"))
+			},
+		},
+		{
+			name:    "mysql_rename_table",
+			query:   "RENAME TABLE patient TO person",
+			changed: true,
+			check: func(t *testing.T, s SchemaMap) {
+				assert.True(t, s.HasColumn("healthcare", "person", "ssn"))
+			},
+		},
+		{
+			name:    "drop_table",
+			query:   "DROP TABLE IF EXISTS healthcare.patient CASCADE",
+			changed: true,
+			check: func(t *testing.T, s SchemaMap) {
+				assert.Nil(t, s.GetTableColumns("healthcare", "patient"))
+			},
+		},
+		{
+			name:    "add_constraint_is_ignored",
+			query:   "ALTER TABLE patient ADD CONSTRAINT ssn_unique UNIQUE (ssn)",
+			changed: false,
+		},
+		{
+			name:    "alter_unknown_table",
+			query:   "ALTER TABLE nowhere ADD COLUMN x int",
+			changed: false,
+		},
+		{
+			name:    "non_ddl_query",
+			query:   "SELECT ssn FROM patient",
+			changed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSchema()
+			changed := applyDDL(s, tt.query, tt.defaultSchema)
+			assert.Equal(t, tt.changed, changed)
+			if tt.check != nil {
+				tt.check(t, s)
+			}
+		})
+	}
+}
+
+func TestSchemaHistory_ApplyDDLCreatesVersion(t *testing.T) {
+	base := SchemaMap{"healthcare": {"patient": {"ssn": "VARCHAR", "email": "TEXT"}}}
+	h := NewSchemaHistory(base)
+
+	dropAt := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	assert.True(t, h.ApplyDDL(dropAt, "ALTER TABLE healthcare.patient DROP COLUMN ssn", "public"))
+	assert.False(t, h.ApplyDDL(time.Time{}, "ALTER TABLE healthcare.patient DROP COLUMN email", "public"))
+
+	// Events before the DDL still see the column; the base map is not mutated
+	assert.True(t, h.At(dropAt.Add(-time.Minute)).HasColumn("healthcare", "patient", "ssn"))
+	assert.False(t, h.At(dropAt).HasColumn("healthcare", "patient", "ssn"))
+	assert.True(t, base.HasColumn("healthcare", "patient", "ssn"))
+	assert.Equal(t, "ddl", h.VersionAt(dropAt).Source)
+}
+
+func TestSchemaHistory_ApplyDDLAroundSnapshot(t *testing.T) {
+	base := SchemaMap{"healthcare": {"patient": {"ssn": "VARCHAR"}, "visit": {"id": "INT"}}}
+	h := NewSchemaHistory(base)
+
+	createAt := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	dropAt := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	snapshotAt := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	alterAt := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	h.AddVersion(snapshotAt, "june.csv", SchemaMap{"healthcare": {"patient": {"ssn": "VARCHAR"}, "visit": {"id": "INT"}}})
+
+	// Seen in reverse order: an ALTER after the snapshot, a DROP before it,
+	// then a CREATE before the DROP
+	require.True(t, h.ApplyDDL(alterAt, "ALTER TABLE healthcare.visit ADD COLUMN note TEXT", "public"))
+	require.True(t, h.ApplyDDL(dropAt, "DROP TABLE healthcare.visit", "public"))
+	require.True(t, h.ApplyDDL(createAt, "CREATE TABLE healthcare.billing (iban TEXT)", "public"))
+
+	// The DROP doesn't reach past the snapshot, which still lists the table
+	assert.False(t, h.At(dropAt).HasColumn("healthcare", "visit", "id"))
+	assert.True(t, h.At(snapshotAt).HasColumn("healthcare", "visit", "id"))
+	assert.False(t, h.At(snapshotAt).HasColumn("healthcare", "visit", "note"))
+	assert.Equal(t, "june.csv", h.VersionAt(snapshotAt).Source)
+	assert.True(t, h.At(alterAt).HasColumn("healthcare", "visit", "note"))
+
+	// The older CREATE is carried to the DROP's version, but not past the snapshot
+	assert.True(t, h.At(createAt).HasColumn("healthcare", "billing", "iban"))
+	assert.True(t, h.At(dropAt).HasColumn("healthcare", "billing", "iban"))
+	assert.False(t, h.At(snapshotAt).HasColumn("healthcare", "billing", "iban"))
+	assert.False(t, h.At(alterAt).HasColumn("healthcare", "billing", "iban"))
+
+	// A CREATE older than a DROP of the same table doesn't bring it back later
+	h2 := NewSchemaHistory(SchemaMap{})
+	assert.False(t, h2.ApplyDDL(dropAt, "DROP TABLE healthcare.visit", "public"), "nothing to drop yet")
+	require.True(t, h2.ApplyDDL(createAt, "CREATE TABLE healthcare.visit (id INT)", "public"))
+	assert.True(t, h2.At(createAt).HasColumn("healthcare", "visit", "id"))
+	assert.False(t, h2.At(dropAt).HasColumn("healthcare", "visit", "id"))
+	assert.False(t, h2.Latest().HasColumn("healthcare", "visit", "id"))
+}
+
+func TestSchemaHistory_ApplyDDLOutOfOrder(t *testing.T) {
+	base := SchemaMap{"healthcare": {"patient": {"ssn": "VARCHAR"}}}
+	h := NewSchemaHistory(base)
+
+	addAt := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	dropAt := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	snapshotAt := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	h.AddVersion(snapshotAt, "june.csv", SchemaMap{"healthcare": {"patient": {"ssn": "VARCHAR"}, "visit": {"id": "INT"}}})
+
+	// The later DROP is seen first, then the earlier ADD
+	require.True(t, h.ApplyDDL(dropAt, "ALTER TABLE healthcare.patient DROP COLUMN ssn", "public"))
+	require.True(t, h.ApplyDDL(addAt, "ALTER TABLE healthcare.patient ADD COLUMN email TEXT", "public"))
+
+	assert.True(t, h.At(addAt).HasColumn("healthcare", "patient", "email"))
+	assert.True(t, h.At(addAt).HasColumn("healthcare", "patient", "ssn"))
+	// The later DDL version carries the earlier column too
+	assert.True(t, h.At(dropAt).HasColumn("healthcare", "patient", "email"))
+	assert.False(t, h.At(dropAt).HasColumn("healthcare", "patient", "ssn"))
+	// The snapshot is authoritative and unchanged
+	assert.False(t, h.At(snapshotAt).HasColumn("healthcare", "patient", "email"))
+	assert.True(t, h.At(snapshotAt).HasColumn("healthcare", "patient", "ssn"))
+	assert.True(t, h.At(snapshotAt).HasColumn("healthcare", "visit", "id"))
+	assert.Equal(t, "june.csv", h.VersionAt(snapshotAt).Source)
+	// Earlier versions are untouched
+	assert.False(t, h.At(addAt.Add(-time.Minute)).HasColumn("healthcare", "patient", "email"))
+}
+
+func TestEnricher_TimeAwareResolution(t *testing.T) {
+	base := createTestEnricher(false, true)
+	renamedAt := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	h := NewSchemaHistory(base.schemas.Latest())
+	h.AddVersion(renamedAt, "after_rename.csv", SchemaMap{
+		"healthcare": {"patient": {"patient_id": "UUID", "national_id": "VARCHAR"}},
+	})
+	enricher := NewEnricherWithHistory(h, base.dict, base.riskScoring, EnrichmentOptions{Debug: true})
+
+	before := enricher.ProcessEvent(map[string]interface{}{
+		"event_id":   "before",
+		"timestamp":  "2025-04-30T23:59:59Z",
+		"db_system":  "postgres",
+		"query_type": "SELECT",
+		"raw_query":  "SELECT ssn FROM patient",
+	})
+	assert.True(t, before.ShouldEmit)
+	assert.Equal(t, []string{"PII"}, before.Categories)
+
+	after := enricher.ProcessEvent(map[string]interface{}{
+		"event_id":   "after",
+		"timestamp":  "2025-05-01T00:00:01Z",
+		"db_system":  "postgres",
+		"query_type": "SELECT",
+		"raw_query":  "SELECT ssn FROM patient",
+	})
+	assert.False(t, after.ShouldEmit)
+}
+
+func TestEnricher_ReplayDDL(t *testing.T) {
+	base := createTestEnricher(false, false)
+	enricher := NewEnricherWithHistory(NewSchemaHistory(base.schemas.Latest()), base.dict, base.riskScoring,
+		EnrichmentOptions{ReplayDDL: true})
+
+	// A table created mid-stream is unknown to the schema CSV
+	enricher.ProcessEvent(map[string]interface{}{
+		"event_id":   "ddl",
+		"timestamp":  "2025-09-01T10:00:00Z",
+		"db_system":  "postgres",
+		"query_type": "CREATE",
+		"raw_query":  "CREATE TABLE archive.patient_backup (ssn varchar(11), note text)",
+	})
+
+	result := enricher.ProcessEvent(map[string]interface{}{
+		"event_id":   "read",
+		"timestamp":  "2025-09-01T10:05:00Z",
+		"db_system":  "postgres",
+		"query_type": "SELECT",
+		"raw_query":  "SELECT ssn FROM archive.patient_backup",
+	})
+	assert.True(t, result.ShouldEmit)
+	assert.Equal(t, []string{"PII"}, result.Categories)
+
+	// Events before the CREATE do not see the table
+	earlier := enricher.ProcessEvent(map[string]interface{}{
+		"event_id":   "earlier",
+		"timestamp":  "2025-09-01T09:00:00Z",
+		"db_system":  "postgres",
+		"query_type": "SELECT",
+		"raw_query":  "SELECT ssn FROM archive.patient_backup",
+	})
+	assert.False(t, earlier.ShouldEmit)
+}
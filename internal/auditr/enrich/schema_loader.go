@@ -23,11 +23,65 @@ type SchemaMap map[string]map[string]map[string]string
 // - Stripping size specifications (e.g., VARCHAR(255) -> VARCHAR)
 // - Handling special cases for different databases
 func LoadSchemaCSV(path string) (SchemaMap, error) {
+	schema := make(SchemaMap)
+	err := readSchemaCSV(path, func(dbName, schemaName, tableName, columnName, columnType string) {
+		// Initialize nested maps if they don't exist
+		if schema[schemaName] == nil {
+			schema[schemaName] = make(map[string]map[string]string)
+		}
+		if schema[schemaName][tableName] == nil {
+			schema[schemaName][tableName] = make(map[string]string)
+		}
+
+		// Store the column with normalized type
+		schema[schemaName][tableName][columnName] = columnType
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logSchemaSummary("", schema)
+	return schema, nil
+}
+
+// LoadSchemaCatalogCSV loads a database schema CSV keeping the db_name column, so that
+// identically named tables in different databases stay distinct.
+// CSV format is the same as for LoadSchemaCSV.
+//
+// The returned map structure is: [db_name][schema_name][table_name][column_name] = normalized_type
+func LoadSchemaCatalogCSV(path string) (SchemaCatalog, error) {
+	catalog := make(SchemaCatalog)
+	err := readSchemaCSV(path, func(dbName, schemaName, tableName, columnName, columnType string) {
+		if catalog[dbName] == nil {
+			catalog[dbName] = make(SchemaMap)
+		}
+		schema := catalog[dbName]
+		if schema[schemaName] == nil {
+			schema[schemaName] = make(map[string]map[string]string)
+		}
+		if schema[schemaName][tableName] == nil {
+			schema[schemaName][tableName] = make(map[string]string)
+		}
+		schema[schemaName][tableName][columnName] = columnType
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for dbName, schema := range catalog {
+		logSchemaSummary(dbName, schema)
+	}
+	return catalog, nil
+}
+
+// readSchemaCSV reads and validates a schema CSV file and calls add for every valid row.
+// Column types are normalized before being passed to add.
+func readSchemaCSV(path string, add func(dbName, schemaName, tableName, columnName, columnType string)) error {
 	logger.L().Debugw("Loading schema from CSV", "path", path)
 
 	file, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open schema file %s: %w", path, err)
+		return fmt.Errorf("failed to open schema file %s: %w", path, err)
 	}
 	defer file.Close()
 
@@ -40,22 +94,21 @@ func LoadSchemaCSV(path string) (SchemaMap, error) {
 	// Read header row
 	header, err := reader.Read()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+		return fmt.Errorf("failed to read CSV header: %w", err)
 	}
 
 	// Validate expected header format
 	expectedHeader := []string{"db_name", "schema_name", "table_name", "column_name", "column_type"}
 	if len(header) != len(expectedHeader) {
-		return nil, fmt.Errorf("invalid CSV header: expected %v, got %v", expectedHeader, header)
+		return fmt.Errorf("invalid CSV header: expected %v, got %v", expectedHeader, header)
 	}
 
 	for i, col := range header {
 		if col != expectedHeader[i] {
-			return nil, fmt.Errorf("invalid CSV header at position %d: expected %s, got %s", i, expectedHeader[i], col)
+			return fmt.Errorf("invalid CSV header at position %d: expected %s, got %s", i, expectedHeader[i], col)
 		}
 	}
 
-	schema := make(SchemaMap)
 	rowCount := 0
 
 	// Process each row
@@ -65,7 +118,7 @@ func LoadSchemaCSV(path string) (SchemaMap, error) {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to read CSV row %d: %w", rowCount+2, err) // +2 for header and 1-based indexing
+			return fmt.Errorf("failed to read CSV row %d: %w", rowCount+2, err) // +2 for header and 1-based indexing
 		}
 
 		if len(record) < 5 {
@@ -109,16 +162,7 @@ func LoadSchemaCSV(path string) (SchemaMap, error) {
 		// Normalize the column type
 		normalizedType := normalizeColumnType(columnType)
 
-		// Initialize nested maps if they don't exist
-		if schema[schemaName] == nil {
-			schema[schemaName] = make(map[string]map[string]string)
-		}
-		if schema[schemaName][tableName] == nil {
-			schema[schemaName][tableName] = make(map[string]string)
-		}
-
-		// Store the column with normalized type
-		schema[schemaName][tableName][columnName] = normalizedType
+		add(dbName, schemaName, tableName, columnName, normalizedType)
 		rowCount++
 
 		logger.L().Debugw("Loaded schema column",
@@ -131,10 +175,13 @@ func LoadSchemaCSV(path string) (SchemaMap, error) {
 	}
 
 	logger.L().Debugw("Schema loading completed",
-		"total_columns", rowCount,
-		"schemas", len(schema))
+		"total_columns", rowCount)
 
-	// Log summary of loaded schemas and tables
+	return nil
+}
+
+// logSchemaSummary logs the number of tables and columns per schema.
+func logSchemaSummary(dbName string, schema SchemaMap) {
 	for schemaName, tables := range schema {
 		tableCount := len(tables)
 		columnCount := 0
@@ -142,12 +189,11 @@ func LoadSchemaCSV(path string) (SchemaMap, error) {
 			columnCount += len(columns)
 		}
 		logger.L().Debugw("Schema summary",
+			"db", dbName,
 			"schema", schemaName,
 			"tables", tableCount,
 			"columns", columnCount)
 	}
-
-	return schema, nil
 }
 
 // normalizeColumnType normalizes database column types for consistent matching.
@@ -312,3 +312,34 @@ func TestLoadSchemaCSV_FileNotFound(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to open schema file")
 }
+
+func TestLoadSchemaCatalogCSV(t *testing.T) {
+	content := `db_name,schema_name,table_name,column_name,column_type
+tenant_a,public,users,id,integer
+tenant_a,public,users,ssn,varchar(11)
+tenant_b,public,users,id,integer
+tenant_b,public,users,nickname,text`
+
+	tmpFile, err := os.CreateTemp("", "catalog_*.csv")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.WriteString(content)
+	require.NoError(t, err)
+	tmpFile.Close()
+
+	catalog, err := LoadSchemaCatalogCSV(tmpFile.Name())
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"tenant_a", "tenant_b"}, catalog.DatabaseNames())
+	assert.Equal(t, map[string]string{"id": "INT", "ssn": "VARCHAR"}, catalog["tenant_a"]["public"]["users"])
+	assert.Equal(t, map[string]string{"id": "INT", "nickname": "TEXT"}, catalog["tenant_b"]["public"]["users"])
+
+	// The merged view combines the columns of same-named tables
+	merged := catalog.Merged()
+	assert.Len(t, merged["public"]["users"], 3)
+
+	// LoadSchemaCSV keeps returning the merged view
+	schema, err := LoadSchemaCSV(tmpFile.Name())
+	require.NoError(t, err)
+	assert.Equal(t, merged, schema)
+}
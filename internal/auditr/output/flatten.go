@@ -0,0 +1,183 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ArraySeparator joins the elements of arrays of scalars in tabular output
+const ArraySeparator = ";"
+
+// leadingColumns come first, in this order, when every field is written;
+// the other columns follow in alphabetical order
+var leadingColumns = []string{
+	"event_id", "timestamp", "db_system", "db_name", "db_user", "client_ip",
+	"query_type", "bulk", "bulk_type", "full_table_read", "sensitivity", "risk_level",
+}
+
+// Flatten turns an event into one value per column for tabular output.
+//
+// Flattening rules:
+//   - Nested objects become dotted columns: {"enrichment": {"schema": "s"}} -> enrichment.schema
+//   - Arrays of scalars are joined with ArraySeparator: ["PII:email", "PHI:dx"] -> "PII:email;PHI:dx"
+//   - Other arrays (of objects or arrays) are written as JSON text
+//   - Empty objects and null become a null value; empty arrays an empty string
+//
+// Values are strings, json.Number, bools or nil.
+//
+// Returns:
+//   - Column values by column name
+//   - Error if the event can't be serialized
+func Flatten(event map[string]any) (map[string]any, error) {
+	norm, err := normalize(event)
+	if err != nil {
+		return nil, err
+	}
+	row := make(map[string]any)
+	flatten("", norm, row)
+	return row, nil
+}
+
+// Select returns the values of the given columns for tabular output. A column
+// is a dotted path (see Lookup); objects are written as JSON text and arrays
+// flattened as in Flatten. Missing columns are null.
+func Select(event map[string]any, columns []string) (map[string]any, error) {
+	norm, err := normalize(event)
+	if err != nil {
+		return nil, err
+	}
+	row := make(map[string]any, len(columns))
+	for _, col := range columns {
+		v, ok := Lookup(norm, col)
+		if !ok {
+			row[col] = nil
+			continue
+		}
+		switch x := v.(type) {
+		case map[string]any:
+			row[col] = jsonText(x)
+		case []any:
+			row[col] = joinArray(x)
+		default:
+			row[col] = x
+		}
+	}
+	return row, nil
+}
+
+// Lookup extracts a value by dotted path, e.g. "enrichment.schema". A key
+// containing dots that exists as-is takes precedence over descending into
+// nested objects.
+func Lookup(event map[string]any, path string) (any, bool) {
+	if v, ok := event[path]; ok {
+		return v, true
+	}
+	var cur any = event
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// SortColumns orders columns stably: leadingColumns first, then alphabetically
+func SortColumns(columns []string) []string {
+	rank := make(map[string]int, len(leadingColumns))
+	for i, col := range leadingColumns {
+		rank[col] = i + 1
+	}
+	sorted := append([]string{}, columns...)
+	sort.Slice(sorted, func(i, j int) bool {
+		ri, rj := rank[sorted[i]], rank[sorted[j]]
+		switch {
+		case ri > 0 && rj > 0:
+			return ri < rj
+		case ri > 0 || rj > 0:
+			return ri > 0
+		}
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
+
+// CellText returns the text of a column value; null is empty
+func CellText(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case json.Number:
+		return x.String()
+	case bool:
+		return strconv.FormatBool(x)
+	}
+	return fmt.Sprint(v)
+}
+
+// normalize round-trips an event through JSON, so values are only maps,
+// slices, strings, json.Number, bools and nil whatever the producer used
+func normalize(event map[string]any) (map[string]any, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var norm map[string]any
+	if err := dec.Decode(&norm); err != nil {
+		return nil, err
+	}
+	return norm, nil
+}
+
+func flatten(prefix string, v any, row map[string]any) {
+	switch x := v.(type) {
+	case map[string]any:
+		if len(x) == 0 && prefix != "" {
+			row[prefix] = nil
+		}
+		for k, child := range x {
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			flatten(key, child, row)
+		}
+	case []any:
+		row[prefix] = joinArray(x)
+	default:
+		row[prefix] = x
+	}
+}
+
+// joinArray joins an array of scalars with ArraySeparator, or returns other
+// arrays as JSON text
+func joinArray(items []any) string {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		switch item.(type) {
+		case map[string]any, []any:
+			return jsonText(items)
+		}
+		parts = append(parts, CellText(item))
+	}
+	return strings.Join(parts, ArraySeparator)
+}
+
+func jsonText(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
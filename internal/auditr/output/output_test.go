@@ -0,0 +1,163 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func testEvents() []map[string]any {
+	return []map[string]any{
+		{
+			"event_id": "e1", "timestamp": "2025-09-01T10:00:00Z", "db_user": "alice", "risk_level": "high",
+			"sensitivity": []string{"PII:email", "PHI:diagnosis"},
+			"enrichment":  map[string]any{"schema": "clinical", "rows": 12},
+		},
+		{
+			"event_id": "e2", "timestamp": "2025-09-01T11:00:00Z", "db_user": "bob", "bulk": true,
+			"query": "SELECT a,\n b FROM t",
+		},
+	}
+}
+
+func writeAll(t *testing.T, opts Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, opts)
+	if err != nil {
+		t.Fatalf("NewWriter(%+v) error = %v", opts, err)
+	}
+	for _, e := range testEvents() {
+		if err := w.Write(e); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.String()
+}
+
+func TestWriter_Formats(t *testing.T) {
+	fields := []string{"timestamp", "db_user", "risk_level", "sensitivity", "enrichment.schema"}
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "csv with all columns in stable order",
+			opts: Options{Format: FormatCSV},
+			want: "event_id,timestamp,db_user,bulk,sensitivity,risk_level,enrichment.rows,enrichment.schema,query\n" +
+				"e1,2025-09-01T10:00:00Z,alice,,PII:email;PHI:diagnosis,high,12,clinical,\n" +
+				"e2,2025-09-01T11:00:00Z,bob,true,,,,,\"SELECT a,\n b FROM t\"\n",
+		},
+		{
+			name: "csv with selected fields",
+			opts: Options{Format: FormatCSV, Fields: fields},
+			want: "timestamp,db_user,risk_level,sensitivity,enrichment.schema\n" +
+				"2025-09-01T10:00:00Z,alice,high,PII:email;PHI:diagnosis,clinical\n" +
+				"2025-09-01T11:00:00Z,bob,,,\n",
+		},
+		{
+			name: "ndjson with selected fields keeps arrays",
+			opts: Options{Format: FormatNDJSON, Fields: []string{"db_user", "sensitivity", "enrichment"}},
+			want: `{"db_user":"alice","sensitivity":["PII:email","PHI:diagnosis"],"enrichment":{"rows":12,"schema":"clinical"}}` + "\n" +
+				`{"db_user":"bob","sensitivity":null,"enrichment":null}` + "\n",
+		},
+		{
+			name: "json array",
+			opts: Options{Format: FormatJSON, Fields: []string{"event_id"}},
+			want: "[\n{\"event_id\":\"e1\"},\n{\"event_id\":\"e2\"}\n]\n",
+		},
+		{
+			name: "table",
+			opts: Options{Format: FormatTable, Fields: []string{"event_id", "bulk", "query"}},
+			want: "EVENT_ID  BULK  QUERY\n" +
+				"e1        -     -\n" +
+				"e2        true  SELECT a, b FROM t\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := writeAll(t, tt.opts); got != tt.want {
+				t.Errorf("output =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriter_Empty(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatCSV, FormatNDJSON} {
+		var buf bytes.Buffer
+		w, _ := NewWriter(&buf, Options{Format: format})
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: Close() error = %v", format, err)
+		}
+		if want := map[string]string{FormatJSON: "[]\n"}[format]; buf.String() != want {
+			t.Errorf("%s: empty output = %q, want %q", format, buf.String(), want)
+		}
+	}
+	if _, err := NewWriter(&bytes.Buffer{}, Options{Format: "xlsx"}); err == nil {
+		t.Errorf("NewWriter(xlsx) accepted")
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	row, err := Flatten(map[string]any{
+		"meta":    map[string]any{"client": map[string]any{"host": "10.0.0.3"}, "empty": map[string]any{}},
+		"matches": []any{map[string]any{"column": "email"}},
+		"tags":    []any{},
+		"n":       1.5,
+	})
+	if err != nil {
+		t.Fatalf("Flatten() error = %v", err)
+	}
+	want := map[string]string{"meta.client.host": "10.0.0.3", "meta.empty": "", "matches": `[{"column":"email"}]`, "tags": "", "n": "1.5"}
+	if len(row) != len(want) {
+		t.Errorf("Flatten() = %v", row)
+	}
+	for col, text := range want {
+		if got := CellText(row[col]); got != text {
+			t.Errorf("%s = %q, want %q", col, got, text)
+		}
+	}
+}
+
+func TestWriter_Parquet(t *testing.T) {
+	data := []byte(writeAll(t, Options{Format: FormatParquet, Fields: []string{"event_id", "bulk", "enrichment.rows"}}))
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatalf("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if footerLen <= 0 || footerLen > len(data)-12 {
+		t.Fatalf("footer length %d out of range", footerLen)
+	}
+	footer := string(data[len(data)-8-footerLen : len(data)-8])
+	for _, name := range []string{"schema", "event_id", "bulk", "enrichment.rows", "auditr"} {
+		if !strings.Contains(footer, name) {
+			t.Errorf("footer missing %q", name)
+		}
+	}
+
+	// Column types: event_id is a string, bulk a boolean, enrichment.rows an integer
+	rows := []map[string]any{}
+	for _, e := range testEvents() {
+		row, _ := Select(e, []string{"event_id", "bulk", "enrichment.rows"})
+		rows = append(rows, row)
+	}
+	for col, want := range map[string]int32{"event_id": parquetByteArray, "bulk": parquetBoolean, "enrichment.rows": parquetInt64} {
+		values := []any{rows[0][col], rows[1][col]}
+		if got := parquetType(values); got != want {
+			t.Errorf("parquetType(%s) = %d, want %d", col, got, want)
+		}
+	}
+
+	// A page holds the definition levels as RLE runs (1, 0, 1), then the non-null values
+	page := encodeParquetPage([]any{"e1", nil, "e3"}, parquetByteArray)
+	want := []byte{6, 0, 0, 0, 2, 1, 2, 0, 2, 1, 2, 0, 0, 0, 'e', '1', 2, 0, 0, 0, 'e', '3'}
+	if !bytes.Equal(page, want) {
+		t.Errorf("encodeParquetPage() = %v, want %v", page, want)
+	}
+}
@@ -0,0 +1,339 @@
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+)
+
+// This file implements a minimal Parquet writer: one row group, one
+// uncompressed PLAIN-encoded data page per column, every column optional and
+// flat. Metadata is written with the Thrift compact protocol, per
+// https://github.com/apache/parquet-format.
+//
+// Column types are inferred from the values: a column of only booleans is
+// BOOLEAN, of only integers INT64, of only numbers DOUBLE; anything else is a
+// UTF-8 string (BYTE_ARRAY).
+
+// Parquet physical types, encodings and other enum values
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetOptional     = 1 // FieldRepetitionType
+	parquetUTF8         = 0 // ConvertedType
+	parquetPlain        = 0 // Encoding
+	parquetRLE          = 3 // Encoding
+	parquetDataPage     = 0 // PageType
+	parquetUncompressed = 0 // CompressionCodec
+)
+
+var parquetMagic = []byte("PAR1")
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// parquetChunk is what the footer records about a written column
+type parquetChunk struct {
+	name   string
+	typ    int32
+	offset int64 // Offset of the page header
+	size   int64 // Page header and page
+}
+
+// writeParquet writes the rows as a Parquet file with the given columns
+func writeParquet(w io.Writer, columns []string, rows []map[string]any) error {
+	var buf bytes.Buffer
+	buf.Write(parquetMagic)
+
+	chunks := make([]parquetChunk, 0, len(columns))
+	if len(rows) > 0 {
+		for _, col := range columns {
+			values := make([]any, len(rows))
+			for i, row := range rows {
+				values[i] = row[col]
+			}
+			typ := parquetType(values)
+			page := encodeParquetPage(values, typ)
+
+			header := &thriftWriter{}
+			header.begin()
+			header.i32(1, parquetDataPage)
+			header.i32(2, int32(len(page)))
+			header.i32(3, int32(len(page)))
+			header.structBegin(5) // DataPageHeader
+			header.i32(1, int32(len(values)))
+			header.i32(2, parquetPlain)
+			header.i32(3, parquetRLE)
+			header.i32(4, parquetRLE)
+			header.end()
+			header.end()
+
+			chunk := parquetChunk{name: col, typ: typ, offset: int64(buf.Len())}
+			buf.Write(header.buf.Bytes())
+			buf.Write(page)
+			chunk.size = int64(buf.Len()) - chunk.offset
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	footer := encodeParquetFooter(columns, chunks, len(rows))
+	buf.Write(footer)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(footer)))
+	buf.Write(parquetMagic)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// parquetType infers the physical type of a column from its values
+func parquetType(values []any) int32 {
+	bools, ints, numbers, seen := true, true, true, false
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		seen = true
+		switch x := v.(type) {
+		case bool:
+			ints, numbers = false, false
+		case json.Number:
+			bools = false
+			if _, err := x.Int64(); err != nil {
+				ints = false
+			}
+			if _, err := x.Float64(); err != nil {
+				numbers = false
+			}
+		default:
+			return parquetByteArray
+		}
+	}
+	switch {
+	case !seen:
+		return parquetByteArray
+	case bools:
+		return parquetBoolean
+	case ints:
+		return parquetInt64
+	case numbers:
+		return parquetDouble
+	}
+	return parquetByteArray
+}
+
+// encodeParquetPage encodes a data page (v1): the definition levels (1 for a
+// value, 0 for null) RLE-encoded with their length, then the non-null values
+func encodeParquetPage(values []any, typ int32) []byte {
+	var levels bytes.Buffer
+	for i := 0; i < len(values); {
+		defined := values[i] != nil
+		run := 1
+		for i+run < len(values) && (values[i+run] != nil) == defined {
+			run++
+		}
+		writeUvarint(&levels, uint64(run)<<1) // RLE run header
+		if defined {
+			levels.WriteByte(1)
+		} else {
+			levels.WriteByte(0)
+		}
+		i += run
+	}
+
+	var page bytes.Buffer
+	binary.Write(&page, binary.LittleEndian, uint32(levels.Len()))
+	page.Write(levels.Bytes())
+
+	var bits byte
+	nbits := 0
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		switch typ {
+		case parquetBoolean:
+			if v.(bool) {
+				bits |= 1 << nbits
+			}
+			if nbits++; nbits == 8 {
+				page.WriteByte(bits)
+				bits, nbits = 0, 0
+			}
+		case parquetInt64:
+			n, _ := v.(json.Number).Int64()
+			binary.Write(&page, binary.LittleEndian, n)
+		case parquetDouble:
+			f, _ := v.(json.Number).Float64()
+			binary.Write(&page, binary.LittleEndian, math.Float64bits(f))
+		default:
+			s := CellText(v)
+			binary.Write(&page, binary.LittleEndian, uint32(len(s)))
+			page.WriteString(s)
+		}
+	}
+	if nbits > 0 {
+		page.WriteByte(bits)
+	}
+	return page.Bytes()
+}
+
+// encodeParquetFooter encodes the FileMetaData
+func encodeParquetFooter(columns []string, chunks []parquetChunk, numRows int) []byte {
+	types := make(map[string]int32, len(chunks))
+	for _, c := range chunks {
+		types[c.name] = c.typ
+	}
+
+	t := &thriftWriter{}
+	t.begin()
+	t.i32(1, 1) // version
+
+	t.listBegin(2, thriftStruct, len(columns)+1) // schema: root, then the columns
+	t.begin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(columns)))
+	t.end()
+	for _, col := range columns {
+		typ, ok := types[col]
+		if !ok {
+			typ = parquetByteArray // No rows: columns are untyped
+		}
+		t.begin()
+		t.i32(1, typ)
+		t.i32(3, parquetOptional)
+		t.binary(4, col)
+		if typ == parquetByteArray {
+			t.i32(6, parquetUTF8)
+		}
+		t.end()
+	}
+
+	t.i64(3, int64(numRows))
+
+	groups := 0
+	if len(chunks) > 0 {
+		groups = 1
+	}
+	t.listBegin(4, thriftStruct, groups) // row_groups
+	if groups > 0 {
+		var total int64
+		for _, c := range chunks {
+			total += c.size
+		}
+		t.begin()
+		t.listBegin(1, thriftStruct, len(chunks)) // columns
+		for _, c := range chunks {
+			t.begin()
+			t.i64(2, c.offset) // file_offset
+			t.structBegin(3)   // meta_data
+			t.i32(1, c.typ)
+			t.listBegin(2, thriftI32, 2) // encodings
+			t.listI32(parquetPlain, parquetRLE)
+			t.listBegin(3, thriftBinary, 1) // path_in_schema
+			t.listBinary(c.name)
+			t.i32(4, parquetUncompressed)
+			t.i64(5, int64(numRows))
+			t.i64(6, c.size)
+			t.i64(7, c.size)
+			t.i64(9, c.offset) // data_page_offset
+			t.end()
+			t.end()
+		}
+		t.i64(2, total)
+		t.i64(3, int64(numRows))
+		t.end()
+	}
+
+	t.binary(6, "auditr")
+	t.end()
+	return t.buf.Bytes()
+}
+
+// thriftWriter encodes structs with the Thrift compact protocol. Structs are
+// opened with begin (list elements, top-level) or structBegin (fields) and
+// closed with end.
+type thriftWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	stack  []int16
+}
+
+func (t *thriftWriter) begin() {
+	t.stack = append(t.stack, t.lastID)
+	t.lastID = 0
+}
+
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0) // STOP
+	t.lastID = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.lastID; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		writeUvarint(&t.buf, uint64(uint16((id<<1)^(id>>15))))
+	}
+	t.lastID = id
+}
+
+func (t *thriftWriter) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	writeUvarint(&t.buf, uint64(uint32((v<<1)^(v>>31))))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	writeUvarint(&t.buf, uint64((v<<1)^(v>>63)))
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	writeUvarint(&t.buf, uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) listBegin(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	t.buf.WriteByte(0xF0 | elem)
+	writeUvarint(&t.buf, uint64(n))
+}
+
+func (t *thriftWriter) listI32(values ...int32) {
+	for _, v := range values {
+		writeUvarint(&t.buf, uint64(uint32((v<<1)^(v>>31))))
+	}
+}
+
+func (t *thriftWriter) listBinary(values ...string) {
+	for _, s := range values {
+		writeUvarint(&t.buf, uint64(len(s)))
+		t.buf.WriteString(s)
+	}
+}
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+}
@@ -0,0 +1,131 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// maxTableCell is the width at which table cells are cut
+const maxTableCell = 60
+
+// tabularWriter writes CSV, an aligned table or Parquet.
+// CSV with fixed columns is streamed; otherwise rows are kept until Close,
+// when every column is known (and, for the table, every width).
+type tabularWriter struct {
+	w       io.Writer
+	format  string
+	fields  []string
+	rows    []map[string]any
+	csv     *csv.Writer
+	columns map[string]bool
+}
+
+func (t *tabularWriter) Write(event map[string]any) error {
+	var row map[string]any
+	var err error
+	if len(t.fields) > 0 {
+		row, err = Select(event, t.fields)
+	} else {
+		row, err = Flatten(event)
+	}
+	if err != nil {
+		return &MarshalError{Err: err}
+	}
+
+	if t.format == FormatCSV && len(t.fields) > 0 {
+		if t.csv == nil {
+			t.csv = csv.NewWriter(t.w)
+			t.csv.Write(t.fields)
+		}
+		t.csv.Write(cells(row, t.fields))
+		if err := t.csv.Error(); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+		return nil
+	}
+
+	if t.columns == nil {
+		t.columns = make(map[string]bool)
+	}
+	for col := range row {
+		t.columns[col] = true
+	}
+	t.rows = append(t.rows, row)
+	return nil
+}
+
+func (t *tabularWriter) Close() error {
+	columns := t.fields
+	if len(columns) == 0 {
+		for col := range t.columns {
+			columns = append(columns, col)
+		}
+		columns = SortColumns(columns)
+	}
+	if len(columns) == 0 && t.format != FormatParquet {
+		return nil // No events and no columns: nothing to write
+	}
+
+	switch t.format {
+	case FormatCSV:
+		if t.csv == nil {
+			t.csv = csv.NewWriter(t.w)
+			t.csv.Write(columns)
+		}
+		for _, row := range t.rows {
+			t.csv.Write(cells(row, columns))
+		}
+		t.csv.Flush()
+		if err := t.csv.Error(); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+		return nil
+
+	case FormatTable:
+		tw := tabwriter.NewWriter(t.w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, row := range t.rows {
+			line := cells(row, columns)
+			for i, cell := range line {
+				line[i] = tableCell(cell)
+			}
+			fmt.Fprintln(tw, strings.Join(line, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return fmt.Errorf("failed to write table: %w", err)
+		}
+		return nil
+
+	case FormatParquet:
+		if err := writeParquet(t.w, columns, t.rows); err != nil {
+			return fmt.Errorf("failed to write Parquet: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q", t.format)
+}
+
+// cells returns the text of a row's columns
+func cells(row map[string]any, columns []string) []string {
+	line := make([]string, len(columns))
+	for i, col := range columns {
+		line[i] = CellText(row[col])
+	}
+	return line
+}
+
+// tableCell fits a value on one line of the table: "-" when empty, whitespace
+// collapsed and cut at maxTableCell characters
+func tableCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return "-"
+	}
+	if r := []rune(s); len(r) > maxTableCell {
+		return string(r[:maxTableCell-3]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,170 @@
+// Package output writes events in the output formats shared by the CLI
+// commands: NDJSON, a JSON array, CSV, an aligned table and Parquet.
+//
+// NDJSON and JSON keep events nested. CSV, table and Parquet are tabular and
+// flatten each event into columns (see Flatten). With Options.Fields only those
+// columns are written, in that order; otherwise every column of every event is
+// written, in the stable order of SortColumns.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Output formats
+const (
+	FormatNDJSON  = "ndjson"
+	FormatJSON    = "json"
+	FormatCSV     = "csv"
+	FormatTable   = "table"
+	FormatParquet = "parquet"
+)
+
+// Formats lists the supported output formats
+var Formats = []string{FormatNDJSON, FormatJSON, FormatCSV, FormatTable, FormatParquet}
+
+// ValidFormat reports whether format is one of Formats
+func ValidFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Options configures a Writer.
+//
+// Fields:
+//   - Format: One of Formats (empty for NDJSON)
+//   - Fields: Columns to write, in order, as dotted paths (e.g. enrichment.schema);
+//     empty writes every field
+type Options struct {
+	Format string
+	Fields []string
+}
+
+// Writer writes events in one output format.
+// Close must be called once all events are written: tabular formats without
+// Options.Fields, the table and Parquet only write their output then.
+// Close doesn't close the underlying writer.
+type Writer interface {
+	Write(event map[string]any) error
+	Close() error
+}
+
+// MarshalError is returned by Writer.Write when an event can't be serialized;
+// nothing was written for the event and the writer can still be used.
+type MarshalError struct {
+	Err error
+}
+
+func (e *MarshalError) Error() string {
+	return fmt.Sprintf("failed to marshal event to JSON: %v", e.Err)
+}
+
+func (e *MarshalError) Unwrap() error {
+	return e.Err
+}
+
+// NewWriter creates a writer for the format of opts.
+//
+// Args:
+//   - w: Destination
+//   - opts: Format and column selection
+//
+// Returns:
+//   - The writer
+//   - Error if the format is unknown
+func NewWriter(w io.Writer, opts Options) (Writer, error) {
+	switch opts.Format {
+	case FormatNDJSON, "":
+		return &jsonWriter{w: w, fields: opts.Fields}, nil
+	case FormatJSON:
+		return &jsonWriter{w: w, fields: opts.Fields, array: true}, nil
+	case FormatCSV, FormatTable, FormatParquet:
+		return &tabularWriter{w: w, format: opts.Format, fields: opts.Fields}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q, expected %s", opts.Format, strings.Join(Formats, ", "))
+}
+
+// jsonWriter writes NDJSON, or a JSON array with array set
+type jsonWriter struct {
+	w      io.Writer
+	fields []string
+	array  bool
+	count  int
+}
+
+func (j *jsonWriter) Write(event map[string]any) error {
+	var data []byte
+	var err error
+	if len(j.fields) == 0 {
+		data, err = json.Marshal(event)
+	} else {
+		data, err = marshalSelected(event, j.fields)
+	}
+	if err != nil {
+		return &MarshalError{Err: err}
+	}
+
+	sep := "\n"
+	if j.array {
+		sep = ",\n"
+		if j.count == 0 {
+			sep = "[\n"
+		}
+		data = append([]byte(sep), data...)
+	} else {
+		data = append(data, '\n')
+	}
+	if _, err := j.w.Write(data); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	j.count++
+	return nil
+}
+
+func (j *jsonWriter) Close() error {
+	if !j.array {
+		return nil
+	}
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	if _, err := io.WriteString(j.w, end); err != nil {
+		return fmt.Errorf("failed to write event: %w", err)
+	}
+	return nil
+}
+
+// marshalSelected marshals the selected fields of an event as a JSON object
+// with keys in the order of fields; missing fields are null
+func marshalSelected(event map[string]any, fields []string) ([]byte, error) {
+	event, err := normalize(event)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, field := range fields {
+		value, _ := Lookup(event, field)
+		key, _ := json.Marshal(field)
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(data)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
@@ -1,7 +1,11 @@
 package parsers
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
 )
 
 var sqlClassificationTests = []struct {
@@ -204,3 +208,30 @@ func TestNormalizeStmtType(t *testing.T) {
 		}
 	}
 }
+
+// TRUNCATE is classified as DELETE, so compliance controls on data changes
+// list DELETE and cover TRUNCATE events through it
+func TestTruncateMapsToChangeControls(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "..", "..", "cmd", "auditr", "config", "compliance_mapping.json"))
+	if err != nil {
+		t.Skipf("compliance mapping not found: %v", err)
+	}
+	defer f.Close()
+	cm, err := config.ValidateCompliance(f)
+	if err != nil {
+		t.Fatalf("bundled compliance mapping invalid: %v", err)
+	}
+
+	for _, queryType := range []string{detectQueryType("TRUNCATE TABLE patients;"), normalizeStmtType("TRUNCATE TABLE patients")} {
+		refs := cm.Match([]string{"PHI"}, queryType)
+		found := false
+		for _, ref := range refs {
+			if ref == "hipaa:164.312(c)(1)" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("TRUNCATE (query_type %q) maps to %v, want hipaa:164.312(c)(1)", queryType, refs)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package query
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/output"
+)
+
+// This file implements the group-by mode of query (--group-by).
+// Matched events are counted per distinct combination of group-by values
+// (and time bucket) instead of being written out.
+
+// Pseudo-fields of the sensitivity array entries ("PII:email" -> PII, email)
+const (
+	fieldSensitivityCategory = "sensitivity.category"
+	fieldSensitivityField    = "sensitivity.field"
+)
+
+// rowCountFields are the fields a row count is read from, first one present wins.
+// Audit sources that record rows read or affected use one of these names.
+var rowCountFields = []string{"row_count", "rows", "rows_affected", "rows_sent", "meta.row_count", "meta.rows", "meta.rows_affected", "meta.rows_sent"}
+
+// GroupOptions configures the group-by mode.
+//
+// Fields:
+//   - Fields: Fields to group by; dotted paths as in --where, plus sensitivity.category and sensitivity.field
+//   - Distinct: Fields whose distinct values are counted per group
+//   - Bucket: Width of time buckets (0 for none); day multiples start at midnight in Location
+//   - Top: Keep only the N largest groups, per bucket when bucketing (0 for all)
+//   - Location: Zone buckets are aligned and times displayed in (nil for UTC)
+type GroupOptions struct {
+	Fields   []string
+	Distinct []string
+	Bucket   time.Duration
+	Top      int
+	Location *time.Location
+}
+
+// Group is the aggregate of the events sharing group-by values.
+//
+// Fields:
+//   - Bucket: Start of the time bucket (nil without bucketing or for events without a timestamp)
+//   - Values: Group-by values, in the order of GroupOptions.Fields ("" when missing)
+//   - Count: Number of events
+//   - Distinct: Number of distinct values per GroupOptions.Distinct field
+//   - FirstSeen/LastSeen: Earliest and latest event timestamp
+//   - Rows: Sum of the row counts of the events that have one (HasRows)
+type Group struct {
+	Bucket    *time.Time
+	Values    []string
+	Count     int
+	Distinct  []int
+	FirstSeen *time.Time
+	LastSeen  *time.Time
+	Rows      int64
+	HasRows   bool
+
+	distinct []map[string]bool
+}
+
+// Aggregator accumulates matched events into groups.
+type Aggregator struct {
+	opts    GroupOptions
+	groups  map[string]*Group
+	hasRows bool
+}
+
+// NewAggregator creates an aggregator for the given options
+func NewAggregator(opts GroupOptions) *Aggregator {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	return &Aggregator{opts: opts, groups: make(map[string]*Group)}
+}
+
+// Add counts an event in its groups. An event with several values for a
+// group-by field (e.g. two sensitivity entries) counts once in each group.
+func (a *Aggregator) Add(e Event) {
+	var bucket *time.Time
+	timestamp, err := ParseTimestamp(e["timestamp"])
+	hasTime := err == nil
+	if hasTime && a.opts.Bucket > 0 {
+		start := bucketStart(timestamp, a.opts.Bucket, a.opts.Location)
+		bucket = &start
+	}
+	rows, hasRows := rowCount(e)
+
+	for _, values := range groupCombinations(e, a.opts.Fields) {
+		key := strings.Join(values, "\x00")
+		if bucket != nil {
+			key = bucket.Format(time.RFC3339) + "\x00" + key
+		}
+		g, ok := a.groups[key]
+		if !ok {
+			g = &Group{Bucket: bucket, Values: values, distinct: make([]map[string]bool, len(a.opts.Distinct))}
+			for i := range g.distinct {
+				g.distinct[i] = make(map[string]bool)
+			}
+			a.groups[key] = g
+		}
+
+		g.Count++
+		for i, field := range a.opts.Distinct {
+			for _, v := range fieldValues(e, field) {
+				if v != "" {
+					g.distinct[i][v] = true
+				}
+			}
+		}
+		if hasTime {
+			if g.FirstSeen == nil || timestamp.Before(*g.FirstSeen) {
+				t := timestamp
+				g.FirstSeen = &t
+			}
+			if g.LastSeen == nil || timestamp.After(*g.LastSeen) {
+				t := timestamp
+				g.LastSeen = &t
+			}
+		}
+		if hasRows {
+			g.Rows += rows
+			g.HasRows = true
+			a.hasRows = true
+		}
+	}
+}
+
+// Groups returns the groups ordered by bucket, then count (largest first),
+// then group-by values, keeping the Top largest of each bucket
+func (a *Aggregator) Groups() []*Group {
+	groups := make([]*Group, 0, len(a.groups))
+	for _, g := range a.groups {
+		g.Distinct = make([]int, len(g.distinct))
+		for i, values := range g.distinct {
+			g.Distinct[i] = len(values)
+		}
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		gi, gj := groups[i], groups[j]
+		if bi, bj := bucketTime(gi), bucketTime(gj); !bi.Equal(bj) {
+			return bi.Before(bj)
+		}
+		if gi.Count != gj.Count {
+			return gi.Count > gj.Count
+		}
+		if gi.Rows != gj.Rows {
+			return gi.Rows > gj.Rows
+		}
+		return strings.Join(gi.Values, "\x00") < strings.Join(gj.Values, "\x00")
+	})
+	if a.opts.Top <= 0 {
+		return groups
+	}
+
+	var top []*Group
+	kept := 0
+	for i, g := range groups {
+		if i > 0 && !bucketTime(g).Equal(bucketTime(groups[i-1])) {
+			kept = 0
+		}
+		if kept < a.opts.Top {
+			top = append(top, g)
+			kept++
+		}
+	}
+	return top
+}
+
+// Columns returns the output column names: bucket (when bucketing), the
+// group-by fields, count, distinct_<field> per distinct field, first_seen,
+// last_seen and rows (when any event had a row count)
+func (a *Aggregator) Columns() []string {
+	var cols []string
+	if a.opts.Bucket > 0 {
+		cols = append(cols, "bucket")
+	}
+	cols = append(cols, a.opts.Fields...)
+	cols = append(cols, "count")
+	for _, field := range a.opts.Distinct {
+		cols = append(cols, "distinct_"+field)
+	}
+	cols = append(cols, "first_seen", "last_seen")
+	if a.hasRows {
+		cols = append(cols, "rows")
+	}
+	return cols
+}
+
+// Write writes the groups in an output format (see output.Formats), one row
+// per group with the columns of Columns, and returns how many were written.
+//
+// Args:
+//   - w: Destination
+//   - format: Output format; table when empty
+//
+// Returns:
+//   - Number of groups written
+//   - Error if the format is unknown or writing fails
+func (a *Aggregator) Write(w io.Writer, format string) (int, error) {
+	if format == "" {
+		format = output.FormatTable
+	}
+	cols := a.Columns()
+	out, err := output.NewWriter(w, output.Options{Format: format, Fields: cols})
+	if err != nil {
+		return 0, err
+	}
+	groups := a.Groups()
+	for _, g := range groups {
+		if err := out.Write(a.record(g)); err != nil {
+			return 0, err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return 0, err
+	}
+	return len(groups), nil
+}
+
+// record returns a group keyed by column name; missing values are nil
+func (a *Aggregator) record(g *Group) map[string]any {
+	rec := make(map[string]any)
+	if a.opts.Bucket > 0 {
+		rec["bucket"] = nullIfEmpty(a.formatTime(g.Bucket))
+	}
+	for i, field := range a.opts.Fields {
+		rec[field] = nullIfEmpty(g.Values[i])
+	}
+	rec["count"] = g.Count
+	for i, field := range a.opts.Distinct {
+		rec["distinct_"+field] = g.Distinct[i]
+	}
+	rec["first_seen"] = nullIfEmpty(a.formatTime(g.FirstSeen))
+	rec["last_seen"] = nullIfEmpty(a.formatTime(g.LastSeen))
+	if a.hasRows {
+		rec["rows"] = nil
+		if g.HasRows {
+			rec["rows"] = g.Rows
+		}
+	}
+	return rec
+}
+
+func (a *Aggregator) formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.In(a.opts.Location).Format(time.RFC3339)
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// bucketTime orders groups without a bucket first
+func bucketTime(g *Group) time.Time {
+	if g.Bucket == nil {
+		return time.Time{}
+	}
+	return *g.Bucket
+}
+
+// bucketStart returns the start of t's bucket. Buckets of whole days start at
+// midnight in loc, and buckets of whole weeks on a Monday (as report's weeks
+// do); shorter buckets are aligned to loc's offset from UTC.
+func bucketStart(t time.Time, width time.Duration, loc *time.Location) time.Time {
+	local := t.In(loc)
+	const day = 24 * time.Hour
+	if width >= day && width%day == 0 {
+		midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		days := int(width / day)
+		if days > 1 {
+			// Count days from 1970-01-01 (a Thursday) in loc so multi-day
+			// buckets are stable; weeks count from Monday 1970-01-05
+			epochDay := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC).Unix() / int64(day/time.Second)
+			if days%7 == 0 {
+				epochDay -= 4
+			}
+			midnight = midnight.AddDate(0, 0, -int(((epochDay%int64(days))+int64(days))%int64(days)))
+		}
+		return midnight
+	}
+	_, offset := local.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(width).Add(-shift).In(loc)
+}
+
+// groupCombinations returns every combination of the event's values for the
+// group-by fields; a missing field contributes a single "" value
+func groupCombinations(e Event, fields []string) [][]string {
+	combos := [][]string{{}}
+	for _, field := range fields {
+		values := fieldValues(e, field)
+		if len(values) == 0 {
+			values = []string{""}
+		}
+		next := make([][]string, 0, len(combos)*len(values))
+		for _, combo := range combos {
+			for _, v := range values {
+				next = append(next, append(append([]string{}, combo...), v))
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// fieldValues returns the distinct text values of a field: one for a scalar,
+// one per element for an array. sensitivity.category and sensitivity.field
+// split the entries of the sensitivity array.
+func fieldValues(e Event, field string) []string {
+	var values []string
+	seen := make(map[string]bool)
+	add := func(v string) {
+		if !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+
+	switch field {
+	case fieldSensitivityCategory, fieldSensitivityField:
+		entries, _ := GetStringSlice(e, "sensitivity")
+		for _, entry := range entries {
+			category, name := ParseSensitivityEntry(entry)
+			if field == fieldSensitivityCategory {
+				add(category)
+			} else if name != "" {
+				add(name)
+			}
+		}
+		return values
+	}
+
+	v, ok := GetPath(e, field)
+	if !ok || v == nil {
+		return nil
+	}
+	switch items := v.(type) {
+	case []any:
+		for _, item := range items {
+			if s, ok := scalarString(item); ok {
+				add(s)
+			}
+		}
+	case []string:
+		for _, item := range items {
+			add(item)
+		}
+	default:
+		if s, ok := scalarString(v); ok {
+			add(s)
+		} else if data, err := json.Marshal(v); err == nil {
+			add(string(data))
+		}
+	}
+	return values
+}
+
+// rowCount returns the event's row count from the first of rowCountFields it has
+func rowCount(e Event) (int64, bool) {
+	for _, field := range rowCountFields {
+		if v, ok := GetPath(e, field); ok {
+			if n, ok := numberValue(v); ok {
+				return int64(n), true
+			}
+		}
+	}
+	return 0, false
+}
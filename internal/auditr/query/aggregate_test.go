@@ -0,0 +1,154 @@
+package query
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/output"
+)
+
+func aggregateEvents() []Event {
+	return []Event{
+		{"db_user": "alice", "client_ip": "10.0.0.1", "sensitivity": []any{"PHI:diagnosis", "PII:email"}, "timestamp": "2025-09-01T10:00:00Z", "row_count": float64(5)},
+		{"db_user": "alice", "client_ip": "10.0.0.2", "sensitivity": []any{"PHI:diagnosis"}, "timestamp": "2025-09-01T12:00:00Z", "row_count": float64(7)},
+		{"db_user": "bob", "client_ip": "10.0.0.3", "sensitivity": []any{"PHI:mrn"}, "timestamp": "2025-09-01T23:30:00Z"},
+		{"db_user": "bob", "client_ip": "10.0.0.3", "sensitivity": []any{"PHI:mrn"}, "timestamp": "2025-09-02T08:00:00Z"},
+		{"db_user": "carol", "sensitivity": []any{"PII:email"}, "timestamp": "2025-09-02T09:00:00Z"},
+	}
+}
+
+func TestAggregator_GroupBy(t *testing.T) {
+	a := NewAggregator(GroupOptions{Fields: []string{"db_user", "sensitivity.field"}, Distinct: []string{"client_ip"}})
+	for _, e := range aggregateEvents() {
+		a.Add(e)
+	}
+
+	var got []string
+	for _, g := range a.Groups() {
+		got = append(got, strings.Join(g.Values, "/"))
+	}
+	if want := "alice/diagnosis,bob/mrn,alice/email,carol/email"; strings.Join(got, ",") != want {
+		t.Fatalf("groups = %s, want %s", strings.Join(got, ","), want)
+	}
+
+	alice := a.Groups()[0]
+	if alice.Count != 2 || alice.Distinct[0] != 2 || alice.Rows != 12 || !alice.HasRows {
+		t.Errorf("alice/diagnosis = %+v", alice)
+	}
+	if alice.FirstSeen.Format(time.RFC3339) != "2025-09-01T10:00:00Z" || alice.LastSeen.Format(time.RFC3339) != "2025-09-01T12:00:00Z" {
+		t.Errorf("alice/diagnosis seen %s - %s", alice.FirstSeen, alice.LastSeen)
+	}
+	if bob := a.Groups()[1]; bob.HasRows || bob.Distinct[0] != 1 {
+		t.Errorf("bob/mrn = %+v", bob)
+	}
+}
+
+func TestAggregator_BucketTop(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	tests := []struct {
+		name string
+		opts GroupOptions
+		want string
+	}{
+		{"daily top user in UTC", GroupOptions{Fields: []string{"db_user"}, Bucket: 24 * time.Hour, Top: 1},
+			"2025-09-01T00:00:00Z alice 2|2025-09-02T00:00:00Z bob 1"},
+		{"daily buckets follow the zone", GroupOptions{Fields: []string{"db_user"}, Bucket: 24 * time.Hour, Top: 1, Location: tokyo},
+			"2025-09-01T00:00:00+09:00 alice 2|2025-09-02T00:00:00+09:00 bob 2"},
+		{"12 hour buckets", GroupOptions{Fields: []string{"sensitivity.category"}, Bucket: 12 * time.Hour},
+			"2025-09-01T00:00:00Z PHI 1|2025-09-01T00:00:00Z PII 1|2025-09-01T12:00:00Z PHI 2|2025-09-02T00:00:00Z PHI 1|2025-09-02T00:00:00Z PII 1"},
+		{"ties go to more rows", GroupOptions{Fields: []string{"client_ip"}, Top: 2},
+			"10.0.0.3 2|10.0.0.2 1"},
+		{"missing field groups as empty", GroupOptions{Fields: []string{"client_ip"}},
+			"10.0.0.3 2|10.0.0.2 1|10.0.0.1 1| 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAggregator(tt.opts)
+			for _, e := range aggregateEvents() {
+				a.Add(e)
+			}
+			cols := a.Columns()
+			var got []string
+			for _, g := range a.Groups() {
+				rec := a.record(g)
+				var row []string
+				for _, col := range cols[:len(cols)-3] {
+					row = append(row, output.CellText(rec[col]))
+				}
+				got = append(got, strings.Join(row, " "))
+			}
+			if strings.Join(got, "|") != tt.want {
+				t.Errorf("groups = %s, want %s", strings.Join(got, "|"), tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregator_Write(t *testing.T) {
+	a := NewAggregator(GroupOptions{Fields: []string{"client_ip"}, Top: 2})
+	for _, e := range aggregateEvents() {
+		a.Add(e)
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{output.FormatCSV, "client_ip,count,first_seen,last_seen,rows\n" +
+			"10.0.0.3,2,2025-09-01T23:30:00Z,2025-09-02T08:00:00Z,\n" +
+			"10.0.0.2,1,2025-09-01T12:00:00Z,2025-09-01T12:00:00Z,7\n"},
+		{output.FormatNDJSON, `{"client_ip":"10.0.0.3","count":2,"first_seen":"2025-09-01T23:30:00Z","last_seen":"2025-09-02T08:00:00Z","rows":null}` + "\n" +
+			`{"client_ip":"10.0.0.2","count":1,"first_seen":"2025-09-01T12:00:00Z","last_seen":"2025-09-01T12:00:00Z","rows":7}` + "\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		n, err := a.Write(&buf, tt.format)
+		if err != nil || n != 2 {
+			t.Fatalf("Write(%s) = %d, %v", tt.format, n, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("Write(%s) =\n%s\nwant\n%s", tt.format, buf.String(), tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := a.Write(&buf, output.FormatTable); err != nil || !strings.HasPrefix(buf.String(), "CLIENT_IP  COUNT") || !strings.Contains(buf.String(), "  -\n") {
+		t.Errorf("Write(table) = %q, %v", buf.String(), err)
+	}
+	if _, err := a.Write(&buf, "xml"); err == nil {
+		t.Errorf("Write(xml) accepted")
+	}
+}
+
+func TestBucketStart(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("zone database not available")
+	}
+	ts := time.Date(2025, 9, 4, 15, 45, 30, 0, time.FixedZone("X", 2*3600)) // Thursday
+
+	tests := []struct {
+		name  string
+		t     time.Time
+		width time.Duration
+		loc   *time.Location
+		want  time.Time
+	}{
+		{"hour", ts, time.Hour, time.UTC, time.Date(2025, 9, 4, 13, 0, 0, 0, time.UTC)},
+		{"day", ts, 24 * time.Hour, time.UTC, time.Date(2025, 9, 4, 0, 0, 0, 0, time.UTC)},
+		{"day in zone", time.Date(2025, 9, 4, 23, 30, 0, 0, time.UTC), 24 * time.Hour, berlin, time.Date(2025, 9, 5, 0, 0, 0, 0, berlin)},
+		{"week starts on Monday", ts, 7 * 24 * time.Hour, time.UTC, time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"Sunday ends the week", time.Date(2025, 9, 7, 23, 0, 0, 0, time.UTC), 7 * 24 * time.Hour, time.UTC, time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"week in zone", time.Date(2025, 9, 7, 23, 0, 0, 0, time.UTC), 7 * 24 * time.Hour, berlin, time.Date(2025, 9, 8, 0, 0, 0, 0, berlin)},
+		{"two weeks", ts, 14 * 24 * time.Hour, time.UTC, time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"before 1970", time.Date(1969, 12, 31, 12, 0, 0, 0, time.UTC), 7 * 24 * time.Hour, time.UTC, time.Date(1969, 12, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bucketStart(tt.t, tt.width, tt.loc); !got.Equal(tt.want) {
+				t.Errorf("bucketStart() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
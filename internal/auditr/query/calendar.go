@@ -0,0 +1,213 @@
+package query
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+)
+
+// Calendar decides which times are business hours.
+// It is built from a config.BusinessCalendar (see LoadCalendar and NewCalendar).
+//
+// Fields:
+//   - Location: Zone business days and hours are evaluated in
+//   - workdays: Weekdays that are business days, unless a holiday
+//   - start/end: Business hours as minutes after midnight; end is exclusive
+//   - holidays: Holiday names by date (2006-01-02) or, for yearly holidays, month and day (01-02)
+type Calendar struct {
+	Location *time.Location
+	workdays map[time.Weekday]bool
+	start    int
+	end      int
+	holidays map[string]string
+}
+
+// NewCalendar builds a calendar from its configuration.
+//
+// Args:
+//   - bc: The calendar configuration (nil for config.DefaultBusinessCalendar)
+//   - loc: Zone used when the calendar sets no timezone (nil for UTC)
+//
+// Returns:
+//   - The calendar
+//   - Error if the configuration is invalid
+func NewCalendar(bc *config.BusinessCalendar, loc *time.Location) (*Calendar, error) {
+	if bc == nil {
+		bc = &config.DefaultBusinessCalendar
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	c := &Calendar{Location: loc, workdays: make(map[time.Weekday]bool), holidays: make(map[string]string)}
+	if bc.Timezone != "" {
+		tz, err := time.LoadLocation(bc.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", bc.Timezone, err)
+		}
+		c.Location = tz
+	}
+
+	workdays := bc.Workdays
+	if len(workdays) == 0 {
+		workdays = config.DefaultBusinessCalendar.Workdays
+	}
+	for _, day := range workdays {
+		d, ok := config.ParseWeekday(day)
+		if !ok {
+			return nil, fmt.Errorf("invalid workday %q", day)
+		}
+		c.workdays[d] = true
+	}
+
+	hours := bc.BusinessHours
+	if hours.Start == "" && hours.End == "" {
+		hours = config.DefaultBusinessCalendar.BusinessHours
+	}
+	var err error
+	if c.start, err = config.ParseTimeOfDay(hours.Start); err != nil {
+		return nil, err
+	}
+	if c.end, err = config.ParseTimeOfDay(hours.End); err != nil {
+		return nil, err
+	}
+	if c.start >= c.end {
+		return nil, fmt.Errorf("business hours start %s must be before end %s", hours.Start, hours.End)
+	}
+
+	for _, h := range bc.Holidays {
+		name := h.Name
+		if name == "" {
+			name = "holiday"
+		}
+		c.holidays[h.Date] = name
+	}
+	return c, nil
+}
+
+// LoadCalendar loads and validates a business calendar file from a file path.
+// It uses the config.ValidateCalendar function.
+func LoadCalendar(path string, loc *time.Location) (*Calendar, error) {
+	logger.L().Debugw("Loading business calendar", "path", path)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open calendar file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	bc, err := config.ValidateCalendar(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate calendar: %w", err)
+	}
+	cal, err := NewCalendar(bc, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.L().Debugw("Loaded business calendar",
+		"timezone", cal.Location.String(),
+		"workdays", strings.Join(bc.Workdays, ","),
+		"business_hours", bc.BusinessHours.Start+"-"+bc.BusinessHours.End,
+		"holidays", len(bc.Holidays))
+	return cal, nil
+}
+
+// Holiday returns the name of the holiday on t's date in the calendar's zone, if any
+func (c *Calendar) Holiday(t time.Time) (string, bool) {
+	t = t.In(c.Location)
+	if name, ok := c.holidays[t.Format("2006-01-02")]; ok {
+		return name, true
+	}
+	name, ok := c.holidays[t.Format("01-02")]
+	return name, ok
+}
+
+// IsBusinessDay reports whether t falls on a workday that is not a holiday
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	if !c.workdays[t.In(c.Location).Weekday()] {
+		return false
+	}
+	_, holiday := c.Holiday(t)
+	return !holiday
+}
+
+// IsBusinessHours reports whether t falls within business hours of a business day
+func (c *Calendar) IsBusinessHours(t time.Time) bool {
+	if !c.IsBusinessDay(t) {
+		return false
+	}
+	local := t.In(c.Location)
+	minute := local.Hour()*60 + local.Minute()
+	return minute >= c.start && minute < c.end
+}
+
+// LastBusinessDay returns the window [start, end) of the last business day
+// before now's date, from midnight to midnight in the calendar's zone
+func (c *Calendar) LastBusinessDay(now time.Time) (time.Time, time.Time) {
+	local := now.In(c.Location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.Location)
+	// A year without a business day would mean a misconfigured calendar; stop there
+	for i := 0; i < 366; i++ {
+		day = day.AddDate(0, 0, -1)
+		if c.IsBusinessDay(day) {
+			break
+		}
+	}
+	return day, day.AddDate(0, 0, 1)
+}
+
+// ParseTimeIn parses a time given on the command line.
+// Times with a zone (RFC 3339) are taken as-is; dates and times without a zone
+// are in loc (UTC if nil).
+//
+// Examples: "2025-10-01T08:00:00Z", "2025-10-01T08:00:00+02:00", "2025-10-01 08:00", "2025-10-01"
+func ParseTimeIn(s string, loc *time.Location) (time.Time, error) {
+	t, _, err := parseTimeIn(s, loc)
+	return t, err
+}
+
+// ParseUntil parses the end of a time window like ParseTimeIn, except that a
+// date on its own ends after that day, so --until 2025-09-30 includes September 30
+func ParseUntil(s string, loc *time.Location) (time.Time, error) {
+	t, dateOnly, err := parseTimeIn(s, loc)
+	if err == nil && dateOnly {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, err
+}
+
+// parseTimeIn parses a time and reports whether it was a date on its own
+func parseTimeIn(s string, loc *time.Location) (time.Time, bool, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, false, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid time %q, expected RFC3339 (2025-10-01T08:00:00Z), 2025-10-01 08:00 or 2025-10-01", s)
+}
+
+// ParseMonth returns the window [start, end) of a calendar month given as 2025-09, in loc (UTC if nil)
+func ParseMonth(s string, loc *time.Location) (time.Time, time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	start, err := time.ParseInLocation("2006-01", strings.TrimSpace(s), loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid month %q, expected YYYY-MM", s)
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
@@ -0,0 +1,94 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+)
+
+func testCalendar(t *testing.T) *Calendar {
+	t.Helper()
+	cal, err := NewCalendar(&config.BusinessCalendar{
+		Timezone:      "America/New_York",
+		BusinessHours: config.BusinessHours{Start: "08:00", End: "18:00"},
+		Holidays:      []config.Holiday{{Date: "12-25", Name: "Christmas"}, {Date: "2025-09-01", Name: "Labor Day"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewCalendar() error = %v", err)
+	}
+	return cal
+}
+
+func TestCalendar_IsBusinessHours(t *testing.T) {
+	cal := testCalendar(t)
+	tests := []struct {
+		name string
+		at   string
+		want bool
+	}{
+		{"weekday morning", "2025-09-02T12:00:00Z", true},            // 08:00 EDT
+		{"before opening", "2025-09-02T11:59:00Z", false},            // 07:59 EDT
+		{"closing time is off-hours", "2025-09-02T22:00:00Z", false}, // 18:00 EDT
+		{"weekend", "2025-09-06T14:00:00Z", false},
+		{"dated holiday", "2025-09-01T14:00:00Z", false},
+		{"yearly holiday", "2026-12-25T15:00:00Z", false},
+		{"evening in UTC is business hours in New York", "2025-09-02T20:00:00Z", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			at, _ := time.Parse(time.RFC3339, tt.at)
+			if got := cal.IsBusinessHours(at); got != tt.want {
+				t.Errorf("IsBusinessHours(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalendar_LastBusinessDay(t *testing.T) {
+	cal := testCalendar(t)
+	ny, _ := time.LoadLocation("America/New_York")
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2025, 9, 3, 10, 0, 0, 0, ny), time.Date(2025, 9, 2, 0, 0, 0, 0, ny)},      // Wednesday -> Tuesday
+		{time.Date(2025, 9, 2, 10, 0, 0, 0, ny), time.Date(2025, 8, 29, 0, 0, 0, 0, ny)},     // Tuesday after Labor Day -> Friday
+		{time.Date(2025, 9, 8, 1, 0, 0, 0, time.UTC), time.Date(2025, 9, 5, 0, 0, 0, 0, ny)}, // Sunday night in New York -> Friday
+	}
+	for _, tt := range tests {
+		start, end := cal.LastBusinessDay(tt.now)
+		if !start.Equal(tt.want) || !end.Equal(tt.want.AddDate(0, 0, 1)) {
+			t.Errorf("LastBusinessDay(%s) = %s - %s, want %s", tt.now, start, end, tt.want)
+		}
+	}
+}
+
+func TestParseTimeWindows(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	since, err := ParseTimeIn("2025-10-01 08:00", berlin)
+	if err != nil || !since.Equal(time.Date(2025, 10, 1, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseTimeIn(local time) = %s, %v", since, err)
+	}
+	since, err = ParseTimeIn("2025-10-01T08:00:00Z", berlin)
+	if err != nil || !since.Equal(time.Date(2025, 10, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseTimeIn(RFC3339) = %s, %v", since, err)
+	}
+	until, err := ParseUntil("2025-09-30", nil)
+	if err != nil || !until.Equal(time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseUntil(date) = %s, %v", until, err)
+	}
+	until, err = ParseUntil("2025-09-30T12:00", nil)
+	if err != nil || !until.Equal(time.Date(2025, 9, 30, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseUntil(time) = %s, %v", until, err)
+	}
+	if _, err := ParseTimeIn("yesterday", nil); err == nil {
+		t.Errorf("ParseTimeIn(yesterday) accepted")
+	}
+
+	start, end, err := ParseMonth("2025-10", berlin)
+	if err != nil || !start.Equal(time.Date(2025, 9, 30, 22, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2025, 10, 31, 23, 0, 0, 0, time.UTC)) {
+		t.Errorf("ParseMonth(2025-10) = %s - %s, %v", start, end, err)
+	}
+}
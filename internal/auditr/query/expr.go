@@ -0,0 +1,561 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/enrich"
+)
+
+// This file implements the --where expression language.
+//
+// An expression is compiled once into an EventFilter built from the same
+// composable filters as the other flags (see FilterAllOf, FilterAnyOf and
+// FilterNot), so it combines with them using AND logic.
+//
+// Grammar (keywords are case-insensitive):
+//
+//	expr       := and ( "or" and )*
+//	and        := unary ( "and" unary )*
+//	unary      := "not" unary | "(" expr ")" | predicate
+//	predicate  := field [ op value | [ "not" ] "in" list | [ "not" ] "contains" value | [ "not" ] "matches" value ]
+//	op         := "=" | "==" | "!=" | "<" | "<=" | ">" | ">=" | "~" | "!~"
+//	list       := "(" value ( "," value )* ")" | "[" value ( "," value )* "]"
+//	value      := "string" | 'string' | word | number | true | false | null | @time
+//
+// Fields are paths into the event, e.g. db_user, enrichment.schema or
+// meta.client.host. A field on its own is true when it is set to a non-empty,
+// non-false value (e.g. "bulk").
+//
+// Examples:
+//
+//	sensitivity contains "PHI" and db_user = appuser1 and query_type = SELECT
+//	(sensitivity contains PHI and db_user = appuser1) or bulk_type = export
+//	risk_level >= high and not db_user in (postgres, replicator)
+//	query ~ "(?i)password" and timestamp >= @2025-10-01 and timestamp < @now-1h
+
+// CompileWhere compiles a --where expression into an EventFilter.
+//
+// Comparison rules:
+//   - Strings compare case-insensitively; risk levels order low < medium < high < critical
+//   - Numbers compare numerically, including numeric strings in the event
+//   - Time literals (@2025-10-01, @2025-10-01T08:00:00Z, @now, @now-7d) compare
+//     against timestamps in the event; times without a zone are UTC (--tz with the CLI)
+//   - Array fields (sensitivity, regulations, ...) match when any element matches
+//   - contains is a case-insensitive substring match; matches / ~ is a Go regular
+//     expression (use (?i) for case-insensitive)
+//   - A missing field matches no comparison; != and not match it
+//
+// Args:
+//   - expr: The expression
+//
+// Returns:
+//   - The compiled filter
+//   - Error describing the first syntax error or invalid literal, with its offset
+func CompileWhere(expr string) (EventFilter, error) {
+	return compileWhere(expr, time.Now(), time.UTC)
+}
+
+// compileWhere compiles an expression with @now fixed at now and zone-less
+// time literals in loc (UTC if nil)
+func compileWhere(expr string, now time.Time, loc *time.Location) (EventFilter, error) {
+	toks, err := lexWhere(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &whereParser{toks: toks, now: now, loc: loc}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+	return f, nil
+}
+
+// Token kinds of the expression lexer
+const (
+	tokEOF    = iota
+	tokWord   // Field name, keyword or bare value
+	tokString // Quoted string
+	tokTime   // @time literal
+	tokOp     // Comparison operator
+	tokPunct  // ( ) [ ] ,
+)
+
+type whereToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// lexWhere splits an expression into tokens
+func lexWhere(s string) ([]whereToken, error) {
+	var toks []whereToken
+	isWord := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.:/-*", r)
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.IndexByte("()[],", c) >= 0:
+			toks = append(toks, whereToken{tokPunct, string(c), i})
+			i++
+		case strings.IndexByte("=!<>~", c) >= 0:
+			op := string(c)
+			if i+1 < len(s) && (s[i+1] == '=' || (c == '!' && s[i+1] == '~')) {
+				op = s[i : i+2]
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected \"!\" at offset %d (use != or not)", i)
+			}
+			toks = append(toks, whereToken{tokOp, op, i})
+			i += len(op)
+		case c == '"' || c == '\'':
+			text, n, err := lexString(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%v at offset %d", err, i)
+			}
+			toks = append(toks, whereToken{tokString, text, i})
+			i += n
+		case c == '@':
+			j := i + 1
+			for j < len(s) && (isWord(rune(s[j])) || s[j] == '+') {
+				j++
+			}
+			toks = append(toks, whereToken{tokTime, s[i+1 : j], i})
+			i = j
+		default:
+			j := i
+			for j < len(s) {
+				r, n := utf8.DecodeRuneInString(s[j:])
+				if !isWord(r) {
+					break
+				}
+				j += n
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q at offset %d", s[i:i+1], i)
+			}
+			toks = append(toks, whereToken{tokWord, s[i:j], i})
+			i = j
+		}
+	}
+	return append(toks, whereToken{tokEOF, "", len(s)}), nil
+}
+
+// lexString reads a quoted string; a backslash escapes the next character
+func lexString(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// whereParser is a recursive descent parser that compiles as it parses
+type whereParser struct {
+	toks []whereToken
+	i    int
+	now  time.Time
+	loc  *time.Location
+}
+
+func (p *whereParser) peek() whereToken {
+	return p.toks[p.i]
+}
+
+func (p *whereParser) next() whereToken {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// keyword reports whether the next token is the given keyword, consuming it if so
+func (p *whereParser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == tokWord && strings.EqualFold(t.text, kw) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *whereParser) errorf(t whereToken, format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...)
+	if t.kind == tokEOF {
+		return fmt.Errorf("%s at end of expression", msg)
+	}
+	return fmt.Errorf("%s at offset %d", msg, t.pos)
+}
+
+func (p *whereParser) parseOr() (EventFilter, error) {
+	f, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	filters := []EventFilter{f}
+	for p.keyword("or") {
+		f, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return FilterAnyOf(filters...), nil
+}
+
+func (p *whereParser) parseAnd() (EventFilter, error) {
+	f, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	filters := []EventFilter{f}
+	for p.keyword("and") {
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return FilterAllOf(filters...), nil
+}
+
+func (p *whereParser) parseUnary() (EventFilter, error) {
+	if p.keyword("not") {
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return FilterNot(f), nil
+	}
+	if t := p.peek(); t.kind == tokPunct && t.text == "(" {
+		p.next()
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokPunct || t.text != ")" {
+			return nil, p.errorf(t, "expected \")\"")
+		}
+		return f, nil
+	}
+	return p.parsePredicate()
+}
+
+// whereKeywords can't be used as field names
+var whereKeywords = map[string]bool{"and": true, "or": true, "not": true, "in": true, "contains": true, "matches": true}
+
+func (p *whereParser) parsePredicate() (EventFilter, error) {
+	t := p.next()
+	if t.kind != tokWord || whereKeywords[strings.ToLower(t.text)] {
+		return nil, p.errorf(t, "expected field name, got %q", t.text)
+	}
+	field := t.text
+
+	negate := p.keyword("not")
+	op := p.peek()
+	var f EventFilter
+	var err error
+	switch {
+	case op.kind == tokWord && strings.EqualFold(op.text, "in"):
+		p.next()
+		f, err = p.parseIn(field)
+	case op.kind == tokWord && strings.EqualFold(op.text, "contains"):
+		p.next()
+		f, err = p.parseValueFilter(field, "contains")
+	case op.kind == tokWord && strings.EqualFold(op.text, "matches"):
+		p.next()
+		f, err = p.parseValueFilter(field, "~")
+	case negate:
+		return nil, p.errorf(op, "expected in, contains or matches after not")
+	case op.kind == tokOp:
+		p.next()
+		f, err = p.parseValueFilter(field, op.text)
+	default:
+		// A field on its own tests that it is set
+		return FilterByField(field, isTruthy), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if negate {
+		return FilterNot(f), nil
+	}
+	return f, nil
+}
+
+// parseIn compiles "field in (a, b, ...)"
+func (p *whereParser) parseIn(field string) (EventFilter, error) {
+	open := p.next()
+	if open.kind != tokPunct || (open.text != "(" && open.text != "[") {
+		return nil, p.errorf(open, "expected list after in")
+	}
+	closing := map[string]string{"(": ")", "[": "]"}[open.text]
+	var values []whereValue
+	for {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		t := p.next()
+		if t.kind == tokPunct && t.text == closing {
+			break
+		}
+		if t.kind != tokPunct || t.text != "," {
+			return nil, p.errorf(t, "expected \",\" or %q in list", closing)
+		}
+	}
+	return FilterByField(field, func(v any) bool {
+		for _, want := range values {
+			if want.equal(v) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// parseValueFilter compiles "field <op> value"
+func (p *whereParser) parseValueFilter(field, op string) (EventFilter, error) {
+	at := p.peek()
+	want, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "=", "==":
+		return FilterByField(field, want.equal), nil
+	case "!=":
+		return FilterNot(FilterByField(field, want.equal)), nil
+	case "<", "<=", ">", ">=":
+		return FilterByField(field, func(v any) bool {
+			c, ok := want.compare(v)
+			switch op {
+			case "<":
+				return ok && c < 0
+			case "<=":
+				return ok && c <= 0
+			case ">":
+				return ok && c > 0
+			}
+			return ok && c >= 0
+		}), nil
+	case "contains":
+		return FilterByField(field, func(v any) bool {
+			s, ok := scalarString(v)
+			return ok && containsString(s, want.text)
+		}), nil
+	case "~", "!~":
+		re, err := regexp.Compile(want.text)
+		if err != nil {
+			return nil, p.errorf(at, "invalid regular expression: %v", err)
+		}
+		f := FilterByField(field, func(v any) bool {
+			s, ok := scalarString(v)
+			return ok && re.MatchString(s)
+		})
+		if op == "!~" {
+			return FilterNot(f), nil
+		}
+		return f, nil
+	}
+	return nil, p.errorf(at, "unknown operator %q", op)
+}
+
+// whereValue is a literal of an expression
+type whereValue struct {
+	text   string     // Text of the literal (unquoted)
+	num    *float64   // Numeric value of bare numbers
+	t      *time.Time // Time literals
+	isBool bool       // true / false
+	isNull bool       // null
+}
+
+func (p *whereParser) parseValue() (whereValue, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return whereValue{text: t.text}, nil
+	case tokTime:
+		tm, err := parseTimeLiteral(t.text, p.now, p.loc)
+		if err != nil {
+			return whereValue{}, p.errorf(t, "%v", err)
+		}
+		return whereValue{text: t.text, t: &tm}, nil
+	case tokWord:
+		v := whereValue{text: t.text}
+		switch strings.ToLower(t.text) {
+		case "true", "false":
+			v.isBool, v.text = true, strings.ToLower(t.text)
+		case "null":
+			v.isNull = true
+		default:
+			if n, err := strconv.ParseFloat(t.text, 64); err == nil {
+				v.num = &n
+			}
+		}
+		return v, nil
+	}
+	return whereValue{}, p.errorf(t, "expected value, got %q", t.text)
+}
+
+// parseTimeLiteral parses the text of an @time literal: a date, an RFC 3339
+// time, or now with an optional +/- duration (e.g. now-7d)
+func parseTimeLiteral(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	if strings.HasPrefix(strings.ToLower(s), "now") {
+		rest := s[3:]
+		if rest == "" {
+			return now, nil
+		}
+		sign := time.Duration(1)
+		switch rest[0] {
+		case '-':
+			sign = -1
+		case '+':
+		default:
+			return time.Time{}, fmt.Errorf("invalid time %q, expected now-<duration> or now+<duration>", "@"+s)
+		}
+		d, err := ParseDuration(rest[1:])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q: %v", "@"+s, err)
+		}
+		return now.Add(sign * d), nil
+	}
+	if t, err := ParseTimeIn(s, loc); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected @2025-10-01, @2025-10-01T08:00:00Z or @now-7d", "@"+s)
+}
+
+// equal reports whether an event value equals the literal
+func (w whereValue) equal(v any) bool {
+	switch {
+	case w.isNull:
+		return v == nil
+	case w.t != nil:
+		t, err := ParseTimestamp(v)
+		return err == nil && t.Equal(*w.t)
+	case w.isBool:
+		if b, ok := v.(bool); ok {
+			return strconv.FormatBool(b) == w.text
+		}
+	case w.num != nil:
+		if n, ok := numberValue(v); ok {
+			return n == *w.num
+		}
+	}
+	s, ok := scalarString(v)
+	return ok && stringsEqualFold(s, w.text)
+}
+
+// compare orders an event value against the literal; ok is false when they
+// can't be ordered
+func (w whereValue) compare(v any) (int, bool) {
+	switch {
+	case w.t != nil:
+		t, err := ParseTimestamp(v)
+		if err != nil {
+			return 0, false
+		}
+		return t.Compare(*w.t), true
+	case w.num != nil:
+		n, ok := numberValue(v)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case n < *w.num:
+			return -1, true
+		case n > *w.num:
+			return 1, true
+		}
+		return 0, true
+	}
+	s, ok := scalarString(v)
+	if !ok {
+		return 0, false
+	}
+	a, b := strings.ToLower(s), strings.ToLower(w.text)
+	if enrich.ValidateRiskLevel(a) && enrich.ValidateRiskLevel(b) {
+		return enrich.CompareRiskLevels(a, b), true
+	}
+	return strings.Compare(a, b), true
+}
+
+// numberValue returns the numeric value of a number or numeric string
+func numberValue(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// scalarString returns the text of a string, number or boolean value
+func scalarString(v any) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(s), true
+	case int64:
+		return strconv.FormatInt(s, 10), true
+	case bool:
+		return strconv.FormatBool(s), true
+	}
+	return "", false
+}
+
+// isTruthy reports whether a value is set: not null, false, zero or empty
+func isTruthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case float64:
+		return x != 0
+	case map[string]any:
+		return len(x) > 0
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package query
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileWhere(t *testing.T) {
+	now := time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)
+	event := Event{
+		"event_id":    "e1",
+		"timestamp":   "2025-10-02T09:30:00Z",
+		"db_user":     "appuser1",
+		"query_type":  "SELECT",
+		"query":       "SELECT ssn FROM patients WHERE id = 7",
+		"sensitivity": []any{"PII:ssn", "PHI:diagnosis"},
+		"bulk":        false,
+		"row_count":   float64(250),
+		"risk_level":  "high",
+		"enrichment":  map[string]any{"schema": "clinical", "tables": []any{"patients", "visits"}},
+		"meta":        map[string]any{"client": map[string]any{"host": "10.0.0.3", "port": "5432"}},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`db_user = appuser1`, true},
+		{`db_user == "APPUSER1"`, true},
+		{`db_user != appuser1`, false},
+		{`db_user in (postgres, appuser1)`, true},
+		{`db_user not in [postgres, replicator]`, true},
+		{`sensitivity contains PHI`, true},
+		{`sensitivity contains Financial`, false},
+		{`sensitivity = "PII:ssn"`, true},
+		{`query ~ "(?i)\\bssn\\b"`, true},
+		{`query matches "^DELETE"`, false},
+		{`query !~ "^DELETE"`, true},
+		{`row_count >= 100 and row_count < 1000`, true},
+		{`row_count > 250`, false},
+		{`meta.client.port = 5432`, true},
+		{`risk_level >= medium`, true},
+		{`risk_level > high`, false},
+		{`enrichment.schema = clinical and enrichment.tables contains visit`, true},
+		{`meta.client.host = 10.0.0.3`, true},
+		{`meta.client.missing = x`, false},
+		{`meta.client.missing != x`, true},
+		{`bulk`, false},
+		{`not bulk and enrichment`, true},
+		{`bulk = false`, true},
+		{`timestamp >= @2025-10-02 and timestamp < @now`, true},
+		{`timestamp >= @now-2h`, false},
+		{`timestamp > @2025-10-02T09:00:00Z`, true},
+		{`(db_user = postgres or query_type = select) and not sensitivity contains Financial`, true},
+		{`db_user = postgres or query_type = UPDATE and bulk`, false},
+		{`db_user = postgres or query_type = SELECT and row_count = 250`, true},
+		{`NOT (db_user = appuser1 OR bulk)`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			filter, err := compileWhere(tt.expr, now, time.UTC)
+			if err != nil {
+				t.Fatalf("compileWhere() error = %v", err)
+			}
+			if got := filter(event); got != tt.want {
+				t.Errorf("compileWhere(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileWhere_Errors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // Substring of the error
+	}{
+		{``, "expected field name"},
+		{`db_user =`, "expected value"},
+		{`db_user = "appuser1`, "unterminated string"},
+		{`(db_user = a`, `expected ")"`},
+		{`db_user = a b`, `unexpected "b" at offset 12`},
+		{`db_user in (a, b`, "in list"},
+		{`db_user not = a`, "expected in, contains or matches"},
+		{`query ~ "("`, "invalid regular expression"},
+		{`timestamp > @yesterday`, "invalid time"},
+		{`db_user ! a`, "use != or not"},
+		{`and = a`, "expected field name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := CompileWhere(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CompileWhere(%q) error = %v, want %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
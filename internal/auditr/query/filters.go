@@ -1,7 +1,10 @@
 package query
 
 import (
+	"strings"
 	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/enrich"
 )
 
 // FilterBySensitivity creates a filter that matches events with specific sensitivity categories.
@@ -10,6 +13,7 @@ import (
 // Examples:
 // - FilterBySensitivity(["PII"]) matches events with sensitivity ["PII:email", "PHI:diagnosis"]
 // - FilterBySensitivity(["PHI", "Financial"]) matches events with any PHI or Financial entries
+// - FilterBySensitivity(["Credentials"]) works the same for custom dictionary categories
 //
 // The filter is case-insensitive and treats missing sensitivity field as non-match.
 func FilterBySensitivity(categories []string) EventFilter {
@@ -31,6 +35,30 @@ func FilterBySensitivity(categories []string) EventFilter {
 	}
 }
 
+// FilterByRegulation creates a filter that matches events tagged with specific regulations.
+// The 'regulations' array is added by the enrich phase from the dictionary category metadata.
+//
+// Examples:
+// - FilterByRegulation(["HIPAA"]) matches events with regulations ["GDPR-Art9", "HIPAA"]
+// - FilterByRegulation(["PCI-DSS", "SOX"]) matches events with any PCI-DSS or SOX tag
+//
+// The filter is case-insensitive and treats missing regulations field as non-match.
+func FilterByRegulation(regulations []string) EventFilter {
+	return func(e Event) bool {
+		tags, ok := GetStringSlice(e, "regulations")
+		if !ok || len(tags) == 0 {
+			return false // No regulation tags = no match
+		}
+
+		for _, tag := range tags {
+			if matchesAny(tag, regulations) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 // FilterByUser creates a filter that matches events by database user.
 // This filter looks for the 'db_user' field in events and performs case-insensitive matching.
 //
@@ -121,6 +149,47 @@ func FilterByBulkType(bulkType string) EventFilter {
 	}
 }
 
+// FilterByRisk creates a filter that matches events by risk level.
+// This filter looks for the 'risk_level' field set by the enrich phase.
+//
+// Examples:
+// - FilterByRisk(["high", "critical"]) matches events where risk_level = "high" or "critical"
+// - FilterByRisk(["low"]) matches only low-risk events
+//
+// The filter is case-insensitive and treats missing risk_level field as non-match.
+func FilterByRisk(levels []string) EventFilter {
+	return func(e Event) bool {
+		riskLevel, ok := GetString(e, "risk_level")
+		if !ok {
+			return false // No risk_level field = no match
+		}
+		return matchesAny(riskLevel, levels) // Check if risk_level matches any of the requested levels
+	}
+}
+
+// FilterByMinRisk creates a filter that matches events at or above a risk level.
+// Levels are ordered low < medium < high < critical (see enrich.CompareRiskLevels).
+//
+// Examples:
+// - FilterByMinRisk("high") matches events where risk_level = "high" or "critical"
+// - FilterByMinRisk("low") matches every event with a valid risk level
+//
+// The filter is case-insensitive and treats missing or unknown risk_level as non-match.
+func FilterByMinRisk(min string) EventFilter {
+	min = strings.ToLower(min)
+	return func(e Event) bool {
+		riskLevel, ok := GetString(e, "risk_level")
+		if !ok {
+			return false // No risk_level field = no match
+		}
+		riskLevel = strings.ToLower(riskLevel)
+		if !enrich.ValidateRiskLevel(riskLevel) {
+			return false // Unknown level can't be ordered = no match
+		}
+		return enrich.CompareRiskLevels(riskLevel, min) >= 0
+	}
+}
+
 // FilterBySensitiveFields creates a filter that matches events containing specific field names in sensitivity entries.
 // This filter looks for field names (after the colon) in sensitivity entries like "PII:email".
 //
@@ -182,6 +251,47 @@ func FilterByTime(since time.Time, last time.Duration) EventFilter {
 	}
 }
 
+// FilterByTimeRange creates a filter that matches events in the window [from, until).
+// This filter is used with --until, --between, --month and --last-business-day.
+//
+// Examples:
+// - FilterByTimeRange(sep1, oct1) matches events in September (--month 2025-09)
+// - FilterByTimeRange(time.Time{}, oct1) matches events before Oct 1 (--until 2025-09-30)
+//
+// A zero bound leaves that side of the window open.
+// The filter treats missing or invalid timestamp as non-match.
+func FilterByTimeRange(from, until time.Time) EventFilter {
+	return func(e Event) bool {
+		timestamp, err := ParseTimestamp(e["timestamp"])
+		if err != nil {
+			return false // Invalid timestamp = no match
+		}
+		if !from.IsZero() && timestamp.Before(from) {
+			return false
+		}
+		return until.IsZero() || timestamp.Before(until) // End of the window is exclusive
+	}
+}
+
+// FilterByBusinessHours creates a filter that matches events inside or outside business hours.
+// This filter is used with --business-hours (inside = true) and --off-hours (inside = false).
+//
+// Examples:
+// - FilterByBusinessHours(cal, false) matches a Saturday login, a 02:00 export or a holiday query
+// - FilterByBusinessHours(cal, true) matches a Tuesday 10:30 SELECT
+//
+// Business days, hours and holidays come from the calendar, in its timezone.
+// The filter treats missing or invalid timestamp as non-match for both.
+func FilterByBusinessHours(cal *Calendar, inside bool) EventFilter {
+	return func(e Event) bool {
+		timestamp, err := ParseTimestamp(e["timestamp"])
+		if err != nil {
+			return false // Invalid timestamp = no match
+		}
+		return cal.IsBusinessHours(timestamp) == inside
+	}
+}
+
 // FilterExcludeErrors creates a filter that excludes ERROR events.
 // This filter is used with the --exclude-errors flag to filter out malformed or error events.
 //
@@ -200,6 +310,73 @@ func FilterExcludeErrors() EventFilter {
 	}
 }
 
+// FilterByField creates a filter that matches events where a field's value satisfies a predicate.
+// The field is a path into the event (see GetPath), so nested keys such as
+// "enrichment.schema" or "meta.client.host" work the same as top-level ones.
+//
+// Examples:
+// - FilterByField("db_user", isTruthy) matches events with a non-empty db_user
+// - FilterByField("sensitivity", p) matches events where p holds for any sensitivity entry
+//
+// Array values match when the predicate holds for any element.
+// The filter treats a missing field as non-match.
+func FilterByField(path string, pred func(any) bool) EventFilter {
+	return func(e Event) bool {
+		v, ok := GetPath(e, path)
+		if !ok {
+			return false // Missing field = no match
+		}
+		switch items := v.(type) {
+		case []any:
+			for _, item := range items {
+				if pred(item) {
+					return true
+				}
+			}
+			return false
+		case []string:
+			for _, item := range items {
+				if pred(item) {
+					return true
+				}
+			}
+			return false
+		}
+		return pred(v)
+	}
+}
+
+// FilterAllOf creates a filter that matches events matching every one of the filters (AND).
+func FilterAllOf(filters ...EventFilter) EventFilter {
+	return func(e Event) bool {
+		return matchAll(e, filters)
+	}
+}
+
+// FilterAnyOf creates a filter that matches events matching at least one of the filters (OR).
+//
+// Examples:
+// - FilterAnyOf(FilterByUser("alice"), FilterByBulkType("export")) matches alice's events and every export
+//
+// If no filters are provided, no events match.
+func FilterAnyOf(filters ...EventFilter) EventFilter {
+	return func(e Event) bool {
+		for _, filter := range filters {
+			if filter(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FilterNot creates a filter that matches events the given filter rejects (NOT).
+func FilterNot(filter EventFilter) EventFilter {
+	return func(e Event) bool {
+		return !filter(e)
+	}
+}
+
 // matchAll applies all filters to an event using AND logic.
 // This is the core function that combines multiple filters.
 // An event must match ALL filters to be included in the results.
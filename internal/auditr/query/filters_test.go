@@ -1,6 +1,8 @@
 package query
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 	"time"
 )
@@ -73,6 +75,81 @@ func TestFilterBySensitivity(t *testing.T) {
 	}
 }
 
+func TestFilterByRegulation(t *testing.T) {
+	tests := []struct {
+		name        string
+		regulations []string
+		event       Event
+		want        bool
+	}{
+		{
+			name:        "matches regulation tag",
+			regulations: []string{"HIPAA"},
+			event: Event{
+				"sensitivity": []string{"PHI:diagnosis"},
+				"regulations": []string{"GDPR-Art9", "HIPAA"},
+			},
+			want: true,
+		},
+		{
+			name:        "case insensitive match",
+			regulations: []string{"pci-dss"},
+			event: Event{
+				"regulations": []interface{}{"PCI-DSS"},
+			},
+			want: true,
+		},
+		{
+			name:        "no match",
+			regulations: []string{"SOX"},
+			event: Event{
+				"regulations": []string{"HIPAA"},
+			},
+			want: false,
+		},
+		{
+			name:        "no regulations field",
+			regulations: []string{"HIPAA"},
+			event: Event{
+				"sensitivity": []string{"PHI:diagnosis"},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := FilterByRegulation(tt.regulations)
+			got := filter(tt.event)
+			if got != tt.want {
+				t.Errorf("FilterByRegulation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStats_CustomCategoriesAndRegulations(t *testing.T) {
+	stats := NewStats()
+	stats.IncrementMatched(Event{
+		"sensitivity": []string{"Credentials:api_key", "GDPR-Special:religion"},
+		"regulations": []string{"GDPR-Art9", "SOC2"},
+	})
+	stats.IncrementMatched(Event{
+		"sensitivity": []interface{}{"Credentials:password_hash"},
+		"regulations": []interface{}{"SOC2"},
+	})
+
+	if stats.BySensitivity["Credentials"] != 2 || stats.BySensitivity["GDPR-Special"] != 1 {
+		t.Errorf("BySensitivity = %v", stats.BySensitivity)
+	}
+	if stats.ByRegulation["SOC2"] != 2 || stats.ByRegulation["GDPR-Art9"] != 1 {
+		t.Errorf("ByRegulation = %v", stats.ByRegulation)
+	}
+	if _, ok := stats.GetSummaryMap()["by_regulation"]; !ok {
+		t.Errorf("summary map missing by_regulation")
+	}
+}
+
 func TestFilterByUser(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -526,3 +603,141 @@ func TestMatchAll(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterByRisk(t *testing.T) {
+	tests := []struct {
+		name   string
+		levels []string
+		event  Event
+		want   bool
+	}{
+		{"matches listed level", []string{"high", "critical"}, Event{"risk_level": "critical"}, true},
+		{"case insensitive match", []string{"HIGH"}, Event{"risk_level": "high"}, true},
+		{"no match for other level", []string{"high", "critical"}, Event{"risk_level": "medium"}, false},
+		{"no risk_level field", []string{"low"}, Event{"query_type": "SELECT"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FilterByRisk(tt.levels)(tt.event); got != tt.want {
+				t.Errorf("FilterByRisk() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterByMinRisk(t *testing.T) {
+	tests := []struct {
+		name  string
+		min   string
+		event Event
+		want  bool
+	}{
+		{"same level", "high", Event{"risk_level": "high"}, true},
+		{"higher level", "high", Event{"risk_level": "critical"}, true},
+		{"lower level", "high", Event{"risk_level": "medium"}, false},
+		{"case insensitive", "Medium", Event{"risk_level": "HIGH"}, true},
+		{"unknown level", "low", Event{"risk_level": "severe"}, false},
+		{"no risk_level field", "low", Event{"query_type": "SELECT"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FilterByMinRisk(tt.min)(tt.event); got != tt.want {
+				t.Errorf("FilterByMinRisk() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStats_RiskCrossTabs(t *testing.T) {
+	stats := NewStats()
+	for _, e := range []Event{
+		{"db_user": "alice", "query_type": "SELECT", "risk_level": "low"},
+		{"db_user": "alice", "query_type": "SELECT", "risk_level": "low"},
+		{"db_user": "bob", "query_type": "SELECT", "risk_level": "critical"},
+		{"db_user": "bob", "query_type": "COPY", "risk_level": "high"},
+		{"db_user": "carol", "query_type": "DELETE", "risk_level": "high"},
+		{"db_user": "carol", "query_type": "DELETE", "risk_level": "High"},
+	} {
+		stats.IncrementMatched(e)
+	}
+
+	if stats.RiskByUser["alice"]["low"] != 2 || stats.RiskByUser["bob"]["critical"] != 1 {
+		t.Errorf("RiskByUser = %v", stats.RiskByUser)
+	}
+	// Risk levels are counted case-insensitively
+	if stats.RiskByUser["carol"]["high"] != 2 || len(stats.RiskByUser["carol"]) != 1 || len(riskColumns(stats.RiskByQueryType)) != 3 {
+		t.Errorf("RiskByUser = %v, RiskByQueryType = %v; want High and high counted together", stats.RiskByUser, stats.RiskByQueryType)
+	}
+	if stats.RiskByQueryType["SELECT"]["low"] != 2 || stats.RiskByQueryType["SELECT"]["critical"] != 1 {
+		t.Errorf("RiskByQueryType = %v", stats.RiskByQueryType)
+	}
+
+	var buf bytes.Buffer
+	stats.PrintSummary(&buf)
+	out := buf.String()
+	header := strings.Index(out, "USER ")
+	if !strings.Contains(out, "low  high  critical  total") {
+		t.Errorf("risk columns not ordered low to critical:\n%s", out)
+	}
+	bob, carol, alice := strings.Index(out, "bob "), strings.Index(out, "carol "), strings.Index(out, "alice ")
+	if header < 0 || !(header < bob && bob < carol && carol < alice) {
+		t.Errorf("risk by user table not ordered riskiest first:\n%s", out)
+	}
+	if !strings.Contains(out, "Risk by query type:") {
+		t.Errorf("summary missing risk by query type:\n%s", out)
+	}
+	if _, ok := stats.GetSummaryMap()["risk_by_user"]; !ok {
+		t.Errorf("summary map missing risk_by_user")
+	}
+}
+
+func TestFilterByTimeRange(t *testing.T) {
+	from := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		from      time.Time
+		until     time.Time
+		timestamp any
+		want      bool
+	}{
+		{"start is inclusive", from, until, "2025-09-01T00:00:00Z", true},
+		{"end is exclusive", from, until, "2025-10-01T00:00:00Z", false},
+		{"before window", from, until, "2025-08-31T23:59:59Z", false},
+		{"offset timestamp inside", from, until, "2025-10-01T01:00:00+02:00", true},
+		{"open start", time.Time{}, until, "2020-01-01T00:00:00Z", true},
+		{"open end", from, time.Time{}, "2030-01-01T00:00:00Z", true},
+		{"missing timestamp", from, until, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FilterByTimeRange(tt.from, tt.until)(Event{"timestamp": tt.timestamp}); got != tt.want {
+				t.Errorf("FilterByTimeRange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterByBusinessHours(t *testing.T) {
+	cal, err := NewCalendar(nil, nil) // Mon-Fri 09:00-17:00 UTC
+	if err != nil {
+		t.Fatalf("NewCalendar() error = %v", err)
+	}
+	inside, outside := FilterByBusinessHours(cal, true), FilterByBusinessHours(cal, false)
+	tests := []struct {
+		timestamp  any
+		wantInside bool
+		wantOff    bool
+	}{
+		{"2025-09-02T10:30:00Z", true, false},
+		{"2025-09-02T02:00:00Z", false, true},
+		{"2025-09-06T10:30:00Z", false, true},
+		{"not a time", false, false},
+	}
+	for _, tt := range tests {
+		e := Event{"timestamp": tt.timestamp}
+		if inside(e) != tt.wantInside || outside(e) != tt.wantOff {
+			t.Errorf("%v: business hours = %v, off hours = %v", tt.timestamp, inside(e), outside(e))
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package query
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+)
+
+// This file implements the sidecar index of NDJSON files (auditr index build).
+// A file is split into blocks of consecutive lines; the index records each
+// block's byte range and time range, and which blocks hold events of each
+// db_user and sensitivity category. RunQuery uses it to read only the blocks
+// that can match the time, user and category filters, and scans files that
+// have no index or whose index is stale.
+
+// IndexSuffix is appended to a file's path to name its index
+const IndexSuffix = ".index.json"
+
+// DefaultIndexBlockEvents is the default number of lines per block
+const DefaultIndexBlockEvents = 1000
+
+// indexVersion is the index format written by this version
+const indexVersion = 1
+
+// Index is the content of an index file.
+//
+// Fields:
+//   - Version: Index format version
+//   - Size / ModTime: Size and modification time of the file when indexed; the
+//     index is stale once either changes
+//   - BlockEvents: Lines (events and malformed lines) per block
+//   - Events / Errors: Events and malformed lines in the file
+//   - Blocks: Blocks in file order
+//   - Users: Block numbers per db_user (lower-case)
+//   - Categories: Block numbers per sensitivity category (lower-case)
+type Index struct {
+	Version     int              `json:"version"`
+	Size        int64            `json:"size"`
+	ModTime     time.Time        `json:"mod_time"`
+	BlockEvents int              `json:"block_events"`
+	Events      int              `json:"events"`
+	Errors      int              `json:"errors"`
+	Blocks      []IndexBlock     `json:"blocks"`
+	Users       map[string][]int `json:"users"`
+	Categories  map[string][]int `json:"categories"`
+}
+
+// IndexBlock is a range of consecutive lines of the file.
+//
+// Fields:
+//   - Offset / Length: Byte range of the block's lines
+//   - FirstLine: Line number of the block's first line (1-based)
+//   - Events / Errors: Events and malformed lines in the block
+//   - MinTime / MaxTime: Earliest and latest event timestamp (nil when no event has one)
+type IndexBlock struct {
+	Offset    int64      `json:"offset"`
+	Length    int64      `json:"length"`
+	FirstLine int        `json:"first_line"`
+	Events    int        `json:"events"`
+	Errors    int        `json:"errors,omitempty"`
+	MinTime   *time.Time `json:"min_time,omitempty"`
+	MaxTime   *time.Time `json:"max_time,omitempty"`
+}
+
+// IndexFilter is the part of a query an index can answer. Blocks are
+// candidates when they can hold an event that matches every set constraint.
+//
+// Fields:
+//   - From: Events on or after (zero: unbounded)
+//   - Until: Events before (zero: unbounded)
+//   - User: db_user, case-insensitive (empty: any)
+//   - Categories: Any of these sensitivity categories, case-insensitive (empty: any)
+type IndexFilter struct {
+	From       time.Time
+	Until      time.Time
+	User       string
+	Categories []string
+}
+
+// IsZero reports whether the filter has no constraint, so every block is a candidate
+func (f IndexFilter) IsZero() bool {
+	return f.From.IsZero() && f.Until.IsZero() && f.User == "" && len(f.Categories) == 0
+}
+
+// IndexPath returns the path of a file's index
+func IndexPath(path string) string {
+	return path + IndexSuffix
+}
+
+// BuildIndex reads an NDJSON file and indexes it in blocks of blockEvents lines.
+// Lines are split and parsed exactly as the query reader does, so the blocks
+// read back to the same events.
+//
+// Args:
+//   - path: NDJSON file
+//   - blockEvents: Lines per block (DefaultIndexBlockEvents when <= 0)
+//
+// Returns:
+//   - The index
+//   - Error if the file can't be read
+func BuildIndex(path string, blockEvents int) (*Index, error) {
+	if blockEvents <= 0 {
+		blockEvents = DefaultIndexBlockEvents
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+
+	ix := &Index{
+		Version:     indexVersion,
+		Size:        info.Size(),
+		ModTime:     info.ModTime().UTC(),
+		BlockEvents: blockEvents,
+		Users:       make(map[string][]int),
+		Categories:  make(map[string][]int),
+	}
+
+	// Track the byte offset of every line through the split function
+	var offset int64
+	scanner := bufio.NewScanner(f)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		offset += int64(advance)
+		return advance, token, err
+	})
+
+	var block *IndexBlock
+	lineNumber := 0
+	var lineStart int64
+	for scanner.Scan() {
+		lineNumber++
+		start := lineStart
+		lineStart = offset
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		if block == nil || block.Events+block.Errors == blockEvents {
+			ix.Blocks = append(ix.Blocks, IndexBlock{Offset: start, FirstLine: lineNumber})
+			block = &ix.Blocks[len(ix.Blocks)-1]
+		}
+		block.Length = offset - block.Offset
+
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			block.Errors++
+			ix.Errors++
+			continue
+		}
+		block.Events++
+		ix.Events++
+		ix.addEvent(len(ix.Blocks)-1, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s line %d: %w", path, lineNumber+1, err)
+	}
+
+	logger.L().Debugw("Indexed file",
+		"file", path,
+		"events", ix.Events,
+		"blocks", len(ix.Blocks),
+		"users", len(ix.Users),
+		"categories", len(ix.Categories))
+	return ix, nil
+}
+
+// addEvent records an event of block n in the time range and postings
+func (ix *Index) addEvent(n int, e Event) {
+	block := &ix.Blocks[n]
+	if timestamp, err := ParseTimestamp(e["timestamp"]); err == nil {
+		timestamp = timestamp.UTC()
+		if block.MinTime == nil || timestamp.Before(*block.MinTime) {
+			t := timestamp
+			block.MinTime = &t
+		}
+		if block.MaxTime == nil || timestamp.After(*block.MaxTime) {
+			t := timestamp
+			block.MaxTime = &t
+		}
+	}
+	if user, ok := GetString(e, "db_user"); ok {
+		addPosting(ix.Users, strings.ToLower(user), n)
+	}
+	if sensitivity, ok := GetStringSlice(e, "sensitivity"); ok {
+		for _, entry := range sensitivity {
+			category, _ := ParseSensitivityEntry(entry)
+			addPosting(ix.Categories, strings.ToLower(category), n)
+		}
+	}
+}
+
+// addPosting adds block n to a postings list; blocks are added in order
+func addPosting(postings map[string][]int, key string, n int) {
+	blocks := postings[key]
+	if len(blocks) == 0 || blocks[len(blocks)-1] != n {
+		postings[key] = append(blocks, n)
+	}
+}
+
+// WriteIndex writes an index next to the file it indexes (see IndexPath)
+func WriteIndex(ix *Index, path string) error {
+	data, err := json.Marshal(ix)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+	if err := os.WriteFile(IndexPath(path), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write index %s: %w", IndexPath(path), err)
+	}
+	logger.L().Debugw("Index written", "path", IndexPath(path), "blocks", len(ix.Blocks))
+	return nil
+}
+
+// LoadIndex loads the index of a file and checks that it is current.
+//
+// Returns:
+//   - The index
+//   - os.ErrNotExist (wrapped) when the file has no index
+//   - Error if the index can't be read or no longer matches the file
+func LoadIndex(path string) (*Index, error) {
+	data, err := os.ReadFile(IndexPath(path))
+	if err != nil {
+		return nil, err
+	}
+	var ix Index
+	if err := json.Unmarshal(data, &ix); err != nil {
+		return nil, fmt.Errorf("failed to parse index %s: %w", IndexPath(path), err)
+	}
+	if ix.Version != indexVersion {
+		return nil, fmt.Errorf("index %s has version %d, expected %d", IndexPath(path), ix.Version, indexVersion)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+	if info.Size() != ix.Size || !info.ModTime().Equal(ix.ModTime) {
+		return nil, fmt.Errorf("index %s is stale: %s changed since it was indexed", IndexPath(path), path)
+	}
+	return &ix, nil
+}
+
+// Candidates returns the numbers of the blocks that can hold events matching
+// the filter, in file order
+func (ix *Index) Candidates(f IndexFilter) []int {
+	var candidates []int
+	for n, block := range ix.Blocks {
+		if f.From.IsZero() && f.Until.IsZero() {
+			candidates = append(candidates, n)
+			continue
+		}
+		// Events without a timestamp never match a time filter
+		if block.MinTime == nil {
+			continue
+		}
+		if !f.From.IsZero() && block.MaxTime.Before(f.From) {
+			continue
+		}
+		if !f.Until.IsZero() && !block.MinTime.Before(f.Until) {
+			continue
+		}
+		candidates = append(candidates, n)
+	}
+
+	if f.User != "" {
+		candidates = intersectBlocks(candidates, ix.Users[strings.ToLower(f.User)])
+	}
+	if len(f.Categories) > 0 {
+		var union []int
+		for _, category := range f.Categories {
+			union = append(union, ix.Categories[strings.ToLower(category)]...)
+		}
+		sort.Ints(union)
+		candidates = intersectBlocks(candidates, union)
+	}
+	return candidates
+}
+
+// intersectBlocks returns the blocks of a that are also in b; both are sorted
+func intersectBlocks(a, b []int) []int {
+	var out []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// indexFilter extracts the constraints an index can answer from the query options.
+// now must not be later than the time the --last filter was built with, so
+// the index never rules out a block the filter would match.
+func indexFilter(opts QueryOptions, now time.Time) IndexFilter {
+	f := IndexFilter{User: opts.User, Categories: opts.Sensitivity}
+	if opts.LastDuration > 0 {
+		f.From = now.Add(-opts.LastDuration) // --last takes precedence over --since
+	} else {
+		f.From = opts.Since
+	}
+	if !opts.From.IsZero() && opts.From.After(f.From) {
+		f.From = opts.From
+	}
+	f.Until = opts.Until
+	return f
+}
+
+// ReadEventsIndexed reads events like ReadEvents, but reads only the candidate
+// blocks of files that have a current index. Files without an index, with a
+// stale one, and stdin are scanned in full. The filters of the query still
+// apply to every event read; the index only skips blocks that can't match.
+// The events and malformed lines of skipped blocks are reported as results
+// with SkippedEvents / SkippedErrors, in file order, so that counts of the
+// input match a full scan.
+func ReadEventsIndexed(files []string, f IndexFilter) <-chan EventResult {
+	if len(files) == 0 || f.IsZero() {
+		return ReadEvents(files)
+	}
+
+	ch := make(chan EventResult, 100)
+	go func() {
+		defer close(ch)
+		for _, file := range files {
+			ix, err := LoadIndex(file)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					logger.L().Debugw("No index, scanning file", "file", file)
+				} else {
+					logger.L().Warnw("Not using index, scanning file (rebuild it with auditr index build)", "file", file, "error", err)
+				}
+				readFile(file, ch)
+				continue
+			}
+			readBlocks(file, ix, ix.Candidates(f), ch)
+		}
+	}()
+	return ch
+}
+
+// readBlocks reads the given blocks of an indexed file (in ascending order).
+// The blocks skipped between them are reported in file order, each run just
+// before the next block read, so a reader that stops early (--limit) has
+// counted exactly the lines a full scan would have passed.
+func readBlocks(file string, ix *Index, blocks []int, ch chan<- EventResult) {
+	logger.L().Debugw("Reading file through index", "file", file, "blocks", len(blocks), "total_blocks", len(ix.Blocks))
+	skip := func(from, to int) {
+		var skipped EventResult
+		for _, block := range ix.Blocks[from:to] {
+			skipped.SkippedEvents += block.Events
+			skipped.SkippedErrors += block.Errors
+		}
+		if skipped.SkippedEvents > 0 || skipped.SkippedErrors > 0 {
+			ch <- skipped
+		}
+	}
+	if len(blocks) == 0 {
+		skip(0, len(ix.Blocks))
+		return
+	}
+	fh, err := os.Open(file)
+	if err != nil {
+		ch <- EventResult{Err: fmt.Errorf("failed to open file %s: %w", file, err)}
+		return
+	}
+	defer fh.Close()
+
+	next := 0
+	for _, n := range blocks {
+		skip(next, n)
+		block := ix.Blocks[n]
+		section := io.NewSectionReader(fh, block.Offset, block.Length)
+		readLines(section, file, block.FirstLine-1, ch)
+		next = n + 1
+	}
+	skip(next, len(ix.Blocks))
+}
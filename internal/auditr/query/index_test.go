@@ -0,0 +1,284 @@
+package query
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeIndexedFile writes NDJSON lines to a temp file and indexes it in blocks of two lines
+func writeIndexedFile(t *testing.T, lines []string) (string, *Index) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ix, err := BuildIndex(path, 2)
+	if err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	if err := WriteIndex(ix, path); err != nil {
+		t.Fatalf("WriteIndex() error = %v", err)
+	}
+	return path, ix
+}
+
+func indexTestLines() []string {
+	return []string{
+		`{"event_id":"e1","timestamp":"2025-09-01T10:00:00Z","db_user":"alice","sensitivity":["PII:email"]}`,
+		`{"event_id":"e2","timestamp":"2025-09-01T11:00:00Z","db_user":"bob"}`,
+		``,
+		`{"event_id":"e3","timestamp":"2025-09-02T10:00:00Z","db_user":"Bob","sensitivity":["PHI:diagnosis"]}`,
+		`not json`,
+		`{"event_id":"e4","timestamp":"2025-09-03T10:00:00Z","db_user":"carol","sensitivity":["Financial:iban"]}`,
+		`{"event_id":"e5","db_user":"alice"}`,
+	}
+}
+
+func TestBuildIndex(t *testing.T) {
+	path, ix := writeIndexedFile(t, indexTestLines())
+
+	if ix.Events != 5 || ix.Errors != 1 || len(ix.Blocks) != 3 {
+		t.Fatalf("index has %d events, %d errors, %d blocks; want 5, 1, 3", ix.Events, ix.Errors, len(ix.Blocks))
+	}
+	wantUsers := map[string][]int{"alice": {0, 2}, "bob": {0, 1}, "carol": {2}}
+	if !reflect.DeepEqual(ix.Users, wantUsers) {
+		t.Errorf("Users = %v, want %v", ix.Users, wantUsers)
+	}
+	wantCategories := map[string][]int{"pii": {0}, "phi": {1}, "financial": {2}}
+	if !reflect.DeepEqual(ix.Categories, wantCategories) {
+		t.Errorf("Categories = %v, want %v", ix.Categories, wantCategories)
+	}
+
+	// Blocks start at their first line and cover their lines' bytes
+	data, _ := os.ReadFile(path)
+	for i, want := range []string{"e3", "e4"} {
+		block := ix.Blocks[i+1]
+		text := string(data[block.Offset : block.Offset+block.Length])
+		if !strings.Contains(strings.SplitN(text, "\n", 2)[0], want) {
+			t.Errorf("block %d starts with %q, want event %s", i+1, text, want)
+		}
+	}
+	if ix.Blocks[1].FirstLine != 4 || ix.Blocks[1].Errors != 1 {
+		t.Errorf("block 1 = %+v, want first line 4 with 1 error", ix.Blocks[1])
+	}
+	// e5 has no timestamp: the block's time range is e4's alone
+	if !ix.Blocks[2].MinTime.Equal(*ix.Blocks[2].MaxTime) {
+		t.Errorf("block 2 time range = %v - %v, want a single time", ix.Blocks[2].MinTime, ix.Blocks[2].MaxTime)
+	}
+
+	loaded, err := LoadIndex(path)
+	if err != nil || len(loaded.Blocks) != 3 {
+		t.Fatalf("LoadIndex() = %v, %v", loaded, err)
+	}
+}
+
+func TestIndex_Candidates(t *testing.T) {
+	_, ix := writeIndexedFile(t, indexTestLines())
+	day := func(d int) time.Time { return time.Date(2025, 9, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		filter IndexFilter
+		want   []int
+	}{
+		{"no constraint", IndexFilter{}, []int{0, 1, 2}},
+		{"user is case-insensitive", IndexFilter{User: "BOB"}, []int{0, 1}},
+		{"unknown user", IndexFilter{User: "dave"}, nil},
+		{"any of the categories", IndexFilter{Categories: []string{"financial", "PII"}}, []int{0, 2}},
+		{"from", IndexFilter{From: day(2)}, []int{1, 2}},
+		{"until is exclusive", IndexFilter{Until: day(2).Add(10 * time.Hour)}, []int{0}},
+		{"window and user", IndexFilter{From: day(1), Until: day(3), User: "alice"}, []int{0}},
+		{"window and category", IndexFilter{From: day(2), Categories: []string{"PII"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ix.Candidates(tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Candidates() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadEventsIndexed(t *testing.T) {
+	path, _ := writeIndexedFile(t, indexTestLines())
+
+	var skippedEvents, skippedErrors int
+	read := func(f IndexFilter) (ids []string, errs []string) {
+		skippedEvents, skippedErrors = 0, 0
+		for result := range ReadEventsIndexed([]string{path}, f) {
+			if result.SkippedEvents > 0 || result.SkippedErrors > 0 {
+				skippedEvents += result.SkippedEvents
+				skippedErrors += result.SkippedErrors
+				continue
+			}
+			if result.Err != nil {
+				errs = append(errs, result.Err.Error())
+				continue
+			}
+			ids = append(ids, result.Event["event_id"].(string))
+		}
+		return ids, errs
+	}
+
+	// Only the blocks of bob are read; the malformed line reports its file line
+	ids, errs := read(IndexFilter{User: "bob"})
+	if strings.Join(ids, ",") != "e1,e2,e3" || len(errs) != 1 || !strings.Contains(errs[0], "line 5") {
+		t.Errorf("indexed read = %v, %v; want e1,e2,e3 and a line 5 error", ids, errs)
+	}
+	// The skipped block is reported by its counts
+	if skippedEvents != 2 || skippedErrors != 0 {
+		t.Errorf("skipped %d events, %d errors; want 2, 0", skippedEvents, skippedErrors)
+	}
+	if ids, _ := read(IndexFilter{User: "carol"}); len(ids) != 2 || skippedEvents != 3 || skippedErrors != 1 {
+		t.Errorf("read of carol = %v, skipped %d events, %d errors; want 2 events, 3 and 1 skipped", ids, skippedEvents, skippedErrors)
+	}
+
+	// No constraint reads everything
+	if ids, _ := read(IndexFilter{}); len(ids) != 5 {
+		t.Errorf("unfiltered read = %v, want 5 events", ids)
+	}
+
+	// A changed file is scanned in full
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"event_id":"e6","db_user":"dave"}` + "\n")
+	f.Close()
+	if _, err := LoadIndex(path); err == nil || !strings.Contains(err.Error(), "stale") {
+		t.Errorf("LoadIndex() of a changed file error = %v, want stale", err)
+	}
+	if ids, _ := read(IndexFilter{User: "dave"}); len(ids) != 6 {
+		t.Errorf("read of a stale index = %v, want all 6 events", ids)
+	}
+}
+
+func TestRunQuery_Indexed(t *testing.T) {
+	path, _ := writeIndexedFile(t, indexTestLines())
+	dir := filepath.Dir(path)
+
+	for _, opts := range []QueryOptions{
+		{User: "bob"},
+		{Sensitivity: []string{"PHI", "financial"}},
+		{From: time.Date(2025, 9, 2, 0, 0, 0, 0, time.UTC), User: "carol"},
+		{LastDuration: 24 * time.Hour},
+	} {
+		opts.InputFiles = []string{path}
+		opts.OutputFile = filepath.Join(dir, "indexed.ndjson")
+		if err := RunQuery(opts); err != nil {
+			t.Fatalf("RunQuery(%+v) error = %v", opts, err)
+		}
+		opts.NoIndex = true
+		opts.OutputFile = filepath.Join(dir, "scanned.ndjson")
+		if err := RunQuery(opts); err != nil {
+			t.Fatalf("RunQuery(%+v) error = %v", opts, err)
+		}
+		indexed, _ := os.ReadFile(filepath.Join(dir, "indexed.ndjson"))
+		scanned, _ := os.ReadFile(filepath.Join(dir, "scanned.ndjson"))
+		if string(indexed) != string(scanned) {
+			t.Errorf("RunQuery(%+v) indexed output\n%s\ndiffers from scan\n%s", opts, indexed, scanned)
+		}
+	}
+}
+
+func TestRunQuery_IndexedSummary(t *testing.T) {
+	path, _ := writeIndexedFile(t, indexTestLines())
+
+	// summary runs the query and returns what it printed to stderr
+	summary := func(opts QueryOptions) string {
+		t.Helper()
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stderr := os.Stderr
+		os.Stderr = w
+		err = RunQuery(opts)
+		os.Stderr = stderr
+		w.Close()
+		out, _ := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("RunQuery(%+v) error = %v", opts, err)
+		}
+		return string(out)
+	}
+
+	for _, opts := range []QueryOptions{
+		{User: "bob"},
+		{User: "carol"},
+		{User: "dave"},
+	} {
+		opts.InputFiles = []string{path}
+		opts.Summary = true
+		indexed := summary(opts)
+		opts.NoIndex = true
+		scanned := summary(opts)
+		if indexed != scanned {
+			t.Errorf("RunQuery(%+v) indexed summary\n%s\ndiffers from scan\n%s", opts, indexed, scanned)
+		}
+		if !strings.Contains(indexed, "Total events processed: 5") {
+			t.Errorf("RunQuery(%+v) summary\n%s\nwant 5 events processed", opts, indexed)
+		}
+	}
+
+	// With --limit the query stops early; skipped blocks after that point are
+	// not counted, as a scan never reaches them
+	for _, tt := range []struct {
+		user      string
+		processed int
+	}{
+		{"alice", 1},
+		{"bob", 2},
+		{"carol", 4},
+	} {
+		opts := QueryOptions{InputFiles: []string{path}, User: tt.user, Limit: 1, Summary: true}
+		indexed := summary(opts)
+		opts.NoIndex = true
+		scanned := summary(opts)
+		if indexed != scanned {
+			t.Errorf("RunQuery(%+v) indexed summary\n%s\ndiffers from scan\n%s", opts, indexed, scanned)
+		}
+		if want := fmt.Sprintf("Total events processed: %d\n", tt.processed); !strings.Contains(indexed, want) {
+			t.Errorf("RunQuery(%+v) summary\n%s\nwant %d events processed", opts, indexed, tt.processed)
+		}
+	}
+}
+
+func TestReadEventsIndexed_LimitCounts(t *testing.T) {
+	path, _ := writeIndexedFile(t, indexTestLines())
+
+	// count consumes results as RunQuery does with --limit 1 and a user filter
+	count := func(input <-chan EventResult, user string) (events, errors int) {
+		for result := range input {
+			events += result.SkippedEvents
+			errors += result.SkippedErrors
+			if result.SkippedEvents > 0 || result.SkippedErrors > 0 {
+				continue
+			}
+			if result.Err != nil {
+				errors++
+				continue
+			}
+			events++
+			if u, _ := GetString(result.Event, "db_user"); strings.EqualFold(u, user) {
+				break
+			}
+		}
+		return events, errors
+	}
+
+	for _, user := range []string{"alice", "bob", "carol", "dave"} {
+		events, errors := count(ReadEventsIndexed([]string{path}, IndexFilter{User: user}), user)
+		wantEvents, wantErrors := count(ReadEvents([]string{path}), user)
+		if events != wantEvents || errors != wantErrors {
+			t.Errorf("indexed read for %s counted %d events, %d errors; scan counted %d, %d", user, events, errors, wantEvents, wantErrors)
+		}
+	}
+}
@@ -36,24 +36,29 @@ func ReadEvents(files []string) <-chan EventResult {
 		// Read from each file sequentially
 		// This approach is simple and handles file errors gracefully
 		for _, file := range files {
-			f, err := os.Open(file)
-			if err != nil {
-				// Send error on channel but continue with other files
-				ch <- EventResult{
-					Event: nil,
-					Err:   fmt.Errorf("failed to open file %s: %w", file, err),
-				}
-				continue
-			}
-
-			readFromReader(f, file, ch)
-			f.Close()
+			readFile(file, ch)
 		}
 	}()
 
 	return ch
 }
 
+// readFile reads every event of a file; an open error is sent on the channel
+func readFile(file string, ch chan<- EventResult) {
+	f, err := os.Open(file)
+	if err != nil {
+		// Send error on channel but continue with other files
+		ch <- EventResult{
+			Event: nil,
+			Err:   fmt.Errorf("failed to open file %s: %w", file, err),
+		}
+		return
+	}
+	defer f.Close()
+
+	readFromReader(f, file, ch)
+}
+
 // readFromReader reads NDJSON lines from a reader and sends events on the channel.
 // This is the core parsing logic that handles individual files or stdin.
 //
@@ -69,8 +74,14 @@ func ReadEvents(files []string) <-chan EventResult {
 // - Scanner errors (I/O issues) are sent as EventResult with Err set
 // - Processing continues even after errors (resilient design)
 func readFromReader(r io.Reader, source string, ch chan<- EventResult) {
+	readLines(r, source, 0, ch)
+}
+
+// readLines is readFromReader for input starting after line startLine of the
+// source, such as a block of an indexed file, so errors report file line numbers
+func readLines(r io.Reader, source string, startLine int, ch chan<- EventResult) {
 	scanner := bufio.NewScanner(r)
-	lineNumber := 0
+	lineNumber := startLine
 
 	for scanner.Scan() {
 		lineNumber++
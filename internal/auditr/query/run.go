@@ -1,15 +1,23 @@
 package query
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+	"github.com/vaibhaw-/AuditR/internal/auditr/output"
+	"github.com/vaibhaw-/AuditR/internal/auditr/verify"
 )
 
 // RunQuery is the main orchestration function that processes events according to the query options.
 // This function coordinates all components of the query system:
 // - Building filters from CLI options
-// - Opening input/output streams
+// - Opening input/output streams (indexed files are read through their index when filters allow, see ReadEventsIndexed)
 // - Processing events through filters
 // - Collecting statistics
 // - Writing results and summaries
@@ -22,26 +30,86 @@ import (
 // - I/O errors are returned as wrapped errors
 // - Filter errors are logged but don't stop processing
 func RunQuery(opts QueryOptions) error {
+	// Taken before the filters are built, so the index never skips a block
+	// that --last would match
+	now := time.Now()
+
 	// Build filters from CLI options
 	// This creates the filter chain based on user-specified criteria
-	filters := buildFilters(opts)
+	filters, err := buildFilters(opts)
+	if err != nil {
+		return err
+	}
+
+	// Verify the input chain up front so a missing checkpoint or stdin input fails
+	// before any output is written
+	var attestation *verify.AttestationSet
+	if opts.Attest || opts.CheckpointPath != "" {
+		var err error
+		attestation, err = verify.Attest(opts.InputFiles, opts.CheckpointPath, opts.PublicKeyPath, opts.TSACertPath)
+		if err != nil {
+			return fmt.Errorf("failed to verify input: %w", err)
+		}
+	}
 
 	// Open output writer (stdout or file)
-	output, err := openOutput(opts.OutputFile)
+	out, err := openOutput(opts.OutputFile)
 	if err != nil {
 		return fmt.Errorf("failed to open output: %w", err)
 	}
 	// Ensure output is closed if it's a file
-	if closer, ok := output.(io.Closer); ok {
+	if closer, ok := out.(io.Closer); ok {
 		defer closer.Close()
 	}
 
+	// Hash the output so the attestation is bound to exactly what was written
+	outputHash := sha256.New()
+	if attestation != nil {
+		out = io.MultiWriter(out, outputHash)
+	}
+
 	// Initialize statistics tracking
 	stats := NewStats()
+	stats.Location = opts.Location
+
+	// In group-by mode matched events are aggregated instead of written
+	var groups *Aggregator
+	if len(opts.GroupBy) > 0 {
+		groups = NewAggregator(GroupOptions{
+			Fields:   opts.GroupBy,
+			Distinct: opts.Distinct,
+			Bucket:   opts.Bucket,
+			Top:      opts.Top,
+			Location: opts.Location,
+		})
+	}
+
+	// Matched events are written in the requested format
+	var events output.Writer
+	if groups == nil && (!opts.Summary || opts.OutputFile != "") {
+		if events, err = output.NewWriter(out, output.Options{Format: opts.Format, Fields: opts.Fields}); err != nil {
+			return err
+		}
+	}
+
+	// Read only the index blocks that can match the filters. An attestation
+	// vouches for every input event, so it is never answered from an index.
+	var input <-chan EventResult
+	if opts.NoIndex || attestation != nil {
+		input = ReadEvents(opts.InputFiles)
+	} else {
+		input = ReadEventsIndexed(opts.InputFiles, indexFilter(opts, now))
+	}
 
 	// Process events from input stream
 	// This is the main processing loop that handles each event
-	for result := range ReadEvents(opts.InputFiles) {
+	for result := range input {
+		// Count the lines of index blocks skipped unread, as a full scan would
+		if result.SkippedEvents > 0 || result.SkippedErrors > 0 {
+			stats.AddSkipped(result.SkippedEvents, result.SkippedErrors)
+			continue
+		}
+
 		// Handle parsing errors gracefully
 		if result.Err != nil {
 			stats.IncrementError() // Count error events
@@ -57,9 +125,11 @@ func RunQuery(opts QueryOptions) error {
 
 			// Only write events to output if not in summary-only mode
 			// When --summary is specified without --output, only print summary to stderr
-			if !opts.Summary || opts.OutputFile != "" {
+			if groups != nil {
+				groups.Add(result.Event)
+			} else if events != nil {
 				// Write matching event to output
-				if err := WriteEventNDJSON(output, result.Event); err != nil {
+				if err := events.Write(result.Event); err != nil {
 					return fmt.Errorf("failed to write event: %w", err)
 				}
 			}
@@ -71,11 +141,68 @@ func RunQuery(opts QueryOptions) error {
 		}
 	}
 
+	// Write the groups once every event is counted
+	// Tabular formats are only written out once every event is seen
+	written := stats.MatchedEvents
+	if events != nil {
+		if err := events.Close(); err != nil {
+			return fmt.Errorf("failed to write events: %w", err)
+		}
+	}
+	if groups != nil {
+		if written, err = groups.Write(out, opts.Format); err != nil {
+			return fmt.Errorf("failed to write groups: %w", err)
+		}
+	}
+
 	// Print summary statistics if requested
 	if opts.Summary {
 		stats.PrintSummary(os.Stderr) // Summary goes to stderr, events to stdout
 	}
 
+	if attestation != nil {
+		attestation.Output = opts.OutputFile
+		if attestation.Output == "" {
+			attestation.Output = "-"
+		}
+		if groups != nil || !opts.Summary || opts.OutputFile != "" {
+			attestation.OutputEvents = written
+		}
+		attestation.OutputSHA256 = hex.EncodeToString(outputHash.Sum(nil))
+		if err := writeAttestation(attestation, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeAttestation writes the attestation as indented JSON. The destination is
+// --attestation-output when set, otherwise a sidecar next to --output
+// (<output>.attestation.json), otherwise stderr.
+func writeAttestation(set *verify.AttestationSet, opts QueryOptions) error {
+	if !set.Verified {
+		logger.L().Warnw("Input chain or checkpoint did not verify; see attestation", "inputs", opts.InputFiles)
+	}
+
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal attestation: %w", err)
+	}
+	data = append(data, '\n')
+
+	path := opts.AttestationFile
+	if path == "" && opts.OutputFile != "" {
+		path = opts.OutputFile + ".attestation.json"
+	}
+	if path == "" {
+		_, err = os.Stderr.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write attestation %s: %w", path, err)
+	}
+	logger.L().Debugw("Attestation written", "path", path, "verified", set.Verified)
 	return nil
 }
 
@@ -84,16 +211,19 @@ func RunQuery(opts QueryOptions) error {
 // Only non-empty options are converted to filters, ensuring efficient processing.
 //
 // Filter order:
-// 1. Sensitivity categories (PII, PHI, Financial)
+// 1. Sensitivity categories (PII, PHI, Financial, or custom) and regulation tags
 // 2. User and IP filters
 // 3. Query type filters
 // 4. Bulk operation filter
-// 5. Sensitive field filters
-// 6. Time-based filters
-// 7. Error exclusion filter
+// 5. Risk level filters
+// 6. Sensitive field filters
+// 7. Time-based filters (--since/--last, then closed windows and business hours)
+// 8. Error exclusion filter
+// 9. --where expression
 //
 // All filters are combined using AND logic in the main processing loop.
-func buildFilters(opts QueryOptions) []EventFilter {
+// Returns an error if the --where expression doesn't compile.
+func buildFilters(opts QueryOptions) ([]EventFilter, error) {
 	var filters []EventFilter
 
 	// Sensitivity filter - match by sensitivity categories
@@ -101,6 +231,11 @@ func buildFilters(opts QueryOptions) []EventFilter {
 		filters = append(filters, FilterBySensitivity(opts.Sensitivity))
 	}
 
+	// Regulation filter - match by regulation tags from category metadata
+	if len(opts.Regulations) > 0 {
+		filters = append(filters, FilterByRegulation(opts.Regulations))
+	}
+
 	// User filter - match by database user
 	if opts.User != "" {
 		filters = append(filters, FilterByUser(opts.User))
@@ -126,6 +261,16 @@ func buildFilters(opts QueryOptions) []EventFilter {
 		filters = append(filters, FilterByBulkType(opts.BulkType))
 	}
 
+	// Risk filter - match by risk levels (low, medium, high, critical)
+	if len(opts.RiskLevels) > 0 {
+		filters = append(filters, FilterByRisk(opts.RiskLevels))
+	}
+
+	// Minimum risk filter - match events at or above a risk level
+	if opts.MinRisk != "" {
+		filters = append(filters, FilterByMinRisk(opts.MinRisk))
+	}
+
 	// Sensitive fields filter - match by field names in sensitivity entries
 	if len(opts.FilterFields) > 0 {
 		filters = append(filters, FilterBySensitiveFields(opts.FilterFields))
@@ -136,12 +281,43 @@ func buildFilters(opts QueryOptions) []EventFilter {
 		filters = append(filters, FilterByTime(opts.Since, opts.LastDuration))
 	}
 
+	// Time window filter - match events in [From, Until)
+	if !opts.From.IsZero() || !opts.Until.IsZero() {
+		filters = append(filters, FilterByTimeRange(opts.From, opts.Until))
+	}
+
+	// Business hours filters - match events inside or outside the calendar's business hours
+	if opts.BusinessHours || opts.OffHours {
+		cal := opts.Calendar
+		if cal == nil {
+			var err error
+			if cal, err = NewCalendar(nil, opts.Location); err != nil {
+				return nil, err
+			}
+		}
+		if opts.BusinessHours {
+			filters = append(filters, FilterByBusinessHours(cal, true))
+		}
+		if opts.OffHours {
+			filters = append(filters, FilterByBusinessHours(cal, false))
+		}
+	}
+
 	// Exclude errors filter - filter out ERROR events
 	if opts.ExcludeErrors {
 		filters = append(filters, FilterExcludeErrors())
 	}
 
-	return filters
+	// Where filter - match by an expression over any field
+	if opts.Where != "" {
+		where, err := compileWhere(opts.Where, time.Now(), opts.Location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --where expression: %w", err)
+		}
+		filters = append(filters, where)
+	}
+
+	return filters, nil
 }
 
 // openOutput opens the output file or returns stdout.
@@ -1,11 +1,17 @@
 package query
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/verify"
 )
 
 func TestRunQueryIntegration(t *testing.T) {
@@ -223,3 +229,86 @@ func TestRunQueryWithMalformedJSON(t *testing.T) {
 	// Should have processed 2 valid events and 1 error
 	// The exact error counting would need to be verified through the stats
 }
+
+func TestRunQueryWithAttestation(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Hash-chain a few events the way the verify phase does
+	events := strings.Join([]string{
+		`{"event_id":"h1","timestamp":"2025-01-01T10:00:00Z","db_user":"alice","query_type":"SELECT","sensitivity":["PHI:diagnosis"]}`,
+		`{"event_id":"h2","timestamp":"2025-01-01T11:00:00Z","db_user":"bob","query_type":"SELECT","sensitivity":["PII:email"]}`,
+		`{"event_id":"h3","timestamp":"2025-01-01T12:00:00Z","db_user":"alice","query_type":"UPDATE","sensitivity":["PHI:notes"]}`,
+	}, "\n") + "\n"
+	var hashed bytes.Buffer
+	if _, _, err := verify.ComputeChain(strings.NewReader(events), &hashed, nil); err != nil {
+		t.Fatalf("ComputeChain() error = %v", err)
+	}
+	inputFile := filepath.Join(tempDir, "hashed.jsonl")
+	if err := os.WriteFile(inputFile, hashed.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write hashed input: %v", err)
+	}
+
+	outputFile := filepath.Join(tempDir, "phi.jsonl")
+	err := RunQuery(QueryOptions{
+		InputFiles:  []string{inputFile},
+		OutputFile:  outputFile,
+		Sensitivity: []string{"PHI"},
+		Attest:      true,
+	})
+	if err != nil {
+		t.Fatalf("RunQuery() error = %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile + ".attestation.json")
+	if err != nil {
+		t.Fatalf("attestation sidecar not written: %v", err)
+	}
+	var set verify.AttestationSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatalf("invalid attestation JSON: %v", err)
+	}
+	if !set.Verified || len(set.Attestations) != 1 {
+		t.Fatalf("attestation = %+v, want one verified input", set)
+	}
+	a := set.Attestations[0]
+	if a.FirstChainIndex != 1 || a.LastChainIndex != 3 || a.Events != 3 || !a.ChainIntact {
+		t.Errorf("attestation chain = %+v, want intact indices 1-3", a)
+	}
+	if set.OutputEvents != 2 {
+		t.Errorf("OutputEvents = %d, want 2", set.OutputEvents)
+	}
+	output, _ := os.ReadFile(outputFile)
+	sum := sha256.Sum256(output)
+	if set.OutputSHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("OutputSHA256 = %s, want hash of output file", set.OutputSHA256)
+	}
+
+	// A tampered input still produces output, but the attestation says so
+	tampered := strings.Replace(hashed.String(), `"db_user":"bob"`, `"db_user":"eve"`, 1)
+	if err := os.WriteFile(inputFile, []byte(tampered), 0644); err != nil {
+		t.Fatalf("Failed to write tampered input: %v", err)
+	}
+	attestationFile := filepath.Join(tempDir, "attestation.json")
+	err = RunQuery(QueryOptions{
+		InputFiles:      []string{inputFile},
+		OutputFile:      outputFile,
+		Attest:          true,
+		AttestationFile: attestationFile,
+	})
+	if err != nil {
+		t.Fatalf("RunQuery() error = %v", err)
+	}
+	data, _ = os.ReadFile(attestationFile)
+	set = verify.AttestationSet{}
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatalf("invalid attestation JSON: %v", err)
+	}
+	if set.Verified || len(set.Attestations[0].TamperedIndices) != 1 || set.Attestations[0].TamperedIndices[0] != 2 {
+		t.Errorf("attestation = %+v, want event 2 flagged as tampered", set.Attestations[0])
+	}
+
+	// Stdin can't be re-read for verification
+	if err := RunQuery(QueryOptions{Attest: true, OutputFile: outputFile}); err == nil {
+		t.Error("expected error when attesting stdin input")
+	}
+}
@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strings"
+	"text/tabwriter"
 	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/enrich"
 )
 
 // Stats tracks comprehensive statistics about processed events.
@@ -15,30 +19,40 @@ import (
 // - InputEvents: Total number of events processed (including errors)
 // - MatchedEvents: Number of events that passed all filters
 // - ErrorEvents: Number of events that failed to parse or had I/O errors
-// - BySensitivity: Breakdown by sensitivity categories (PII, PHI, Financial)
+// - BySensitivity: Breakdown by sensitivity categories (PII, PHI, Financial, or custom)
+// - ByRegulation: Breakdown by regulation tags from category metadata
 // - ByQueryType: Breakdown by query types (SELECT, INSERT, UPDATE, DELETE, etc.)
 // - ByRiskLevel: Breakdown by risk levels (low, medium, high, critical)
+// - RiskByUser: Risk levels cross-tabulated against database users
+// - RiskByQueryType: Risk levels cross-tabulated against query types
 // - BulkCount: Number of bulk operations found
 // - FirstTimestamp/LastTimestamp: Time range of processed events
 type Stats struct {
-	InputEvents    int            // Total events processed (including errors)
-	MatchedEvents  int            // Events that passed all filters
-	ErrorEvents    int            // Events that failed to parse or had I/O errors
-	BySensitivity  map[string]int // Count by sensitivity category (PII, PHI, Financial)
-	ByQueryType    map[string]int // Count by query type (SELECT, INSERT, UPDATE, etc.)
-	ByRiskLevel    map[string]int // Count by risk level (low, medium, high, critical)
-	BulkCount      int            // Number of bulk operations
-	FirstTimestamp *time.Time     // Earliest event timestamp
-	LastTimestamp  *time.Time     // Latest event timestamp
+	InputEvents     int                       // Total events processed (including errors)
+	MatchedEvents   int                       // Events that passed all filters
+	ErrorEvents     int                       // Events that failed to parse or had I/O errors
+	BySensitivity   map[string]int            // Count by sensitivity category (PII, PHI, Financial, or custom)
+	ByRegulation    map[string]int            // Count by regulation tag (HIPAA, PCI-DSS, etc.)
+	ByQueryType     map[string]int            // Count by query type (SELECT, INSERT, UPDATE, etc.)
+	ByRiskLevel     map[string]int            // Count by risk level (low, medium, high, critical)
+	RiskByUser      map[string]map[string]int // Count by db_user, then risk level
+	RiskByQueryType map[string]map[string]int // Count by query type, then risk level
+	BulkCount       int                       // Number of bulk operations
+	FirstTimestamp  *time.Time                // Earliest event timestamp
+	LastTimestamp   *time.Time                // Latest event timestamp
+	Location        *time.Location            // Zone times are displayed in (nil = as recorded in the events)
 }
 
 // NewStats creates a new Stats instance with initialized maps.
 // This constructor ensures all map fields are properly initialized to avoid nil pointer panics.
 func NewStats() *Stats {
 	return &Stats{
-		BySensitivity: make(map[string]int),
-		ByQueryType:   make(map[string]int),
-		ByRiskLevel:   make(map[string]int),
+		BySensitivity:   make(map[string]int),
+		ByRegulation:    make(map[string]int),
+		ByQueryType:     make(map[string]int),
+		ByRiskLevel:     make(map[string]int),
+		RiskByUser:      make(map[string]map[string]int),
+		RiskByQueryType: make(map[string]map[string]int),
 	}
 }
 
@@ -54,15 +68,24 @@ func (s *Stats) IncrementError() {
 	s.ErrorEvents++
 }
 
+// AddSkipped counts the events and malformed lines of index blocks that were
+// skipped unread; none of them matched, since the index ruled their blocks out.
+func (s *Stats) AddSkipped(events, errors int) {
+	s.InputEvents += events
+	s.ErrorEvents += errors
+}
+
 // IncrementMatched increments the matched events counter and updates all relevant statistics.
 // This is the main function for tracking statistics about events that passed all filters.
 // It updates all breakdown counters and tracks the time range of processed events.
 //
 // Statistics updated:
 // - MatchedEvents: Total count of matching events
-// - BySensitivity: Count by sensitivity categories (PII, PHI, Financial)
+// - BySensitivity: Count by sensitivity categories (PII, PHI, Financial, or custom)
+// - ByRegulation: Count by regulation tags
 // - ByQueryType: Count by query types (SELECT, INSERT, UPDATE, etc.)
 // - ByRiskLevel: Count by risk levels (low, medium, high, critical)
+// - RiskByUser/RiskByQueryType: Count by user and query type, per risk level
 // - BulkCount: Count of bulk operations
 // - FirstTimestamp/LastTimestamp: Time range of all matched events
 func (s *Stats) IncrementMatched(e Event) {
@@ -78,6 +101,13 @@ func (s *Stats) IncrementMatched(e Event) {
 		}
 	}
 
+	// Update regulation breakdown - count each regulation tag on the event
+	if regulations, ok := GetStringSlice(e, "regulations"); ok {
+		for _, regulation := range regulations {
+			s.ByRegulation[regulation]++
+		}
+	}
+
 	// Update query type breakdown - count the query type
 	if queryType, ok := GetString(e, "query_type"); ok {
 		s.ByQueryType[queryType]++ // Increment count for this query type
@@ -86,6 +116,16 @@ func (s *Stats) IncrementMatched(e Event) {
 	// Update risk level breakdown - count the risk level
 	if riskLevel, ok := GetString(e, "risk_level"); ok {
 		s.ByRiskLevel[riskLevel]++ // Increment count for this risk level
+
+		// Cross-tabulate the risk level against user and query type; levels are
+		// compared case-insensitively, as the risk filters do
+		level := strings.ToLower(riskLevel)
+		if user, ok := GetString(e, "db_user"); ok {
+			addCrossTab(s.RiskByUser, user, level)
+		}
+		if queryType, ok := GetString(e, "query_type"); ok {
+			addCrossTab(s.RiskByQueryType, queryType, level)
+		}
 	}
 
 	// Update bulk count - count bulk operations
@@ -114,9 +154,11 @@ func (s *Stats) IncrementMatched(e Event) {
 // - Total events processed (including errors)
 // - Time range of matched events (if available)
 // - Number of matched events
-// - Breakdown by sensitivity categories (PII, PHI, Financial)
+// - Breakdown by sensitivity categories (PII, PHI, Financial, or custom)
+// - Breakdown by regulation tags (if any events carry them)
 // - Breakdown by query types (SELECT, INSERT, UPDATE, etc.)
 // - Breakdown by risk levels (low, medium, high, critical)
+// - Risk levels by user and by query type (tables, riskiest rows first)
 // - Count of bulk operations
 //
 // The breakdowns are sorted by count (descending) then by name (ascending) for readability.
@@ -127,8 +169,8 @@ func (s *Stats) PrintSummary(w io.Writer) {
 	// Time range - show the span of matched events
 	if s.FirstTimestamp != nil && s.LastTimestamp != nil {
 		fmt.Fprintf(w, "  Time range: %s to %s\n",
-			s.FirstTimestamp.Format(time.RFC3339),
-			s.LastTimestamp.Format(time.RFC3339))
+			s.formatTime(*s.FirstTimestamp),
+			s.formatTime(*s.LastTimestamp))
 	}
 
 	fmt.Fprintf(w, "  Matched: %d\n", s.MatchedEvents)
@@ -141,6 +183,13 @@ func (s *Stats) PrintSummary(w io.Writer) {
 		fmt.Fprintf(w, "\n")
 	}
 
+	// Regulation breakdown - show counts by regulation tag
+	if len(s.ByRegulation) > 0 {
+		fmt.Fprintf(w, "  By regulation:\n")
+		s.printSortedMap(w, s.ByRegulation, "    ")
+		fmt.Fprintf(w, "\n")
+	}
+
 	// Query type breakdown - show counts by query type
 	if len(s.ByQueryType) > 0 {
 		fmt.Fprintf(w, "  By query type:\n")
@@ -155,6 +204,18 @@ func (s *Stats) PrintSummary(w io.Writer) {
 		fmt.Fprintf(w, "\n")
 	}
 
+	// Risk cross-tabs - show risk levels per user and per query type
+	if len(s.RiskByUser) > 0 {
+		fmt.Fprintf(w, "  Risk by user:\n")
+		s.printCrossTab(w, s.RiskByUser, "USER", "    ")
+		fmt.Fprintf(w, "\n")
+	}
+	if len(s.RiskByQueryType) > 0 {
+		fmt.Fprintf(w, "  Risk by query type:\n")
+		s.printCrossTab(w, s.RiskByQueryType, "QUERY TYPE", "    ")
+		fmt.Fprintf(w, "\n")
+	}
+
 	// Bulk operations count - show count of bulk operations
 	if s.BulkCount > 0 {
 		fmt.Fprintf(w, "  Bulk operations: %d\n", s.BulkCount)
@@ -195,6 +256,88 @@ func (s *Stats) printSortedMap(w io.Writer, m map[string]int, indent string) {
 	}
 }
 
+// addCrossTab increments the count of a risk level in one row of a cross-tab
+func addCrossTab(tab map[string]map[string]int, row, riskLevel string) {
+	if tab[row] == nil {
+		tab[row] = make(map[string]int)
+	}
+	tab[row][riskLevel]++
+}
+
+// riskColumns returns the risk levels found in a cross-tab, lowest first.
+// Levels unknown to the risk hierarchy come last, alphabetically.
+func riskColumns(tab map[string]map[string]int) []string {
+	seen := make(map[string]bool)
+	var levels []string
+	for _, row := range tab {
+		for level := range row {
+			if !seen[level] {
+				seen[level] = true
+				levels = append(levels, level)
+			}
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		vi, vj := enrich.GetRiskLevelValue(strings.ToLower(levels[i])), enrich.GetRiskLevelValue(strings.ToLower(levels[j]))
+		switch {
+		case vi == vj:
+			return levels[i] < levels[j]
+		case vi == 0 || vj == 0:
+			return vj == 0 // Unknown levels last
+		}
+		return vi < vj
+	})
+	return levels
+}
+
+// printCrossTab prints a risk cross-tab as an aligned table: one row per user or
+// query type, one column per risk level and a total.
+// Rows are sorted riskiest first: by count at the highest risk level (descending),
+// then the next level down, then by total and name.
+//
+// Example:
+//
+//	USER      low  medium  high  critical  total
+//	appuser3  2    0       4     1         7
+func (s *Stats) printCrossTab(w io.Writer, tab map[string]map[string]int, label, indent string) {
+	levels := riskColumns(tab)
+
+	rows := make([]string, 0, len(tab))
+	totals := make(map[string]int, len(tab))
+	for row, counts := range tab {
+		rows = append(rows, row)
+		for _, n := range counts {
+			totals[row] += n
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := tab[rows[i]], tab[rows[j]]
+		for k := len(levels) - 1; k >= 0; k-- {
+			if enrich.GetRiskLevelValue(strings.ToLower(levels[k])) == 0 {
+				continue // Unknown levels don't rank rows
+			}
+			if a[levels[k]] != b[levels[k]] {
+				return a[levels[k]] > b[levels[k]]
+			}
+		}
+		if totals[rows[i]] != totals[rows[j]] {
+			return totals[rows[i]] > totals[rows[j]]
+		}
+		return rows[i] < rows[j]
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s%s\t%s\ttotal\n", indent, label, strings.Join(levels, "\t"))
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s%s", indent, row)
+		for _, level := range levels {
+			fmt.Fprintf(tw, "\t%d", tab[row][level])
+		}
+		fmt.Fprintf(tw, "\t%d\n", totals[row])
+	}
+	tw.Flush()
+}
+
 // GetSummaryMap returns the statistics as a map for programmatic access
 func (s *Stats) GetSummaryMap() map[string]interface{} {
 	summary := map[string]interface{}{
@@ -202,17 +345,28 @@ func (s *Stats) GetSummaryMap() map[string]interface{} {
 		"matched_events":         s.MatchedEvents,
 		"error_events":           s.ErrorEvents,
 		"by_sensitivity":         s.BySensitivity,
+		"by_regulation":          s.ByRegulation,
 		"by_query_type":          s.ByQueryType,
 		"by_risk_level":          s.ByRiskLevel,
+		"risk_by_user":           s.RiskByUser,
+		"risk_by_query_type":     s.RiskByQueryType,
 		"bulk_operations":        s.BulkCount,
 	}
 
 	if s.FirstTimestamp != nil && s.LastTimestamp != nil {
 		summary["time_range"] = map[string]string{
-			"start": s.FirstTimestamp.Format(time.RFC3339),
-			"end":   s.LastTimestamp.Format(time.RFC3339),
+			"start": s.formatTime(*s.FirstTimestamp),
+			"end":   s.formatTime(*s.LastTimestamp),
 		}
 	}
 
 	return summary
 }
+
+// formatTime formats a time as RFC 3339 in the display zone
+func (s *Stats) formatTime(t time.Time) string {
+	if s.Location != nil {
+		t = t.In(s.Location)
+	}
+	return t.Format(time.RFC3339)
+}
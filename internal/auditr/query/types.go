@@ -7,6 +7,7 @@ import "time"
 // All fields from the original NDJSON are preserved, including:
 // - Core fields: timestamp, db_user, query_type, risk_level
 // - Sensitivity data: sensitivity array with "Category:field" entries
+// - Category metadata: regulations and retention_classes arrays
 // - Bulk operation flags: bulk, bulk_type, full_table_read
 // - Hash chain data: hash, hash_prev, hash_chain_index (from verify phase)
 // - Database-specific fields: client_ip, connection_id, db_system, etc.
@@ -21,8 +22,9 @@ type QueryOptions struct {
 	OutputFile string   // Output file path, empty means stdout
 
 	// Sensitivity-based filtering
-	Sensitivity  []string // Filter by sensitivity categories (PII, PHI, Financial)
+	Sensitivity  []string // Filter by sensitivity categories (PII, PHI, Financial, or custom)
 	FilterFields []string // Filter by field names in sensitivity entries (email, ssn, etc.)
+	Regulations  []string // Filter by regulation tags from category metadata (HIPAA, GDPR-Art9, etc.)
 
 	// User and connection filtering
 	User string // Filter by database user (db_user field)
@@ -33,14 +35,45 @@ type QueryOptions struct {
 	Bulk     bool     // Show only bulk operations (bulk == true)
 	BulkType string   // Filter by specific bulk operation type (export, import, backup, etc.)
 
+	// Risk-based filtering
+	RiskLevels []string // Filter by risk levels (low, medium, high, critical)
+	MinRisk    string   // Include events at or above this risk level
+
+	// Expression filtering
+	Where string // --where expression over any event field (see CompileWhere)
+
 	// Time-based filtering
 	Since        time.Time     // Include events on or after this time (ISO 8601 UTC)
 	LastDuration time.Duration // Include events from the last N days/hours
+	Until        time.Time     // Include events before this time (exclusive end of --until, --between, --month)
+	From         time.Time     // Start of a closed window (--between, --month, --last-business-day)
+
+	// Time zone and business calendar
+	Location      *time.Location // Zone for zone-less times in --where and for displayed times (nil = UTC / as recorded)
+	Calendar      *Calendar      // Business calendar for BusinessHours / OffHours
+	BusinessHours bool           // Include only events within business hours
+	OffHours      bool           // Include only events outside business hours (nights, weekends, holidays)
 
 	// Error handling and output options
-	ExcludeErrors bool // Exclude events with query_type == "ERROR"
-	Summary       bool // Print summary counts instead of full events
-	Limit         int  // Limit number of output events (0 = no limit)
+	ExcludeErrors bool     // Exclude events with query_type == "ERROR"
+	Summary       bool     // Print summary counts instead of full events
+	Limit         int      // Limit number of output events (0 = no limit)
+	Format        string   // Output format (see output.Formats); ndjson for events, table for groups when empty
+	Fields        []string // Columns to write, in order (dotted paths); empty writes every field
+	NoIndex       bool     // Scan every input file even when it has an index (see Index)
+
+	// Group-by mode (see Aggregator); groups are written instead of events
+	GroupBy  []string      // Fields to group by (db_user, client_ip, sensitivity.field, ...)
+	Distinct []string      // Fields to count distinct values of per group
+	Bucket   time.Duration // Time bucket width (0 = no bucketing)
+	Top      int           // Keep the N largest groups, per bucket (0 = all)
+
+	// Verification attestation of the (hashed) input files
+	Attest          bool   // Verify the input chain and attach an attestation to the output
+	CheckpointPath  string // Signed checkpoint checked against the last input file (implies Attest)
+	PublicKeyPath   string // Public key for the checkpoint signature
+	TSACertPath     string // Trusted TSA certificate for the checkpoint timestamp
+	AttestationFile string // Attestation output path; default <output>.attestation.json, or stderr
 }
 
 // EventFilter is a function that determines if an event matches certain criteria.
@@ -54,4 +87,9 @@ type EventFilter func(Event) bool
 type EventResult struct {
 	Event Event // The parsed event (nil if parsing failed)
 	Err   error // Error encountered during reading/parsing (nil if successful)
+
+	// Events and malformed lines of index blocks that were skipped unread
+	// (see ReadEventsIndexed); Event and Err are nil on such a result
+	SkippedEvents int
+	SkippedErrors int
 }
@@ -0,0 +1,397 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+	"github.com/vaibhaw-/AuditR/internal/auditr/query"
+	"github.com/vaibhaw-/AuditR/internal/auditr/verify"
+)
+
+// Time bucket sizes for the risk timeline
+const (
+	BucketHour = "hour"
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+// riskOrder lists the known risk levels from highest to lowest
+var riskOrder = []string{"critical", "high", "medium", "low"}
+
+// AuditOptions contains the inputs of an audit report.
+type AuditOptions struct {
+	InputFiles []string  // Enriched or hashed NDJSON file(s), empty means stdin
+	Title      string    // Report title
+	Since      time.Time // Include events on or after this time (zero = no lower bound)
+	Until      time.Time // Include events before this time (zero = no upper bound)
+	Bucket     string    // Risk timeline bucket: hour, day or week (default day)
+	TopUsers   int       // Users listed in the top users table (0 = all)
+	MaxRows    int       // Rows listed per evidence table (0 = all)
+
+	// Optional compliance section
+	Mapping   *config.ComplianceMapping
+	Framework string
+
+	// Optional checkpoint evidence
+	CheckpointPath string // Signed checkpoint JSON
+	PublicKeyPath  string // Public key used to check the checkpoint signature
+	TSACertPath    string // Trusted TSA certificate for the checkpoint timestamp
+}
+
+// CountEntry is one row of a breakdown table.
+type CountEntry struct {
+	Key   string
+	Count int
+}
+
+// RiskBucket counts events per risk level in one time bucket.
+type RiskBucket struct {
+	Start  time.Time
+	Counts map[string]int
+	Total  int
+}
+
+// UserAccess summarizes one user's access to sensitive data.
+type UserAccess struct {
+	User            string
+	SensitiveEvents int
+	BulkEvents      int
+	Categories      []string
+	HighestRisk     string
+
+	categories map[string]bool
+}
+
+// AuditReport is the data behind the HTML, Markdown and text reports. Custom
+// templates receive a *AuditReport as their root value.
+type AuditReport struct {
+	Title       string
+	GeneratedAt time.Time
+	Since       time.Time
+	Until       time.Time
+	Bucket      string
+
+	// Executive summary
+	InputEvents     int // Valid events read
+	ErrorEvents     int // Lines that failed to parse
+	WindowEvents    int // Events inside the time window
+	SensitiveEvents int // Events with at least one sensitivity match
+	HighRiskEvents  int // Events with risk_level high or critical
+	DistinctUsers   int
+	FirstEvent      *time.Time
+	LastEvent       *time.Time
+
+	BySensitivity []CountEntry
+	ByRiskLevel   []CountEntry
+	ByQueryType   []CountEntry
+
+	RiskLevels   []string // Timeline columns, highest risk first
+	RiskTimeline []RiskBucket
+
+	TopUsers []UserAccess
+
+	BulkExportCount int
+	BulkExports     []EvidenceRow
+	EscalationCount int
+	Escalations     []EvidenceRow
+
+	// Verification attestation of the input files (nil when reading stdin)
+	Attestation *verify.AttestationSet
+	Compliance  *ComplianceReport
+}
+
+// BuildAuditReport reads events once and computes every report section. Chain
+// verification covers whole input files (not just the window), since a chain can
+// only be checked from its start.
+func BuildAuditReport(opts AuditOptions) (*AuditReport, error) {
+	if err := checkWindow(opts.Since, opts.Until); err != nil {
+		return nil, err
+	}
+	if opts.CheckpointPath != "" && len(opts.InputFiles) == 0 {
+		return nil, fmt.Errorf("checkpoint evidence requires input files (stdin can't be verified)")
+	}
+	bucket := opts.Bucket
+	if bucket == "" {
+		bucket = BucketDay
+	}
+	if bucket != BucketHour && bucket != BucketDay && bucket != BucketWeek {
+		return nil, fmt.Errorf("invalid bucket %q: expected hour, day or week", opts.Bucket)
+	}
+
+	report := &AuditReport{
+		Title:       opts.Title,
+		GeneratedAt: time.Now().UTC(),
+		Since:       opts.Since,
+		Until:       opts.Until,
+		Bucket:      bucket,
+	}
+	if report.Title == "" {
+		report.Title = "AuditR audit report"
+	}
+
+	if opts.Framework != "" {
+		compliance, err := newComplianceReport(opts.Mapping, opts.Framework, opts.Since, opts.Until, opts.MaxRows)
+		if err != nil {
+			return nil, err
+		}
+		report.Compliance = compliance
+	}
+
+	bySensitivity := make(map[string]int)
+	byRisk := make(map[string]int)
+	byType := make(map[string]int)
+	timeline := make(map[time.Time]*RiskBucket)
+	users := make(map[string]*UserAccess)
+	allUsers := make(map[string]bool)
+
+	for result := range query.ReadEvents(opts.InputFiles) {
+		if result.Err != nil {
+			report.ErrorEvents++
+			logger.L().Debugw("Skipping unreadable event", "error", result.Err)
+			continue
+		}
+		report.InputEvents++
+		e := result.Event
+
+		ts, ok := inWindow(e, opts.Since, opts.Until)
+		if !ok {
+			continue
+		}
+		report.WindowEvents++
+		if report.Compliance != nil {
+			report.Compliance.WindowEvents++
+			report.Compliance.addEvent(e, ts)
+		}
+
+		row := evidenceRow(e, ts)
+		if row.User != "" {
+			allUsers[row.User] = true
+		}
+		if row.QueryType != "" {
+			byType[row.QueryType]++
+		}
+		if row.RiskLevel != "" {
+			byRisk[row.RiskLevel]++
+			if row.RiskLevel == "high" || row.RiskLevel == "critical" {
+				report.HighRiskEvents++
+			}
+		}
+
+		if !ts.IsZero() {
+			if report.FirstEvent == nil || ts.Before(*report.FirstEvent) {
+				t := ts
+				report.FirstEvent = &t
+			}
+			if report.LastEvent == nil || ts.After(*report.LastEvent) {
+				t := ts
+				report.LastEvent = &t
+			}
+			start := bucketStart(ts, bucket)
+			b, ok := timeline[start]
+			if !ok {
+				b = &RiskBucket{Start: start, Counts: make(map[string]int)}
+				timeline[start] = b
+			}
+			b.Total++
+			if row.RiskLevel != "" {
+				b.Counts[row.RiskLevel]++
+			}
+		}
+
+		// Sensitive access per category and per user
+		categories := eventCategories(row.Sensitivity)
+		for _, c := range categories {
+			bySensitivity[c]++
+		}
+		if len(categories) > 0 {
+			report.SensitiveEvents++
+			user := row.User
+			if user == "" {
+				user = "(unknown)"
+			}
+			ua, ok := users[user]
+			if !ok {
+				ua = &UserAccess{User: user, categories: make(map[string]bool)}
+				users[user] = ua
+			}
+			ua.SensitiveEvents++
+			if row.Bulk {
+				ua.BulkEvents++
+			}
+			for _, c := range categories {
+				ua.categories[c] = true
+			}
+			if riskRank(row.RiskLevel) > riskRank(ua.HighestRisk) {
+				ua.HighestRisk = row.RiskLevel
+			}
+		}
+
+		if row.Bulk && strings.EqualFold(row.BulkType, "export") {
+			report.BulkExportCount++
+			if opts.MaxRows == 0 || len(report.BulkExports) < opts.MaxRows {
+				report.BulkExports = append(report.BulkExports, row)
+			}
+		}
+		if strings.HasSuffix(strings.ToUpper(row.QueryType), "_ESCALATION") {
+			report.EscalationCount++
+			if opts.MaxRows == 0 || len(report.Escalations) < opts.MaxRows {
+				report.Escalations = append(report.Escalations, row)
+			}
+		}
+	}
+
+	report.DistinctUsers = len(allUsers)
+	report.BySensitivity = sortedCounts(bySensitivity)
+	report.ByQueryType = sortedCounts(byType)
+	report.RiskLevels, report.ByRiskLevel = riskCounts(byRisk)
+	report.RiskTimeline = sortedTimeline(timeline)
+	report.TopUsers = topUsers(users, opts.TopUsers)
+	if report.Compliance != nil {
+		report.Compliance.InputEvents = report.InputEvents
+		report.Compliance.ErrorEvents = report.ErrorEvents
+		report.Compliance.finish()
+	}
+
+	// Chain verification and checkpoint evidence
+	if len(opts.InputFiles) > 0 {
+		attestation, err := verify.Attest(opts.InputFiles, opts.CheckpointPath, opts.PublicKeyPath, opts.TSACertPath)
+		if err != nil {
+			return nil, err
+		}
+		report.Attestation = attestation
+	}
+
+	logger.L().Debugw("Audit report built",
+		"input_events", report.InputEvents,
+		"window_events", report.WindowEvents,
+		"sensitive_events", report.SensitiveEvents,
+		"chain_intact", report.ChainIntact())
+
+	return report, nil
+}
+
+// ChainIntact reports whether every input file carries an intact hash chain.
+func (r *AuditReport) ChainIntact() bool {
+	return r.Attestation != nil && r.Attestation.ChainIntact()
+}
+
+// Checkpoint returns the checkpoint evidence, or nil when no checkpoint was given.
+func (r *AuditReport) Checkpoint() *verify.CheckpointAttestation {
+	if r.Attestation == nil {
+		return nil
+	}
+	return r.Attestation.Checkpoint()
+}
+
+// eventCategories returns the distinct categories of "Category:column" entries
+func eventCategories(sensitivity []string) []string {
+	seen := make(map[string]bool)
+	var categories []string
+	for _, entry := range sensitivity {
+		category, _ := query.ParseSensitivityEntry(entry)
+		if category != "" && !seen[category] {
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
+// bucketStart truncates a timestamp to the start of its UTC hour, day or ISO week
+func bucketStart(ts time.Time, bucket string) time.Time {
+	ts = ts.UTC()
+	switch bucket {
+	case BucketHour:
+		return ts.Truncate(time.Hour)
+	case BucketWeek:
+		day := time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC)
+		offset := (int(day.Weekday()) + 6) % 7 // Monday = 0
+		return day.AddDate(0, 0, -offset)
+	default:
+		return time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// riskRank orders risk levels; unknown levels rank lowest
+func riskRank(level string) int {
+	for i, r := range riskOrder {
+		if r == level {
+			return len(riskOrder) - i
+		}
+	}
+	return 0
+}
+
+// sortedCounts sorts a breakdown by count (descending) then key (ascending)
+func sortedCounts(m map[string]int) []CountEntry {
+	entries := make([]CountEntry, 0, len(m))
+	for k, v := range m {
+		entries = append(entries, CountEntry{Key: k, Count: v})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count == entries[j].Count {
+			return entries[i].Key < entries[j].Key
+		}
+		return entries[i].Count > entries[j].Count
+	})
+	return entries
+}
+
+// riskCounts orders risk levels from critical to low, followed by any other levels seen
+func riskCounts(m map[string]int) ([]string, []CountEntry) {
+	var levels []string
+	var entries []CountEntry
+	for _, level := range riskOrder {
+		levels = append(levels, level)
+		if n := m[level]; n > 0 {
+			entries = append(entries, CountEntry{Key: level, Count: n})
+		}
+	}
+	var other []string
+	for level := range m {
+		if riskRank(level) == 0 {
+			other = append(other, level)
+		}
+	}
+	sort.Strings(other)
+	for _, level := range other {
+		levels = append(levels, level)
+		entries = append(entries, CountEntry{Key: level, Count: m[level]})
+	}
+	return levels, entries
+}
+
+func sortedTimeline(m map[time.Time]*RiskBucket) []RiskBucket {
+	buckets := make([]RiskBucket, 0, len(m))
+	for _, b := range m {
+		buckets = append(buckets, *b)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start.Before(buckets[j].Start) })
+	return buckets
+}
+
+// topUsers sorts users by sensitive events (descending) and keeps the first n
+func topUsers(m map[string]*UserAccess, n int) []UserAccess {
+	users := make([]UserAccess, 0, len(m))
+	for _, ua := range m {
+		for c := range ua.categories {
+			ua.Categories = append(ua.Categories, c)
+		}
+		sort.Strings(ua.Categories)
+		users = append(users, *ua)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].SensitiveEvents == users[j].SensitiveEvents {
+			return users[i].User < users[j].User
+		}
+		return users[i].SensitiveEvents > users[j].SensitiveEvents
+	})
+	if n > 0 && len(users) > n {
+		users = users[:n]
+	}
+	return users
+}
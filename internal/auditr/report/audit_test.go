@@ -0,0 +1,205 @@
+package report
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/verify"
+)
+
+var auditEvents = []string{
+	`{"event_id":"e1","timestamp":"2025-09-01T10:00:00Z","db_user":"alice","query_type":"SELECT","sensitivity":["PHI:diagnosis"],"risk_level":"high","bulk":true,"bulk_type":"export"}`,
+	`{"event_id":"e2","timestamp":"2025-09-01T11:30:00Z","db_user":"alice","query_type":"SELECT","sensitivity":["PII:ssn","PHI:diagnosis"],"risk_level":"critical"}`,
+	`{"event_id":"e3","timestamp":"2025-09-02T09:00:00Z","db_user":"admin","query_type":"GRANT_ESCALATION","risk_level":"medium"}`,
+	`{"event_id":"e4","timestamp":"2025-09-02T10:00:00Z","db_user":"bob","query_type":"UPDATE","sensitivity":["PII:email"],"risk_level":"low"}`,
+	`{"event_id":"e5","timestamp":"2025-09-03T10:00:00Z","db_user":"carol","query_type":"SELECT","risk_level":"low","bulk":true,"bulk_type":"read"}`,
+	// Outside the window
+	`{"event_id":"e6","timestamp":"2025-10-01T00:00:00Z","db_user":"alice","query_type":"SELECT","sensitivity":["PHI:diagnosis"],"risk_level":"high"}`,
+}
+
+// writeHashed hash-chains the events into a file and returns its path and head hash
+func writeHashed(t *testing.T, dir string, lines ...string) (string, string) {
+	t.Helper()
+	var out bytes.Buffer
+	state, _, err := verify.ComputeChain(strings.NewReader(strings.Join(lines, "\n")+"\n"), &out, nil)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "hashed.ndjson")
+	require.NoError(t, os.WriteFile(path, out.Bytes(), 0644))
+	return path, state.LastHeadHash
+}
+
+func genKeys(t *testing.T, dir string) (privPath, pubPath string) {
+	t.Helper()
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(sk)
+	require.NoError(t, err)
+	privPath = filepath.Join(dir, "private.pem")
+	require.NoError(t, os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), 0600))
+	der, err := x509.MarshalPKIXPublicKey(&sk.PublicKey)
+	require.NoError(t, err)
+	pubPath = filepath.Join(dir, "public.pem")
+	require.NoError(t, os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+	return privPath, pubPath
+}
+
+func TestBuildAuditReport_Sections(t *testing.T) {
+	input := writeEvents(t, append(auditEvents, `not json`)...)
+
+	rep, err := BuildAuditReport(AuditOptions{
+		InputFiles: []string{input},
+		Until:      time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC),
+		Mapping:    testMapping(t),
+		Framework:  "hipaa",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "AuditR audit report", rep.Title)
+	assert.Equal(t, BucketDay, rep.Bucket)
+	assert.Equal(t, 6, rep.InputEvents)
+	assert.Equal(t, 1, rep.ErrorEvents)
+	assert.Equal(t, 5, rep.WindowEvents)
+	assert.Equal(t, 3, rep.SensitiveEvents)
+	assert.Equal(t, 2, rep.HighRiskEvents)
+	assert.Equal(t, 4, rep.DistinctUsers)
+	require.NotNil(t, rep.FirstEvent)
+	assert.Equal(t, "2025-09-01T10:00:00Z", rep.FirstEvent.Format(time.RFC3339))
+	assert.Equal(t, "2025-09-03T10:00:00Z", rep.LastEvent.Format(time.RFC3339))
+
+	assert.Equal(t, []CountEntry{{"PHI", 2}, {"PII", 2}}, rep.BySensitivity)
+	assert.Equal(t, []string{"critical", "high", "medium", "low"}, rep.RiskLevels)
+
+	// Risk timeline, one bucket per day
+	require.Len(t, rep.RiskTimeline, 3)
+	assert.Equal(t, 2, rep.RiskTimeline[0].Total)
+	assert.Equal(t, 1, rep.RiskTimeline[0].Counts["critical"])
+	assert.Equal(t, 1, rep.RiskTimeline[1].Counts["medium"])
+
+	// Top users ordered by sensitive events
+	require.Len(t, rep.TopUsers, 2)
+	assert.Equal(t, "alice", rep.TopUsers[0].User)
+	assert.Equal(t, 2, rep.TopUsers[0].SensitiveEvents)
+	assert.Equal(t, 1, rep.TopUsers[0].BulkEvents)
+	assert.Equal(t, []string{"PHI", "PII"}, rep.TopUsers[0].Categories)
+	assert.Equal(t, "critical", rep.TopUsers[0].HighestRisk)
+
+	// Only bulk exports, not bulk reads
+	assert.Equal(t, 1, rep.BulkExportCount)
+	require.Len(t, rep.BulkExports, 1)
+	assert.Equal(t, "e1", rep.BulkExports[0].EventID)
+	assert.Equal(t, 1, rep.EscalationCount)
+	assert.Equal(t, "e3", rep.Escalations[0].EventID)
+
+	// Plain enriched input carries no hash chain
+	require.NotNil(t, rep.Attestation)
+	require.Len(t, rep.Attestation.Attestations, 1)
+	assert.False(t, rep.Attestation.Attestations[0].Hashed)
+	assert.False(t, rep.Attestation.Verified)
+	assert.False(t, rep.ChainIntact())
+	assert.Nil(t, rep.Checkpoint())
+
+	require.NotNil(t, rep.Compliance)
+	assert.Equal(t, 6, rep.Compliance.InputEvents)
+	assert.Equal(t, 5, rep.Compliance.WindowEvents)
+	assert.Equal(t, 3, rep.Compliance.MappedEvents)
+}
+
+func TestBuildAuditReport_Options(t *testing.T) {
+	input := writeEvents(t, auditEvents...)
+
+	rep, err := BuildAuditReport(AuditOptions{InputFiles: []string{input}, TopUsers: 1, MaxRows: 1, Bucket: BucketWeek})
+	require.NoError(t, err)
+	assert.Len(t, rep.TopUsers, 1)
+	assert.Equal(t, 6, rep.WindowEvents)
+	assert.Len(t, rep.Escalations, 1)
+	require.Len(t, rep.RiskTimeline, 2)
+	assert.Equal(t, "2025-09-01", rep.RiskTimeline[0].Start.Format("2006-01-02"))
+	assert.Nil(t, rep.Compliance)
+
+	_, err = BuildAuditReport(AuditOptions{InputFiles: []string{input}, Bucket: "month"})
+	assert.ErrorContains(t, err, "invalid bucket")
+
+	_, err = BuildAuditReport(AuditOptions{InputFiles: []string{input}, Framework: "hipaa"})
+	assert.ErrorContains(t, err, "compliance mapping is required")
+}
+
+func TestBuildAuditReport_ChainAndCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	input, head := writeHashed(t, dir, auditEvents...)
+	privPath, pubPath := genKeys(t, dir)
+	cpPath, err := verify.WriteCheckpoint(filepath.Join(dir, "checkpoints"), len(auditEvents), head, privPath)
+	require.NoError(t, err)
+
+	rep, err := BuildAuditReport(AuditOptions{
+		InputFiles:     []string{input},
+		CheckpointPath: cpPath,
+		PublicKeyPath:  pubPath,
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, rep.Attestation)
+	require.Len(t, rep.Attestation.Attestations, 1)
+	att := rep.Attestation.Attestations[0]
+	assert.True(t, att.Hashed)
+	assert.True(t, att.ChainIntact)
+	assert.Equal(t, len(auditEvents), att.Events)
+	assert.Equal(t, 1, att.FirstChainIndex)
+	assert.Equal(t, len(auditEvents), att.LastChainIndex)
+	assert.Equal(t, head, att.HeadHash)
+	assert.True(t, rep.ChainIntact())
+	assert.True(t, rep.Attestation.Verified)
+
+	cp := rep.Checkpoint()
+	require.NotNil(t, cp)
+	assert.Equal(t, len(auditEvents), cp.ChainIndex)
+	assert.True(t, cp.HeadMatches)
+	assert.Equal(t, verify.SignatureValid, cp.SignatureStatus)
+	assert.True(t, strings.HasPrefix(cp.KeyFingerprint, "SHA256:"))
+	assert.Equal(t, verify.AlgECDSAP256SHA256, cp.Algorithm)
+	assert.Equal(t, cp.KeyFingerprint, cp.KeyID)
+	assert.Empty(t, cp.Error)
+
+	var buf bytes.Buffer
+	require.NoError(t, rep.Render(&buf, FormatMarkdown, ""))
+	assert.Contains(t, buf.String(), "## Verification attestation")
+	assert.Contains(t, buf.String(), `"key_fingerprint": "`+cp.KeyFingerprint+`"`)
+
+	// Tamper with one event: the stored head still matches the checkpoint, but the
+	// chain no longer verifies
+	data, err := os.ReadFile(input)
+	require.NoError(t, err)
+	tampered := strings.Replace(string(data), `"db_user":"bob"`, `"db_user":"mallory"`, 1)
+	require.NoError(t, os.WriteFile(input, []byte(tampered), 0644))
+
+	rep, err = BuildAuditReport(AuditOptions{InputFiles: []string{input}, CheckpointPath: cpPath})
+	require.NoError(t, err)
+	assert.False(t, rep.ChainIntact())
+	assert.False(t, rep.Attestation.Verified)
+	assert.Equal(t, []int{4}, rep.Attestation.Attestations[0].TamperedIndices)
+	assert.True(t, rep.Checkpoint().HeadMatches)
+	assert.Equal(t, verify.SignatureNotChecked, rep.Checkpoint().SignatureStatus)
+}
+
+func TestBucketStart(t *testing.T) {
+	// Thursday
+	ts := time.Date(2025, 9, 4, 15, 45, 30, 0, time.FixedZone("X", 2*3600))
+
+	assert.Equal(t, time.Date(2025, 9, 4, 13, 0, 0, 0, time.UTC), bucketStart(ts, BucketHour))
+	assert.Equal(t, time.Date(2025, 9, 4, 0, 0, 0, 0, time.UTC), bucketStart(ts, BucketDay))
+	assert.Equal(t, time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), bucketStart(ts, BucketWeek))
+
+	sunday := time.Date(2025, 9, 7, 23, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), bucketStart(sunday, BucketWeek))
+}
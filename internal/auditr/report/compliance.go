@@ -0,0 +1,311 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+	"github.com/vaibhaw-/AuditR/internal/auditr/query"
+)
+
+// ComplianceOptions contains the inputs of a per-framework compliance report.
+type ComplianceOptions struct {
+	InputFiles []string                  // Enriched or hashed NDJSON file(s), empty means stdin
+	Mapping    *config.ComplianceMapping // Framework control definitions
+	Framework  string                    // Framework key, e.g. "hipaa"
+	Since      time.Time                 // Include events on or after this time (zero = no lower bound)
+	Until      time.Time                 // Include events before this time (zero = no upper bound)
+	MaxRows    int                       // Evidence rows listed per control (0 = all)
+}
+
+// EvidenceRow is one event listed as evidence for a control.
+type EvidenceRow struct {
+	EventID     string
+	Timestamp   time.Time
+	User        string
+	QueryType   string
+	RiskLevel   string
+	Sensitivity []string
+	Bulk        bool
+	BulkType    string
+	RawQuery    string
+}
+
+// ControlEvidence collects the events relevant to one control.
+type ControlEvidence struct {
+	ID        string
+	Title     string
+	Events    int
+	Users     []string // Distinct db_user values, sorted
+	First     *time.Time
+	Last      *time.Time
+	Rows      []EvidenceRow
+	Truncated int // Events counted but not listed because of MaxRows
+
+	users map[string]bool
+}
+
+// ComplianceReport is the evidence for every control of one framework over a time window.
+type ComplianceReport struct {
+	Framework     string
+	FrameworkName string
+	Since         time.Time
+	Until         time.Time
+	GeneratedAt   time.Time
+	InputEvents   int // Valid events read
+	ErrorEvents   int // Lines that failed to parse
+	WindowEvents  int // Events inside the time window
+	MappedEvents  int // Events relevant to at least one control of the framework
+	Controls      []*ControlEvidence
+
+	mapping *config.ComplianceMapping
+	maxRows int
+	byID    map[string]*ControlEvidence
+}
+
+// BuildComplianceReport reads events and groups them by the framework controls they
+// are relevant to. Controls stamped by enrich (compliance_controls) are used when
+// present, so the report reflects the mapping in force at enrichment time; events
+// without the field are mapped from their sensitivity categories and query type.
+func BuildComplianceReport(opts ComplianceOptions) (*ComplianceReport, error) {
+	report, err := newComplianceReport(opts.Mapping, opts.Framework, opts.Since, opts.Until, opts.MaxRows)
+	if err != nil {
+		return nil, err
+	}
+
+	for result := range query.ReadEvents(opts.InputFiles) {
+		if result.Err != nil {
+			report.ErrorEvents++
+			logger.L().Debugw("Skipping unreadable event", "error", result.Err)
+			continue
+		}
+		report.InputEvents++
+
+		ts, ok := inWindow(result.Event, opts.Since, opts.Until)
+		if !ok {
+			continue
+		}
+		report.WindowEvents++
+		report.addEvent(result.Event, ts)
+	}
+
+	report.finish()
+	return report, nil
+}
+
+// newComplianceReport creates an empty report with one evidence entry per control
+func newComplianceReport(mapping *config.ComplianceMapping, framework string, since, until time.Time, maxRows int) (*ComplianceReport, error) {
+	if mapping == nil {
+		return nil, fmt.Errorf("compliance mapping is required")
+	}
+	key, fw, ok := mapping.Framework(framework)
+	if !ok {
+		return nil, fmt.Errorf("unknown framework %q (available: %s)", framework, strings.Join(mapping.FrameworkKeys(), ", "))
+	}
+	if err := checkWindow(since, until); err != nil {
+		return nil, err
+	}
+
+	report := &ComplianceReport{
+		Framework:     key,
+		FrameworkName: fw.Name,
+		Since:         since,
+		Until:         until,
+		GeneratedAt:   time.Now().UTC(),
+		mapping:       mapping,
+		maxRows:       maxRows,
+		byID:          make(map[string]*ControlEvidence, len(fw.Controls)),
+	}
+	for _, c := range fw.Controls {
+		ce := &ControlEvidence{ID: c.ID, Title: c.Title, users: make(map[string]bool)}
+		report.Controls = append(report.Controls, ce)
+		report.byID[c.ID] = ce
+	}
+	return report, nil
+}
+
+// addEvent records an in-window event under every control it is relevant to
+func (r *ComplianceReport) addEvent(e query.Event, ts time.Time) {
+	controlIDs := eventControls(e, r.Framework, r.mapping)
+	if len(controlIDs) == 0 {
+		return
+	}
+	r.MappedEvents++
+
+	row := evidenceRow(e, ts)
+	for _, id := range controlIDs {
+		ce, ok := r.byID[id]
+		if !ok {
+			logger.L().Debugw("Event references control not in mapping",
+				"event_id", row.EventID, "framework", r.Framework, "control", id)
+			continue
+		}
+		ce.add(row, r.maxRows)
+	}
+}
+
+// finish sorts the distinct users of each control
+func (r *ComplianceReport) finish() {
+	for _, ce := range r.Controls {
+		ce.Users = ce.Users[:0]
+		for u := range ce.users {
+			ce.Users = append(ce.Users, u)
+		}
+		sort.Strings(ce.Users)
+	}
+
+	logger.L().Debugw("Compliance report built",
+		"framework", r.Framework,
+		"input_events", r.InputEvents,
+		"window_events", r.WindowEvents,
+		"mapped_events", r.MappedEvents)
+}
+
+// checkWindow rejects empty or inverted [since, until) windows
+func checkWindow(since, until time.Time) error {
+	if !since.IsZero() && !until.IsZero() && !until.After(since) {
+		return fmt.Errorf("report window end %s must be after start %s", until.Format(time.RFC3339), since.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// inWindow returns the event timestamp and whether the event falls in [since, until).
+// Without bounds every event is in the window; with bounds, events without a
+// parseable timestamp are excluded.
+func inWindow(e query.Event, since, until time.Time) (time.Time, bool) {
+	ts, err := query.ParseTimestamp(e["timestamp"])
+	if since.IsZero() && until.IsZero() {
+		return ts, true
+	}
+	if err != nil {
+		return ts, false
+	}
+	if !since.IsZero() && ts.Before(since) {
+		return ts, false
+	}
+	if !until.IsZero() && !ts.Before(until) {
+		return ts, false
+	}
+	return ts, true
+}
+
+// eventControls returns the IDs of the framework's controls an event is relevant to
+func eventControls(e query.Event, framework string, mapping *config.ComplianceMapping) []string {
+	refs, stamped := query.GetStringSlice(e, "compliance_controls")
+	if !stamped {
+		sensitivity, _ := query.GetStringSlice(e, "sensitivity")
+		queryType, _ := query.GetString(e, "query_type")
+		refs = mapping.Match(eventCategories(sensitivity), queryType)
+	}
+
+	var ids []string
+	for _, ref := range refs {
+		fw, id := config.ParseControlRef(ref)
+		if strings.EqualFold(fw, framework) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func evidenceRow(e query.Event, ts time.Time) EvidenceRow {
+	row := EvidenceRow{Timestamp: ts}
+	row.EventID, _ = query.GetString(e, "event_id")
+	row.User, _ = query.GetString(e, "db_user")
+	row.QueryType, _ = query.GetString(e, "query_type")
+	row.RiskLevel, _ = query.GetString(e, "risk_level")
+	row.Sensitivity, _ = query.GetStringSlice(e, "sensitivity")
+	row.Bulk, _ = query.GetBool(e, "bulk")
+	row.BulkType, _ = query.GetString(e, "bulk_type")
+	row.RawQuery, _ = query.GetString(e, "raw_query")
+	return row
+}
+
+func (ce *ControlEvidence) add(row EvidenceRow, maxRows int) {
+	ce.Events++
+	if row.User != "" {
+		ce.users[row.User] = true
+	}
+	if !row.Timestamp.IsZero() {
+		if ce.First == nil || row.Timestamp.Before(*ce.First) {
+			t := row.Timestamp
+			ce.First = &t
+		}
+		if ce.Last == nil || row.Timestamp.After(*ce.Last) {
+			t := row.Timestamp
+			ce.Last = &t
+		}
+	}
+	if maxRows > 0 && len(ce.Rows) >= maxRows {
+		ce.Truncated++
+		return
+	}
+	ce.Rows = append(ce.Rows, row)
+}
+
+// WriteText renders the report as plain-text tables: a control summary followed by
+// one evidence table per control.
+func (r *ComplianceReport) WriteText(w io.Writer) error {
+	title := r.Framework
+	if r.FrameworkName != "" {
+		title = r.FrameworkName
+	}
+	fmt.Fprintf(w, "Compliance report: %s\n", title)
+	fmt.Fprintf(w, "  Window: %s to %s\n", formatBound(r.Since, "beginning"), formatBound(r.Until, "end"))
+	fmt.Fprintf(w, "  Generated: %s\n", r.GeneratedAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "  Events read: %d (errors: %d), in window: %d, relevant to %s: %d\n\n",
+		r.InputEvents, r.ErrorEvents, r.WindowEvents, r.Framework, r.MappedEvents)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTROL\tEVENTS\tUSERS\tFIRST\tLAST\tTITLE")
+	for _, ce := range r.Controls {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\n",
+			ce.ID, ce.Events, len(ce.Users), formatTime(ce.First), formatTime(ce.Last), ce.Title)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, ce := range r.Controls {
+		fmt.Fprintf(w, "\n%s %s\n", ce.ID, ce.Title)
+		if ce.Events == 0 {
+			fmt.Fprintf(w, "  No evidence in window.\n")
+			continue
+		}
+		fmt.Fprintf(w, "  Users: %s\n", strings.Join(ce.Users, ", "))
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  TIMESTAMP\tUSER\tTYPE\tRISK\tBULK\tSENSITIVITY\tEVENT_ID")
+		for _, row := range ce.Rows {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%t\t%s\t%s\n",
+				formatTime(&row.Timestamp), row.User, row.QueryType, row.RiskLevel, row.Bulk,
+				strings.Join(row.Sensitivity, ","), row.EventID)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if ce.Truncated > 0 {
+			fmt.Fprintf(w, "  ... %d more event(s) not listed\n", ce.Truncated)
+		}
+	}
+	return nil
+}
+
+func formatBound(t time.Time, open string) string {
+	if t.IsZero() {
+		return open
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,121 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+)
+
+func testMapping(t *testing.T) *config.ComplianceMapping {
+	t.Helper()
+	mapping, err := config.ValidateCompliance(strings.NewReader(`{
+		"frameworks": {
+			"hipaa": {
+				"name": "HIPAA Security Rule",
+				"controls": [
+					{ "id": "164.312(b)", "title": "Audit controls", "categories": ["PHI"] },
+					{ "id": "164.308(a)(4)", "title": "Access management", "escalation_types": ["*"] }
+				]
+			},
+			"pci-dss": {
+				"controls": [{ "id": "10.2.1.1", "title": "Cardholder data access", "categories": ["Financial"] }]
+			}
+		}
+	}`))
+	require.NoError(t, err)
+	return mapping
+}
+
+func writeEvents(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644))
+	return path
+}
+
+func TestBuildComplianceReport(t *testing.T) {
+	input := writeEvents(t,
+		`{"event_id":"e1","timestamp":"2025-09-01T10:00:00Z","db_user":"alice","query_type":"SELECT","sensitivity":["PHI:diagnosis"],"risk_level":"high"}`,
+		`{"event_id":"e2","timestamp":"2025-09-02T10:00:00Z","db_user":"bob","query_type":"SELECT","sensitivity":["PHI:diagnosis","PII:ssn"],"risk_level":"high"}`,
+		`{"event_id":"e3","timestamp":"2025-09-03T10:00:00Z","db_user":"admin","query_type":"GRANT_ESCALATION","risk_level":"low"}`,
+		`{"event_id":"e4","timestamp":"2025-09-04T10:00:00Z","db_user":"carol","query_type":"SELECT","sensitivity":["Financial:card_last4"]}`,
+		// Stamped controls win over the categories
+		`{"event_id":"e5","timestamp":"2025-09-05T10:00:00Z","db_user":"dave","query_type":"SELECT","sensitivity":["PHI:notes"],"compliance_controls":["pci-dss:10.2.1.1"]}`,
+		// Outside the window
+		`{"event_id":"e6","timestamp":"2025-10-01T00:00:00Z","db_user":"alice","query_type":"SELECT","sensitivity":["PHI:diagnosis"]}`,
+		`not json`,
+	)
+
+	rep, err := BuildComplianceReport(ComplianceOptions{
+		InputFiles: []string{input},
+		Mapping:    testMapping(t),
+		Framework:  "HIPAA",
+		Since:      time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC),
+		Until:      time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC),
+		MaxRows:    1,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "hipaa", rep.Framework)
+	assert.Equal(t, 6, rep.InputEvents)
+	assert.Equal(t, 1, rep.ErrorEvents)
+	assert.Equal(t, 5, rep.WindowEvents)
+	assert.Equal(t, 3, rep.MappedEvents)
+
+	require.Len(t, rep.Controls, 2)
+	audit := rep.Controls[0]
+	assert.Equal(t, "164.312(b)", audit.ID)
+	assert.Equal(t, 2, audit.Events)
+	assert.Equal(t, []string{"alice", "bob"}, audit.Users)
+	assert.Len(t, audit.Rows, 1)
+	assert.Equal(t, 1, audit.Truncated)
+	assert.Equal(t, "2025-09-02T10:00:00Z", audit.Last.Format(time.RFC3339))
+
+	access := rep.Controls[1]
+	assert.Equal(t, 1, access.Events)
+	assert.Equal(t, "e3", access.Rows[0].EventID)
+
+	var buf bytes.Buffer
+	require.NoError(t, rep.WriteText(&buf))
+	out := buf.String()
+	assert.Contains(t, out, "Compliance report: HIPAA Security Rule")
+	assert.Contains(t, out, "164.308(a)(4) Access management")
+	assert.Contains(t, out, "1 more event(s) not listed")
+	assert.NotContains(t, out, "e6")
+}
+
+func TestBuildComplianceReport_Errors(t *testing.T) {
+	mapping := testMapping(t)
+
+	_, err := BuildComplianceReport(ComplianceOptions{Mapping: mapping, Framework: "iso27001"})
+	assert.ErrorContains(t, err, "unknown framework")
+
+	ts := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	_, err = BuildComplianceReport(ComplianceOptions{Mapping: mapping, Framework: "hipaa", Since: ts, Until: ts})
+	assert.Error(t, err)
+
+	_, err = BuildComplianceReport(ComplianceOptions{Framework: "hipaa"})
+	assert.Error(t, err)
+}
+
+func TestWriteText_EmptyControl(t *testing.T) {
+	rep, err := BuildComplianceReport(ComplianceOptions{
+		InputFiles: []string{writeEvents(t, `{"event_id":"e1","query_type":"SELECT"}`)},
+		Mapping:    testMapping(t),
+		Framework:  "pci-dss",
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, rep.WriteText(&buf))
+	assert.Contains(t, buf.String(), "No evidence in window.")
+	assert.Contains(t, buf.String(), "beginning to end")
+}
@@ -0,0 +1,222 @@
+package report
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/verify"
+)
+
+// Output formats of the audit report
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// BuiltinTemplate returns the source of the built-in template for a format, as a
+// starting point for custom templates.
+func BuiltinTemplate(format string) (string, error) {
+	name, err := templateFile(format)
+	if err != nil {
+		return "", err
+	}
+	data, err := builtinTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read built-in template %s: %w", name, err)
+	}
+	return string(data), nil
+}
+
+func templateFile(format string) (string, error) {
+	switch format {
+	case FormatText, "":
+		return "report.txt.tmpl", nil
+	case FormatMarkdown, "md":
+		return "report.md.tmpl", nil
+	case FormatHTML:
+		return "report.html.tmpl", nil
+	default:
+		return "", fmt.Errorf("unknown report format %q (expected text, markdown or html)", format)
+	}
+}
+
+// Render writes the report in the given format. When templatePath is set the file
+// is used instead of the built-in template; HTML templates are parsed with
+// html/template so event values are escaped, other formats with text/template.
+// Custom templates receive the *AuditReport as root value and the same functions
+// as the built-in ones.
+func (r *AuditReport) Render(w io.Writer, format, templatePath string) error {
+	src, err := BuiltinTemplate(format)
+	if err != nil {
+		return err
+	}
+	name := "report"
+	if templatePath != "" {
+		data, err := os.ReadFile(templatePath)
+		if err != nil {
+			return fmt.Errorf("failed to read report template %s: %w", templatePath, err)
+		}
+		src = string(data)
+		name = templatePath
+	}
+
+	// Render into a buffer so a template error doesn't leave a partial report behind
+	var buf bytes.Buffer
+	if format == FormatHTML {
+		tmpl, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap(templateFuncs())).Parse(src)
+		if err != nil {
+			return fmt.Errorf("failed to parse report template %s: %w", name, err)
+		}
+		if err := tmpl.Execute(&buf, r); err != nil {
+			return fmt.Errorf("failed to render report: %w", err)
+		}
+	} else {
+		tmpl, err := template.New(name).Funcs(template.FuncMap(templateFuncs())).Parse(src)
+		if err != nil {
+			return fmt.Errorf("failed to parse report template %s: %w", name, err)
+		}
+		if err := tmpl.Execute(&buf, r); err != nil {
+			return fmt.Errorf("failed to render report: %w", err)
+		}
+	}
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// templateFuncs are the functions available to report templates
+func templateFuncs() map[string]any {
+	return map[string]any{
+		"time":        templateTime,
+		"bound":       formatBound,
+		"pct":         percent,
+		"md":          markdownEscape,
+		"join":        strings.Join,
+		"bucketLabel": bucketLabel,
+		"ints":        joinInts,
+		"short":       shortHash,
+		"dict":        dict,
+		"sub":         func(a, b int) int { return a - b },
+		"maxBucket":   maxBucket,
+		"barWidth":    barWidth,
+		"riskClass":   riskClass,
+		"attestationJSON": func(a *verify.AttestationSet) (string, error) {
+			data, err := json.MarshalIndent(a, "", "  ")
+			return string(data), err
+		},
+		"complianceText": func(c *ComplianceReport) (string, error) {
+			var buf bytes.Buffer
+			err := c.WriteText(&buf)
+			return strings.TrimRight(buf.String(), "\n"), err
+		},
+	}
+}
+
+// templateTime formats a time.Time or *time.Time as RFC3339, "-" when unset
+func templateTime(v any) string {
+	switch t := v.(type) {
+	case time.Time:
+		return formatTime(&t)
+	case *time.Time:
+		return formatTime(t)
+	default:
+		return "-"
+	}
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+}
+
+// markdownEscape keeps a value inside one Markdown table cell
+func markdownEscape(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.ReplaceAll(s, "\r", "")
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+func bucketLabel(start time.Time, bucket string) string {
+	switch bucket {
+	case BucketHour:
+		return start.Format("2006-01-02 15:00")
+	case BucketWeek:
+		return "week of " + start.Format("2006-01-02")
+	default:
+		return start.Format("2006-01-02")
+	}
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func shortHash(h string) string {
+	if len(h) > 16 {
+		return h[:16] + "…"
+	}
+	return h
+}
+
+// dict builds a map from key/value pairs so templates can pass several values to
+// a sub-template
+func dict(pairs ...any) (map[string]any, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict expects key/value pairs")
+	}
+	m := make(map[string]any, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict key %v is not a string", pairs[i])
+		}
+		m[key] = pairs[i+1]
+	}
+	return m, nil
+}
+
+func maxBucket(timeline []RiskBucket) int {
+	max := 0
+	for _, b := range timeline {
+		if b.Total > max {
+			max = b.Total
+		}
+	}
+	return max
+}
+
+// barWidth scales a count to a percentage of the largest bucket
+func barWidth(n, max int) string {
+	if max == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(n)*100/float64(max), 'f', 1, 64)
+}
+
+func riskClass(level string) string {
+	for _, known := range riskOrder {
+		if level == known {
+			return "risk-" + level
+		}
+	}
+	return "risk-other"
+}
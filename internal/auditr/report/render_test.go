@@ -0,0 +1,101 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func buildTestAuditReport(t *testing.T, extra ...string) *AuditReport {
+	t.Helper()
+	input := writeEvents(t, append(auditEvents, extra...)...)
+	rep, err := BuildAuditReport(AuditOptions{
+		InputFiles: []string{input},
+		Title:      "September access review",
+		Mapping:    testMapping(t),
+		Framework:  "hipaa",
+	})
+	require.NoError(t, err)
+	return rep
+}
+
+func TestRender_BuiltinFormats(t *testing.T) {
+	rep := buildTestAuditReport(t)
+
+	sections := map[string][]string{
+		FormatText: {
+			"Executive summary:", "Risk distribution by day:", "Top users by sensitive access:",
+			"Bulk exports (1):", "Privilege escalations (1):", "Chain verification:",
+			"not hashed", "No checkpoint provided.", "Compliance report: HIPAA Security Rule",
+		},
+		FormatMarkdown: {
+			"# September access review", "## Executive summary", "## Risk distribution over time",
+			"| 2025-09-01 | 1 | 1 | 0 | 0 | 2 |", "## Top users by sensitive access", "| alice | 3 |",
+			"## Bulk exports", "## Privilege escalations", "## Chain verification", "## Checkpoint evidence",
+			"## Compliance: HIPAA Security Rule", "### 164.312(b) Audit controls",
+		},
+		FormatHTML: {
+			"<title>September access review</title>", "<h2>Executive summary</h2>",
+			`class="risk-critical"`, "<h2>Top users by sensitive access</h2>", "<h2>Bulk exports</h2>",
+			"<h2>Privilege escalations</h2>", "<h2>Chain verification</h2>", "<h2>Checkpoint evidence</h2>",
+			"<h2>Compliance: HIPAA Security Rule</h2>",
+		},
+	}
+	for format, want := range sections {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, rep.Render(&buf, format, ""))
+			for _, s := range want {
+				assert.Contains(t, buf.String(), s)
+			}
+		})
+	}
+
+	assert.ErrorContains(t, rep.Render(&bytes.Buffer{}, "pdf", ""), "unknown report format")
+}
+
+func TestRender_EscapesEventValues(t *testing.T) {
+	rep := buildTestAuditReport(t,
+		`{"event_id":"x1","timestamp":"2025-09-05T10:00:00Z","db_user":"<script>a|b</script>","query_type":"SELECT","sensitivity":["PHI:notes"],"risk_level":"high"}`)
+
+	var html bytes.Buffer
+	require.NoError(t, rep.Render(&html, FormatHTML, ""))
+	assert.NotContains(t, html.String(), "<script>")
+	assert.Contains(t, html.String(), "&lt;script&gt;")
+
+	var md bytes.Buffer
+	require.NoError(t, rep.Render(&md, FormatMarkdown, ""))
+	assert.Contains(t, md.String(), `a\|b`)
+}
+
+func TestRender_CustomTemplate(t *testing.T) {
+	rep := buildTestAuditReport(t)
+	path := filepath.Join(t.TempDir(), "custom.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte(
+		`{{.Title}}: {{.SensitiveEvents}}/{{.WindowEvents}} sensitive ({{pct .SensitiveEvents .WindowEvents}})
+{{range .TopUsers}}{{.User}}={{.SensitiveEvents}} {{end}}`), 0644))
+
+	var buf bytes.Buffer
+	require.NoError(t, rep.Render(&buf, FormatMarkdown, path))
+	assert.Equal(t, "September access review: 4/6 sensitive (66.7%)\nalice=3 bob=1 ", buf.String())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{{.NoSuchField}}`), 0644))
+	assert.ErrorContains(t, rep.Render(&bytes.Buffer{}, FormatText, path), "failed to render report")
+
+	assert.ErrorContains(t, rep.Render(&bytes.Buffer{}, FormatText, filepath.Join(t.TempDir(), "missing.tmpl")),
+		"failed to read report template")
+}
+
+func TestBuiltinTemplate(t *testing.T) {
+	for _, format := range []string{FormatText, FormatMarkdown, "md", FormatHTML} {
+		src, err := BuiltinTemplate(format)
+		require.NoError(t, err, format)
+		assert.Contains(t, src, "{{")
+	}
+	_, err := BuiltinTemplate("pdf")
+	assert.Error(t, err)
+}
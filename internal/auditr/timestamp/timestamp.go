@@ -0,0 +1,533 @@
+// Package timestamp implements the parts of RFC 3161 (Time-Stamp Protocol) that
+// AuditR needs to timestamp checkpoints: building a TimeStampReq, requesting it
+// from a TSA over HTTP, and parsing and verifying the TimeStampResp and its token
+// (a CMS SignedData over a TSTInfo, RFC 5652).
+//
+// Only SHA-256 message imprints are requested. Tokens signed with RSA (PKCS#1
+// v1.5) or ECDSA over SHA-256/384/512 can be verified.
+package timestamp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// Object identifiers used by RFC 3161 and CMS
+var (
+	oidSignedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidAttrContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttrSigningCertV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidSHA256            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512            = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidRSAEncryption     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSHA256WithRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA384WithRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSHA512WithRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidECDSAWithSHA256   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+
+	// LocalTSAPolicy is the policy OID of tokens issued by LocalTSA (a test OID,
+	// not a registered policy)
+	LocalTSAPolicy = asn1.ObjectIdentifier{1, 2, 3, 4, 1}
+)
+
+const (
+	// HTTP media types of RFC 3161 section 3.4
+	queryContentType = "application/timestamp-query"
+	replyContentType = "application/timestamp-reply"
+
+	defaultRequestTimeout = 30 * time.Second
+	maxResponseBytes      = 1 << 20
+
+	// PKIStatus values
+	statusGranted         = 0
+	statusGrantedWithMods = 1
+	statusRejection       = 2
+
+	// PKIFailureInfo bits
+	failBadAlg        = 0
+	failBadRequest    = 2
+	failBadDataFormat = 5
+	failSystemFailure = 25
+)
+
+// messageImprint is the hash of the timestamped data
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// timeStampReq is the TimeStampReq of RFC 3161 section 2.4.1
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+	Extensions     []pkix.Extension      `asn1:"tag:0,optional"`
+}
+
+// pkiStatusInfo is the status of a TimeStampResp
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+// timeStampResp is the TimeStampResp of RFC 3161 section 2.4.2
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// accuracy of the TSA clock
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+// tstInfo is the TSTInfo signed by the TSA
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time        `asn1:"generalized"`
+	Accuracy       accuracy         `asn1:"optional"`
+	Ordering       bool             `asn1:"optional"`
+	Nonce          *big.Int         `asn1:"optional"`
+	TSA            asn1.RawValue    `asn1:"optional,tag:0"`
+	Extensions     []pkix.Extension `asn1:"optional,tag:1"`
+}
+
+// contentInfo wraps the CMS SignedData of a token
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type encapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// essCertIDv2 and signingCertificateV2 bind the signer certificate (RFC 5035);
+// the hash algorithm defaults to SHA-256 and is omitted
+type essCertIDv2 struct {
+	CertHash []byte
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// Token is a parsed and (by Verify) checked RFC 3161 timestamp token.
+//
+// Fields:
+//   - GenTime: Time asserted by the TSA
+//   - SerialNumber: Token serial number, unique per TSA
+//   - Policy: TSA policy under which the token was issued
+//   - HashedMessage: SHA-256 imprint of the timestamped data
+//   - Nonce: Nonce of the request (nil if none was sent)
+//   - Signer: Certificate that signed the token (set by Verify)
+type Token struct {
+	GenTime       time.Time
+	SerialNumber  *big.Int
+	Policy        asn1.ObjectIdentifier
+	HashedMessage []byte
+	Nonce         *big.Int
+	Signer        *x509.Certificate
+
+	hashAlgorithm asn1.ObjectIdentifier
+	certificates  []*x509.Certificate
+	eContent      []byte
+	signer        signerInfo
+}
+
+// NewRequest builds a DER TimeStampReq for a SHA-256 digest with a random nonce,
+// asking the TSA to include its certificate.
+//
+// Returns:
+//   - DER-encoded request
+//   - The nonce, to be checked against the response
+//   - Error if the digest isn't a SHA-256 digest
+func NewRequest(digest []byte) ([]byte, *big.Int, error) {
+	if len(digest) != sha256.Size {
+		return nil, nil, fmt.Errorf("timestamp request: want a %d-byte SHA-256 digest, got %d bytes", sha256.Size, len(digest))
+	}
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, nil, fmt.Errorf("timestamp request: nonce: %w", err)
+	}
+	req := timeStampReq{
+		Version:        1,
+		MessageImprint: messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256}, HashedMessage: digest},
+		Nonce:          nonce,
+		CertReq:        true,
+	}
+	der, err := asn1.Marshal(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("timestamp request: marshal: %w", err)
+	}
+	return der, nonce, nil
+}
+
+// ParseResponse parses a DER TimeStampResp and returns its token.
+//
+// Returns an error if the TSA didn't grant the request or the token is malformed.
+// The token's signature is not checked; call Verify.
+func ParseResponse(der []byte) (*Token, error) {
+	var resp timeStampResp
+	rest, err := asn1.Unmarshal(der, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("parse timestamp response: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("parse timestamp response: trailing data")
+	}
+	if resp.Status.Status != statusGranted && resp.Status.Status != statusGrantedWithMods {
+		return nil, fmt.Errorf("timestamp request rejected (status %d): %v", resp.Status.Status, resp.Status.StatusString)
+	}
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("timestamp response has no token")
+	}
+	return ParseToken(resp.TimeStampToken.FullBytes)
+}
+
+// ParseToken parses a DER timestamp token (CMS ContentInfo with a SignedData
+// over a TSTInfo).
+func ParseToken(der []byte) (*Token, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("parse timestamp token: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("parse timestamp token: content type %v is not SignedData", ci.ContentType)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("parse timestamp token: signed data: %w", err)
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return nil, fmt.Errorf("parse timestamp token: content type %v is not TSTInfo", sd.EncapContentInfo.EContentType)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("parse timestamp token: want one signer, got %d", len(sd.SignerInfos))
+	}
+
+	var info tstInfo
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent, &info); err != nil {
+		return nil, fmt.Errorf("parse timestamp token: TSTInfo: %w", err)
+	}
+
+	t := &Token{
+		GenTime:       info.GenTime.UTC(),
+		SerialNumber:  info.SerialNumber,
+		Policy:        info.Policy,
+		HashedMessage: info.MessageImprint.HashedMessage,
+		Nonce:         info.Nonce,
+		hashAlgorithm: info.MessageImprint.HashAlgorithm.Algorithm,
+		eContent:      sd.EncapContentInfo.EContent,
+		signer:        sd.SignerInfos[0],
+	}
+	if len(sd.Certificates.Bytes) > 0 {
+		certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse timestamp token: certificates: %w", err)
+		}
+		t.certificates = certs
+	}
+	return t, nil
+}
+
+// Verify checks that the token covers digest and was signed by a TSA certificate.
+//
+// The signer certificate is looked up among the certificates embedded in the
+// token and the trusted ones. When trusted is non-empty, the signer must chain
+// to one of them (a TSA certificate or its CA) and be valid for timestamping at
+// the token's time; with no trusted certificates only the token's integrity is
+// checked.
+//
+// Args:
+//   - digest: SHA-256 digest the token must cover
+//   - trusted: Trusted TSA or CA certificates (may be empty)
+//
+// Returns:
+//   - Error describing the first check that failed
+func (t *Token) Verify(digest []byte, trusted []*x509.Certificate) error {
+	if !t.hashAlgorithm.Equal(oidSHA256) {
+		return fmt.Errorf("timestamp token: imprint algorithm %v is not SHA-256", t.hashAlgorithm)
+	}
+	if !bytes.Equal(t.HashedMessage, digest) {
+		return fmt.Errorf("timestamp token does not cover this data")
+	}
+
+	signer, err := t.findSigner(append(append([]*x509.Certificate{}, t.certificates...), trusted...))
+	if err != nil {
+		return err
+	}
+	if err := t.checkSignedAttrs(signer); err != nil {
+		return err
+	}
+	sigAlg, err := signatureAlgorithm(t.signer.DigestAlgorithm.Algorithm, t.signer.SignatureAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	signed, err := signedAttrsDER(t.signer.SignedAttrs.Bytes)
+	if err != nil {
+		return err
+	}
+	if err := signer.CheckSignature(sigAlg, signed, t.signer.Signature); err != nil {
+		return fmt.Errorf("timestamp token signature: %w", err)
+	}
+	t.Signer = signer
+
+	if len(trusted) == 0 {
+		return nil
+	}
+	roots := x509.NewCertPool()
+	for _, c := range trusted {
+		roots.AddCert(c)
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range t.certificates {
+		intermediates.AddCert(c)
+	}
+	_, err = signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   t.GenTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	if err != nil {
+		return fmt.Errorf("timestamp token signer %q is not trusted: %w", signer.Subject.CommonName, err)
+	}
+	return nil
+}
+
+// TSAName returns the common name of the token's signer, once verified.
+func (t *Token) TSAName() string {
+	if t.Signer == nil {
+		return ""
+	}
+	return t.Signer.Subject.CommonName
+}
+
+// findSigner returns the certificate identified by the signer's SID
+func (t *Token) findSigner(candidates []*x509.Certificate) (*x509.Certificate, error) {
+	sid := t.signer.SID
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		// subjectKeyIdentifier [0]
+		for _, c := range candidates {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c, nil
+			}
+		}
+		return nil, fmt.Errorf("timestamp token: signer certificate not found (by key identifier)")
+	}
+	var ias issuerAndSerial
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil, fmt.Errorf("timestamp token: signer identifier: %w", err)
+	}
+	for _, c := range candidates {
+		if bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) && c.SerialNumber.Cmp(ias.Serial) == 0 {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("timestamp token: signer certificate not found (serial %v)", ias.Serial)
+}
+
+// checkSignedAttrs checks the content type, message digest and (when present)
+// the signing certificate attributes
+func (t *Token) checkSignedAttrs(signer *x509.Certificate) error {
+	if len(t.signer.SignedAttrs.Bytes) == 0 {
+		return fmt.Errorf("timestamp token: no signed attributes")
+	}
+	var attrs []attribute
+	rest := t.signer.SignedAttrs.Bytes
+	for len(rest) > 0 {
+		var a attribute
+		var err error
+		rest, err = asn1.Unmarshal(rest, &a)
+		if err != nil {
+			return fmt.Errorf("timestamp token: signed attributes: %w", err)
+		}
+		attrs = append(attrs, a)
+	}
+
+	newHash, err := digestFunc(t.signer.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	h := newHash()
+	h.Write(t.eContent)
+	wantDigest := h.Sum(nil)
+
+	var sawType, sawDigest bool
+	for _, a := range attrs {
+		if len(a.Values) != 1 {
+			continue
+		}
+		switch {
+		case a.Type.Equal(oidAttrContentType):
+			var ct asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &ct); err != nil || !ct.Equal(oidTSTInfo) {
+				return fmt.Errorf("timestamp token: content type attribute is not TSTInfo")
+			}
+			sawType = true
+		case a.Type.Equal(oidAttrMessageDigest):
+			var md []byte
+			if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &md); err != nil || !bytes.Equal(md, wantDigest) {
+				return fmt.Errorf("timestamp token: message digest attribute does not match the TSTInfo")
+			}
+			sawDigest = true
+		case a.Type.Equal(oidAttrSigningCertV2):
+			var sc signingCertificateV2
+			if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &sc); err == nil && len(sc.Certs) > 0 {
+				sum := sha256.Sum256(signer.Raw)
+				if !bytes.Equal(sc.Certs[0].CertHash, sum[:]) {
+					return fmt.Errorf("timestamp token: signing certificate attribute does not match the signer")
+				}
+			}
+		}
+	}
+	if !sawType || !sawDigest {
+		return fmt.Errorf("timestamp token: missing content type or message digest attribute")
+	}
+	return nil
+}
+
+// signedAttrsDER re-encodes [0] IMPLICIT signed attributes as the SET that was signed
+func signedAttrsDER(content []byte) ([]byte, error) {
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: content})
+}
+
+// digestFunc returns the hash constructor for a digest algorithm
+func digestFunc(alg asn1.ObjectIdentifier) (func() hash.Hash, error) {
+	switch {
+	case alg.Equal(oidSHA256):
+		return sha256.New, nil
+	case alg.Equal(oidSHA384):
+		return sha512.New384, nil
+	case alg.Equal(oidSHA512):
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("timestamp token: unsupported digest algorithm %v", alg)
+}
+
+// signatureAlgorithm maps a CMS digest and signature algorithm to x509
+func signatureAlgorithm(digest, sig asn1.ObjectIdentifier) (x509.SignatureAlgorithm, error) {
+	switch {
+	case sig.Equal(oidECDSAWithSHA256):
+		return x509.ECDSAWithSHA256, nil
+	case sig.Equal(oidECDSAWithSHA384):
+		return x509.ECDSAWithSHA384, nil
+	case sig.Equal(oidECDSAWithSHA512):
+		return x509.ECDSAWithSHA512, nil
+	case sig.Equal(oidSHA256WithRSA):
+		return x509.SHA256WithRSA, nil
+	case sig.Equal(oidSHA384WithRSA):
+		return x509.SHA384WithRSA, nil
+	case sig.Equal(oidSHA512WithRSA):
+		return x509.SHA512WithRSA, nil
+	case sig.Equal(oidRSAEncryption):
+		switch {
+		case digest.Equal(oidSHA256):
+			return x509.SHA256WithRSA, nil
+		case digest.Equal(oidSHA384):
+			return x509.SHA384WithRSA, nil
+		case digest.Equal(oidSHA512):
+			return x509.SHA512WithRSA, nil
+		}
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("timestamp token: unsupported signature algorithm %v with digest %v", sig, digest)
+}
+
+// Client requests timestamps from an RFC 3161 TSA over HTTP.
+type Client struct {
+	URL        string
+	HTTPClient *http.Client // Defaults to a client with a 30s timeout
+}
+
+// Timestamp requests a token for a SHA-256 digest and returns the DER
+// TimeStampResp after checking that it was granted, covers the digest and
+// echoes the request nonce.
+func (c *Client) Timestamp(digest []byte) ([]byte, error) {
+	req, nonce, err := NewRequest(digest)
+	if err != nil {
+		return nil, err
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = &http.Client{Timeout: defaultRequestTimeout}
+	}
+	resp, err := hc.Post(c.URL, queryContentType, bytes.NewReader(req))
+	if err != nil {
+		return nil, fmt.Errorf("timestamp request to %s: %w", c.URL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("timestamp response from %s: %w", c.URL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("timestamp request to %s: HTTP %s", c.URL, resp.Status)
+	}
+
+	tok, err := ParseResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	if err := tok.Verify(digest, nil); err != nil {
+		return nil, err
+	}
+	if tok.Nonce == nil || tok.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("timestamp response from %s does not echo the request nonce", c.URL)
+	}
+	return body, nil
+}
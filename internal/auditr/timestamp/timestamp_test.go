@@ -0,0 +1,142 @@
+package timestamp
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalTSA_RoundTrip(t *testing.T) {
+	tsa, err := NewLocalTSA()
+	if err != nil {
+		t.Fatalf("NewLocalTSA: %v", err)
+	}
+	fixed := time.Now().UTC().Add(-30 * time.Minute).Truncate(time.Second)
+	tsa.Now = func() time.Time { return fixed }
+	srv := httptest.NewServer(tsa)
+	defer srv.Close()
+
+	digest := sha256.Sum256([]byte("checkpoint"))
+	resp, err := (&Client{URL: srv.URL}).Timestamp(digest[:])
+	if err != nil {
+		t.Fatalf("Timestamp: %v", err)
+	}
+
+	tok, err := ParseResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseResponse: %v", err)
+	}
+	if err := tok.Verify(digest[:], []*x509.Certificate{tsa.Certificate}); err != nil {
+		t.Fatalf("Verify with trusted TSA: %v", err)
+	}
+	if !tok.GenTime.Equal(fixed) {
+		t.Errorf("GenTime = %v, want %v", tok.GenTime, fixed)
+	}
+	if !tok.Policy.Equal(LocalTSAPolicy) {
+		t.Errorf("Policy = %v, want %v", tok.Policy, LocalTSAPolicy)
+	}
+	if tok.TSAName() != "AuditR local TSA" {
+		t.Errorf("TSAName = %q", tok.TSAName())
+	}
+
+	other := sha256.Sum256([]byte("other checkpoint"))
+	if err := tok.Verify(other[:], nil); err == nil {
+		t.Error("expected token not to cover a different digest")
+	}
+
+	stranger, err := NewLocalTSA()
+	if err != nil {
+		t.Fatalf("NewLocalTSA: %v", err)
+	}
+	if err := tok.Verify(digest[:], []*x509.Certificate{stranger.Certificate}); err == nil {
+		t.Error("expected verification against an unrelated TSA certificate to fail")
+	}
+}
+
+func TestLocalTSA_TamperedToken(t *testing.T) {
+	tsa, err := NewLocalTSA()
+	if err != nil {
+		t.Fatalf("NewLocalTSA: %v", err)
+	}
+	digest := sha256.Sum256([]byte("checkpoint"))
+	req, _, err := NewRequest(digest[:])
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	resp, err := tsa.Respond(req)
+	if err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+
+	// Flip a byte of the signed TSTInfo's generation time
+	tok, err := ParseResponse(resp)
+	if err != nil {
+		t.Fatalf("ParseResponse: %v", err)
+	}
+	genTime := []byte(tok.GenTime.Format("20060102150405Z"))
+	i := strings.Index(string(resp), string(genTime))
+	if i < 0 {
+		t.Fatal("generation time not found in response")
+	}
+	tampered := append([]byte{}, resp...)
+	tampered[i+3]++
+	tok, err = ParseResponse(tampered)
+	if err != nil {
+		t.Fatalf("ParseResponse (tampered): %v", err)
+	}
+	if err := tok.Verify(digest[:], []*x509.Certificate{tsa.Certificate}); err == nil {
+		t.Error("expected tampered token to fail verification")
+	}
+}
+
+func TestLocalTSA_Rejection(t *testing.T) {
+	tsa, err := NewLocalTSA()
+	if err != nil {
+		t.Fatalf("NewLocalTSA: %v", err)
+	}
+	req, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA512},
+			HashedMessage: make([]byte, 64),
+		},
+	})
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	resp, err := tsa.Respond(req)
+	if err != nil {
+		t.Fatalf("Respond: %v", err)
+	}
+	if _, err := ParseResponse(resp); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("expected rejection, got %v", err)
+	}
+}
+
+func TestLoadOrCreateLocalTSA(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "tsa.pem")
+	keyPath := filepath.Join(dir, "tsa-key.pem")
+
+	created, err := LoadOrCreateLocalTSA(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	loaded, err := LoadOrCreateLocalTSA(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !loaded.Certificate.Equal(created.Certificate) {
+		t.Error("loaded certificate differs from created one")
+	}
+	certs, err := LoadCertificates(certPath)
+	if err != nil || len(certs) != 1 {
+		t.Fatalf("LoadCertificates: %v (%d certs)", err, len(certs))
+	}
+}
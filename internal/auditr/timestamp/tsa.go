@@ -0,0 +1,336 @@
+package timestamp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// localTSAValidity is the validity of a generated LocalTSA certificate
+const localTSAValidity = 10 * 365 * 24 * time.Hour
+
+// LocalTSA is a minimal RFC 3161 time-stamping authority for tests and offline
+// setups. It signs TSTInfo tokens with an ECDSA P-256 key whose self-signed
+// certificate carries the timeStamping extended key usage; trust it by passing
+// that certificate to verification (--tsa-cert).
+//
+// It implements http.Handler, so it can be served with net/http or httptest.
+// LocalTSA is not a substitute for a real TSA: its clock is the local clock.
+//
+// Fields:
+//   - Certificate: TSA certificate (self-signed)
+//   - Key: TSA signing key
+//   - Policy: Policy OID put in issued tokens (default LocalTSAPolicy)
+//   - Now: Clock (default time.Now); tests can inject a fixed time
+type LocalTSA struct {
+	Certificate *x509.Certificate
+	Key         crypto.Signer
+	Policy      asn1.ObjectIdentifier
+	Now         func() time.Time
+
+	mu     sync.Mutex
+	serial int64
+}
+
+// NewLocalTSA creates a LocalTSA with a fresh key and self-signed certificate.
+func NewLocalTSA() (*LocalTSA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate TSA key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return nil, fmt.Errorf("generate TSA certificate serial: %w", err)
+	}
+	now := time.Now().UTC()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "AuditR local TSA", Organization: []string{"AuditR"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(localTSAValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("create TSA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse TSA certificate: %w", err)
+	}
+	return &LocalTSA{Certificate: cert, Key: key}, nil
+}
+
+// LoadOrCreateLocalTSA loads a LocalTSA from PEM certificate and key files,
+// creating both (key mode 0600) if neither exists.
+func LoadOrCreateLocalTSA(certPath, keyPath string) (*LocalTSA, error) {
+	_, certErr := os.Stat(certPath)
+	_, keyErr := os.Stat(keyPath)
+	if errors.Is(certErr, os.ErrNotExist) && errors.Is(keyErr, os.ErrNotExist) {
+		tsa, err := NewLocalTSA()
+		if err != nil {
+			return nil, err
+		}
+		if err := tsa.save(certPath, keyPath); err != nil {
+			return nil, err
+		}
+		return tsa, nil
+	}
+
+	certs, err := LoadCertificates(certPath)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read TSA key: %w", err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM for TSA key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse TSA key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("TSA key must be an ECDSA P-256 key")
+	}
+	if !ecKey.PublicKey.Equal(certs[0].PublicKey) {
+		return nil, fmt.Errorf("TSA key does not match certificate %s", certPath)
+	}
+	return &LocalTSA{Certificate: certs[0], Key: ecKey}, nil
+}
+
+// save writes the certificate and key as PEM
+func (t *LocalTSA) save(certPath, keyPath string) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(t.Key)
+	if err != nil {
+		return fmt.Errorf("marshal TSA key: %w", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return fmt.Errorf("write TSA key: %w", err)
+	}
+	if err := os.WriteFile(certPath, t.CertificatePEM(), 0o644); err != nil {
+		return fmt.Errorf("write TSA certificate: %w", err)
+	}
+	return nil
+}
+
+// CertificatePEM returns the TSA certificate as PEM, to be trusted by verifiers.
+func (t *LocalTSA) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: t.Certificate.Raw})
+}
+
+// Respond answers a DER TimeStampReq with a DER TimeStampResp.
+//
+// Malformed requests and unsupported hash algorithms are answered with a
+// rejection response, as a TSA would; an error is returned only if the
+// response itself can't be produced.
+func (t *LocalTSA) Respond(reqDER []byte) ([]byte, error) {
+	var req timeStampReq
+	rest, err := asn1.Unmarshal(reqDER, &req)
+	if err != nil || len(rest) > 0 || req.Version != 1 {
+		return rejection(failBadDataFormat, "malformed TimeStampReq")
+	}
+	if !req.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) || len(req.MessageImprint.HashedMessage) != sha256.Size {
+		return rejection(failBadAlg, "only SHA-256 message imprints are accepted")
+	}
+	policy := t.Policy
+	if policy == nil {
+		policy = LocalTSAPolicy
+	}
+	if req.ReqPolicy != nil && !req.ReqPolicy.Equal(policy) {
+		return rejection(failBadRequest, "unsupported policy")
+	}
+
+	token, err := t.sign(req, policy)
+	if err != nil {
+		return rejection(failSystemFailure, "signing failed")
+	}
+	return asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: statusGranted},
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+}
+
+// sign builds the token (ContentInfo with SignedData) for a request
+func (t *LocalTSA) sign(req timeStampReq, policy asn1.ObjectIdentifier) ([]byte, error) {
+	now := time.Now
+	if t.Now != nil {
+		now = t.Now
+	}
+	t.mu.Lock()
+	t.serial++
+	serial := t.serial
+	t.mu.Unlock()
+
+	eContent, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         policy,
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   big.NewInt(serial),
+		GenTime:        now().UTC().Truncate(time.Second),
+		Accuracy:       accuracy{Seconds: 1},
+		Nonce:          req.Nonce,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal TSTInfo: %w", err)
+	}
+
+	contentDigest := sha256.Sum256(eContent)
+	certHash := sha256.Sum256(t.Certificate.Raw)
+	attrs, err := marshalAttributes(
+		attrValue{oidAttrContentType, oidTSTInfo},
+		attrValue{oidAttrMessageDigest, contentDigest[:]},
+		attrValue{oidAttrSigningCertV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:]}}}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := signedAttrsDER(attrs)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(signed)
+	sig, err := t.Key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("sign token: %w", err)
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerial{Issuer: asn1.RawValue{FullBytes: t.Certificate.RawIssuer}, Serial: t.Certificate.SerialNumber})
+	if err != nil {
+		return nil, fmt.Errorf("marshal signer identifier: %w", err)
+	}
+	sd := signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: encapContentInfo{EContentType: oidTSTInfo, EContent: eContent},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+			Signature:          sig,
+		}},
+	}
+	if req.CertReq {
+		sd.Certificates = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: t.Certificate.Raw}
+	}
+	sdDER, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, fmt.Errorf("marshal signed data: %w", err)
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdDER},
+	})
+}
+
+// ServeHTTP answers RFC 3161 requests POSTed as application/timestamp-query.
+func (t *LocalTSA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST a TimeStampReq", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("Content-Type") != queryContentType {
+		http.Error(w, "content type must be "+queryContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+	req, err := io.ReadAll(io.LimitReader(r.Body, maxResponseBytes))
+	if err != nil {
+		http.Error(w, "read request", http.StatusBadRequest)
+		return
+	}
+	resp, err := t.Respond(req)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", replyContentType)
+	_, _ = w.Write(resp)
+}
+
+// attrValue is a signed attribute with a single value
+type attrValue struct {
+	oid   asn1.ObjectIdentifier
+	value any
+}
+
+// marshalAttributes returns the DER-sorted contents of a SET OF Attribute
+func marshalAttributes(values ...attrValue) ([]byte, error) {
+	encoded := make([][]byte, 0, len(values))
+	for _, v := range values {
+		val, err := asn1.Marshal(v.value)
+		if err != nil {
+			return nil, fmt.Errorf("marshal attribute %v: %w", v.oid, err)
+		}
+		der, err := asn1.Marshal(attribute{Type: v.oid, Values: []asn1.RawValue{{FullBytes: val}}})
+		if err != nil {
+			return nil, fmt.Errorf("marshal attribute %v: %w", v.oid, err)
+		}
+		encoded = append(encoded, der)
+	}
+	sort.Slice(encoded, func(i, j int) bool { return bytes.Compare(encoded[i], encoded[j]) < 0 })
+	return bytes.Join(encoded, nil), nil
+}
+
+// rejection builds a rejected TimeStampResp
+func rejection(failInfo int, reason string) ([]byte, error) {
+	bits := make([]byte, failInfo/8+1)
+	bits[failInfo/8] = 0x80 >> (failInfo % 8)
+	return asn1.Marshal(timeStampResp{Status: pkiStatusInfo{
+		Status:       statusRejection,
+		StatusString: []string{reason},
+		FailInfo:     asn1.BitString{Bytes: bits, BitLength: failInfo + 1},
+	}})
+}
+
+// LoadCertificates reads one or more PEM certificates, e.g. a TSA certificate
+// or the CA that issued it.
+func LoadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read TSA certificate: %w", err)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse TSA certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return certs, nil
+}
@@ -0,0 +1,242 @@
+package verify
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+)
+
+// Attest verifies the hash chain of each input file and, when checkpointPath is
+// set, checks the checkpoint against the last file.
+//
+// Chain verification reuses VerifyChain, so each file is verified from the zero
+// hash; the checkpoint is checked with VerifyCheckpoint. Verification failures
+// (tampered events, head mismatch, bad signature) are recorded in the returned
+// set rather than returned as errors. A checkpoint whose signature was not
+// checked (no publicKeyPath) leaves the set unverified.
+//
+// Args:
+//   - inputs: Hashed NDJSON files (stdin can't be re-read and isn't supported)
+//   - checkpointPath: Signed checkpoint JSON (optional)
+//   - publicKeyPath: Public key for the checkpoint signature (optional)
+//   - tsaCertPath: Trusted TSA (or CA) certificate for the checkpoint's timestamp (optional)
+//
+// Returns:
+//   - The attestation set
+//   - Error if no inputs are given
+func Attest(inputs []string, checkpointPath, publicKeyPath, tsaCertPath string) (*AttestationSet, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("attestation requires input files (stdin can't be verified)")
+	}
+
+	set := &AttestationSet{VerifiedAt: time.Now().UTC()}
+	for _, path := range inputs {
+		set.Attestations = append(set.Attestations, AttestFile(path))
+	}
+	if checkpointPath != "" {
+		last := set.Attestations[len(set.Attestations)-1]
+		last.Checkpoint = AttestCheckpoint(checkpointPath, publicKeyPath, tsaCertPath, last.HeadHash)
+	}
+
+	set.Verified = true
+	for _, a := range set.Attestations {
+		if !a.ChainIntact {
+			set.Verified = false
+		}
+		// A checkpoint only vouches for the chain once its signature is checked
+		if cp := a.Checkpoint; cp != nil && (!cp.HeadMatches || cp.SignatureStatus != SignatureValid || cp.Error != "") {
+			set.Verified = false
+		}
+	}
+
+	logger.L().Debugw("Attestation computed",
+		"inputs", len(inputs),
+		"checkpoint", checkpointPath,
+		"verified", set.Verified)
+	return set, nil
+}
+
+// AttestFile verifies the hash chain of one file. Files whose first event has no
+// hash field are reported as not hashed rather than tampered.
+func AttestFile(path string) *Attestation {
+	a := &Attestation{Input: path}
+
+	hashed, first, last, err := chainIndexRange(path)
+	if err != nil {
+		a.Error = err.Error()
+		return a
+	}
+	if !hashed {
+		return a
+	}
+	a.Hashed = true
+	a.FirstChainIndex = first
+	a.LastChainIndex = last
+
+	f, err := os.Open(path)
+	if err != nil {
+		a.Error = fmt.Sprintf("open %s: %v", path, err)
+		return a
+	}
+	defer f.Close()
+
+	tampered, head, processed, err := VerifyChain(f)
+	a.TamperedIndices = tampered
+	a.HeadHash = head
+	a.Events = processed
+	if err != nil {
+		a.Error = err.Error()
+	}
+	a.ChainIntact = a.Error == "" && len(a.TamperedIndices) == 0
+	return a
+}
+
+// AttestCheckpoint loads a checkpoint and checks it against a file head hash. The
+// signature is checked separately from the head so a mismatch and a bad
+// signature are reported independently.
+//
+// A timestamp token is trusted only when it verifies against tsaCertPath. Without
+// a trusted certificate the token's own embedded certificate vouches for it, which
+// anyone can forge, so the time is recorded as untrusted and the authority name
+// is left out.
+func AttestCheckpoint(path, publicKeyPath, tsaCertPath, headHash string) *CheckpointAttestation {
+	cp := &CheckpointAttestation{Path: path, SignatureStatus: SignatureNotChecked}
+
+	sc, err := LoadCheckpoint(path)
+	if err != nil {
+		cp.Error = err.Error()
+		return cp
+	}
+	cp.ChainIndex = sc.Checkpoint.ChainIndex
+	cp.HeadHash = sc.Checkpoint.HeadHash
+	cp.CreatedAt = sc.Checkpoint.CreatedAt
+	cp.Algorithm = sc.Checkpoint.SignatureAlgorithm()
+	cp.KeyID = sc.Checkpoint.KeyID
+	cp.HeadMatches = headHash != "" && headHash == sc.Checkpoint.HeadHash
+
+	if sc.TimestampToken != "" {
+		tok, err := CheckpointTimestamp(sc, tsaCertPath)
+		if err != nil {
+			cp.Error = err.Error()
+		} else {
+			cp.TimestampedAt = &tok.GenTime
+			if tsaCertPath != "" {
+				cp.TimestampTrusted = true
+				cp.TimestampAuthority = tok.TSAName()
+			}
+		}
+	}
+
+	if publicKeyPath == "" {
+		return cp
+	}
+	// With a keyring this selects the key by the checkpoint's key ID and time
+	v, err := ResolveVerifier(publicKeyPath, sc.Checkpoint)
+	if err != nil {
+		cp.Error = err.Error()
+		return cp
+	}
+	cp.KeyFingerprint = v.KeyID()
+	// Pass the checkpoint's own head so only the signature is checked here
+	ok, err := VerifyCheckpoint(path, publicKeyPath, sc.Checkpoint.HeadHash)
+	if err != nil {
+		cp.Error = err.Error()
+	}
+	if ok {
+		cp.SignatureStatus = SignatureValid
+	} else {
+		cp.SignatureStatus = SignatureInvalid
+	}
+	return cp
+}
+
+// KeyFingerprint returns the SHA-256 fingerprint of a PEM public key as
+// "SHA256:<hex>", computed over the DER bytes of the PEM block.
+func KeyFingerprint(publicKeyPath string) (string, error) {
+	keyBytes, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		return "", fmt.Errorf("read public key: %w", err)
+	}
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return "", fmt.Errorf("invalid PEM for public key")
+	}
+	sum := sha256.Sum256(block.Bytes)
+	return "SHA256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// chainIndexRange reports whether a file is hashed (its first event has a hash
+// field) and the first and last hash_chain_index it contains.
+func chainIndexRange(path string) (hashed bool, first, last int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, 0, 0, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var evt struct {
+		Hash  *string `json:"hash"`
+		Index *int    `json:"hash_chain_index"`
+	}
+	seen := false
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		evt.Hash, evt.Index = nil, nil
+		if err := json.Unmarshal([]byte(line), &evt); err != nil {
+			if !seen {
+				return false, 0, 0, nil
+			}
+			continue
+		}
+		if !seen {
+			seen = true
+			if evt.Hash == nil {
+				return false, 0, 0, nil
+			}
+			hashed = true
+			if evt.Index != nil {
+				first = *evt.Index
+			}
+		}
+		if evt.Index != nil {
+			last = *evt.Index
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return hashed, first, last, fmt.Errorf("scan %s: %w", path, err)
+	}
+	return hashed, first, last, nil
+}
+
+// Checkpoint returns the checkpoint attestation, if a checkpoint was checked.
+func (s *AttestationSet) Checkpoint() *CheckpointAttestation {
+	for i := len(s.Attestations) - 1; i >= 0; i-- {
+		if cp := s.Attestations[i].Checkpoint; cp != nil {
+			return cp
+		}
+	}
+	return nil
+}
+
+// ChainIntact reports whether every input file carries an intact hash chain.
+func (s *AttestationSet) ChainIntact() bool {
+	for _, a := range s.Attestations {
+		if !a.ChainIntact {
+			return false
+		}
+	}
+	return len(s.Attestations) > 0
+}
@@ -3,34 +3,109 @@ package verify
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"math"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf16"
 )
 
+// Canonicalization schemes, recorded in each hashed event's canon_version
+const (
+	CanonVersionLegacy = 1 // AuditR rules: sorted keys, RFC3339 timestamps, Go number formatting
+	CanonVersionJCS    = 2 // RFC 8785 JSON Canonicalization Scheme
+)
+
+// HashAlgSHA256 is the hash_alg recorded in hashed events (the only one supported)
+const HashAlgSHA256 = "sha256"
+
+// ParseCanonicalization maps a hashing.canonicalization name to its canon_version.
+//
+// Args:
+//   - name: "legacy" (or empty) or "jcs"
+//
+// Returns:
+//   - The canon_version to record in newly hashed events
+//   - Error for an unknown name
+func ParseCanonicalization(name string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "legacy":
+		return CanonVersionLegacy, nil
+	case "jcs":
+		return CanonVersionJCS, nil
+	}
+	return 0, fmt.Errorf("invalid canonicalization %q: use legacy or jcs", name)
+}
+
+// eventScheme returns the canon_version an event was hashed with.
+//
+// Events hashed before the scheme was recorded carry neither canon_version nor
+// hash_alg and canonicalize with the legacy rules, so old chains keep verifying.
+func eventScheme(event map[string]interface{}) (int, error) {
+	if alg, ok := event["hash_alg"]; ok && alg != HashAlgSHA256 {
+		return 0, fmt.Errorf("unsupported hash_alg %v", alg)
+	}
+	v, ok := event["canon_version"]
+	if !ok {
+		return CanonVersionLegacy, nil
+	}
+	if f, ok := v.(float64); ok && (f == CanonVersionLegacy || f == CanonVersionJCS) {
+		return int(f), nil
+	}
+	if i, ok := v.(int); ok && (i == CanonVersionLegacy || i == CanonVersionJCS) {
+		return i, nil
+	}
+	return 0, fmt.Errorf("unsupported canon_version %v", v)
+}
+
 // Canonicalize returns a deterministic JSON string for hashing.
 //
 // This function creates a canonical representation of an event that is consistent
 // across different runs and environments. It's essential for hash chain integrity
 // because the same event must always produce the same hash.
 //
-// Canonicalization Rules:
-//  1. Remove hash-related fields: hash, hash_prev, hash_chain_index
+// The rules depend on the event's canon_version. hash_alg and canon_version are
+// themselves part of the canonical form, so a recorded scheme can't be swapped
+// without breaking the chain.
+//
+// Legacy rules (canon_version 1, or absent):
+//  1. Remove hash-related fields: hash, hash_prev, hash_chain_index, merkle_batch
 //     (These are computed from the canonical form, so they shouldn't be included)
 //  2. Sort keys alphabetically at all levels (recursively)
 //  3. Normalize timestamps to UTC RFC3339 format when parseable
 //  4. Produce compact JSON output (no extra whitespace)
 //  5. Deep copy the input to avoid modifying the original event
 //
+// JCS rules (canon_version 2): remove the same hash-related fields, then
+// serialize per RFC 8785 (see encodeJCS). Values are not rewritten, so any JCS
+// implementation reproduces the canonical form. Test vectors are published in
+// testdata/jcs_vectors.json.
+//
 // Args:
 //   - event: Event map to canonicalize
 //
 // Returns:
 //   - Canonical JSON string representation
-//   - Error if canonicalization fails
+//   - Error if canonicalization fails or the event's scheme is unsupported
 func Canonicalize(event map[string]interface{}) (string, error) {
+	version, err := eventScheme(event)
+	if err != nil {
+		return "", err
+	}
+
 	// Step 1: Deep copy event and remove hash-related fields
 	clean := deepCopyWithoutHashFields(event)
 
+	if version == CanonVersionJCS {
+		var buf bytes.Buffer
+		if err := encodeJCS(&buf, clean); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
 	// Step 2: Normalize timestamps to UTC RFC3339 format
 	normalizeTimestamps(clean)
 
@@ -59,7 +134,7 @@ func deepCopyWithoutHashFields(in map[string]interface{}) map[string]interface{}
 	out := make(map[string]interface{}, len(in))
 	for k, v := range in {
 		// Skip hash-related fields that are computed during chain processing
-		if k == "hash" || k == "hash_prev" || k == "hash_chain_index" {
+		if k == "hash" || k == "hash_prev" || k == "hash_chain_index" || k == "merkle_batch" {
 			continue
 		}
 		// Recursively copy the value
@@ -206,3 +281,166 @@ func encodeSorted(buf *bytes.Buffer, v interface{}) error {
 		return nil
 	}
 }
+
+// encodeJCS encodes a value per RFC 8785 (JSON Canonicalization Scheme)
+//
+// The rules are:
+//   - Object keys sorted by their UTF-16 code units, recursively
+//   - No whitespace
+//   - Strings escape only '"', '\\' and control characters, using the short
+//     forms \b \t \n \f \r where they exist and \u00xx (lowercase) otherwise
+//   - Numbers serialized as IEEE 754 doubles the way ECMAScript does (see jcsNumber)
+//
+// Args:
+//   - buf: Buffer to write JSON to
+//   - v: Value to encode (as decoded by encoding/json)
+//
+// Returns:
+//   - Error for values JSON can't represent (NaN, infinities, unknown types)
+func encodeJCS(buf *bytes.Buffer, v interface{}) error {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJCSString(buf, k)
+			buf.WriteByte(':')
+			if err := encodeJCS(buf, t[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, elem := range t {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJCS(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case string:
+		writeJCSString(buf, t)
+	case float64:
+		n, err := jcsNumber(t)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(n)
+	case int:
+		return encodeJCS(buf, float64(t))
+	case bool:
+		buf.WriteString(strconv.FormatBool(t))
+	case nil:
+		buf.WriteString("null")
+	default:
+		return fmt.Errorf("jcs: unsupported value of type %T", v)
+	}
+	return nil
+}
+
+// lessUTF16 orders strings by their UTF-16 code units, as RFC 8785 requires
+// (this differs from byte order for characters outside the Basic Multilingual Plane)
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// writeJCSString writes a JSON string with the minimal escaping of RFC 8785
+func writeJCSString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			if r < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[r>>4])
+				buf.WriteByte(hex[r&0xf])
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// jcsNumber serializes a number the way ECMAScript's Number.prototype.toString
+// does (RFC 8785 section 3.2.2.3): the shortest digits that round-trip, in plain
+// notation from 1e-6 up to 1e21 and in exponent notation otherwise.
+func jcsNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("jcs: %v is not a valid JSON number", f)
+	}
+	if f == 0 {
+		return "0", nil // Also for -0
+	}
+	sign := ""
+	if f < 0 {
+		sign, f = "-", -f
+	}
+
+	// Shortest round-trip digits d1.d2d3...e±x; the value is 0.d1d2d3... * 10^n
+	sci := strconv.FormatFloat(f, 'e', -1, 64)
+	mant, exp, _ := strings.Cut(sci, "e")
+	digits := strings.Replace(mant, ".", "", 1)
+	e, err := strconv.Atoi(exp)
+	if err != nil {
+		return "", fmt.Errorf("jcs: format %v: %w", f, err)
+	}
+	k, n := len(digits), e+1
+
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k), nil
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:], nil
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits, nil
+	}
+	out := digits[:1]
+	if k > 1 {
+		out += "." + digits[1:]
+	}
+	expSign := "+"
+	if n-1 < 0 {
+		expSign = "-"
+	}
+	return sign + out + "e" + expSign + strconv.Itoa(abs(n-1)), nil
+}
+
+// abs returns the absolute value of an int
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -21,7 +21,8 @@ import (
 // Hash Chain Algorithm:
 // 1. Start with a zero hash (or previous chain head)
 // 2. For each event:
-//   - Canonicalize the event (remove hash fields, sort keys, normalize timestamps)
+//   - Record the scheme in the event: hash_alg, canon_version (hashed with the event)
+//   - Canonicalize the event with the chain's scheme (see Canonicalize and ChainState.CanonVersion)
 //   - Compute: hash = SHA256(previous_hash + "|" + canonicalized_event)
 //   - Augment event with: hash_prev, hash, hash_chain_index
 //   - Write augmented event to output
@@ -36,6 +37,17 @@ import (
 //   - Number of events processed
 //   - Error if any step fails
 func ComputeChain(input io.Reader, output io.Writer, state *ChainState) (*ChainState, int, error) {
+	return ComputeChainWithCheckpoints(input, output, state, nil)
+}
+
+// ComputeChainWithCheckpoints is ComputeChain with periodic checkpoints.
+//
+// After each event the checkpointer is asked whether a checkpoint is due (every
+// N events or every T duration); if so, the events written so far are flushed
+// and a signed checkpoint for the current head is written before streaming
+// continues. A nil checkpointer writes no checkpoints. File-end checkpoints are
+// left to the caller, which knows whether the run completed.
+func ComputeChainWithCheckpoints(input io.Reader, output io.Writer, state *ChainState, cp *Checkpointer) (*ChainState, int, error) {
 	log := logger.L()
 
 	// Initialize state if not provided (start of new chain)
@@ -45,7 +57,7 @@ func ComputeChain(input io.Reader, output io.Writer, state *ChainState) (*ChainS
 	}
 
 	start := time.Now()
-	log.Debugw("verify.compute: start", "start_index", state.LastChainIndex)
+	log.Debugw("verify.compute: start", "start_index", state.LastChainIndex, "canon_version", state.scheme())
 
 	// Set up buffered I/O for efficient processing
 	scanner := bufio.NewScanner(input)
@@ -56,6 +68,7 @@ func ComputeChain(input io.Reader, output io.Writer, state *ChainState) (*ChainS
 	head := state.LastHeadHash    // Previous event's hash (or zero hash for new chain)
 	index := state.LastChainIndex // Last processed event index
 	processed := 0                // Counter for events processed in this run
+	version := state.scheme()     // canon_version of the events written
 
 	// Process each event in the input stream
 	for scanner.Scan() {
@@ -67,24 +80,13 @@ func ComputeChain(input io.Reader, output io.Writer, state *ChainState) (*ChainS
 			return nil, processed, fmt.Errorf("decode event: %w", err)
 		}
 
-		// Canonicalize event for consistent hashing
-		// This removes hash fields, sorts keys, and normalizes timestamps
-		canon, err := Canonicalize(evt)
+		// Hash the event onto the chain and augment it with the chain fields
+		index++
+		newHead, err := hashEvent(evt, head, index, version)
 		if err != nil {
-			return nil, processed, fmt.Errorf("canonicalize: %w", err)
+			return nil, processed, err
 		}
 
-		// Compute hash chain: SHA256(previous_hash + "|" + canonicalized_event)
-		// The "|" separator prevents hash collision attacks
-		h := sha256.Sum256([]byte(head + "|" + canon))
-		newHead := hex.EncodeToString(h[:])
-
-		// Augment event with hash chain metadata
-		evt["hash_prev"] = head         // Previous event's hash
-		evt["hash"] = newHead           // This event's hash
-		index++                         // Increment chain index
-		evt["hash_chain_index"] = index // Position in the chain
-
 		// Serialize and write augmented event
 		out, err := json.Marshal(evt)
 		if err != nil {
@@ -97,6 +99,17 @@ func ComputeChain(input io.Reader, output io.Writer, state *ChainState) (*ChainS
 		// Update chain state for next iteration
 		head = newHead
 		processed++
+
+		// Periodic checkpoint: flush first so the checkpoint never refers to
+		// events that are still buffered
+		if cp != nil && cp.Due(index) {
+			if err := writer.Flush(); err != nil {
+				return nil, processed, fmt.Errorf("flush output: %w", err)
+			}
+			if err := cp.Write(index, head); err != nil {
+				return nil, processed, fmt.Errorf("periodic checkpoint at index %d: %w", index, err)
+			}
+		}
 	}
 	// Check for scanner errors (e.g., truncated input)
 	if err := scanner.Err(); err != nil {
@@ -106,7 +119,40 @@ func ComputeChain(input io.Reader, output io.Writer, state *ChainState) (*ChainS
 	log.Infow("verify.compute: done", "events", processed, "end_index", index, "duration", time.Since(start))
 
 	// Return updated state for potential continuation
-	return &ChainState{LastChainIndex: index, LastHeadHash: head}, processed, nil
+	return &ChainState{LastChainIndex: index, LastHeadHash: head, CanonVersion: state.CanonVersion}, processed, nil
+}
+
+// hashEvent hashes one event onto a linear chain and augments it in place.
+//
+// The scheme (hash_alg, canon_version) is recorded first because it is part of
+// the canonical form; then hash = SHA256(previous_hash + "|" + canonicalized_event)
+// and the chain fields hash_prev, hash and hash_chain_index are set.
+//
+// Returns:
+//   - The event's hash (the new chain head)
+//   - Error if canonicalization fails
+func hashEvent(evt map[string]interface{}, head string, index, version int) (string, error) {
+	// Record the hashing scheme; it is part of the canonical form
+	evt["hash_alg"] = HashAlgSHA256
+	evt["canon_version"] = version
+
+	// Canonicalize event for consistent hashing
+	// This removes hash fields, sorts keys, and (legacy scheme) normalizes timestamps
+	canon, err := Canonicalize(evt)
+	if err != nil {
+		return "", fmt.Errorf("canonicalize: %w", err)
+	}
+
+	// Compute hash chain: SHA256(previous_hash + "|" + canonicalized_event)
+	// The "|" separator prevents hash collision attacks
+	h := sha256.Sum256([]byte(head + "|" + canon))
+	newHead := hex.EncodeToString(h[:])
+
+	// Augment event with hash chain metadata
+	evt["hash_prev"] = head         // Previous event's hash
+	evt["hash"] = newHead           // This event's hash
+	evt["hash_chain_index"] = index // Position in the chain
+	return newHead, nil
 }
 
 // VerifyChain validates a hashed NDJSON file, returning tampered indices and final head.
@@ -131,15 +177,148 @@ func ComputeChain(input io.Reader, output io.Writer, state *ChainState) (*ChainS
 //   - Total number of events processed
 //   - Error if verification fails
 func VerifyChain(input io.Reader) ([]int, string, int, error) {
+	tampered, head, processed, _, _, _, err := VerifyChainHeads(input, nil)
+	return tampered, head, processed, err
+}
+
+// VerifyChainHeads is VerifyChain that also returns the recomputed head at each
+// wanted chain index and the first and last hash_chain_index seen, so a file can
+// be checked against several checkpoints in one pass.
+func VerifyChainHeads(input io.Reader, wantHeads map[int]bool) (tampered []int, head string, processed int, heads map[int]string, first, last int, err error) {
+	check, err := CheckChain(input, wantHeads, "")
+	return check.Tampered, check.Head, check.Processed, check.Heads, check.First, check.Last, err
+}
+
+// ChainCheck is the full result of CheckChain.
+//
+// Head, Heads, First and Last describe the file's chain; for a file holding
+// several named streams they describe the stream of its first event, and
+// Streams has every stream.
+//
+// Fields:
+//   - Tampered: Indices of events that failed verification (all streams)
+//   - Head: Final head hash of the chain
+//   - Processed: Number of events read
+//   - Heads: Recomputed heads at the wanted chain indices
+//   - First / Last: First and last hash_chain_index seen
+//   - Streams: Result per stream, keyed by hash_stream ("" for the unnamed chain)
+//   - Findings: Classification of the failures (see TamperFinding)
+//   - Size: Bytes read
+type ChainCheck struct {
+	Tampered  []int
+	Head      string
+	Processed int
+	Heads     map[int]string
+	First     int
+	Last      int
+	Streams   map[string]*StreamCheck
+	Findings  []TamperFinding
+	Size      int64
+}
+
+// StreamCheck is the verification result of one chain in a file.
+//
+// Fields:
+//   - Tampered: Indices of the stream's events that failed verification
+//   - Head: Final head hash of the stream
+//   - Processed: Number of the stream's events read
+//   - Heads: Recomputed heads at the wanted chain indices
+//   - First / Last: First and last hash_chain_index of the stream
+type StreamCheck struct {
+	Tampered  []int
+	Head      string
+	Processed int
+	Heads     map[int]string
+	First     int
+	Last      int
+}
+
+// chainCursor is CheckChain's position in one stream
+type chainCursor struct {
+	check   *StreamCheck
+	rolling string // Recomputed head, independent of the stored hashes
+	mv      *merkleVerifier
+	diag    *chainDiagnoser
+
+	// Merkle mode: batch being read, diagnoser changes at its start and
+	// whether the diagnoser recorded anything in the previous batch
+	batch, batchMark int
+	prevChanged      bool
+}
+
+// CheckChain verifies a hashed NDJSON stream and classifies any failures.
+//
+// The recomputed head is an independent rolling chain: it starts from the first
+// event's hash_prev and chains the recomputed hashes only, ignoring the stored
+// ones. A modified event therefore changes every recomputed head from its index
+// on, even where the stored hashes were left untouched, so a checkpoint taken
+// after the modification no longer matches.
+//
+// Files hashed in Merkle mode (events carry merkle_batch, see ComputeMerkleChain)
+// are verified batch by batch: leaf hashes and batch links are checked, and heads
+// are recomputed at batch ends, where Merkle mode checkpoints are taken.
+//
+// Events of named streams (hash_stream, see StreamHasher) are verified per
+// stream, each chain independently of the others. When a stream is expected,
+// events of any other stream are reported as spliced.
+//
+// Alongside the flat list of tampered indices, every failure is classified as a
+// modified, deleted, inserted, reordered or spliced event with the byte offset
+// of the line concerned (see chainDiagnoser). Truncation can only be told from
+// a checkpoint and is left to the caller (see TruncationFinding).
+//
+// Args:
+//   - input: NDJSON stream of events with hash chain metadata
+//   - wantHeads: Chain indices to record recomputed heads at (nil for none)
+//   - stream: Stream every event must belong to (empty to accept any)
+//
+// Returns:
+//   - The check result (never nil; partial on error)
+//   - Error if an event can't be read
+func CheckChain(input io.Reader, wantHeads map[int]bool, stream string) (*ChainCheck, error) {
+	return CheckChainFrom(input, wantHeads, stream, nil)
+}
+
+// CheckChainFrom is CheckChain for input that continues earlier chains: the
+// first event of each stream must link to its head in startHeads instead of the
+// zero hash. Streams missing from startHeads continue from their first event's
+// hash_prev, unchecked; with nil startHeads every stream starts from the zero
+// hash, as in CheckChain.
+//
+// Args:
+//   - input / wantHeads / stream: As for CheckChain
+//   - startHeads: Head each stream continues from, keyed by hash_stream
+//
+// Returns:
+//   - The check result (never nil; partial on error)
+//   - Error if an event can't be read
+func CheckChainFrom(input io.Reader, wantHeads map[int]bool, stream string, startHeads map[string]string) (*ChainCheck, error) {
 	log := logger.L()
 	start := time.Now()
-	log.Debugw("verify.check: start")
+	log.Debugw("verify.check: start", "stream", stream)
+
+	// Track the byte offset of each line for the findings
+	var offset, next int64
 	scanner := bufio.NewScanner(input)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if token != nil {
+			offset = next
+		}
+		next += int64(advance)
+		return advance, token, err
+	})
 
 	// Initialize verification state
-	tampered := make([]int, 0) // Track indices of tampered events
-	head := zeroHash()         // Start with zero hash (first event should have this as hash_prev)
-	processed := 0             // Count of events processed
+	c := &ChainCheck{
+		Tampered: make([]int, 0), // Track indices of tampered events
+		Head:     zeroHash(),     // Start with zero hash (first event should have this as hash_prev)
+		Heads:    make(map[int]string),
+		Streams:  make(map[string]*StreamCheck),
+	}
+	cursors := make(map[string]*chainCursor)
+	var order []string // Streams in order of first appearance
+	var spliced []TamperFinding
 
 	// Verify each event in the chain
 	for scanner.Scan() {
@@ -148,37 +327,125 @@ func VerifyChain(input io.Reader) ([]int, string, int, error) {
 
 		// Parse JSON event
 		if err := json.Unmarshal(line, &evt); err != nil {
-			return tampered, head, processed, fmt.Errorf("decode event: %w", err)
+			return c, fmt.Errorf("decode event at byte offset %d: %w", offset, err)
 		}
 
 		// Extract hash chain metadata from the event
-		prev, _ := evt["hash_prev"].(string)             // Previous event's hash
-		got, _ := evt["hash"].(string)                   // This event's stored hash
-		idxFloat, _ := evt["hash_chain_index"].(float64) // Chain index (JSON numbers are float64)
-		idx := int(idxFloat)                             // Convert to int
+		prev, _ := evt["hash_prev"].(string)                    // Previous event's hash
+		got, _ := evt["hash"].(string)                          // This event's stored hash
+		idxFloat, hasIndex := evt["hash_chain_index"].(float64) // Chain index (JSON numbers are float64)
+		idx := int(idxFloat)                                    // Convert to int
+		name, _ := evt["hash_stream"].(string)                  // Named stream ("" for the unnamed chain)
+		c.Processed++
+
+		// An event of another stream was spliced into this one
+		if stream != "" && name != stream {
+			c.Tampered = append(c.Tampered, idx)
+			spliced = append(spliced, TamperFinding{Type: FindingSpliced, Stream: name, ChainIndex: idx, Offset: offset,
+				Detail: fmt.Sprintf("event of stream %q in stream %q", name, stream)})
+			continue
+		}
 
 		// Recompute hash using the same algorithm as during chain creation
 		canon, err := Canonicalize(evt)
 		if err != nil {
-			return tampered, head, processed, fmt.Errorf("canonicalize: %w", err)
+			return c, fmt.Errorf("canonicalize: %w", err)
+		}
+
+		// Each stream is its own chain; the first event decides how it was hashed
+		cur, ok := cursors[name]
+		if !ok {
+			head, ok := startHeads[name]
+			if !ok {
+				head = zeroHash()
+				if startHeads != nil {
+					head = prev
+				}
+			}
+			cur = &chainCursor{
+				check: &StreamCheck{Tampered: make([]int, 0), Head: head, Heads: make(map[int]string), First: idx},
+				diag:  newChainDiagnoser(name),
+			}
+			if isMerkleEvent(evt) {
+				cur.mv = newMerkleVerifier(wantHeads, cur.check.Heads, prev)
+				cur.mv.head = head
+			}
+			cursors[name] = cur
+			c.Streams[name] = cur.check
+			order = append(order, name)
+		}
+		sc := cur.check
+
+		if cur.mv != nil {
+			if batch, ok := evt["merkle_batch"].(float64); ok {
+				if int(batch) != cur.batch {
+					cur.prevChanged = cur.diag.changes() != cur.batchMark
+					cur.batch, cur.batchMark = int(batch), cur.diag.changes()
+				}
+				hashOK, linkOK := cur.mv.add(idx, int(batch), prev, got, canon)
+				// Findings in the previous batch already explain a broken batch link
+				diagLink := linkOK || cur.prevChanged
+				cur.diag.observe(idx, hasIndex, prev, got, hashOK, &diagLink, offset)
+			} else {
+				cur.mv.tampered = append(cur.mv.tampered, idx)
+				cur.diag.observe(idx, false, prev, got, false, nil, offset)
+			}
+			sc.Last = idx
+			sc.Processed++
+			continue
 		}
+
 		calc := sha256.Sum256([]byte(prev + "|" + canon))
 		want := hex.EncodeToString(calc[:])
 
 		// Check for tampering: hash mismatch or broken chain
-		if prev != head || want != got {
-			tampered = append(tampered, idx)
+		if prev != sc.Head || want != got {
+			sc.Tampered = append(sc.Tampered, idx)
+			c.Tampered = append(c.Tampered, idx)
+		}
+		cur.diag.observe(idx, hasIndex, prev, got, want == got, nil, offset)
+
+		// Track the index range and the recomputed heads callers asked for
+		if sc.Processed == 0 {
+			cur.rolling = prev
+		}
+		sc.Last = idx
+		if len(wantHeads) > 0 {
+			sum := sha256.Sum256([]byte(cur.rolling + "|" + canon))
+			cur.rolling = hex.EncodeToString(sum[:])
+			if wantHeads[idx] {
+				sc.Heads[idx] = cur.rolling
+			}
 		}
 
 		// Update head for next iteration
-		head = got
-		processed++
+		sc.Head = got
+		sc.Processed++
 	}
+	c.Size = next
 	// Check for scanner errors (e.g., truncated input)
 	if err := scanner.Err(); err != nil {
-		return tampered, head, processed, fmt.Errorf("scan input: %w", err)
+		return c, fmt.Errorf("scan input: %w", err)
+	}
+
+	// Close the streams and merge their findings in file order
+	c.Findings = spliced
+	for _, name := range order {
+		cur := cursors[name]
+		if cur.mv != nil {
+			cur.mv.closeBatch()
+			cur.check.Tampered = append(cur.check.Tampered, cur.mv.tampered...)
+			c.Tampered = append(c.Tampered, cur.mv.tampered...)
+			cur.check.Head = cur.mv.head
+		}
+		c.Findings = append(c.Findings, cur.diag.finish()...)
+	}
+	sortFindings(c.Findings)
+	if len(order) > 0 {
+		first := c.Streams[order[0]]
+		c.Head, c.Heads, c.First, c.Last = first.Head, first.Heads, first.First, first.Last
 	}
 
-	log.Infow("verify.check: done", "events", processed, "tampered", len(tampered), "duration", time.Since(start))
-	return tampered, head, processed, nil
+	log.Infow("verify.check: done", "events", c.Processed, "streams", len(order), "tampered", len(c.Tampered), "findings", len(c.Findings), "duration", time.Since(start))
+	return c, nil
 }
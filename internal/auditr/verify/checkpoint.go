@@ -1,21 +1,44 @@
 package verify
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/sha256"
-	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 )
 
-// WriteCheckpoint creates and signs a checkpoint JSON; returns path written
+// WriteCheckpoint creates and signs a checkpoint JSON with a private key file;
+// returns path written
+//
+// The key type selects the algorithm (see LoadFileSigner). It is a convenience
+// wrapper around WriteCheckpointWithSigner.
+//
+// Args:
+//   - dir: Directory where checkpoint file will be written
+//   - index: Chain index at the time of checkpoint
+//   - headHash: Hash of the last event in the chain
+//   - privateKeyPath: Path to the PEM private key (ECDSA P-256, Ed25519 or RSA)
+//
+// Returns:
+//   - Path to the created checkpoint file
+//   - Error if checkpoint creation fails
+func WriteCheckpoint(dir string, index int, headHash string, privateKeyPath string) (string, error) {
+	signer, err := LoadFileSigner(privateKeyPath)
+	if err != nil {
+		return "", err
+	}
+	return WriteCheckpointWithSigner(dir, index, headHash, signer)
+}
+
+// WriteCheckpointWithSigner creates and signs a checkpoint JSON without a
+// timestamp token; returns path written (see WriteTimestampedCheckpoint)
+func WriteCheckpointWithSigner(dir string, index int, headHash string, signer Signer) (string, error) {
+	return WriteTimestampedCheckpoint(dir, index, headHash, signer, nil)
+}
+
+// WriteTimestampedCheckpoint creates and signs a checkpoint JSON; returns path written
 //
 // A checkpoint is a cryptographically signed snapshot of the hash chain state at a
 // specific point in time. It provides tamper-evident evidence of the chain's integrity
@@ -26,18 +49,31 @@ import (
 // - Chain index: Position in the hash chain
 // - Head hash: The hash of the last event in the chain
 // - Created timestamp: When the checkpoint was created
-// - Digital signature: ECDSA signature of the canonicalized checkpoint data
+// - Algorithm and key ID of the signer (covered by the signature)
+// - Digital signature: Signature of the canonicalized checkpoint data
+// - Timestamp token (when ts is set): RFC 3161 token vouching for the creation time
+//
+// The checkpoint is also appended to the directory's manifest (manifest.json),
+// which indexes all checkpoints by chain index.
 //
 // Args:
 //   - dir: Directory where checkpoint file will be written
 //   - index: Chain index at the time of checkpoint
 //   - headHash: Hash of the last event in the chain
-//   - privateKeyPath: Path to ECDSA private key for signing
+//   - signer: Signer for the checkpoint (key file or external signer)
+//   - ts: Time-stamping authority client (nil for no timestamp token)
 //
 // Returns:
 //   - Path to the created checkpoint file
-//   - Error if checkpoint creation fails
-func WriteCheckpoint(dir string, index int, headHash string, privateKeyPath string) (string, error) {
+//   - Error if checkpoint creation or timestamping fails
+func WriteTimestampedCheckpoint(dir string, index int, headHash string, signer Signer, ts Timestamper) (string, error) {
+	return WriteStreamCheckpoint(dir, "", index, headHash, signer, ts)
+}
+
+// WriteStreamCheckpoint is WriteTimestampedCheckpoint for a named chain (see
+// StreamHasher). The stream name is signed with the checkpoint, so a checkpoint
+// of one stream can't vouch for another; an empty name is the unnamed chain.
+func WriteStreamCheckpoint(dir, stream string, index int, headHash string, signer Signer, ts Timestamper) (string, error) {
 	// Validate required parameters
 	if dir == "" {
 		return "", fmt.Errorf("checkpoint dir required")
@@ -49,7 +85,14 @@ func WriteCheckpoint(dir string, index int, headHash string, privateKeyPath stri
 	}
 
 	// Create checkpoint data structure
-	cp := Checkpoint{ChainIndex: index, HeadHash: headHash, CreatedAt: time.Now().UTC()}
+	cp := Checkpoint{
+		ChainIndex: index,
+		HeadHash:   headHash,
+		CreatedAt:  time.Now().UTC(),
+		Algorithm:  signer.Algorithm(),
+		KeyID:      signer.KeyID(),
+		Stream:     stream,
+	}
 
 	// Canonicalize checkpoint for consistent signing
 	// This ensures the same data always produces the same signature
@@ -58,8 +101,8 @@ func WriteCheckpoint(dir string, index int, headHash string, privateKeyPath stri
 		return "", err
 	}
 
-	// Sign the canonicalized checkpoint data using ECDSA
-	sig, err := signMessageECDSA(privateKeyPath, []byte(canon))
+	// Sign the canonicalized checkpoint data
+	sig, err := signer.Sign([]byte(canon))
 	if err != nil {
 		return "", err
 	}
@@ -67,6 +110,15 @@ func WriteCheckpoint(dir string, index int, headHash string, privateKeyPath stri
 	// Create signed checkpoint with base64-encoded signature
 	sc := SignedCheckpoint{Checkpoint: cp, Signature: base64.StdEncoding.EncodeToString(sig)}
 
+	// Have a TSA vouch for the checkpoint's time
+	if ts != nil {
+		token, err := ts.Timestamp(checkpointImprint(canon))
+		if err != nil {
+			return "", fmt.Errorf("timestamp checkpoint: %w", err)
+		}
+		sc.TimestampToken = base64.StdEncoding.EncodeToString(token)
+	}
+
 	// Serialize to JSON
 	b, err := json.Marshal(sc)
 	if err != nil {
@@ -81,6 +133,11 @@ func WriteCheckpoint(dir string, index int, headHash string, privateKeyPath stri
 	if err := os.WriteFile(path, b, 0644); err != nil {
 		return "", fmt.Errorf("write checkpoint: %w", err)
 	}
+
+	// Record the checkpoint in the directory's manifest
+	if err := appendManifest(dir, ManifestEntry{File: name, ChainIndex: index, HeadHash: headHash, CreatedAt: cp.CreatedAt, Stream: stream}); err != nil {
+		return "", err
+	}
 	return path, nil
 }
 
@@ -93,7 +150,7 @@ func WriteCheckpoint(dir string, index int, headHash string, privateKeyPath stri
 //
 // Args:
 //   - path: Path to the checkpoint file to verify
-//   - publicKeyPath: Path to the ECDSA public key for signature verification
+//   - publicKeyPath: Path to the PEM public key or keyring (see ResolveVerifier)
 //   - expectedHeadHash: The head hash that should be in the checkpoint
 //
 // Returns:
@@ -101,22 +158,43 @@ func WriteCheckpoint(dir string, index int, headHash string, privateKeyPath stri
 //   - false if checkpoint is invalid or head hash doesn't match
 //   - error if verification process fails
 func VerifyCheckpoint(path, publicKeyPath, expectedHeadHash string) (bool, error) {
-	// Read checkpoint file
-	b, err := os.ReadFile(path)
+	sc, err := LoadCheckpoint(path)
 	if err != nil {
-		return false, fmt.Errorf("read checkpoint: %w", err)
+		return false, err
 	}
 
-	// Parse JSON checkpoint
-	var sc SignedCheckpoint
-	if err := json.Unmarshal(b, &sc); err != nil {
-		return false, fmt.Errorf("unmarshal checkpoint: %w", err)
+	// Resolve the key first so a bad key (or keyring) is reported even on a head mismatch
+	v, err := ResolveVerifier(publicKeyPath, sc.Checkpoint)
+	if err != nil {
+		return false, err
 	}
 
 	// Verify head hash matches expected value
 	if sc.Checkpoint.HeadHash != expectedHeadHash {
 		return false, nil
 	}
+	return VerifySignedCheckpoint(sc, v)
+}
+
+// VerifySignedCheckpoint verifies the signature of a loaded checkpoint.
+//
+// The algorithm recorded in the checkpoint (ecdsa-p256-sha256 when absent) must
+// match the verifier's, so a signature is never checked under a different
+// algorithm than the one it was made with. A timestamp token, if present, is
+// checked for integrity (see CheckpointTimestamp); trust in the TSA is checked
+// separately by VerifyCheckpointTimestamp.
+//
+// Args:
+//   - sc: Signed checkpoint
+//   - v: Verifier for the signing key
+//
+// Returns:
+//   - true if the signature is valid
+//   - error if the algorithms differ or the signature can't be decoded
+func VerifySignedCheckpoint(sc *SignedCheckpoint, v Verifier) (bool, error) {
+	if alg := sc.Checkpoint.SignatureAlgorithm(); alg != v.Algorithm() {
+		return false, fmt.Errorf("checkpoint signed with %s, public key is %s", alg, v.Algorithm())
+	}
 
 	// Canonicalize checkpoint data for signature verification
 	canon, err := canonicalizeCheckpoint(sc.Checkpoint)
@@ -129,10 +207,34 @@ func VerifyCheckpoint(path, publicKeyPath, expectedHeadHash string) (bool, error
 	if err != nil {
 		return false, fmt.Errorf("decode signature: %w", err)
 	}
+	ok, err := v.Verify([]byte(canon), sig)
+	if err != nil || !ok {
+		return ok, err
+	}
 
-	// Verify ECDSA signature
-	ok, err := verifyMessageECDSA(publicKeyPath, []byte(canon), sig)
-	return ok, err
+	// A timestamp token, when present, must cover this checkpoint and agree with its time
+	if sc.TimestampToken != "" {
+		if _, err := CheckpointTimestamp(sc, ""); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// LoadCheckpoint reads a signed checkpoint file without verifying it.
+//
+// This is used by reporting to show checkpoint evidence (chain index, head hash,
+// creation time) next to the result of VerifyCheckpoint.
+func LoadCheckpoint(path string) (*SignedCheckpoint, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+	var sc SignedCheckpoint
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return nil, fmt.Errorf("unmarshal checkpoint: %w", err)
+	}
+	return &sc, nil
 }
 
 // canonicalizeCheckpoint creates a deterministic JSON representation of a checkpoint
@@ -149,12 +251,23 @@ func VerifyCheckpoint(path, publicKeyPath, expectedHeadHash string) (bool, error
 //   - Error if canonicalization fails
 func canonicalizeCheckpoint(cp Checkpoint) (string, error) {
 	// Create map with deterministic field order for consistent JSON output
-	// Order: chain_index, head_hash, created_at (RFC3339 UTC)
+	// Keys are sorted by json.Marshal; created_at is RFC3339 UTC
 	m := map[string]interface{}{
 		"chain_index": cp.ChainIndex,
 		"head_hash":   cp.HeadHash,
 		"created_at":  cp.CreatedAt.UTC().Format(time.RFC3339),
 	}
+	// Algorithm, key ID and stream are signed when present; checkpoints written before
+	// they were recorded canonicalize as before
+	if cp.Algorithm != "" {
+		m["algorithm"] = cp.Algorithm
+	}
+	if cp.KeyID != "" {
+		m["key_id"] = cp.KeyID
+	}
+	if cp.Stream != "" {
+		m["stream"] = cp.Stream
+	}
 
 	// Marshal to JSON (Go's json.Marshal produces deterministic output for maps)
 	b, err := json.Marshal(m)
@@ -163,140 +276,3 @@ func canonicalizeCheckpoint(cp Checkpoint) (string, error) {
 	}
 	return string(b), nil
 }
-
-// signMessageECDSA signs a message using an ECDSA private key
-//
-// This function loads an ECDSA private key from a PEM file and uses it to sign
-// the provided message. The signature is created using SHA-256 hashing and
-// ECDSA with P-256 curve.
-//
-// Supported private key formats:
-// - EC PRIVATE KEY (PKCS#1 format)
-// - PRIVATE KEY (PKCS#8 format)
-//
-// Args:
-//   - privateKeyPath: Path to PEM-encoded ECDSA private key file
-//   - msg: Message bytes to sign
-//
-// Returns:
-//   - ASN.1 DER-encoded signature
-//   - Error if signing fails
-func signMessageECDSA(privateKeyPath string, msg []byte) ([]byte, error) {
-	// Read private key file
-	keyBytes, err := os.ReadFile(privateKeyPath)
-	if err != nil {
-		return nil, fmt.Errorf("read private key: %w", err)
-	}
-
-	// Decode PEM format
-	block, _ := pem.Decode(keyBytes)
-	if block == nil {
-		return nil, fmt.Errorf("invalid PEM for private key")
-	}
-
-	// Parse private key based on PEM block type
-	var pk *ecdsa.PrivateKey
-	if block.Type == "EC PRIVATE KEY" {
-		// PKCS#1 format (legacy)
-		pk, err = x509.ParseECPrivateKey(block.Bytes)
-	} else {
-		// Try PKCS#8 format (modern)
-		var key any
-		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
-		if err == nil {
-			var ok bool
-			pk, ok = key.(*ecdsa.PrivateKey)
-			if !ok {
-				return nil, fmt.Errorf("not an ECDSA private key")
-			}
-		}
-	}
-	if err != nil {
-		return nil, fmt.Errorf("parse private key: %w", err)
-	}
-
-	// Validate curve (only P-256 is supported)
-	if pk.Curve != elliptic.P256() {
-		return nil, fmt.Errorf("unsupported curve: want P-256")
-	}
-
-	// Hash message with SHA-256
-	sum := sha256.Sum256(msg)
-
-	// Sign using ECDSA with ASN.1 DER encoding
-	return ecdsa.SignASN1(rand.Reader, pk, sum[:])
-}
-
-// verifyMessageECDSA verifies an ECDSA signature using a public key
-//
-// This function loads an ECDSA public key from a PEM file and uses it to verify
-// a signature against a message. The verification uses SHA-256 hashing and
-// ECDSA with P-256 curve.
-//
-// Supported public key formats:
-// - PUBLIC KEY (PKIX format)
-// - EC PUBLIC KEY (PKIX format for EC keys)
-//
-// Args:
-//   - publicKeyPath: Path to PEM-encoded ECDSA public key file
-//   - msg: Original message that was signed
-//   - sig: ASN.1 DER-encoded signature to verify
-//
-// Returns:
-//   - true if signature is valid
-//   - false if signature is invalid
-//   - error if verification process fails
-func verifyMessageECDSA(publicKeyPath string, msg []byte, sig []byte) (bool, error) {
-	// Read public key file
-	keyBytes, err := os.ReadFile(publicKeyPath)
-	if err != nil {
-		return false, fmt.Errorf("read public key: %w", err)
-	}
-
-	// Decode PEM format
-	block, _ := pem.Decode(keyBytes)
-	if block == nil {
-		return false, fmt.Errorf("invalid PEM for public key")
-	}
-
-	// Parse public key based on PEM block type
-	var pub *ecdsa.PublicKey
-	switch block.Type {
-	case "PUBLIC KEY":
-		// PKIX format (standard)
-		key, err := x509.ParsePKIXPublicKey(block.Bytes)
-		if err != nil {
-			return false, fmt.Errorf("parse public key: %w", err)
-		}
-		var ok bool
-		pub, ok = key.(*ecdsa.PublicKey)
-		if !ok {
-			return false, fmt.Errorf("not an ECDSA public key")
-		}
-	case "EC PUBLIC KEY":
-		// PKIX format specifically for EC keys
-		k, err := x509.ParsePKIXPublicKey(block.Bytes)
-		if err != nil {
-			return false, fmt.Errorf("parse EC public key: %w", err)
-		}
-		var ok bool
-		pub, ok = k.(*ecdsa.PublicKey)
-		if !ok {
-			return false, fmt.Errorf("not an ECDSA public key")
-		}
-	default:
-		return false, fmt.Errorf("unsupported public key type: %s", block.Type)
-	}
-
-	// Validate curve (only P-256 is supported)
-	if pub.Curve != elliptic.P256() {
-		return false, fmt.Errorf("unsupported curve: want P-256")
-	}
-
-	// Hash message with SHA-256 (same as during signing)
-	sum := sha256.Sum256(msg)
-
-	// Verify signature using ECDSA
-	ok := ecdsa.VerifyASN1(pub, sum[:], sig)
-	return ok, nil
-}
@@ -0,0 +1,197 @@
+package verify
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CheckpointDirResult is the outcome of checking a hashed file against every
+// signed checkpoint in a checkpoint directory.
+//
+// Fields:
+//   - Results: One result per checkpoint within the file's chain index range, ordered by chain index
+//   - Unreadable: Checkpoint files that could not be loaded (no chain index is known for them)
+//   - Skipped: Number of checkpoints outside the file's chain index range
+//   - Truncations: Truncated findings of streams whose tail is missing from the file
+//   - LastGood: Last verified checkpoint before the first divergence (nil if none)
+//   - Divergence: Chain index of the first divergence (0 if nothing diverged)
+type CheckpointDirResult struct {
+	Results     []CheckpointResult
+	Unreadable  []CheckpointResult
+	Skipped     int
+	Truncations []TamperFinding
+	LastGood    *CheckpointResult
+	Divergence  int
+}
+
+// Failed returns the checkpoints that did not verify, unreadable ones included.
+func (d *CheckpointDirResult) Failed() []CheckpointResult {
+	var failed []CheckpointResult
+	for _, r := range d.Results {
+		if !r.OK() {
+			failed = append(failed, r)
+		}
+	}
+	return append(failed, d.Unreadable...)
+}
+
+// LastGoodCheckpoint locates the first divergence of a verified file and the last
+// checkpoint that verified before it.
+//
+// The divergence is the chain index of the first checkpoint that did not verify
+// or the first tampered event, whichever comes first. Events up to
+// lastGood.ChainIndex are covered by a verified signature, so the divergence lies
+// within (lastGood.ChainIndex, divergence].
+//
+// Chain indices are only comparable within one chain: for a file holding several
+// named streams, call it with the results and tampered indices of one stream.
+//
+// Args:
+//   - results: Checkpoint results ordered by chain index
+//   - tampered: Tampered chain indices as returned by VerifyChain, in any order
+//
+// Returns:
+//   - Last verified checkpoint before the divergence (nil if none)
+//   - Chain index of the first divergence (0 if nothing diverged)
+func LastGoodCheckpoint(results []CheckpointResult, tampered []int) (lastGood *CheckpointResult, divergence int) {
+	for _, r := range results {
+		if !r.OK() {
+			divergence = r.ChainIndex
+			break
+		}
+	}
+	// Tampered indices come in detection order, which isn't ascending for
+	// reordered input or Merkle leaves
+	for _, index := range tampered {
+		if divergence == 0 || index < divergence {
+			divergence = index
+		}
+	}
+	for i := range results {
+		if divergence > 0 && results[i].ChainIndex >= divergence {
+			break
+		}
+		lastGood = &results[i]
+	}
+	return lastGood, divergence
+}
+
+// VerifyCheckpointDir verifies a hashed file and checks it against every signed
+// checkpoint (checkpoint-*.json) found in a checkpoint directory, including the
+// per-stream directories under streams/ (see StreamDir).
+//
+// Unlike VerifyManifest, the checkpoint files themselves are the source of truth:
+// the directory is scanned rather than the manifest read, so a checkpoint missing
+// from (or altered in) the manifest is still checked. Each checkpoint's HeadHash
+// is compared with the recomputed head at its ChainIndex in its stream (see
+// CheckChain) and its signature is verified. Checkpoints outside their stream's
+// chain index range in the file, or of streams the file doesn't hold, are
+// counted as skipped; those beyond the last event of their stream also report
+// the stream's truncated tail (Truncations), which counts as its divergence.
+//
+// For a file holding several streams, LastGood and Divergence describe the first
+// stream (by name) that diverged.
+//
+// Args:
+//   - input: Path of the hashed NDJSON file
+//   - dir: Checkpoint directory
+//   - publicKeyPath: Public key for checkpoint signatures
+//   - stream: Stream every event must belong to (empty to accept any, see CheckChain)
+//
+// Returns:
+//   - Tampered chain indices, final head hash and events processed (as VerifyChain)
+//   - Per-checkpoint results with the last good checkpoint before the first divergence
+//   - Error if the file or directory can't be read
+func VerifyCheckpointDir(input, dir, publicKeyPath, stream string) ([]int, string, int, *CheckpointDirResult, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "checkpoint-*.json"))
+	if err != nil {
+		return nil, "", 0, nil, fmt.Errorf("list checkpoints: %w", err)
+	}
+	streamPaths, err := filepath.Glob(filepath.Join(dir, StreamsDir, "*", "checkpoint-*.json"))
+	if err != nil {
+		return nil, "", 0, nil, fmt.Errorf("list stream checkpoints: %w", err)
+	}
+	paths = append(paths, streamPaths...)
+
+	// Load every checkpoint up front to know which chain heads to collect
+	res := &CheckpointDirResult{}
+	loaded := make(map[string]*SignedCheckpoint, len(paths))
+	want := make(map[int]bool, len(paths))
+	for _, p := range paths {
+		sc, err := LoadCheckpoint(p)
+		if err != nil {
+			res.Unreadable = append(res.Unreadable, CheckpointResult{Path: p, Error: err.Error()})
+			continue
+		}
+		loaded[p] = sc
+		want[sc.Checkpoint.ChainIndex] = true
+	}
+
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, "", 0, nil, fmt.Errorf("open input: %w", err)
+	}
+	defer f.Close()
+
+	check, err := CheckChain(f, want, stream)
+	if err != nil {
+		return check.Tampered, check.Head, check.Processed, nil, err
+	}
+
+	var beyond []Checkpoint
+	for _, p := range paths {
+		sc, ok := loaded[p]
+		if !ok {
+			continue
+		}
+		s, ok := check.Streams[sc.Checkpoint.Stream]
+		if ok && sc.Checkpoint.ChainIndex > s.Last {
+			beyond = append(beyond, sc.Checkpoint)
+		}
+		if !ok || sc.Checkpoint.ChainIndex < s.First || sc.Checkpoint.ChainIndex > s.Last {
+			res.Skipped++
+			continue
+		}
+		res.Results = append(res.Results, checkCheckpoint(p, sc, check.Streams, publicKeyPath))
+	}
+	// File names sort by creation time; order by stream and chain index for the
+	// divergence walk
+	sort.SliceStable(res.Results, func(i, j int) bool {
+		if res.Results[i].Stream != res.Results[j].Stream {
+			return res.Results[i].Stream < res.Results[j].Stream
+		}
+		return res.Results[i].ChainIndex < res.Results[j].ChainIndex
+	})
+	names := make([]string, 0, len(check.Streams))
+	for name := range check.Streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	res.Truncations = tailTruncations(beyond, check)
+	truncatedAt := make(map[string]int, len(res.Truncations))
+	for _, f := range res.Truncations {
+		truncatedAt[f.Stream] = f.ChainIndex
+	}
+	for _, name := range names {
+		// Results are sorted by stream, so each stream's results are contiguous
+		from := sort.Search(len(res.Results), func(i int) bool { return res.Results[i].Stream >= name })
+		to := from
+		for to < len(res.Results) && res.Results[to].Stream == name {
+			to++
+		}
+		lastGood, divergence := LastGoodCheckpoint(res.Results[from:to], check.Streams[name].Tampered)
+		// A cut-off tail diverges from the checkpoints after the stream's last event
+		if at, ok := truncatedAt[name]; ok && divergence == 0 {
+			divergence = at
+		}
+		if lastGood != nil || divergence > 0 {
+			res.LastGood, res.Divergence = lastGood, divergence
+		}
+		if divergence > 0 {
+			break
+		}
+	}
+	return check.Tampered, check.Head, check.Processed, res, nil
+}
@@ -0,0 +1,298 @@
+package verify
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Tamper finding types reported by CheckChain and RunVerifyPhase
+const (
+	FindingModified  = "modified"  // Payload or stored hash changed, event still in place
+	FindingDeleted   = "deleted"   // Chain indices missing from the file
+	FindingInserted  = "inserted"  // Event that is not part of the chain
+	FindingReordered = "reordered" // Event found after a later chain index
+	FindingTruncated = "truncated" // Events missing at the end of the file, before the checkpoint
+	FindingSpliced   = "spliced"   // Event of another named stream
+)
+
+// diagnoseWindow is how many recent chain indices the diagnoser remembers to
+// check hash_prev links; reorders further apart than this are still classified
+// from the index sequence alone.
+const diagnoseWindow = 4096
+
+// TamperFinding classifies one verification failure.
+//
+// VerifyChain only says which events failed; a finding says what most likely
+// happened to them, so an investigation can start at the right place in the file.
+//
+// Fields:
+//   - Type: One of the Finding* constants
+//   - Stream: Named stream of the event (empty for the unnamed chain)
+//   - ChainIndex: First chain index concerned (0 for an inserted event without chain fields)
+//   - Count: Number of chain indices concerned (deleted and truncated findings)
+//   - Offset: Byte offset in the input of the event concerned; for deleted events
+//     the offset where they are missing, for truncation the end of the file
+//   - Detail: Human-readable explanation
+type TamperFinding struct {
+	Type       string `json:"type"`             // Finding type
+	Stream     string `json:"stream,omitempty"` // Named stream
+	ChainIndex int    `json:"chain_index"`      // First chain index concerned
+	Count      int    `json:"count,omitempty"`  // Indices concerned (deleted / truncated)
+	Offset     int64  `json:"offset"`           // Byte offset in the input
+	Detail     string `json:"detail"`           // Explanation
+}
+
+// String formats the finding for console output
+func (f TamperFinding) String() string {
+	if f.Stream != "" {
+		return fmt.Sprintf("%s in stream %q at chain index %d (byte offset %d): %s", f.Type, f.Stream, f.ChainIndex, f.Offset, f.Detail)
+	}
+	return fmt.Sprintf("%s at chain index %d (byte offset %d): %s", f.Type, f.ChainIndex, f.Offset, f.Detail)
+}
+
+// diagEntry is a remembered event: its stored hash and where it starts
+type diagEntry struct {
+	hash   string
+	offset int64
+}
+
+// indexGap is a range of chain indices skipped in the file so far
+type indexGap struct {
+	from, to int
+	offset   int64
+}
+
+// chainDiagnoser classifies failures while CheckChain streams a file.
+//
+// Events are expected in increasing chain index order. A jump forward opens a
+// gap; an index that later fills the gap is a reordered event and any gap
+// still open at the end of the file is a deletion. An index seen before, or an
+// event without chain fields, was inserted. Events in place are modified when
+// their payload no longer matches the stored hash, or when the next event's
+// hash_prev no longer links to their stored hash (the hash was rewritten).
+type chainDiagnoser struct {
+	stream   string
+	findings []TamperFinding
+	recent   map[int]diagEntry
+	gaps     []indexGap
+	max      int
+	started  bool
+	noted    int // Findings and gaps recorded so far, see changes
+}
+
+func newChainDiagnoser(stream string) *chainDiagnoser {
+	return &chainDiagnoser{stream: stream, recent: make(map[int]diagEntry)}
+}
+
+// observe classifies one event.
+//
+// Args:
+//   - idx / hasIndex: hash_chain_index and whether the event has one
+//   - prev / hash: Stored hash_prev and hash
+//   - hashOK: Whether the stored hash matches the recomputed one
+//   - linkOK: Whether hash_prev links (Merkle mode); nil to check it against the
+//     stored hash of the previous chain index (linear mode)
+//   - offset: Byte offset of the event in the input
+func (d *chainDiagnoser) observe(idx int, hasIndex bool, prev, hash string, hashOK bool, linkOK *bool, offset int64) {
+	if !hasIndex || hash == "" {
+		d.add(FindingInserted, idx, 0, offset, "event has no hash chain fields")
+		return
+	}
+	switch {
+	case !d.started:
+		// The file may continue an earlier chain, so its first link is not checked
+		d.started = true
+		d.max = idx
+		if !hashOK {
+			d.add(FindingModified, idx, 0, offset, "payload does not match the stored hash")
+		}
+	case idx == d.max+1:
+		d.inPlace(idx, prev, hashOK, linkOK, offset)
+		d.max = idx
+	case idx > d.max+1:
+		d.gaps = append(d.gaps, indexGap{from: d.max + 1, to: idx - 1, offset: offset})
+		d.noted++
+		if !hashOK {
+			d.add(FindingModified, idx, 0, offset, "payload does not match the stored hash")
+		}
+		d.max = idx
+	case d.fillGap(idx):
+		d.add(FindingReordered, idx, 0, offset, fmt.Sprintf("event found after chain index %d", d.max))
+		if !hashOK {
+			d.add(FindingModified, idx, 0, offset, "payload does not match the stored hash")
+		}
+	default:
+		d.add(FindingInserted, idx, 0, offset, fmt.Sprintf("duplicate chain index %d", idx))
+		return
+	}
+	d.recent[idx] = diagEntry{hash: hash, offset: offset}
+	delete(d.recent, idx-diagnoseWindow)
+}
+
+// inPlace classifies an event found at the expected chain index
+func (d *chainDiagnoser) inPlace(idx int, prev string, hashOK bool, linkOK *bool, offset int64) {
+	if !hashOK {
+		d.add(FindingModified, idx, 0, offset, "payload does not match the stored hash")
+		return
+	}
+	if linkOK != nil {
+		if !*linkOK {
+			d.add(FindingModified, idx, 0, offset, "hash_prev does not link to the previous batch head (a stored hash was rewritten)")
+		}
+		return
+	}
+	if e, ok := d.recent[idx-1]; ok && prev != e.hash {
+		// The payload still matches, so the predecessor's stored hash was rewritten
+		d.add(FindingModified, idx-1, 0, e.offset, fmt.Sprintf("stored hash was rewritten: hash_prev of chain index %d no longer links to it", idx))
+	}
+}
+
+// fillGap removes idx from the open gaps, reporting whether it was in one
+func (d *chainDiagnoser) fillGap(idx int) bool {
+	for i, g := range d.gaps {
+		if idx < g.from || idx > g.to {
+			continue
+		}
+		var rest []indexGap
+		if idx > g.from {
+			rest = append(rest, indexGap{from: g.from, to: idx - 1, offset: g.offset})
+		}
+		if idx < g.to {
+			rest = append(rest, indexGap{from: idx + 1, to: g.to, offset: g.offset})
+		}
+		d.gaps = append(d.gaps[:i], append(rest, d.gaps[i+1:]...)...)
+		return true
+	}
+	return false
+}
+
+// add appends a finding unless it repeats the previous one
+func (d *chainDiagnoser) add(typ string, idx, count int, offset int64, detail string) {
+	if n := len(d.findings); n > 0 && d.findings[n-1].Type == typ && d.findings[n-1].ChainIndex == idx && typ != FindingInserted {
+		return
+	}
+	d.findings = append(d.findings, TamperFinding{Type: typ, Stream: d.stream, ChainIndex: idx, Count: count, Offset: offset, Detail: detail})
+	d.noted++
+}
+
+// changes counts the findings and gaps recorded so far. In Merkle mode a batch
+// whose events were deleted, inserted or modified no longer links to the next
+// one; comparing counts lets CheckChain tell that from a rewritten batch head.
+func (d *chainDiagnoser) changes() int {
+	return d.noted
+}
+
+// finish reports the gaps never filled as deletions and returns the findings
+// in file order
+func (d *chainDiagnoser) finish() []TamperFinding {
+	for _, g := range d.gaps {
+		n := g.to - g.from + 1
+		detail := fmt.Sprintf("chain index %d missing", g.from)
+		if n > 1 {
+			detail = fmt.Sprintf("chain indices %d-%d missing", g.from, g.to)
+		}
+		d.add(FindingDeleted, g.from, n, g.offset, detail)
+	}
+	d.gaps = nil
+	sortFindings(d.findings)
+	return d.findings
+}
+
+// sortFindings orders findings by byte offset, then chain index
+func sortFindings(findings []TamperFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Offset != findings[j].Offset {
+			return findings[i].Offset < findings[j].Offset
+		}
+		return findings[i].ChainIndex < findings[j].ChainIndex
+	})
+}
+
+// TruncationFinding reports events missing at the end of a file when the
+// checkpoint signs a later chain index than the last one in the file.
+//
+// Args:
+//   - cp: The checkpoint the file is verified against
+//   - last: Last hash_chain_index in the file
+//   - size: Size of the file in bytes
+//
+// Returns:
+//   - The truncated finding, or nil if the checkpoint is within the file
+func TruncationFinding(cp Checkpoint, last int, size int64) *TamperFinding {
+	if cp.ChainIndex <= last {
+		return nil
+	}
+	n := cp.ChainIndex - last
+	return &TamperFinding{
+		Type:       FindingTruncated,
+		ChainIndex: last + 1,
+		Count:      n,
+		Offset:     size,
+		Detail:     fmt.Sprintf("file ends at chain index %d but the checkpoint signs chain index %d", last, cp.ChainIndex),
+	}
+}
+
+// tailTruncations reports, for each stream of a file, the events cut off its end
+// when a checkpoint of the stream signs a later chain index than the stream's
+// last one in the file. Only the highest such checkpoint per stream is used.
+// Checkpoints of streams the file holds no event of are not considered: their
+// events may live in other files.
+//
+// Args:
+//   - beyond: Checkpoints beyond the end of their stream in the file
+//   - check: Chain check of the file
+//
+// Returns:
+//   - One truncated finding per stream, ordered by stream name
+func tailTruncations(beyond []Checkpoint, check *ChainCheck) []TamperFinding {
+	highest := make(map[string]Checkpoint)
+	for _, cp := range beyond {
+		if h, ok := highest[cp.Stream]; !ok || cp.ChainIndex > h.ChainIndex {
+			highest[cp.Stream] = cp
+		}
+	}
+	names := make([]string, 0, len(highest))
+	for name := range highest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var findings []TamperFinding
+	for _, name := range names {
+		s, ok := check.Streams[name]
+		if !ok {
+			continue
+		}
+		if f := TruncationFinding(highest[name], s.Last, check.Size); f != nil {
+			f.Stream = name
+			findings = append(findings, *f)
+		}
+	}
+	return findings
+}
+
+// DiagnoseChain re-reads a hashed file and classifies its failures (see
+// CheckChain; stream is the stream every event must belong to, or empty).
+//
+// RunVerifyPhase calls it after a manifest or checkpoint directory verification
+// fails, so the common passing case reads the file only once.
+//
+// Args:
+//   - input: Hashed NDJSON file
+//
+// Returns:
+//   - Findings in file order (empty if the chain is intact)
+//   - Error if the file can't be read
+func DiagnoseChain(input, stream string) ([]TamperFinding, error) {
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("open input: %w", err)
+	}
+	defer f.Close()
+	check, err := CheckChain(f, nil, stream)
+	if err != nil {
+		return nil, err
+	}
+	return check.Findings, nil
+}
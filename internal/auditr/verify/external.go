@@ -0,0 +1,170 @@
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+)
+
+// externalSignTimeout bounds a single external signing request
+const externalSignTimeout = 30 * time.Second
+
+// ExternalSigner delegates signing to a process outside AuditR, so that the
+// private key can stay in an HSM, a KMS or a signing daemon.
+//
+// Two transports are supported:
+//
+// Command: the command is run once per checkpoint with the payload to sign on
+// stdin and must print the base64 signature on stdout. AUDITR_SIGN_ALGORITHM and
+// AUDITR_SIGN_KEY_ID are set in its environment. A non-zero exit status fails the
+// checkpoint; stderr is included in the error.
+//
+// Socket: a Unix socket is dialled once per checkpoint and sent one JSON line
+// {"algorithm": ..., "key_id": ..., "message": <base64>}. The signer answers with
+// one JSON line {"signature": <base64>} or {"error": "..."}.
+//
+// The signer doesn't know the key, so Algorithm and KeyID come from configuration
+// and are recorded in the checkpoint as given. Verification uses the exported
+// public key like any file key.
+//
+// Fields:
+//   - Command: Command and arguments (Command transport)
+//   - Socket: Unix socket path (Socket transport)
+//   - Alg: Signature algorithm produced by the external signer
+//   - ID: Key ID recorded in checkpoints
+type ExternalSigner struct {
+	Command []string
+	Socket  string
+	Alg     string
+	ID      string
+}
+
+func (s *ExternalSigner) Algorithm() string { return s.Alg }
+func (s *ExternalSigner) KeyID() string     { return s.ID }
+
+// Sign sends msg to the external signer and returns the decoded signature.
+func (s *ExternalSigner) Sign(msg []byte) ([]byte, error) {
+	var encoded string
+	var err error
+	if s.Socket != "" {
+		encoded, err = s.signSocket(msg)
+	} else {
+		encoded, err = s.signCommand(msg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("external signer: decode signature: %w", err)
+	}
+	if len(sig) == 0 {
+		return nil, fmt.Errorf("external signer: empty signature")
+	}
+	return sig, nil
+}
+
+func (s *ExternalSigner) signCommand(msg []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), externalSignTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Env = append(os.Environ(), "AUDITR_SIGN_ALGORITHM="+s.Alg, "AUDITR_SIGN_KEY_ID="+s.ID)
+	cmd.Stdin = bytes.NewReader(msg)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("external signer %s: %w: %s", s.Command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// externalSignRequest and externalSignResponse are the socket protocol messages
+type externalSignRequest struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	Message   string `json:"message"`
+}
+
+type externalSignResponse struct {
+	Signature string `json:"signature"`
+	Error     string `json:"error"`
+}
+
+func (s *ExternalSigner) signSocket(msg []byte) (string, error) {
+	conn, err := net.DialTimeout("unix", s.Socket, externalSignTimeout)
+	if err != nil {
+		return "", fmt.Errorf("external signer: dial %s: %w", s.Socket, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(externalSignTimeout))
+
+	req := externalSignRequest{Algorithm: s.Alg, KeyID: s.ID, Message: base64.StdEncoding.EncodeToString(msg)}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return "", fmt.Errorf("external signer: send request: %w", err)
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return "", fmt.Errorf("external signer: read response: %w", err)
+	}
+	var resp externalSignResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return "", fmt.Errorf("external signer: decode response: %w", err)
+	}
+	if resp.Error != "" {
+		return "", fmt.Errorf("external signer: %s", resp.Error)
+	}
+	return resp.Signature, nil
+}
+
+// NewSigner returns the checkpoint signer for a run.
+//
+// When signing.external configures a command or socket, an ExternalSigner is
+// returned and no private key is needed. Otherwise the private key file is
+// loaded (privateKeyPath, falling back to signing.private_key_path).
+//
+// Args:
+//   - cfg: Application configuration (may be nil)
+//   - privateKeyPath: Private key from the command line (optional)
+//
+// Returns:
+//   - Signer for checkpoints
+//   - Error if the configuration is incomplete or the key can't be loaded
+func NewSigner(cfg *config.Config, privateKeyPath string) (Signer, error) {
+	if cfg != nil && cfg.Signing.External.Enabled() {
+		ext := cfg.Signing.External
+		if len(ext.Command) > 0 && ext.Socket != "" {
+			return nil, fmt.Errorf("signing.external: set either command or socket, not both")
+		}
+		if !ValidAlgorithm(ext.Algorithm) {
+			return nil, fmt.Errorf("signing.external.algorithm %q is not one of %s, %s, %s", ext.Algorithm, AlgECDSAP256SHA256, AlgEd25519, AlgRSAPSSSHA256)
+		}
+		if ext.KeyID == "" {
+			return nil, fmt.Errorf("signing.external.key_id is required")
+		}
+		return &ExternalSigner{Command: ext.Command, Socket: ext.Socket, Alg: ext.Algorithm, ID: ext.KeyID}, nil
+	}
+
+	key := privateKeyPath
+	if key == "" && cfg != nil {
+		key = cfg.Signing.PrivateKeyPath
+	}
+	if key == "" {
+		return nil, fmt.Errorf("checkpoint requested but signing key not provided (use --private-key, signing.private_key_path or signing.external)")
+	}
+	if _, err := os.Stat(key); err != nil {
+		return nil, fmt.Errorf("checkpoint requested but signing key not found: %s", key)
+	}
+	return LoadFileSigner(key)
+}
@@ -0,0 +1,400 @@
+package verify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// KeyringVersion is the keyring file format version written by SaveKeyring
+const KeyringVersion = 1
+
+// generatedRSABits is the modulus size of RSA keys created by GenerateKey
+const generatedRSABits = 3072
+
+// Keyring publishes the public checkpoint keys of a deployment over time.
+//
+// Keys are ordered oldest first. Each key has a validity window; the active key
+// has no NotAfter. Every key after the first carries an endorsement: a signature
+// by the key it replaced over the new key's ID, algorithm, public key and start
+// of validity. Trust is therefore anchored on the first key, and a key added to
+// the file without the previous private key doesn't verify.
+//
+// A keyring can be passed wherever a public key is expected (--public-key);
+// checkpoints are then verified with the key selected by their key ID and
+// creation time (see ResolveVerifier).
+type Keyring struct {
+	Version int          `json:"version"`
+	Keys    []KeyringKey `json:"keys"`
+}
+
+// KeyringKey is one public key in a keyring.
+//
+// Fields:
+//   - KeyID: SHA256:<hex> fingerprint of the public key (the key ID recorded in checkpoints)
+//   - Algorithm: Signature algorithm of the key
+//   - PublicKey: PEM-encoded public key (PKIX)
+//   - NotBefore: Start of validity
+//   - NotAfter: End of validity (nil while the key is active)
+//   - Endorsement: Signature by the previous key (nil for the first key)
+type KeyringKey struct {
+	KeyID       string          `json:"key_id"`
+	Algorithm   string          `json:"algorithm"`
+	PublicKey   string          `json:"public_key"`
+	NotBefore   time.Time       `json:"not_before"`
+	NotAfter    *time.Time      `json:"not_after,omitempty"`
+	Endorsement *KeyEndorsement `json:"endorsement,omitempty"`
+}
+
+// KeyEndorsement is the signature of a rotated-out key over its successor.
+type KeyEndorsement struct {
+	KeyID     string `json:"key_id"`    // Key that signed the endorsement
+	Signature string `json:"signature"` // Base64 signature over the endorsed key
+}
+
+// ValidAt reports whether the key's validity window contains t.
+func (k *KeyringKey) ValidAt(t time.Time) bool {
+	if t.Before(k.NotBefore) {
+		return false
+	}
+	return k.NotAfter == nil || t.Before(*k.NotAfter)
+}
+
+// Verifier returns a verifier for the key's public key.
+func (k *KeyringKey) Verifier() (Verifier, error) {
+	v, err := ParsePublicKeyPEM([]byte(k.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", k.KeyID, err)
+	}
+	if v.KeyID() != k.KeyID || v.Algorithm() != k.Algorithm {
+		return nil, fmt.Errorf("key %s: public key does not match its key ID or algorithm", k.KeyID)
+	}
+	return v, nil
+}
+
+// endorsementPayload is the message signed when endorsing a key
+func (k *KeyringKey) endorsementPayload() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"key_id":     k.KeyID,
+		"algorithm":  k.Algorithm,
+		"public_key": k.PublicKey,
+		"not_before": k.NotBefore.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+// LoadKeyring reads a keyring file.
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read keyring: %w", err)
+	}
+	return parseKeyring(data)
+}
+
+// isKeyring tells a keyring file (JSON) from a PEM public key
+func isKeyring(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+func parseKeyring(data []byte) (*Keyring, error) {
+	var kr Keyring
+	if err := json.Unmarshal(data, &kr); err != nil {
+		return nil, fmt.Errorf("unmarshal keyring: %w", err)
+	}
+	if kr.Version > KeyringVersion {
+		return nil, fmt.Errorf("unsupported keyring version %d", kr.Version)
+	}
+	return &kr, nil
+}
+
+// SaveKeyring writes the keyring through a temporary file and rename.
+func SaveKeyring(path string, kr *Keyring) error {
+	kr.Version = KeyringVersion
+	b, err := json.MarshalIndent(kr, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal keyring: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("mkdir: %w", err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("write keyring: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace keyring: %w", err)
+	}
+	return nil
+}
+
+// Active returns the key currently used for signing, nil if there is none.
+func (kr *Keyring) Active() *KeyringKey {
+	if n := len(kr.Keys); n > 0 && kr.Keys[n-1].NotAfter == nil {
+		return &kr.Keys[n-1]
+	}
+	return nil
+}
+
+// Find returns the key with the given ID, nil if it isn't in the keyring.
+func (kr *Keyring) Find(keyID string) *KeyringKey {
+	for i := range kr.Keys {
+		if kr.Keys[i].KeyID == keyID {
+			return &kr.Keys[i]
+		}
+	}
+	return nil
+}
+
+// Add publishes the first key of a keyring, valid from notBefore.
+//
+// Returns an error if the keyring already has an active key; use Rotate to
+// replace it so the new key is endorsed by the old one.
+func (kr *Keyring) Add(pub crypto.PublicKey, notBefore time.Time) (*KeyringKey, error) {
+	if active := kr.Active(); active != nil {
+		return nil, fmt.Errorf("keyring already has an active key %s; rotate it instead", active.KeyID)
+	}
+	if len(kr.Keys) > 0 {
+		return nil, fmt.Errorf("keyring has retired keys; new keys must be added by rotation")
+	}
+	k, err := newKeyringKey(pub, notBefore)
+	if err != nil {
+		return nil, err
+	}
+	kr.Keys = append(kr.Keys, *k)
+	return &kr.Keys[len(kr.Keys)-1], nil
+}
+
+// Rotate retires the active key at time at and publishes next, endorsed by the
+// active key.
+//
+// Args:
+//   - current: Signer for the active key (its private key proves the rotation)
+//   - next: Public key of the new key
+//   - at: Rotation time; end of the old key's and start of the new key's validity
+//
+// Returns:
+//   - The new keyring entry
+//   - Error if current isn't the active key or signing fails
+func (kr *Keyring) Rotate(current Signer, next crypto.PublicKey, at time.Time) (*KeyringKey, error) {
+	active := kr.Active()
+	if active == nil {
+		return nil, fmt.Errorf("keyring has no active key to rotate")
+	}
+	if current.KeyID() != active.KeyID {
+		return nil, fmt.Errorf("signing key %s is not the active key %s", current.KeyID(), active.KeyID)
+	}
+	if !at.After(active.NotBefore) {
+		return nil, fmt.Errorf("rotation time %s is not after the active key's start %s", at.Format(time.RFC3339), active.NotBefore.Format(time.RFC3339))
+	}
+
+	k, err := newKeyringKey(next, at)
+	if err != nil {
+		return nil, err
+	}
+	if kr.Find(k.KeyID) != nil {
+		return nil, fmt.Errorf("key %s is already in the keyring", k.KeyID)
+	}
+	payload, err := k.endorsementPayload()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := current.Sign(payload)
+	if err != nil {
+		return nil, fmt.Errorf("endorse new key: %w", err)
+	}
+	k.Endorsement = &KeyEndorsement{KeyID: current.KeyID(), Signature: base64.StdEncoding.EncodeToString(sig)}
+
+	end := at.UTC()
+	active.NotAfter = &end
+	kr.Keys = append(kr.Keys, *k)
+	return &kr.Keys[len(kr.Keys)-1], nil
+}
+
+// VerifyEndorsements checks that every key after the first is endorsed by the key
+// before it and that validity windows follow each other.
+func (kr *Keyring) VerifyEndorsements() error {
+	for i := 1; i < len(kr.Keys); i++ {
+		prev, k := &kr.Keys[i-1], &kr.Keys[i]
+		if k.Endorsement == nil {
+			return fmt.Errorf("key %s is not endorsed by %s", k.KeyID, prev.KeyID)
+		}
+		if k.Endorsement.KeyID != prev.KeyID {
+			return fmt.Errorf("key %s is endorsed by %s, want %s", k.KeyID, k.Endorsement.KeyID, prev.KeyID)
+		}
+		if prev.NotAfter == nil || !prev.NotAfter.Equal(k.NotBefore) {
+			return fmt.Errorf("key %s does not start when %s ends", k.KeyID, prev.KeyID)
+		}
+		v, err := prev.Verifier()
+		if err != nil {
+			return err
+		}
+		payload, err := k.endorsementPayload()
+		if err != nil {
+			return err
+		}
+		sig, err := base64.StdEncoding.DecodeString(k.Endorsement.Signature)
+		if err != nil {
+			return fmt.Errorf("key %s: decode endorsement: %w", k.KeyID, err)
+		}
+		ok, err := v.Verify(payload, sig)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("key %s: endorsement by %s does not verify", k.KeyID, prev.KeyID)
+		}
+	}
+	return nil
+}
+
+// Select returns the verifier for a checkpoint signed with keyID at time at.
+//
+// The keyring's endorsements are checked first. A checkpoint with a key ID must
+// name a key in the keyring that was valid when the checkpoint was created.
+// Checkpoints written before key IDs were recorded are matched by algorithm and
+// creation time alone.
+//
+// Args:
+//   - keyID: Key ID recorded in the checkpoint (may be empty)
+//   - alg: Checkpoint signature algorithm
+//   - at: Checkpoint creation time
+//
+// Returns:
+//   - Verifier for the selected key
+//   - Error if the keyring is broken or no key matches
+func (kr *Keyring) Select(keyID, alg string, at time.Time) (Verifier, error) {
+	if err := kr.VerifyEndorsements(); err != nil {
+		return nil, err
+	}
+	if keyID != "" {
+		k := kr.Find(keyID)
+		if k == nil {
+			return nil, fmt.Errorf("key %s is not in the keyring", keyID)
+		}
+		if !k.ValidAt(at) {
+			return nil, fmt.Errorf("key %s was not valid at %s", keyID, at.UTC().Format(time.RFC3339))
+		}
+		return k.Verifier()
+	}
+	for i := range kr.Keys {
+		if kr.Keys[i].Algorithm == alg && kr.Keys[i].ValidAt(at) {
+			return kr.Keys[i].Verifier()
+		}
+	}
+	return nil, fmt.Errorf("no %s key in the keyring was valid at %s", alg, at.UTC().Format(time.RFC3339))
+}
+
+func newKeyringKey(pub crypto.PublicKey, notBefore time.Time) (*KeyringKey, error) {
+	alg, err := keyAlgorithm(pub)
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := publicKeyID(pub)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("marshal public key: %w", err)
+	}
+	return &KeyringKey{
+		KeyID:     keyID,
+		Algorithm: alg,
+		PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		NotBefore: notBefore.UTC(),
+	}, nil
+}
+
+// GenerateKey creates a new private key for a checkpoint algorithm: a P-256 key
+// for ecdsa-p256-sha256, an Ed25519 key, or a 3072-bit RSA key for rsa-pss-sha256.
+func GenerateKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case AlgECDSAP256SHA256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	case AlgRSAPSSSHA256:
+		return rsa.GenerateKey(rand.Reader, generatedRSABits)
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q (expected %s, %s or %s)", alg, AlgECDSAP256SHA256, AlgEd25519, AlgRSAPSSSHA256)
+	}
+}
+
+// SaveKeyringWithKeyPair saves a keyring that gained the key whose files
+// WriteKeyPair just wrote. If the keyring can't be saved, the key files are
+// removed: no private key is left on disk that the keyring doesn't list, and
+// after a rotation the keyring on disk keeps the old key active.
+//
+// Returns:
+//   - Error if the keyring can't be saved (or the key files then removed)
+func SaveKeyringWithKeyPair(path string, kr *Keyring, privPath, pubPath string) error {
+	err := SaveKeyring(path, kr)
+	if err == nil {
+		return nil
+	}
+	for _, p := range []string{privPath, pubPath} {
+		if rerr := os.Remove(p); rerr != nil && !os.IsNotExist(rerr) {
+			return fmt.Errorf("%w; remove %s: %v", err, p, rerr)
+		}
+	}
+	return err
+}
+
+// WriteKeyPair writes a private key (PKCS#8, mode 0600) and its public key (PKIX)
+// to dir as <name>-<key id prefix>.pem and <name>-<key id prefix>.pub.pem.
+//
+// Returns:
+//   - Private and public key paths
+//   - Error if a file exists already or can't be written
+func WriteKeyPair(dir, name string, key crypto.Signer) (privPath, pubPath string, err error) {
+	keyID, err := publicKeyID(key.Public())
+	if err != nil {
+		return "", "", err
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", fmt.Errorf("marshal private key: %w", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return "", "", fmt.Errorf("marshal public key: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", fmt.Errorf("mkdir: %w", err)
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%s-%s", name, strings.TrimPrefix(keyID, "SHA256:")[:12]))
+	privPath, pubPath = base+".pem", base+".pub.pem"
+	// O_EXCL: never overwrite an existing private key
+	f, err := os.OpenFile(privPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", "", fmt.Errorf("create private key: %w", err)
+	}
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}); err != nil {
+		f.Close()
+		return "", "", fmt.Errorf("write private key: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", "", fmt.Errorf("write private key: %w", err)
+	}
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		return "", "", fmt.Errorf("write public key: %w", err)
+	}
+	return privPath, pubPath, nil
+}
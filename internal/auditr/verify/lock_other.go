@@ -0,0 +1,30 @@
+//go:build !unix
+
+package verify
+
+import (
+	"fmt"
+	"os"
+)
+
+// On platforms without flock the lock file itself is the lock: it is created
+// exclusively and removed on unlock. A crashed run leaves it behind, and the
+// error names the file to remove once no run is active.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile releases the lock taken by lockFile and closes the file. The file
+// is closed before it is removed: an open file can't be removed on Windows.
+func unlockFile(f *os.File) error {
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close state lock: %w", err)
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		return fmt.Errorf("remove state lock %s: %w", f.Name(), err)
+	}
+	return nil
+}
+
+// lockFileFlags are the flags the lock file is opened with
+const lockFileFlags = os.O_CREATE | os.O_EXCL | os.O_RDWR
@@ -0,0 +1,36 @@
+//go:build unix
+
+package verify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive, non-blocking flock on an open lock file.
+//
+// The kernel releases the lock when the process exits, so a crashed run never
+// leaves the chain state locked.
+func lockFile(f *os.File) error {
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return errStateLocked
+		}
+		return fmt.Errorf("lock state: %w", err)
+	}
+	return nil
+}
+
+// unlockFile releases the lock taken by lockFile and closes the file
+func unlockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// lockFileFlags are the flags the lock file is opened with
+const lockFileFlags = os.O_CREATE | os.O_RDWR
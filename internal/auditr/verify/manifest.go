@@ -0,0 +1,239 @@
+package verify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ManifestFile is the name of the checkpoint index kept in each checkpoint directory
+const ManifestFile = "manifest.json"
+
+// ManifestEntry describes one checkpoint listed in the manifest.
+//
+// Fields:
+//   - File: Checkpoint file name, relative to the manifest's directory
+//   - ChainIndex: Chain index the checkpoint covers
+//   - HeadHash: Head hash at that index
+//   - CreatedAt: Checkpoint creation time
+//   - Stream: Named chain of the checkpoint (absent for the unnamed chain)
+type ManifestEntry struct {
+	File       string    `json:"file"`
+	ChainIndex int       `json:"chain_index"`
+	HeadHash   string    `json:"head_hash"`
+	CreatedAt  time.Time `json:"created_at"`
+	Stream     string    `json:"stream,omitempty"`
+}
+
+// Manifest lists every checkpoint in a checkpoint directory, ordered by chain index.
+//
+// The manifest is an index only: entries are not signed themselves, so
+// verification always re-checks the signed checkpoint file an entry points to.
+type Manifest struct {
+	Checkpoints []ManifestEntry `json:"checkpoints"`
+}
+
+// LoadManifest reads a checkpoint manifest. A missing file yields an empty manifest.
+func LoadManifest(path string) (*Manifest, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Manifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("unmarshal manifest: %w", err)
+	}
+	return &m, nil
+}
+
+// appendManifest records a checkpoint in the manifest of its directory. The
+// manifest is rewritten through a temporary file and rename so readers never see
+// a partially written index.
+func appendManifest(dir string, entry ManifestEntry) error {
+	path := filepath.Join(dir, ManifestFile)
+	m, err := LoadManifest(path)
+	if err != nil {
+		return err
+	}
+	m.Checkpoints = append(m.Checkpoints, entry)
+	sort.SliceStable(m.Checkpoints, func(i, j int) bool {
+		return m.Checkpoints[i].ChainIndex < m.Checkpoints[j].ChainIndex
+	})
+	return saveManifest(path, m)
+}
+
+// removeManifestEntries removes the entries of the given checkpoint files from
+// the manifest of their directory
+func removeManifestEntries(dir string, files map[string]bool) error {
+	path := filepath.Join(dir, ManifestFile)
+	m, err := LoadManifest(path)
+	if err != nil {
+		return err
+	}
+	kept := m.Checkpoints[:0]
+	for _, e := range m.Checkpoints {
+		if !files[e.File] {
+			kept = append(kept, e)
+		}
+	}
+	m.Checkpoints = kept
+	return saveManifest(path, m)
+}
+
+// saveManifest writes a manifest through a temporary file and rename
+func saveManifest(path string, m *Manifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace manifest: %w", err)
+	}
+	return nil
+}
+
+// InRange returns the entries whose chain index lies in [first, last].
+func (m *Manifest) InRange(first, last int) []ManifestEntry {
+	var entries []ManifestEntry
+	for _, e := range m.Checkpoints {
+		if e.ChainIndex >= first && e.ChainIndex <= last {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// CheckpointResult is the outcome of checking one manifest checkpoint against a file.
+//
+// Fields:
+//   - Path: Checkpoint file
+//   - Stream: Named chain the checkpoint covers (empty for the unnamed chain)
+//   - ChainIndex: Chain index the checkpoint covers
+//   - HeadMatches: The file's recomputed head at ChainIndex equals the checkpoint head
+//   - SignatureValid: The checkpoint signature verified
+//   - Error: Why the checkpoint could not be checked (missing file, bad key, ...)
+type CheckpointResult struct {
+	Path           string `json:"path"`
+	Stream         string `json:"stream,omitempty"`
+	ChainIndex     int    `json:"chain_index"`
+	HeadMatches    bool   `json:"head_matches"`
+	SignatureValid bool   `json:"signature_valid"`
+	Error          string `json:"error,omitempty"`
+}
+
+// OK reports whether the checkpoint matched and its signature verified.
+func (r CheckpointResult) OK() bool {
+	return r.HeadMatches && r.SignatureValid && r.Error == ""
+}
+
+// VerifyManifest verifies a hashed file and checks it against every manifest
+// checkpoint whose chain index falls within the file.
+//
+// The chain is verified once with CheckChain, collecting the recomputed head at
+// each checkpointed index; each checkpoint is then checked with checkCheckpoint
+// against the chain of its stream. Checkpoints outside their stream's index range
+// in the file, or of streams the file doesn't hold, are ignored, except that a
+// checkpoint beyond the last event of its stream reports the truncated tail
+// (see tailTruncations).
+//
+// Args:
+//   - input: Path of the hashed NDJSON file
+//   - manifestPath: Path of the checkpoint manifest
+//   - publicKeyPath: Public key for checkpoint signatures
+//   - stream: Stream every event must belong to (empty to accept any, see CheckChain)
+//
+// Returns:
+//   - Tampered chain indices, final head hash and events processed (as VerifyChain)
+//   - One result per checkpoint in range, ordered by chain index
+//   - Truncated findings of streams whose tail is missing from the file
+//   - Error if the file or manifest can't be read
+func VerifyManifest(input, manifestPath, publicKeyPath, stream string) ([]int, string, int, []CheckpointResult, []TamperFinding, error) {
+	m, err := LoadManifest(manifestPath)
+	if err != nil {
+		return nil, "", 0, nil, nil, err
+	}
+	want := make(map[int]bool, len(m.Checkpoints))
+	for _, e := range m.Checkpoints {
+		want[e.ChainIndex] = true
+	}
+
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, "", 0, nil, nil, fmt.Errorf("open input: %w", err)
+	}
+	defer f.Close()
+
+	check, err := CheckChain(f, want, stream)
+	if err != nil {
+		return check.Tampered, check.Head, check.Processed, nil, nil, err
+	}
+
+	dir := filepath.Dir(manifestPath)
+	var results []CheckpointResult
+	var beyond []Checkpoint
+	for _, e := range m.Checkpoints {
+		// Each entry is checked against the chain of its own stream
+		sc, ok := check.Streams[e.Stream]
+		if ok && e.ChainIndex > sc.Last {
+			beyond = append(beyond, Checkpoint{ChainIndex: e.ChainIndex, Stream: e.Stream})
+		}
+		if !ok || e.ChainIndex < sc.First || e.ChainIndex > sc.Last {
+			continue
+		}
+		path := filepath.Join(dir, e.File)
+		cp, err := LoadCheckpoint(path)
+		if err != nil {
+			results = append(results, CheckpointResult{Path: path, Stream: e.Stream, ChainIndex: e.ChainIndex, Error: err.Error()})
+			continue
+		}
+		r := checkCheckpoint(path, cp, check.Streams, publicKeyPath)
+		// The manifest entry must describe the checkpoint it points to
+		if cp.Checkpoint.ChainIndex != e.ChainIndex || cp.Checkpoint.Stream != e.Stream {
+			r.ChainIndex = e.ChainIndex
+			r.Stream = e.Stream
+			r.HeadMatches = false
+		}
+		results = append(results, r)
+	}
+	return check.Tampered, check.Head, check.Processed, results, tailTruncations(beyond, check), nil
+}
+
+// checkCheckpoint compares a loaded checkpoint with the recomputed head at its
+// chain index in its stream and verifies its signature.
+//
+// The signature is checked over the checkpoint's own head so that a forged
+// checkpoint and a modified chain are reported separately (SignatureValid vs
+// HeadMatches).
+func checkCheckpoint(path string, sc *SignedCheckpoint, streams map[string]*StreamCheck, publicKeyPath string) CheckpointResult {
+	r := CheckpointResult{Path: path, Stream: sc.Checkpoint.Stream, ChainIndex: sc.Checkpoint.ChainIndex}
+	var recomputed string
+	ok := false
+	if s, found := streams[sc.Checkpoint.Stream]; found {
+		recomputed, ok = s.Heads[sc.Checkpoint.ChainIndex]
+	}
+	if !ok {
+		r.Error = fmt.Sprintf("chain index %d not found in input", sc.Checkpoint.ChainIndex)
+		if sc.Checkpoint.Stream != "" {
+			r.Error = fmt.Sprintf("chain index %d of stream %q not found in input", sc.Checkpoint.ChainIndex, sc.Checkpoint.Stream)
+		}
+		return r
+	}
+	r.HeadMatches = sc.Checkpoint.HeadHash == recomputed
+	valid, err := VerifyCheckpoint(path, publicKeyPath, sc.Checkpoint.HeadHash)
+	r.SignatureValid = valid
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
@@ -0,0 +1,317 @@
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+)
+
+// Merkle tree hashing follows RFC 6962 (Certificate Transparency): leaves and
+// interior nodes are hashed with distinct prefixes so a leaf can't be passed
+// off as a node.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// merkleLeafHash returns SHA256(0x00 || canonical event)
+func merkleLeafHash(canon string) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write([]byte(canon))
+	return h.Sum(nil)
+}
+
+// merkleNodeHash returns SHA256(0x01 || left || right)
+func merkleNodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// merkleSplit returns the largest power of two smaller than n (n > 1)
+func merkleSplit(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// merkleRoot computes the RFC 6962 Merkle Tree Hash of a list of leaf hashes.
+// Trees need not be full: the left subtree holds the largest power of two of
+// leaves and the right subtree the rest.
+func merkleRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	case 1:
+		return leaves[0]
+	}
+	k := merkleSplit(len(leaves))
+	return merkleNodeHash(merkleRoot(leaves[:k]), merkleRoot(leaves[k:]))
+}
+
+// MerkleLeaf returns the RFC 6962 leaf hash of an event in canonical form
+// (see Canonicalize)
+func MerkleLeaf(canon string) []byte {
+	return merkleLeafHash(canon)
+}
+
+// MerkleTreeHash returns the hex RFC 6962 Merkle Tree Hash of leaf hashes (see
+// MerkleLeaf). The archive records it for each sealed segment.
+func MerkleTreeHash(leaves [][]byte) string {
+	return hex.EncodeToString(merkleRoot(leaves))
+}
+
+// merkleAuditPath returns the sibling hashes from leaf m up to the root
+// (RFC 6962 PATH(m, D[n]))
+func merkleAuditPath(m int, leaves [][]byte) [][]byte {
+	n := len(leaves)
+	if n <= 1 {
+		return nil
+	}
+	k := merkleSplit(n)
+	if m < k {
+		return append(merkleAuditPath(m, leaves[:k]), merkleRoot(leaves[k:]))
+	}
+	return append(merkleAuditPath(m-k, leaves[k:]), merkleRoot(leaves[:k]))
+}
+
+// merkleRootFromPath recomputes the root from a leaf hash, its index, the tree
+// size and its audit path (RFC 9162 section 2.1.3.2)
+func merkleRootFromPath(leaf []byte, index, size int, path [][]byte) ([]byte, error) {
+	if index < 0 || index >= size {
+		return nil, fmt.Errorf("leaf index %d out of range for tree size %d", index, size)
+	}
+	fn, sn := index, size-1
+	r := leaf
+	for _, p := range path {
+		if sn == 0 {
+			return nil, fmt.Errorf("audit path too long for tree size %d", size)
+		}
+		if fn&1 == 1 || fn == sn {
+			r = merkleNodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = merkleNodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return nil, fmt.Errorf("audit path too short for tree size %d", size)
+	}
+	return r, nil
+}
+
+// merkleBatchHead chains a batch root onto the previous head:
+// SHA256(previous_head + "|" + hex(root))
+func merkleBatchHead(prev string, root []byte) string {
+	sum := sha256.Sum256([]byte(prev + "|" + hex.EncodeToString(root)))
+	return hex.EncodeToString(sum[:])
+}
+
+// ComputeMerkleChain hashes NDJSON events in Merkle mode.
+//
+// Every batchSize events form an RFC 6962 Merkle tree; the batch roots, not the
+// events, are chained. This lets a single event be proven with an audit path and
+// the roots of later batches (see BuildInclusionProof) instead of the whole log.
+//
+// Each event is augmented with:
+//   - hash_alg / canon_version: Hashing scheme (hashed with the event, as in linear mode)
+//   - hash: Leaf hash, SHA256(0x00 || canonicalized_event)
+//   - hash_prev: Chain head before the event's batch (the same for the whole batch)
+//   - hash_chain_index: Position in the chain, as in linear mode
+//   - merkle_batch: Batch number, continuing across runs
+//
+// A batch's head is SHA256(hash_prev + "|" + hex(root)). A batch is closed when it
+// holds batchSize events, when a checkpoint is due (so every checkpoint signs a
+// batch head) and at the end of the input, so the last batch of a run may be
+// shorter.
+//
+// Args:
+//   - input: NDJSON stream of events to process
+//   - output: Where to write the augmented events
+//   - state: Previous chain state (nil for new chain)
+//   - batchSize: Events per Merkle tree (must be positive)
+//   - cp: Periodic checkpointer (nil for none)
+//
+// Returns:
+//   - Updated chain state (head of the last closed batch)
+//   - Number of events processed
+//   - Error if any step fails
+func ComputeMerkleChain(input io.Reader, output io.Writer, state *ChainState, batchSize int, cp *Checkpointer) (*ChainState, int, error) {
+	log := logger.L()
+	if batchSize <= 0 {
+		return nil, 0, fmt.Errorf("merkle batch size must be positive, got %d", batchSize)
+	}
+	if state == nil {
+		state = &ChainState{LastChainIndex: 0, LastHeadHash: zeroHash()}
+	}
+
+	start := time.Now()
+	log.Debugw("verify.compute: start", "start_index", state.LastChainIndex, "merkle_batch_size", batchSize)
+
+	scanner := bufio.NewScanner(input)
+	writer := bufio.NewWriter(output)
+	defer writer.Flush()
+
+	head := state.LastHeadHash
+	index := state.LastChainIndex
+	batch := state.LastMerkleBatch
+	processed := 0
+	var leaves [][]byte
+
+	// closeBatch chains the root of the open batch onto the head
+	closeBatch := func() {
+		if len(leaves) == 0 {
+			return
+		}
+		head = merkleBatchHead(head, merkleRoot(leaves))
+		leaves = leaves[:0]
+	}
+
+	for scanner.Scan() {
+		var evt map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &evt); err != nil {
+			return nil, processed, fmt.Errorf("decode event: %w", err)
+		}
+		evt["hash_alg"] = HashAlgSHA256
+		evt["canon_version"] = state.scheme()
+		canon, err := Canonicalize(evt)
+		if err != nil {
+			return nil, processed, fmt.Errorf("canonicalize: %w", err)
+		}
+
+		if len(leaves) == 0 {
+			batch++
+		}
+		leaf := merkleLeafHash(canon)
+		index++
+		evt["hash_prev"] = head
+		evt["hash"] = hex.EncodeToString(leaf)
+		evt["hash_chain_index"] = index
+		evt["merkle_batch"] = batch
+
+		out, err := json.Marshal(evt)
+		if err != nil {
+			return nil, processed, fmt.Errorf("encode event: %w", err)
+		}
+		if _, err := writer.Write(append(out, '\n')); err != nil {
+			return nil, processed, fmt.Errorf("write event: %w", err)
+		}
+		leaves = append(leaves, leaf)
+		processed++
+
+		if len(leaves) == batchSize {
+			closeBatch()
+		}
+		// A due checkpoint closes the open batch so it signs a batch head
+		if cp != nil && cp.Due(index) {
+			closeBatch()
+			if err := writer.Flush(); err != nil {
+				return nil, processed, fmt.Errorf("flush output: %w", err)
+			}
+			if err := cp.Write(index, head); err != nil {
+				return nil, processed, fmt.Errorf("periodic checkpoint at index %d: %w", index, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, processed, fmt.Errorf("scan input: %w", err)
+	}
+	closeBatch()
+
+	log.Infow("verify.compute: done", "events", processed, "end_index", index, "merkle_batches", batch-state.LastMerkleBatch, "duration", time.Since(start))
+	return &ChainState{LastChainIndex: index, LastHeadHash: head, LastMerkleBatch: batch, CanonVersion: state.CanonVersion}, processed, nil
+}
+
+// isMerkleEvent reports whether a hashed event was written in Merkle mode
+func isMerkleEvent(evt map[string]interface{}) bool {
+	_, ok := evt["merkle_batch"].(float64)
+	return ok
+}
+
+// merkleVerifier checks Merkle mode events batch by batch for VerifyChainHeads.
+//
+// As in linear mode, the stored hashes are checked (leaf hashes and the hash_prev
+// linking each batch to the previous batch head) and, independently, a rolling
+// head is recomputed from the recomputed leaves for comparison with checkpoints.
+// Recomputed heads exist only at batch ends, where Merkle mode checkpoints are.
+type merkleVerifier struct {
+	wantHeads map[int]bool
+	heads     map[int]string
+	tampered  []int
+
+	head    string // Head chained from the stored leaf hashes
+	rolling string // Head chained from the recomputed leaf hashes
+
+	open     bool
+	batch    int
+	prev     string
+	lastIdx  int
+	stored   [][]byte
+	computed [][]byte
+}
+
+func newMerkleVerifier(wantHeads map[int]bool, heads map[int]string, firstPrev string) *merkleVerifier {
+	return &merkleVerifier{wantHeads: wantHeads, heads: heads, head: zeroHash(), rolling: firstPrev}
+}
+
+// add checks one event and adds it to its batch, reporting whether its leaf
+// hash matches and whether its hash_prev links
+func (m *merkleVerifier) add(idx, batch int, prev, storedHash, canon string) (hashOK, linkOK bool) {
+	if m.open && batch != m.batch {
+		m.closeBatch()
+	}
+	linkOK = true
+	if !m.open {
+		// A new batch must continue from the head of the previous one
+		m.open, m.batch, m.prev = true, batch, prev
+		linkOK = prev == m.head
+	} else if prev != m.prev {
+		linkOK = false
+	}
+
+	leaf := merkleLeafHash(canon)
+	stored, err := hex.DecodeString(storedHash)
+	hashOK = err == nil && bytes.Equal(stored, leaf)
+	if err != nil {
+		stored = nil
+	}
+	if !hashOK || !linkOK {
+		m.tampered = append(m.tampered, idx)
+	}
+	m.stored = append(m.stored, stored)
+	m.computed = append(m.computed, leaf)
+	m.lastIdx = idx
+	return hashOK, linkOK
+}
+
+// closeBatch chains the open batch's roots onto the stored and recomputed heads
+func (m *merkleVerifier) closeBatch() {
+	if !m.open {
+		return
+	}
+	m.head = merkleBatchHead(m.prev, merkleRoot(m.stored))
+	m.rolling = merkleBatchHead(m.rolling, merkleRoot(m.computed))
+	if m.wantHeads[m.lastIdx] {
+		m.heads[m.lastIdx] = m.rolling
+	}
+	m.open = false
+	m.stored, m.computed = m.stored[:0], m.computed[:0]
+}
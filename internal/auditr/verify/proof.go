@@ -0,0 +1,258 @@
+package verify
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// InclusionProofVersion is the format version of InclusionProof
+const InclusionProofVersion = 1
+
+// InclusionProof shows that one event is part of a log hashed in Merkle mode
+// (see ComputeMerkleChain) and covered by a signed checkpoint.
+//
+// It is self-contained: a third party holding the public key (or keyring) can
+// check it with VerifyInclusionProof without the rest of the log. The event's
+// leaf hash and audit path give its batch root; chaining that root onto
+// BatchPrev and then the roots of the later batches (RootChain) must give the
+// checkpoint's signed head.
+//
+// Fields:
+//   - Version: Format version (InclusionProofVersion)
+//   - Event: The event as stored in the hashed log
+//   - ChainIndex: hash_chain_index of the event (informational; the signed chain covers content and order within the batch)
+//   - Batch: Merkle batch of the event
+//   - LeafIndex / TreeSize: Position of the event in its batch and the batch size
+//   - AuditPath: Hex sibling hashes from the leaf to the batch root (RFC 6962)
+//   - BatchPrev: Chain head before the event's batch (hash_prev)
+//   - RootChain: Hex roots of the batches after the event's, up to the checkpoint
+//   - Checkpoint: Signed checkpoint at the end of the last batch
+type InclusionProof struct {
+	Version    int                    `json:"version"`
+	Event      map[string]interface{} `json:"event"`
+	ChainIndex int                    `json:"chain_index"`
+	Batch      int                    `json:"merkle_batch"`
+	LeafIndex  int                    `json:"leaf_index"`
+	TreeSize   int                    `json:"tree_size"`
+	AuditPath  []string               `json:"audit_path"`
+	BatchPrev  string                 `json:"batch_prev"`
+	RootChain  []string               `json:"root_chain,omitempty"`
+	Checkpoint SignedCheckpoint       `json:"checkpoint"`
+}
+
+// merkleBatchInfo summarizes a closed batch while building a proof
+type merkleBatchInfo struct {
+	batch   int
+	prev    string
+	root    []byte
+	lastIdx int
+}
+
+// BuildInclusionProof builds an inclusion proof for the event with the given
+// event_id.
+//
+// The log is read once, keeping only the leaf hashes of the batch being read
+// and the root of each batch. Leaf hashes are recomputed from the events, so a
+// modified event yields no proof. The checkpoint used is the earliest one at or
+// after the event's batch whose head matches the recomputed chain.
+//
+// Args:
+//   - input: Hashed NDJSON file written in Merkle mode
+//   - eventID: event_id of the event to prove (the first match is used)
+//   - checkpointPaths: Candidate signed checkpoints
+//
+// Returns:
+//   - The inclusion proof
+//   - Error if the file isn't in Merkle mode, the event isn't found or no checkpoint covers it
+func BuildInclusionProof(input, eventID string, checkpointPaths []string) (*InclusionProof, error) {
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, fmt.Errorf("open input: %w", err)
+	}
+	defer f.Close()
+
+	var (
+		batches      []merkleBatchInfo
+		open         *merkleBatchInfo
+		leaves       [][]byte
+		proof        *InclusionProof
+		targetBatch  = -1 // Position of the event's batch in batches
+		targetLeaves [][]byte
+	)
+	closeBatch := func() {
+		if open == nil {
+			return
+		}
+		open.root = merkleRoot(leaves)
+		if proof != nil && targetBatch < 0 {
+			targetBatch = len(batches)
+			targetLeaves = append([][]byte(nil), leaves...)
+		}
+		batches = append(batches, *open)
+		open, leaves = nil, nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var evt map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &evt); err != nil {
+			return nil, fmt.Errorf("decode event: %w", err)
+		}
+		batchF, ok := evt["merkle_batch"].(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s is not hashed in Merkle mode (hashing.merkle_batch_size)", input)
+		}
+		batch := int(batchF)
+		prev, _ := evt["hash_prev"].(string)
+		idxF, _ := evt["hash_chain_index"].(float64)
+		canon, err := Canonicalize(evt)
+		if err != nil {
+			return nil, fmt.Errorf("canonicalize: %w", err)
+		}
+
+		if open != nil && open.batch != batch {
+			closeBatch()
+		}
+		if open == nil {
+			open = &merkleBatchInfo{batch: batch, prev: prev}
+		}
+		if id, _ := evt["event_id"].(string); proof == nil && id == eventID {
+			proof = &InclusionProof{
+				Version:    InclusionProofVersion,
+				Event:      evt,
+				ChainIndex: int(idxF),
+				Batch:      batch,
+				LeafIndex:  len(leaves),
+				BatchPrev:  prev,
+			}
+		}
+		leaves = append(leaves, merkleLeafHash(canon))
+		open.lastIdx = int(idxF)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan input: %w", err)
+	}
+	closeBatch()
+	if proof == nil {
+		return nil, fmt.Errorf("event %q not found in %s", eventID, input)
+	}
+
+	proof.TreeSize = len(targetLeaves)
+	for _, h := range merkleAuditPath(proof.LeafIndex, targetLeaves) {
+		proof.AuditPath = append(proof.AuditPath, hex.EncodeToString(h))
+	}
+
+	// Chain heads at the end of the event's batch and every later one
+	headAt := make(map[int]int, len(batches)-targetBatch) // batch end index -> position in batches
+	heads := make([]string, len(batches))
+	head := proof.BatchPrev
+	for i := targetBatch; i < len(batches); i++ {
+		head = merkleBatchHead(head, batches[i].root)
+		heads[i] = head
+		headAt[batches[i].lastIdx] = i
+	}
+
+	// Use the earliest checkpoint that signs one of those heads
+	var candidates []*SignedCheckpoint
+	for _, p := range checkpointPaths {
+		sc, err := LoadCheckpoint(p)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, sc)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Checkpoint.ChainIndex < candidates[j].Checkpoint.ChainIndex
+	})
+	for _, sc := range candidates {
+		i, ok := headAt[sc.Checkpoint.ChainIndex]
+		if !ok || heads[i] != sc.Checkpoint.HeadHash {
+			continue
+		}
+		for _, b := range batches[targetBatch+1 : i+1] {
+			proof.RootChain = append(proof.RootChain, hex.EncodeToString(b.root))
+		}
+		proof.Checkpoint = *sc
+		return proof, nil
+	}
+	return nil, fmt.Errorf("no signed checkpoint covers event %q (chain index %d, batch %d); checkpoint the log or run auditr verify to check it for tampering",
+		eventID, proof.ChainIndex, proof.Batch)
+}
+
+// VerifyInclusionProof checks an inclusion proof against the checkpoint signer's
+// public key (or keyring, see ResolveVerifier).
+//
+// Args:
+//   - p: The proof
+//   - publicKeyPath: PEM public key or keyring
+//
+// Returns:
+//   - nil if the event leads to the checkpoint head and the checkpoint signature is valid
+//   - Error describing the failed step otherwise
+func VerifyInclusionProof(p *InclusionProof, publicKeyPath string) error {
+	if p.Version != InclusionProofVersion {
+		return fmt.Errorf("unsupported inclusion proof version %d", p.Version)
+	}
+	canon, err := Canonicalize(p.Event)
+	if err != nil {
+		return fmt.Errorf("canonicalize event: %w", err)
+	}
+	path := make([][]byte, 0, len(p.AuditPath))
+	for _, s := range p.AuditPath {
+		h, err := hex.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("decode audit path: %w", err)
+		}
+		path = append(path, h)
+	}
+	root, err := merkleRootFromPath(merkleLeafHash(canon), p.LeafIndex, p.TreeSize, path)
+	if err != nil {
+		return err
+	}
+
+	head := merkleBatchHead(p.BatchPrev, root)
+	for _, s := range p.RootChain {
+		r, err := hex.DecodeString(s)
+		if err != nil {
+			return fmt.Errorf("decode root chain: %w", err)
+		}
+		head = merkleBatchHead(head, r)
+	}
+	if head != p.Checkpoint.Checkpoint.HeadHash {
+		return fmt.Errorf("event does not lead to the checkpoint head at chain index %d", p.Checkpoint.Checkpoint.ChainIndex)
+	}
+	if p.ChainIndex > p.Checkpoint.Checkpoint.ChainIndex {
+		return fmt.Errorf("event chain index %d is after the checkpoint at %d", p.ChainIndex, p.Checkpoint.Checkpoint.ChainIndex)
+	}
+
+	v, err := ResolveVerifier(publicKeyPath, p.Checkpoint.Checkpoint)
+	if err != nil {
+		return err
+	}
+	ok, err := VerifySignedCheckpoint(&p.Checkpoint, v)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("checkpoint signature is invalid")
+	}
+	return nil
+}
+
+// LoadInclusionProof reads an inclusion proof JSON file.
+func LoadInclusionProof(path string) (*InclusionProof, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read proof: %w", err)
+	}
+	var p InclusionProof
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("unmarshal proof: %w", err)
+	}
+	return &p, nil
+}
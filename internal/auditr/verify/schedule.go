@@ -0,0 +1,177 @@
+package verify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+)
+
+// CheckpointPolicy describes when hash mode writes checkpoints.
+//
+// It is parsed from hashing.checkpoint_interval, which accepts:
+//   - "file_end": one checkpoint when the input is exhausted (default)
+//   - "10000": a checkpoint every 10000 events
+//   - "15m": a checkpoint every 15 minutes, checked as events arrive
+//   - "10000,15m": whichever comes first
+//   - "none" or "": no automatic checkpoints (--checkpoint still forces one at file end)
+//
+// Count and time based policies also checkpoint at file end, so the tail of the
+// input is always covered by a signed head.
+//
+// Fields:
+//   - Every: Write a checkpoint every N events (0 = disabled)
+//   - Period: Write a checkpoint when this much time has passed since the last one (0 = disabled)
+//   - FileEnd: Write a checkpoint after the last event
+type CheckpointPolicy struct {
+	Every   int
+	Period  time.Duration
+	FileEnd bool
+}
+
+// Enabled reports whether the policy writes any checkpoint.
+func (p CheckpointPolicy) Enabled() bool {
+	return p.Every > 0 || p.Period > 0 || p.FileEnd
+}
+
+// Periodic reports whether the policy writes checkpoints while streaming.
+func (p CheckpointPolicy) Periodic() bool {
+	return p.Every > 0 || p.Period > 0
+}
+
+// ParseCheckpointInterval parses a hashing.checkpoint_interval value.
+//
+// Args:
+//   - s: Comma-separated list of "file_end", event counts and durations
+//
+// Returns:
+//   - The checkpoint policy
+//   - Error if a part is not a positive count or duration
+func ParseCheckpointInterval(s string) (CheckpointPolicy, error) {
+	var p CheckpointPolicy
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "" || strings.EqualFold(part, "none"):
+			continue
+		case strings.EqualFold(part, "file_end"):
+			p.FileEnd = true
+		default:
+			if n, err := strconv.Atoi(part); err == nil {
+				if n <= 0 {
+					return p, fmt.Errorf("invalid checkpoint_interval %q: event count must be positive", part)
+				}
+				if p.Every > 0 {
+					return p, fmt.Errorf("invalid checkpoint_interval %q: more than one event count", s)
+				}
+				p.Every = n
+				continue
+			}
+			d, err := time.ParseDuration(part)
+			if err != nil {
+				return p, fmt.Errorf("invalid checkpoint_interval %q: expected file_end, an event count (10000) or a duration (15m)", part)
+			}
+			if d <= 0 {
+				return p, fmt.Errorf("invalid checkpoint_interval %q: duration must be positive", part)
+			}
+			if p.Period > 0 {
+				return p, fmt.Errorf("invalid checkpoint_interval %q: more than one duration", s)
+			}
+			p.Period = d
+		}
+	}
+	if p.Periodic() {
+		p.FileEnd = true
+	}
+	return p, nil
+}
+
+// Checkpointer writes signed checkpoints while ComputeChain streams.
+//
+// ComputeChainWithCheckpoints calls Due after every event and Write when a
+// checkpoint is due, after flushing the events written so far, so every
+// checkpoint refers to events already on disk. Each checkpoint is also recorded
+// in the checkpoint directory's manifest (see WriteCheckpoint).
+//
+// Fields:
+//   - Dir: Checkpoint directory
+//   - Stream: Named chain the checkpoints cover (empty for the unnamed chain)
+//   - PrivateKeyPath: Private key file for signing (loaded on first write)
+//   - Signer: Checkpoint signer; takes precedence over PrivateKeyPath
+//   - Timestamper: RFC 3161 TSA client; when set every checkpoint carries a timestamp token
+//   - Policy: When to write checkpoints
+//   - StatePath: State file whose run record lists every checkpoint written, so
+//     recovering an interrupted run can discard them (empty: not recorded)
+//   - Written: Paths of the checkpoints written so far
+type Checkpointer struct {
+	Dir            string
+	Stream         string
+	PrivateKeyPath string
+	Signer         Signer
+	Timestamper    Timestamper
+	Policy         CheckpointPolicy
+	StatePath      string
+	Written        []string
+
+	now       func() time.Time
+	lastIndex int
+	lastTime  time.Time
+}
+
+// NewCheckpointer creates a checkpointer for a chain currently at startIndex.
+func NewCheckpointer(dir, privateKeyPath string, policy CheckpointPolicy, startIndex int) *Checkpointer {
+	return &Checkpointer{
+		Dir:            dir,
+		PrivateKeyPath: privateKeyPath,
+		Policy:         policy,
+		now:            time.Now,
+		lastIndex:      startIndex,
+		lastTime:       time.Now(),
+	}
+}
+
+// Due reports whether a periodic checkpoint should be written at index.
+func (c *Checkpointer) Due(index int) bool {
+	if index <= c.lastIndex {
+		return false
+	}
+	if c.Policy.Every > 0 && index-c.lastIndex >= c.Policy.Every {
+		return true
+	}
+	if c.Policy.Period > 0 && c.now().Sub(c.lastTime) >= c.Policy.Period {
+		return true
+	}
+	return false
+}
+
+// Write signs and writes a checkpoint for the given chain position. Writing the
+// same index twice is a no-op, so a periodic checkpoint on the last event isn't
+// repeated at file end.
+func (c *Checkpointer) Write(index int, headHash string) error {
+	if index <= c.lastIndex && len(c.Written) > 0 {
+		return nil
+	}
+	if c.Signer == nil {
+		signer, err := LoadFileSigner(c.PrivateKeyPath)
+		if err != nil {
+			return err
+		}
+		c.Signer = signer
+	}
+	path, err := WriteStreamCheckpoint(c.Dir, c.Stream, index, headHash, c.Signer, c.Timestamper)
+	if err != nil {
+		return err
+	}
+	c.Written = append(c.Written, path)
+	c.lastIndex = index
+	c.lastTime = c.now()
+	logger.L().Debugw("checkpoint written", "path", path, "index", index, "stream", c.Stream)
+	if c.StatePath != "" {
+		if err := RecordCheckpoint(c.StatePath, RunCheckpoint{Path: path, Stream: c.Stream, ChainIndex: index}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
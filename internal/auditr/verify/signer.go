@@ -0,0 +1,277 @@
+package verify
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// Checkpoint signature algorithms
+const (
+	AlgECDSAP256SHA256 = "ecdsa-p256-sha256" // ECDSA P-256 over SHA-256, ASN.1 DER signature
+	AlgEd25519         = "ed25519"           // Ed25519 (pure, message signed directly)
+	AlgRSAPSSSHA256    = "rsa-pss-sha256"    // RSA-PSS over SHA-256, salt length = hash length
+)
+
+// DefaultAlgorithm is assumed for checkpoints that don't record an algorithm
+// (written before algorithms were recorded)
+const DefaultAlgorithm = AlgECDSAP256SHA256
+
+// minRSABits is the smallest RSA modulus accepted for checkpoint signing
+const minRSABits = 2048
+
+// Signer signs checkpoint payloads.
+//
+// Implementations exist for PEM key files (ECDSA P-256, Ed25519, RSA-PSS) and for
+// external signers (a command or a local socket) so that production keys can stay
+// in an HSM or KMS.
+type Signer interface {
+	// Algorithm returns the signature algorithm (AlgECDSAP256SHA256, AlgEd25519, AlgRSAPSSSHA256)
+	Algorithm() string
+	// KeyID identifies the signing key; it is recorded in each checkpoint
+	KeyID() string
+	// Sign returns the signature over msg
+	Sign(msg []byte) ([]byte, error)
+}
+
+// Verifier checks checkpoint signatures.
+type Verifier interface {
+	// Algorithm returns the signature algorithm the key verifies
+	Algorithm() string
+	// KeyID identifies the verifying key (SHA256:<hex> of its PKIX encoding)
+	KeyID() string
+	// Verify reports whether sig is a valid signature over msg
+	Verify(msg, sig []byte) (bool, error)
+}
+
+// fileSigner signs with a private key loaded from a PEM file
+type fileSigner struct {
+	alg   string
+	keyID string
+	key   crypto.Signer
+}
+
+func (s *fileSigner) Algorithm() string { return s.alg }
+func (s *fileSigner) KeyID() string     { return s.keyID }
+
+func (s *fileSigner) Sign(msg []byte) ([]byte, error) {
+	switch s.alg {
+	case AlgEd25519:
+		return s.key.Sign(rand.Reader, msg, crypto.Hash(0))
+	case AlgRSAPSSSHA256:
+		sum := sha256.Sum256(msg)
+		return s.key.Sign(rand.Reader, sum[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+	default:
+		sum := sha256.Sum256(msg)
+		return ecdsa.SignASN1(rand.Reader, s.key.(*ecdsa.PrivateKey), sum[:])
+	}
+}
+
+// fileVerifier verifies with a public key loaded from a PEM file
+type fileVerifier struct {
+	alg   string
+	keyID string
+	key   crypto.PublicKey
+}
+
+func (v *fileVerifier) Algorithm() string { return v.alg }
+func (v *fileVerifier) KeyID() string     { return v.keyID }
+
+func (v *fileVerifier) Verify(msg, sig []byte) (bool, error) {
+	switch v.alg {
+	case AlgEd25519:
+		return ed25519.Verify(v.key.(ed25519.PublicKey), msg, sig), nil
+	case AlgRSAPSSSHA256:
+		sum := sha256.Sum256(msg)
+		err := rsa.VerifyPSS(v.key.(*rsa.PublicKey), crypto.SHA256, sum[:], sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		return err == nil, nil
+	default:
+		sum := sha256.Sum256(msg)
+		return ecdsa.VerifyASN1(v.key.(*ecdsa.PublicKey), sum[:], sig), nil
+	}
+}
+
+// LoadFileSigner loads a PEM private key and returns a signer for it.
+//
+// The algorithm follows from the key type: ECDSA P-256 keys sign with
+// ecdsa-p256-sha256, Ed25519 keys with ed25519 and RSA keys (2048 bits or more)
+// with rsa-pss-sha256. The key ID is the SHA-256 fingerprint of the public key's
+// PKIX encoding, so it matches KeyFingerprint of the exported public key.
+//
+// Supported private key formats:
+// - EC PRIVATE KEY (SEC 1)
+// - RSA PRIVATE KEY (PKCS#1)
+// - PRIVATE KEY (PKCS#8, any of the above key types)
+//
+// Args:
+//   - privateKeyPath: Path to the PEM-encoded private key
+//
+// Returns:
+//   - Signer for the key
+//   - Error if the key can't be read or its type is unsupported
+func LoadFileSigner(privateKeyPath string) (Signer, error) {
+	keyBytes, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read private key: %w", err)
+	}
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM for private key")
+	}
+
+	var key any
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	alg, err := keyAlgorithm(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := publicKeyID(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &fileSigner{alg: alg, keyID: keyID, key: signer}, nil
+}
+
+// LoadFileVerifier loads a PEM public key and returns a verifier for it.
+//
+// Args:
+//   - publicKeyPath: Path to the PEM-encoded public key
+//
+// Returns:
+//   - Verifier for the key
+//   - Error if the key can't be read or its type is unsupported
+func LoadFileVerifier(publicKeyPath string) (Verifier, error) {
+	keyBytes, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read public key: %w", err)
+	}
+	return ParsePublicKeyPEM(keyBytes)
+}
+
+// ParsePublicKeyPEM returns a verifier for a PEM-encoded public key.
+//
+// Supported public key formats:
+// - PUBLIC KEY / EC PUBLIC KEY (PKIX)
+// - RSA PUBLIC KEY (PKCS#1)
+func ParsePublicKeyPEM(data []byte) (Verifier, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM for public key")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY", "EC PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported public key type: %s", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+
+	alg, err := keyAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	keyID, err := publicKeyID(key)
+	if err != nil {
+		return nil, err
+	}
+	return &fileVerifier{alg: alg, keyID: keyID, key: key}, nil
+}
+
+// ResolveVerifier returns the verifier for a checkpoint.
+//
+// publicKeyPath is either a PEM public key, used as is, or a keyring file (see
+// Keyring), from which the key is selected by the checkpoint's key ID and
+// creation time so that checkpoints signed before a rotation stay verifiable.
+//
+// Args:
+//   - publicKeyPath: PEM public key or keyring JSON
+//   - cp: Checkpoint to verify
+//
+// Returns:
+//   - Verifier for the checkpoint's signing key
+//   - Error if the key can't be loaded or the keyring has no matching key
+func ResolveVerifier(publicKeyPath string, cp Checkpoint) (Verifier, error) {
+	data, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read public key: %w", err)
+	}
+	if !isKeyring(data) {
+		return ParsePublicKeyPEM(data)
+	}
+	kr, err := parseKeyring(data)
+	if err != nil {
+		return nil, fmt.Errorf("keyring %s: %w", publicKeyPath, err)
+	}
+	return kr.Select(cp.KeyID, cp.SignatureAlgorithm(), cp.CreatedAt)
+}
+
+// keyAlgorithm maps a public key to the checkpoint algorithm it signs with
+func keyAlgorithm(pub crypto.PublicKey) (string, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		// Only P-256 is supported for ECDSA
+		if k.Curve != elliptic.P256() {
+			return "", fmt.Errorf("unsupported curve: want P-256")
+		}
+		return AlgECDSAP256SHA256, nil
+	case ed25519.PublicKey:
+		return AlgEd25519, nil
+	case *rsa.PublicKey:
+		if k.N.BitLen() < minRSABits {
+			return "", fmt.Errorf("RSA key too small: %d bits, want at least %d", k.N.BitLen(), minRSABits)
+		}
+		return AlgRSAPSSSHA256, nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+// publicKeyID returns SHA256:<hex> of the PKIX encoding of a public key
+func publicKeyID(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("marshal public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return "SHA256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// ValidAlgorithm reports whether alg is a supported checkpoint algorithm.
+func ValidAlgorithm(alg string) bool {
+	switch alg {
+	case AlgECDSAP256SHA256, AlgEd25519, AlgRSAPSSSHA256:
+		return true
+	}
+	return false
+}
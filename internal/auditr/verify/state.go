@@ -53,12 +53,123 @@ func LoadState(path string) (*ChainState, error) {
 	return &st, nil
 }
 
-// SaveState writes state atomically using a temp file + rename.
+// SaveState writes the unnamed chain's state, keeping the named streams already
+// in the state store (see SaveStateStore).
 //
-// This function saves the chain state to a JSON file using an atomic write pattern
+// Args:
+//   - path: Path where to save the state file (empty string means no save)
+//   - state: ChainState to persist
+//
+// Returns:
+//   - Error if state cannot be saved
+func SaveState(path string, state *ChainState) error {
+	// If no path provided, skip saving (not an error)
+	if path == "" {
+		return nil
+	}
+	store, err := LoadStateStore(path)
+	if err != nil {
+		// An unreadable store is replaced, as an unreadable state file always was
+		store = &StateStore{}
+	}
+	store.ChainState = *state
+	return SaveStateStore(path, store)
+}
+
+// StateStore is the content of hashing.state_file: the unnamed chain's state at
+// the top level, as before named chains existed, and one state per named stream.
+//
+// Fields:
+//   - ChainState: State of the unnamed chain (top-level JSON fields)
+//   - Streams: State of each named stream, keyed by stream name
+type StateStore struct {
+	ChainState
+	Streams map[string]*ChainState `json:"streams,omitempty"`
+}
+
+// Stream returns the state of a named stream, or a new chain's state if the
+// stream hasn't been hashed yet. The empty name is the unnamed chain.
+func (s *StateStore) Stream(name string) *ChainState {
+	if name == "" {
+		st := s.ChainState
+		return &st
+	}
+	if st, ok := s.Streams[name]; ok {
+		c := *st
+		return &c
+	}
+	return &ChainState{LastChainIndex: 0, LastHeadHash: zeroHash()}
+}
+
+// LoadStateStore loads the state of every chain; returns an empty store if the
+// file is missing. Files written before named chains existed load as the
+// unnamed chain.
+//
+// Args:
+//   - path: Path to the state file (empty string means no state file)
+//
+// Returns:
+//   - StateStore with loaded or default values
+//   - Error if state file exists but cannot be read/parsed
+func LoadStateStore(path string) (*StateStore, error) {
+	store := &StateStore{ChainState: ChainState{LastHeadHash: zeroHash()}}
+	if path == "" {
+		return store, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, fmt.Errorf("open state: %w", err)
+	}
+	if err := json.Unmarshal(b, store); err != nil {
+		return nil, fmt.Errorf("decode state: %w", err)
+	}
+	if store.LastHeadHash == "" {
+		store.LastHeadHash = zeroHash()
+	}
+	return store, nil
+}
+
+// SaveStreamStates updates the given named streams in the state store.
+//
+// The store is re-read just before writing so that streams hashed by other runs
+// since this run started are kept; each run only replaces the streams it hashed.
+//
+// Args:
+//   - path: Path to the state file (empty string means no save)
+//   - states: New state of each stream hashed by this run
+//
+// Returns:
+//   - Error if the store cannot be read or saved
+func SaveStreamStates(path string, states map[string]*ChainState) error {
+	if path == "" {
+		return nil
+	}
+	store, err := LoadStateStore(path)
+	if err != nil {
+		return err
+	}
+	for name, st := range states {
+		if name == "" {
+			store.ChainState = *st
+			continue
+		}
+		if store.Streams == nil {
+			store.Streams = make(map[string]*ChainState)
+		}
+		store.Streams[name] = st
+	}
+	return SaveStateStore(path, store)
+}
+
+// SaveStateStore writes the state store atomically using a temp file + rename.
+//
+// This function saves the chain states to a JSON file using an atomic write pattern
 // to prevent corruption. The process:
 // 1. Write to a temporary file (path.tmp)
-// 2. Close the temporary file
+// 2. Sync and close the temporary file
 // 3. Atomically rename the temporary file to the final path
 //
 // This ensures that if the process is interrupted, either the old state is preserved
@@ -66,11 +177,11 @@ func LoadState(path string) (*ChainState, error) {
 //
 // Args:
 //   - path: Path where to save the state file (empty string means no save)
-//   - state: ChainState to persist
+//   - store: StateStore to persist
 //
 // Returns:
 //   - Error if state cannot be saved
-func SaveState(path string, state *ChainState) error {
+func SaveStateStore(path string, store *StateStore) error {
 	// If no path provided, skip saving (not an error)
 	if path == "" {
 		return nil
@@ -88,12 +199,19 @@ func SaveState(path string, state *ChainState) error {
 	enc.SetEscapeHTML(false) // Don't escape HTML characters for cleaner JSON
 
 	// Write state to temporary file
-	if err := enc.Encode(state); err != nil {
+	if err := enc.Encode(store); err != nil {
 		f.Close()
 		os.Remove(tmp) // Clean up temp file on error
 		return fmt.Errorf("encode state: %w", err)
 	}
 
+	// Flush to disk before the rename makes the new state visible
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp) // Clean up temp file on error
+		return fmt.Errorf("sync temp state: %w", err)
+	}
+
 	// Close temporary file
 	if err := f.Close(); err != nil {
 		os.Remove(tmp) // Clean up temp file on error
@@ -0,0 +1,103 @@
+package verify
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/timestamp"
+)
+
+// MaxTimestampSkew is how far a checkpoint's CreatedAt may be from the time
+// asserted by its timestamp token
+const MaxTimestampSkew = 5 * time.Minute
+
+// ErrNoTimestamp is returned when a checkpoint must be timestamped but has no token
+var ErrNoTimestamp = errors.New("checkpoint has no timestamp token")
+
+// Timestamper obtains RFC 3161 timestamp tokens for checkpoints.
+//
+// timestamp.Client implements it against a TSA's HTTP endpoint; a LocalTSA can
+// stand in for a real TSA in tests and offline setups.
+type Timestamper interface {
+	// Timestamp returns a DER TimeStampResp over a SHA-256 digest
+	Timestamp(digest []byte) ([]byte, error)
+}
+
+// checkpointImprint is the SHA-256 digest a timestamp token covers: the same
+// canonical checkpoint that is signed
+func checkpointImprint(canon string) []byte {
+	sum := sha256.Sum256([]byte(canon))
+	return sum[:]
+}
+
+// CheckpointTimestamp parses and checks the timestamp token of a checkpoint.
+//
+// The token must cover the canonical checkpoint, carry a valid TSA signature and
+// assert a time within MaxTimestampSkew of the checkpoint's CreatedAt. With
+// trusted certificates the TSA must also chain to one of them; without, only
+// the token's integrity is checked.
+//
+// Args:
+//   - sc: Signed checkpoint carrying the token
+//   - tsaCertPath: PEM TSA (or CA) certificate to trust; empty for integrity only
+//
+// Returns:
+//   - The verified token
+//   - ErrNoTimestamp if the checkpoint has no token, or the check that failed
+func CheckpointTimestamp(sc *SignedCheckpoint, tsaCertPath string) (*timestamp.Token, error) {
+	if sc.TimestampToken == "" {
+		return nil, ErrNoTimestamp
+	}
+	der, err := base64.StdEncoding.DecodeString(sc.TimestampToken)
+	if err != nil {
+		return nil, fmt.Errorf("decode timestamp token: %w", err)
+	}
+	tok, err := timestamp.ParseResponse(der)
+	if err != nil {
+		return nil, err
+	}
+
+	canon, err := canonicalizeCheckpoint(sc.Checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	var trusted []*x509.Certificate
+	if tsaCertPath != "" {
+		if trusted, err = timestamp.LoadCertificates(tsaCertPath); err != nil {
+			return nil, err
+		}
+	}
+	if err := tok.Verify(checkpointImprint(canon), trusted); err != nil {
+		return nil, err
+	}
+
+	skew := tok.GenTime.Sub(sc.Checkpoint.CreatedAt)
+	if skew < -MaxTimestampSkew || skew > MaxTimestampSkew {
+		return nil, fmt.Errorf("checkpoint created_at %s is %s away from its timestamp %s (max %s)",
+			sc.Checkpoint.CreatedAt.UTC().Format(time.RFC3339), skew.Round(time.Second),
+			tok.GenTime.Format(time.RFC3339), MaxTimestampSkew)
+	}
+	return tok, nil
+}
+
+// VerifyCheckpointTimestamp checks that a checkpoint file carries a timestamp
+// token issued by a trusted TSA (see CheckpointTimestamp).
+//
+// Args:
+//   - path: Checkpoint file
+//   - tsaCertPath: PEM TSA (or CA) certificate to trust
+//
+// Returns:
+//   - The verified token
+//   - Error if the checkpoint can't be read, has no token or the token doesn't verify
+func VerifyCheckpointTimestamp(path, tsaCertPath string) (*timestamp.Token, error) {
+	sc, err := LoadCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+	return CheckpointTimestamp(sc, tsaCertPath)
+}
@@ -12,9 +12,22 @@ import "time"
 // Fields:
 //   - LastChainIndex: The index of the last processed event in the chain
 //   - LastHeadHash: The hash of the last processed event (used as hash_prev for next event)
+//   - LastMerkleBatch: Number of the last Merkle batch (Merkle mode only)
+//   - CanonVersion: canon_version recorded in the events hashed next (0 means legacy);
+//     set from hashing.canonicalization, so a chain may switch schemes between runs
 type ChainState struct {
-	LastChainIndex int    `json:"last_chain_index"` // Position in the hash chain
-	LastHeadHash   string `json:"last_head_hash"`   // Hash of the last event
+	LastChainIndex  int    `json:"last_chain_index"`            // Position in the hash chain
+	LastHeadHash    string `json:"last_head_hash"`              // Hash of the last event (batch head in Merkle mode)
+	LastMerkleBatch int    `json:"last_merkle_batch,omitempty"` // Last Merkle batch number
+	CanonVersion    int    `json:"canon_version,omitempty"`     // Canonicalization scheme of new events
+}
+
+// scheme returns the canon_version to record in newly hashed events
+func (s *ChainState) scheme() int {
+	if s.CanonVersion == 0 {
+		return CanonVersionLegacy
+	}
+	return s.CanonVersion
 }
 
 // Checkpoint captures the chain head at a given chain index.
@@ -31,10 +44,25 @@ type ChainState struct {
 //   - ChainIndex: Position in the hash chain when checkpoint was created
 //   - HeadHash: Hash of the last event in the chain at checkpoint time
 //   - CreatedAt: Timestamp when the checkpoint was created (UTC)
+//   - Algorithm: Signature algorithm (absent in older checkpoints, meaning ecdsa-p256-sha256)
+//   - KeyID: Identifier of the signing key (SHA256:<hex> for key files, configured for external signers)
+//   - Stream: Named chain the checkpoint covers (absent for the unnamed chain)
 type Checkpoint struct {
-	ChainIndex int       `json:"chain_index"` // Position in the hash chain
-	HeadHash   string    `json:"head_hash"`   // Hash of the last event
-	CreatedAt  time.Time `json:"created_at"`  // Creation timestamp (UTC)
+	ChainIndex int       `json:"chain_index"`         // Position in the hash chain
+	HeadHash   string    `json:"head_hash"`           // Hash of the last event
+	CreatedAt  time.Time `json:"created_at"`          // Creation timestamp (UTC)
+	Algorithm  string    `json:"algorithm,omitempty"` // Signature algorithm
+	KeyID      string    `json:"key_id,omitempty"`    // Signing key identifier
+	Stream     string    `json:"stream,omitempty"`    // Named chain
+}
+
+// SignatureAlgorithm returns the checkpoint's algorithm, defaulting to
+// ecdsa-p256-sha256 for checkpoints that predate recorded algorithms.
+func (c Checkpoint) SignatureAlgorithm() string {
+	if c.Algorithm == "" {
+		return DefaultAlgorithm
+	}
+	return c.Algorithm
 }
 
 // SignedCheckpoint wraps a checkpoint with a detached signature.
@@ -45,10 +73,13 @@ type Checkpoint struct {
 //
 // Fields:
 //   - Checkpoint: The checkpoint data that was signed
-//   - Signature: Base64-encoded ECDSA signature of the canonicalized checkpoint
+//   - Signature: Base64-encoded signature of the canonicalized checkpoint
+//   - TimestampToken: Base64 DER RFC 3161 TimeStampResp over the SHA-256 of the
+//     canonicalized checkpoint (optional, see timestamping.url)
 type SignedCheckpoint struct {
-	Checkpoint Checkpoint `json:"checkpoint"` // The checkpoint data
-	Signature  string     `json:"signature"`  // Base64-encoded ECDSA signature
+	Checkpoint     Checkpoint `json:"checkpoint"`                // The checkpoint data
+	Signature      string     `json:"signature"`                 // Base64-encoded signature
+	TimestampToken string     `json:"timestamp_token,omitempty"` // RFC 3161 timestamp from a TSA
 }
 
 // VerifySummary is appended to the run log to record verify runs.
@@ -64,21 +95,116 @@ type SignedCheckpoint struct {
 //   - OutputFile: Path to the output file (if any)
 //   - EventsProcessed: Total number of events processed
 //   - TamperedEvents: Indices of events that failed verification (if any)
+//   - Findings: What happened to the failed events, with byte offsets (see TamperFinding)
 //   - CheckpointsVerified: Whether checkpoint verification was performed
-//   - CheckpointPath: Path to checkpoint file (if used)
+//   - CheckpointPath: Path to checkpoint file (if used; the last one written in hash mode)
+//   - CheckpointsWritten: Number of checkpoints written in hash mode
+//   - CheckpointsChecked: Number of manifest / directory checkpoints checked in verify mode
+//   - FailedCheckpoints: Manifest / directory checkpoints that did not match or verify
+//   - LastGoodCheckpoint / LastGoodIndex: Last verified checkpoint before the first divergence
+//   - FirstDivergenceIndex: Chain index of the first failing checkpoint or tampered event
+//   - TimestampsVerified: Checkpoints whose RFC 3161 token verified against --tsa-cert
 //   - Status: Overall status of the verification run
 //   - StartTime: When the verification started (RFC3339 format)
 //   - EndTime: When the verification completed (RFC3339 format)
 type VerifySummary struct {
-	Phase               string `json:"phase"`                     // Processing phase
-	Mode                string `json:"mode"`                      // Verification mode
-	InputFile           string `json:"input_file"`                // Input file path
-	OutputFile          string `json:"output_file,omitempty"`     // Output file path (optional)
-	EventsProcessed     int    `json:"events_processed"`          // Number of events processed
-	TamperedEvents      []int  `json:"tampered_events,omitempty"` // Indices of tampered events
-	CheckpointsVerified bool   `json:"checkpoints_verified"`      // Whether checkpoints were verified
-	CheckpointPath      string `json:"checkpoint_path,omitempty"` // Checkpoint file path (optional)
-	Status              string `json:"status"`                    // Overall status
-	StartTime           string `json:"start_time"`                // Start timestamp (RFC3339)
-	EndTime             string `json:"end_time"`                  // End timestamp (RFC3339)
+	Phase                string          `json:"phase"`                            // Processing phase
+	Mode                 string          `json:"mode"`                             // Verification mode
+	InputFile            string          `json:"input_file"`                       // Input file path
+	OutputFile           string          `json:"output_file,omitempty"`            // Output file path (optional)
+	EventsProcessed      int             `json:"events_processed"`                 // Number of events processed
+	Streams              []string        `json:"streams,omitempty"`                // Named streams hashed or verified
+	TamperedEvents       []int           `json:"tampered_events,omitempty"`        // Indices of tampered events
+	Findings             []TamperFinding `json:"findings,omitempty"`               // Classified tamper findings
+	CheckpointsVerified  bool            `json:"checkpoints_verified"`             // Whether checkpoints were verified
+	CheckpointPath       string          `json:"checkpoint_path,omitempty"`        // Checkpoint file path (optional)
+	CheckpointsWritten   int             `json:"checkpoints_written,omitempty"`    // Checkpoints written (hash mode)
+	CheckpointsChecked   int             `json:"checkpoints_checked,omitempty"`    // Manifest checkpoints checked (verify mode)
+	FailedCheckpoints    []string        `json:"failed_checkpoints,omitempty"`     // Manifest checkpoints that failed
+	LastGoodCheckpoint   string          `json:"last_good_checkpoint,omitempty"`   // Last verified checkpoint before the divergence
+	LastGoodIndex        int             `json:"last_good_index,omitempty"`        // Chain index of that checkpoint
+	FirstDivergenceIndex int             `json:"first_divergence_index,omitempty"` // First failing checkpoint or tampered event
+	TimestampsVerified   int             `json:"timestamps_verified,omitempty"`    // Checkpoint timestamps trusted (verify mode)
+	Status               string          `json:"status"`                           // Overall status
+	StartTime            string          `json:"start_time"`                       // Start timestamp (RFC3339)
+	EndTime              string          `json:"end_time"`                         // End timestamp (RFC3339)
+}
+
+// Signature statuses recorded in a checkpoint attestation
+const (
+	SignatureValid      = "valid"       // Signature verified with the given public key
+	SignatureInvalid    = "invalid"     // Signature did not verify
+	SignatureNotChecked = "not_checked" // No public key was provided
+)
+
+// Attestation records the verification result of one hashed input file.
+//
+// Attestations are attached to report and query output so that a reader can tell
+// which chain segment the output was derived from and whether it was intact at
+// the time the output was produced.
+//
+// Fields:
+//   - Input: Path of the verified file
+//   - Hashed: Whether the file carries hash chain fields at all
+//   - Events: Number of events verified
+//   - FirstChainIndex / LastChainIndex: hash_chain_index range covered by the file
+//   - HeadHash: Hash of the last event
+//   - ChainIntact: Hashed, readable and no tampered events
+//   - TamperedIndices: hash_chain_index of events that failed verification
+//   - Checkpoint: Result of checking a signed checkpoint against this file (optional)
+type Attestation struct {
+	Input           string                 `json:"input"`
+	Hashed          bool                   `json:"hashed"`
+	Events          int                    `json:"events"`
+	FirstChainIndex int                    `json:"first_chain_index"`
+	LastChainIndex  int                    `json:"last_chain_index"`
+	HeadHash        string                 `json:"head_hash,omitempty"`
+	ChainIntact     bool                   `json:"chain_intact"`
+	TamperedIndices []int                  `json:"tampered_indices,omitempty"`
+	Checkpoint      *CheckpointAttestation `json:"checkpoint,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+}
+
+// CheckpointAttestation records how a signed checkpoint relates to an input file.
+//
+// Fields:
+//   - Path: Checkpoint file
+//   - ChainIndex / HeadHash / CreatedAt: Checkpoint contents
+//   - Algorithm / KeyID: Signature algorithm and signing key recorded in the checkpoint
+//   - HeadMatches: The checkpoint head equals the head of the attested file
+//   - SignatureStatus: valid, invalid or not_checked
+//   - KeyFingerprint: SHA-256 fingerprint of the verifying public key (SHA256:<hex>)
+//   - TimestampedAt: Time of the checkpoint's RFC 3161 token, if any
+//   - TimestampTrusted: The token verified against a trusted TSA certificate
+//   - TimestampAuthority: Signer of the token (only when trusted)
+type CheckpointAttestation struct {
+	Path               string     `json:"path"`
+	ChainIndex         int        `json:"chain_index"`
+	HeadHash           string     `json:"head_hash"`
+	CreatedAt          time.Time  `json:"created_at"`
+	Algorithm          string     `json:"algorithm"`
+	KeyID              string     `json:"key_id,omitempty"`
+	HeadMatches        bool       `json:"head_matches"`
+	SignatureStatus    string     `json:"signature_status"`
+	KeyFingerprint     string     `json:"key_fingerprint,omitempty"`
+	TimestampedAt      *time.Time `json:"timestamped_at,omitempty"`
+	TimestampTrusted   bool       `json:"timestamp_trusted,omitempty"`
+	TimestampAuthority string     `json:"timestamp_authority,omitempty"`
+	Error              string     `json:"error,omitempty"`
+}
+
+// AttestationSet is the attestation attached to report and query output: one
+// entry per input file, with the checkpoint (if any) checked against the last file.
+//
+// Verified is true when every input is hashed and intact and, if a checkpoint was
+// given, its head matches and its signature was checked and is valid. When the set is
+// written next to derived output (query results), Output, OutputEvents and
+// OutputSHA256 bind the attestation to that output.
+type AttestationSet struct {
+	VerifiedAt   time.Time      `json:"verified_at"`
+	Verified     bool           `json:"verified"`
+	Attestations []*Attestation `json:"attestations"`
+	Output       string         `json:"output,omitempty"`        // Output file ("-" for stdout)
+	OutputEvents int            `json:"output_events,omitempty"` // Events written to the output
+	OutputSHA256 string         `json:"output_sha256,omitempty"` // SHA-256 of the output bytes
 }
@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/vaibhaw-/AuditR/internal/auditr/config"
 	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+	"github.com/vaibhaw-/AuditR/internal/auditr/timestamp"
 )
 
 // VerifyArgs contains the command-line arguments and configuration for the verify phase
@@ -20,20 +23,30 @@ import (
 //   - InputFile: Path to input NDJSON file (empty means stdin)
 //   - OutputFile: Path to output file (empty means stdout for hash mode)
 //   - Checkpoint: Whether to create a checkpoint after processing
-//   - PrivateKeyPath: Path to ECDSA private key for signing checkpoints
-//   - PublicKeyPath: Path to ECDSA public key for verifying checkpoints
+//   - PrivateKeyPath: Path to private key (ECDSA P-256, Ed25519 or RSA) for signing checkpoints
+//   - PublicKeyPath: Path to public key for verifying checkpoints
 //   - SummaryOnly: Whether to output minimal summary information
 //   - Detailed: Whether to output detailed information including timing
 //   - CheckpointPath: Path to checkpoint file for verification (verify mode only)
+//   - ManifestPath: Path to a checkpoint manifest; every checkpoint in the input's range is checked (verify mode only)
+//   - CheckpointDir: Directory whose signed checkpoints are all checked against the input (verify mode only)
+//   - MerkleBatchSize: Events per Merkle tree in hash mode (0 = hashing.merkle_batch_size)
+//   - TSACertPath: TSA (or CA) certificate; checked checkpoints must carry a timestamp token it vouches for (verify mode only)
+//   - Stream: Named chain; hash mode puts every event on it, verify mode reports events of other streams as spliced
 type VerifyArgs struct {
-	InputFile      string // Input NDJSON file path (empty = stdin)
-	OutputFile     string // Output file path (empty = stdout for hash mode)
-	Checkpoint     bool   // Whether to create checkpoint after processing
-	PrivateKeyPath string // ECDSA private key for signing
-	PublicKeyPath  string // ECDSA public key for verification
-	SummaryOnly    bool   // Minimal output mode
-	Detailed       bool   // Detailed output mode with timing
-	CheckpointPath string // Checkpoint file path (verify mode only)
+	InputFile       string // Input NDJSON file path (empty = stdin)
+	OutputFile      string // Output file path (empty = stdout for hash mode)
+	Checkpoint      bool   // Whether to create checkpoint after processing
+	PrivateKeyPath  string // Private key for signing
+	PublicKeyPath   string // Public key for verification
+	SummaryOnly     bool   // Minimal output mode
+	Detailed        bool   // Detailed output mode with timing
+	CheckpointPath  string // Checkpoint file path (verify mode only)
+	ManifestPath    string // Checkpoint manifest path (verify mode only)
+	CheckpointDir   string // Checkpoint directory (verify mode only)
+	TSACertPath     string // Trusted TSA certificate for checkpoint timestamps (verify mode only)
+	MerkleBatchSize int    // Merkle batch size override (hash mode only)
+	Stream          string // Named stream to hash onto or verify
 }
 
 // RunVerifyPhase is the main entry point for the verify phase orchestration
@@ -68,23 +81,87 @@ func RunVerifyPhase(cfg *config.Config, args VerifyArgs) error {
 		mode = "verify"
 	}
 
+	// Parse the checkpoint schedule (file_end, every N events, every T duration)
+	policy, err := ParseCheckpointInterval(cfg.Hashing.CheckpointInterval)
+	if err != nil {
+		return err
+	}
+
+	// Merkle mode batches events into trees whose roots are chained
+	merkleBatch := cfg.Hashing.MerkleBatchSize
+	if args.MerkleBatchSize != 0 {
+		merkleBatch = args.MerkleBatchSize
+	}
+	if merkleBatch < 0 {
+		return fmt.Errorf("invalid merkle batch size %d: must be positive (or 0 for a linear chain)", merkleBatch)
+	}
+
+	// Named streams: a fixed --stream or streams keyed by hashing.stream_by
+	streamOf := StreamKey(args.Stream, cfg.Hashing.StreamBy)
+	if mode == "hash" && streamOf != nil && merkleBatch > 0 {
+		return fmt.Errorf("named streams (--stream, hashing.stream_by) require a linear chain: unset the merkle batch size")
+	}
+
+	// Canonicalization scheme recorded in newly hashed events
+	canonVersion, err := ParseCanonicalization(cfg.Hashing.Canonicalization)
+	if err != nil {
+		return err
+	}
+
 	// Validate all requirements upfront before doing any work
+	var signer Signer
 	if mode == "hash" {
-		// Hash mode: validate checkpoint requirements if checkpointing is requested
-		if args.Checkpoint || cfg.Hashing.CheckpointInterval == "file_end" {
-			key := args.PrivateKeyPath
-			if key == "" {
-				key = cfg.Signing.PrivateKeyPath
-			}
-			if key == "" {
-				return fmt.Errorf("checkpoint requested but signing key not provided (use --private-key or signing.private_key_path)")
-			}
-			if _, err := os.Stat(key); err != nil {
-				return fmt.Errorf("checkpoint requested but signing key not found: %s", key)
+		// Hash mode: load the signer (key file or external signer) if checkpointing is requested
+		if args.Checkpoint || policy.Enabled() {
+			signer, err = NewSigner(cfg, args.PrivateKeyPath)
+			if err != nil {
+				return err
 			}
 		}
 	} else {
 		// Verify mode: validate checkpoint requirements if checkpoint verification is requested
+		sources := 0
+		for _, p := range []string{args.CheckpointPath, args.ManifestPath, args.CheckpointDir} {
+			if p != "" {
+				sources++
+			}
+		}
+		if sources > 1 {
+			return fmt.Errorf("use only one of --checkpoint-path, --manifest or --checkpoint-dir")
+		}
+		if args.ManifestPath != "" || args.CheckpointDir != "" {
+			what := "manifest"
+			if args.CheckpointDir != "" {
+				what = "checkpoint directory"
+			}
+			if args.InputFile == "" {
+				return fmt.Errorf("%s verification requires --input", what)
+			}
+			if args.PublicKeyPath == "" {
+				return fmt.Errorf("%s verification requires --public-key", what)
+			}
+			if _, err := os.Stat(args.PublicKeyPath); err != nil {
+				return fmt.Errorf("public key not found: %s", args.PublicKeyPath)
+			}
+		}
+		if args.ManifestPath != "" {
+			if _, err := os.Stat(args.ManifestPath); err != nil {
+				return fmt.Errorf("checkpoint manifest not found: %s", args.ManifestPath)
+			}
+		}
+		if args.CheckpointDir != "" {
+			if fi, err := os.Stat(args.CheckpointDir); err != nil || !fi.IsDir() {
+				return fmt.Errorf("checkpoint directory not found: %s", args.CheckpointDir)
+			}
+		}
+		if args.TSACertPath != "" {
+			if sources == 0 {
+				return fmt.Errorf("timestamp verification (--tsa-cert) requires --checkpoint-path, --manifest or --checkpoint-dir")
+			}
+			if _, err := os.Stat(args.TSACertPath); err != nil {
+				return fmt.Errorf("TSA certificate not found: %s", args.TSACertPath)
+			}
+		}
 		if args.CheckpointPath != "" {
 			if args.PublicKeyPath == "" {
 				return fmt.Errorf("checkpoint verification requires --public-key")
@@ -100,9 +177,35 @@ func RunVerifyPhase(cfg *config.Config, args VerifyArgs) error {
 
 	log.Infow("verify phase start", "mode", mode, "input", args.InputFile, "output", args.OutputFile)
 
+	// Hash runs continue the chain state: hold its lock for the whole run, resolve
+	// a previous run that was interrupted, then record this run before hashing
+	// (before the output is created, which would replace an interrupted output)
+	stateFile := cfg.Hashing.StateFile
+	if mode == "hash" && stateFile != "" {
+		lock, err := LockState(stateFile)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := lock.Unlock(); err != nil {
+				log.Warnw("could not release chain state lock", "state_file", stateFile, "error", err)
+			}
+		}()
+		if _, err := RecoverInterruptedRun(stateFile, args.OutputFile); err != nil {
+			return err
+		}
+		store, err := LoadStateStore(stateFile)
+		if err != nil {
+			store = &StateStore{ChainState: ChainState{LastHeadHash: zeroHash()}}
+		}
+		rec := RunRecord{Input: args.InputFile, Output: args.OutputFile, Merkle: merkleBatch > 0 && streamOf == nil, State: store}
+		if err := BeginRun(stateFile, rec); err != nil {
+			return err
+		}
+	}
+
 	// Set up input file (stdin if no file specified)
 	var in *os.File
-	var err error
 	if args.InputFile == "" {
 		in = os.Stdin
 	} else {
@@ -139,35 +242,60 @@ func RunVerifyPhase(cfg *config.Config, args VerifyArgs) error {
 	}
 
 	// Execute the appropriate operation based on mode
-	if mode == "hash" {
+	if mode == "hash" && streamOf != nil {
+		// HASH MODE, NAMED STREAMS: one chain per stream
+		if err := hashStreams(cfg, args, in, out, streamOf, canonVersion, policy, signer, &summary); err != nil {
+			return err
+		}
+		if err := CompleteRun(stateFile); err != nil {
+			return err
+		}
+		summary.Status = "sealed"
+	} else if mode == "hash" {
 		// HASH MODE: Compute hash chains for events
 
 		// Load previous state to resume chain from where it left off
 		state, _ := LoadState(cfg.Hashing.StateFile)
-		log.Debugw("state loaded", "index", state.LastChainIndex, "head", state.LastHeadHash)
+		state.CanonVersion = canonVersion
+		log.Debugw("state loaded", "index", state.LastChainIndex, "head", state.LastHeadHash, "canon_version", canonVersion)
+
+		// Set up periodic checkpoints (every N events / T duration) while streaming
+		var cp *Checkpointer
+		if signer != nil {
+			cp = newSignedCheckpointer(cfg, cfg.Hashing.CheckpointDir, "", policy, state.LastChainIndex, signer)
+		}
 
 		// Compute hash chain for all events in input
-		newState, processed, err := ComputeChain(in, out, state)
+		var newState *ChainState
+		var processed int
+		if merkleBatch > 0 {
+			newState, processed, err = ComputeMerkleChain(in, out, state, merkleBatch, cp)
+		} else {
+			newState, processed, err = ComputeChainWithCheckpoints(in, out, state, cp)
+		}
+		if cp != nil && len(cp.Written) > 0 {
+			summary.CheckpointPath = cp.Written[len(cp.Written)-1]
+			summary.CheckpointsWritten = len(cp.Written)
+		}
 		if err != nil {
 			return err
 		}
 		summary.EventsProcessed = processed
 
-		// Create checkpoint if requested (via flag or configured interval)
-		if args.Checkpoint || cfg.Hashing.CheckpointInterval == "file_end" {
-			// Determine which private key to use (command line takes precedence)
-			key := args.PrivateKeyPath
-			if key == "" {
-				key = cfg.Signing.PrivateKeyPath
+		// Create the file-end checkpoint if requested (via flag or configured interval)
+		if args.Checkpoint || policy.FileEnd {
+			// Flush events before sealing them with a checkpoint (validation already done upfront)
+			if err := out.Sync(); err != nil && out != os.Stdout {
+				return fmt.Errorf("sync output: %w", err)
 			}
-
-			// Create and sign checkpoint (validation already done upfront)
-			path, err := WriteCheckpoint(cfg.Hashing.CheckpointDir, newState.LastChainIndex, newState.LastHeadHash, key)
-			if err != nil {
+			if err := cp.Write(newState.LastChainIndex, newState.LastHeadHash); err != nil {
 				return err
 			}
-			log.Infow("checkpoint written", "path", path, "index", newState.LastChainIndex)
-			summary.CheckpointPath = path
+			summary.CheckpointPath = cp.Written[len(cp.Written)-1]
+			summary.CheckpointsWritten = len(cp.Written)
+		}
+		if summary.CheckpointsWritten > 0 {
+			log.Infow("checkpoint written", "path", summary.CheckpointPath, "index", newState.LastChainIndex, "checkpoints", summary.CheckpointsWritten)
 		}
 
 		// Save updated state for next run
@@ -175,21 +303,111 @@ func RunVerifyPhase(cfg *config.Config, args VerifyArgs) error {
 			return err
 		}
 		log.Debugw("state saved", "index", newState.LastChainIndex, "head", newState.LastHeadHash)
+		if err := CompleteRun(stateFile); err != nil {
+			return err
+		}
 		summary.Status = "sealed"
 	} else {
 		// VERIFY MODE: Verify existing hash chains
 
-		// Verify the hash chain integrity
-		tampered, headHash, processed, err := VerifyChain(in)
+		// Verify the hash chain integrity, collecting the recomputed heads at the
+		// checkpoints of a manifest or checkpoint directory when one is given
+		var tampered []int
+		var headHash string
+		var processed int
+		var results, failed []CheckpointResult
+		var truncations []TamperFinding
+		var check *ChainCheck
+		var dirResult *CheckpointDirResult
+		source := args.ManifestPath
+		if args.ManifestPath != "" {
+			tampered, headHash, processed, results, truncations, err = VerifyManifest(args.InputFile, args.ManifestPath, args.PublicKeyPath, args.Stream)
+			for _, r := range results {
+				if !r.OK() {
+					failed = append(failed, r)
+				}
+			}
+		} else if args.CheckpointDir != "" {
+			source = args.CheckpointDir
+			tampered, headHash, processed, dirResult, err = VerifyCheckpointDir(args.InputFile, args.CheckpointDir, args.PublicKeyPath, args.Stream)
+			if dirResult != nil {
+				results, failed, truncations = dirResult.Results, dirResult.Failed(), dirResult.Truncations
+				if dirResult.Skipped > 0 {
+					log.Debugw("checkpoints outside input range skipped", "dir", args.CheckpointDir, "skipped", dirResult.Skipped)
+				}
+			}
+		} else {
+			check, err = CheckChain(in, nil, args.Stream)
+			tampered, headHash, processed = check.Tampered, check.Head, check.Processed
+			summary.Findings = check.Findings
+			for name := range check.Streams {
+				if name != "" {
+					summary.Streams = append(summary.Streams, name)
+				}
+			}
+			slices.Sort(summary.Streams)
+		}
 		if err != nil {
 			return err
 		}
 		summary.EventsProcessed = processed
 		summary.TamperedEvents = tampered
 
+		// Classify what happened to the events of a failed manifest or directory check
+		if source != "" && (len(tampered) > 0 || len(failed) > 0) {
+			findings, err := DiagnoseChain(args.InputFile, args.Stream)
+			if err != nil {
+				return err
+			}
+			summary.Findings = findings
+		}
+		// Checkpoints beyond the end of the file mean its tail was cut off
+		summary.Findings = append(summary.Findings, truncations...)
+
 		// Verify checkpoint if provided (optional in verify mode)
 		verified := true
-		if args.CheckpointPath != "" {
+		if source != "" {
+			summary.CheckpointsChecked = len(results)
+			for _, r := range failed {
+				summary.FailedCheckpoints = append(summary.FailedCheckpoints, r.Path)
+				log.Warnw("checkpoint failed", "path", r.Path, "index", r.ChainIndex,
+					"head_matches", r.HeadMatches, "signature_valid", r.SignatureValid, "error", r.Error)
+			}
+			verified = len(failed) == 0 && len(truncations) == 0
+			summary.CheckpointsVerified = verified && len(results) > 0
+			summary.CheckpointPath = source
+			if len(results) == 0 {
+				log.Warnw("no checkpoints in range of input - verifying hash chain only", "source", source)
+			}
+
+			// Narrow a failure down to the events after the last verified checkpoint
+			// (a checkpoint directory already did, stream by stream)
+			lastGood, divergence := LastGoodCheckpoint(results, tampered)
+			if dirResult != nil {
+				lastGood, divergence = dirResult.LastGood, dirResult.Divergence
+			} else if divergence == 0 && len(truncations) > 0 {
+				divergence = truncations[0].ChainIndex
+			}
+			if lastGood != nil {
+				summary.LastGoodCheckpoint = lastGood.Path
+				summary.LastGoodIndex = lastGood.ChainIndex
+			}
+			summary.FirstDivergenceIndex = divergence
+			log.Infow("checkpoints verify", "source", source, "checked", len(results), "failed", len(failed),
+				"last_good_index", summary.LastGoodIndex, "first_divergence_index", summary.FirstDivergenceIndex)
+		} else if args.CheckpointPath != "" {
+			// A checkpoint of a named stream signs that stream's head
+			sc, err := LoadCheckpoint(args.CheckpointPath)
+			if err != nil {
+				return err
+			}
+			last := check.Last
+			if s, ok := check.Streams[sc.Checkpoint.Stream]; ok {
+				headHash, last = s.Head, s.Last
+			} else if sc.Checkpoint.Stream != "" {
+				headHash, last = "", 0 // The file holds no event of the checkpoint's stream
+			}
+
 			// Verify the checkpoint signature and head hash match (validation already done upfront)
 			v, err := VerifyCheckpoint(args.CheckpointPath, args.PublicKeyPath, headHash)
 			if err != nil {
@@ -197,18 +415,55 @@ func RunVerifyPhase(cfg *config.Config, args VerifyArgs) error {
 			}
 			verified = v
 			summary.CheckpointsVerified = v
-			log.Infow("checkpoint verify", "path", args.CheckpointPath, "result", v)
+			log.Infow("checkpoint verify", "path", args.CheckpointPath, "stream", sc.Checkpoint.Stream, "result", v)
+
+			// A checkpoint beyond the end of the file means its tail was cut off
+			if !v {
+				if f := TruncationFinding(sc.Checkpoint, last, check.Size); f != nil {
+					f.Stream = sc.Checkpoint.Stream
+					summary.Findings = append(summary.Findings, *f)
+				}
+			}
 		} else {
 			// No checkpoint provided - just verify hash chain integrity
 			summary.CheckpointsVerified = false
 			log.Infow("no checkpoint provided - verifying hash chain only")
 		}
 
+		// Require a trusted TSA timestamp on every checked checkpoint
+		if args.TSACertPath != "" {
+			paths := []string{args.CheckpointPath}
+			if source != "" {
+				paths = paths[:0]
+				for _, r := range results {
+					paths = append(paths, r.Path)
+				}
+			}
+			for _, p := range paths {
+				tok, err := VerifyCheckpointTimestamp(p, args.TSACertPath)
+				if err != nil {
+					verified = false
+					summary.CheckpointsVerified = false
+					if !slices.Contains(summary.FailedCheckpoints, p) {
+						summary.FailedCheckpoints = append(summary.FailedCheckpoints, p)
+					}
+					log.Warnw("checkpoint timestamp failed", "path", p, "error", err.Error())
+					continue
+				}
+				summary.TimestampsVerified++
+				log.Debugw("checkpoint timestamp verified", "path", p, "time", tok.GenTime, "tsa", tok.TSAName())
+			}
+			log.Infow("checkpoint timestamps verify", "checked", len(paths), "verified", summary.TimestampsVerified)
+		}
+
 		// Determine overall status based on chain and checkpoint verification
 		summary.Status = "pass"
 		if len(tampered) > 0 || !verified {
 			summary.Status = "fail"
 		}
+		for _, f := range summary.Findings {
+			log.Warnw("tamper finding", "type", f.Type, "stream", f.Stream, "index", f.ChainIndex, "count", f.Count, "offset", f.Offset, "detail", f.Detail)
+		}
 		_ = headHash // Suppress unused variable warning
 	}
 
@@ -222,6 +477,7 @@ func RunVerifyPhase(cfg *config.Config, args VerifyArgs) error {
 		// In summary mode, remove detailed fields to reduce log size
 		if args.SummaryOnly {
 			summary.TamperedEvents = nil
+			summary.Findings = nil
 		}
 
 		// Choose logging format based on detail level
@@ -234,9 +490,18 @@ func RunVerifyPhase(cfg *config.Config, args VerifyArgs) error {
 				"input_file":           summary.InputFile,
 				"output_file":          summary.OutputFile,
 				"events_processed":     summary.EventsProcessed,
+				"streams":              summary.Streams,
 				"tampered_events":      summary.TamperedEvents,
+				"findings":             summary.Findings,
 				"checkpoints_verified": summary.CheckpointsVerified,
 				"checkpoint_path":      summary.CheckpointPath,
+				"checkpoints_written":  summary.CheckpointsWritten,
+				"checkpoints_checked":  summary.CheckpointsChecked,
+				"failed_checkpoints":   summary.FailedCheckpoints,
+				"last_good_checkpoint": summary.LastGoodCheckpoint,
+				"last_good_index":      summary.LastGoodIndex,
+				"first_divergence":     summary.FirstDivergenceIndex,
+				"timestamps_verified":  summary.TimestampsVerified,
 				"status":               summary.Status,
 				"start_time":           summary.StartTime,
 				"end_time":             summary.EndTime,
@@ -260,12 +525,128 @@ func RunVerifyPhase(cfg *config.Config, args VerifyArgs) error {
 		// Detailed output with timing and additional metrics
 		fmt.Printf("verify %s: %s (events=%d, duration_ms=%.2f, checkpoint=%s, tampered=%d)\n",
 			mode, summary.Status, summary.EventsProcessed, durationMs, summary.CheckpointPath, len(summary.TamperedEvents))
+		if summary.CheckpointsWritten > 0 || summary.CheckpointsChecked > 0 {
+			fmt.Printf("  checkpoints: written=%d checked=%d failed=%d\n",
+				summary.CheckpointsWritten, summary.CheckpointsChecked, len(summary.FailedCheckpoints))
+		}
+		if args.TSACertPath != "" {
+			fmt.Printf("  timestamps: verified=%d\n", summary.TimestampsVerified)
+		}
+		if len(summary.Streams) > 0 {
+			fmt.Printf("  streams: %s\n", strings.Join(summary.Streams, ", "))
+		}
+		for _, f := range summary.Findings {
+			fmt.Printf("  %s\n", f)
+		}
+	}
+	if summary.FirstDivergenceIndex > 0 && !args.SummaryOnly {
+		// Point a tamper investigation at the bounded range of unverified events
+		if summary.LastGoodCheckpoint != "" {
+			fmt.Printf("last good checkpoint: index %d (%s); divergence within events %d-%d\n",
+				summary.LastGoodIndex, summary.LastGoodCheckpoint, summary.LastGoodIndex+1, summary.FirstDivergenceIndex)
+		} else {
+			fmt.Printf("no verified checkpoint before the divergence; divergence at or before event %d\n", summary.FirstDivergenceIndex)
+		}
 	}
 
 	log.Infow("verify phase end", "status", summary.Status, "events", summary.EventsProcessed)
 	return nil
 }
 
+// newSignedCheckpointer creates the checkpointer of one chain, signing with the
+// given signer and timestamping through the configured TSA, if any
+func newSignedCheckpointer(cfg *config.Config, dir, stream string, policy CheckpointPolicy, lastIndex int, signer Signer) *Checkpointer {
+	log := logger.L()
+	cp := NewCheckpointer(dir, "", policy, lastIndex)
+	cp.Stream = stream
+	cp.Signer = signer
+	cp.StatePath = cfg.Hashing.StateFile
+	log.Debugw("checkpoint signer", "stream", stream, "algorithm", signer.Algorithm(), "key_id", signer.KeyID())
+	if cfg.Timestamping.URL != "" {
+		cp.Timestamper = &timestamp.Client{URL: cfg.Timestamping.URL}
+		log.Debugw("checkpoint timestamping", "tsa", cfg.Timestamping.URL)
+	}
+	return cp
+}
+
+// hashStreams is hash mode for named streams.
+//
+// Each stream continues its own chain from the state store and writes its
+// checkpoints to its own directory (see StreamDir); only the streams found in
+// the input are updated in the store.
+//
+// Args:
+//   - cfg / args: As for RunVerifyPhase
+//   - in / out: Input events and hashed output
+//   - streamOf: Names each event's stream (see StreamKey)
+//   - canonVersion: canon_version recorded in the events
+//   - policy / signer: Checkpoint schedule and signer (nil signer for no checkpoints)
+//   - summary: Updated with the events, streams and checkpoints written
+//
+// Returns:
+//   - Error if hashing, checkpointing or saving the state fails
+func hashStreams(cfg *config.Config, args VerifyArgs, in, out *os.File, streamOf func(map[string]interface{}) string,
+	canonVersion int, policy CheckpointPolicy, signer Signer, summary *VerifySummary) error {
+	log := logger.L()
+
+	// Load every stream's previous state to resume its chain
+	store, err := LoadStateStore(cfg.Hashing.StateFile)
+	if err != nil {
+		log.Warnw("state store unreadable - starting new chains", "path", cfg.Hashing.StateFile, "err", err.Error())
+		store = &StateStore{}
+	}
+	h := &StreamHasher{StreamOf: streamOf, States: make(map[string]*ChainState), CanonVersion: canonVersion}
+	for name := range store.Streams {
+		h.States[name] = store.Stream(name)
+	}
+	if signer != nil {
+		h.NewCheckpointer = func(stream string, st *ChainState) *Checkpointer {
+			return newSignedCheckpointer(cfg, StreamDir(cfg.Hashing.CheckpointDir, stream), stream, policy, st.LastChainIndex, signer)
+		}
+	}
+
+	processed, err := h.Compute(in, out)
+	written := func() {
+		for _, name := range h.Streams() {
+			if cp := h.Checkpointers[name]; cp != nil && len(cp.Written) > 0 {
+				summary.CheckpointPath = cp.Written[len(cp.Written)-1]
+				summary.CheckpointsWritten += len(cp.Written)
+			}
+		}
+	}
+	if err != nil {
+		written()
+		return err
+	}
+	summary.EventsProcessed = processed
+	summary.Streams = h.Streams()
+
+	// Create each stream's file-end checkpoint if requested
+	if args.Checkpoint || policy.FileEnd {
+		if err := out.Sync(); err != nil && out != os.Stdout {
+			return fmt.Errorf("sync output: %w", err)
+		}
+		for _, name := range h.Streams() {
+			st := h.States[name]
+			if err := h.Checkpointers[name].Write(st.LastChainIndex, st.LastHeadHash); err != nil {
+				return fmt.Errorf("checkpoint of stream %q: %w", name, err)
+			}
+		}
+	}
+	written()
+	if summary.CheckpointsWritten > 0 {
+		log.Infow("checkpoints written", "streams", len(summary.Streams), "checkpoints", summary.CheckpointsWritten)
+	}
+
+	// Save the state of the streams hashed by this run
+	states := make(map[string]*ChainState, len(summary.Streams))
+	for _, name := range summary.Streams {
+		states[name] = h.States[name]
+		log.Debugw("stream state", "stream", name, "index", h.States[name].LastChainIndex, "head", h.States[name].LastHeadHash)
+	}
+	return SaveStreamStates(cfg.Hashing.StateFile, states)
+}
+
 // appendVerifyRunLog appends a verify summary to the run log file
 //
 // This function writes a VerifySummary struct as a JSON line to the specified
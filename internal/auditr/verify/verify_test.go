@@ -2,12 +2,22 @@ package verify
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,6 +25,7 @@ import (
 	"time"
 
 	"github.com/vaibhaw-/AuditR/internal/auditr/config"
+	"github.com/vaibhaw-/AuditR/internal/auditr/timestamp"
 )
 
 func TestCanonicalize_IsDeterministic(t *testing.T) {
@@ -299,9 +310,7 @@ func TestRunVerifyPhase_HashMode_WithCheckpoint_MissingKey(t *testing.T) {
 			CheckpointDir:      dir,
 			CheckpointInterval: "file_end",
 		},
-		Signing: struct {
-			PrivateKeyPath string `mapstructure:"private_key_path"`
-		}{
+		Signing: config.SigningCfg{
 			PrivateKeyPath: "", // Missing key
 		},
 	}
@@ -349,9 +358,7 @@ func TestRunVerifyPhase_HashMode_WithCheckpoint_ValidKey(t *testing.T) {
 			CheckpointDir:      dir,
 			CheckpointInterval: "file_end",
 		},
-		Signing: struct {
-			PrivateKeyPath string `mapstructure:"private_key_path"`
-		}{
+		Signing: config.SigningCfg{
 			PrivateKeyPath: privPath,
 		},
 	}
@@ -626,3 +633,1801 @@ func createValidCheckpoint(t *testing.T, dir, privPath, headHash string) string
 func zeroHashForTest() string {
 	return "0000000000000000000000000000000000000000000000000000000000000000"
 }
+
+func TestAttest_ChainAndCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := mustGenKeys(t, dir)
+
+	in := "{\"a\":1}\n{\"a\":2}\n{\"a\":3}\n"
+	var out bytes.Buffer
+	state, _, err := ComputeChain(strings.NewReader(in), &out, nil)
+	if err != nil {
+		t.Fatalf("compute: %v", err)
+	}
+	input := filepath.Join(dir, "hashed.jsonl")
+	if err := os.WriteFile(input, out.Bytes(), 0644); err != nil {
+		t.Fatalf("write input: %v", err)
+	}
+	cp, err := WriteCheckpoint(dir, state.LastChainIndex, state.LastHeadHash, privPath)
+	if err != nil {
+		t.Fatalf("write checkpoint: %v", err)
+	}
+
+	set, err := Attest([]string{input}, cp, pubPath, "")
+	if err != nil {
+		t.Fatalf("attest: %v", err)
+	}
+	if !set.Verified || len(set.Attestations) != 1 {
+		t.Fatalf("expected one verified attestation, got %+v", set)
+	}
+	a := set.Attestations[0]
+	if !a.Hashed || !a.ChainIntact || a.Events != 3 || a.FirstChainIndex != 1 || a.LastChainIndex != 3 {
+		t.Fatalf("unexpected chain attestation: %+v", a)
+	}
+	if a.HeadHash != state.LastHeadHash {
+		t.Fatalf("head hash = %s, want %s", a.HeadHash, state.LastHeadHash)
+	}
+	if a.Checkpoint == nil || !a.Checkpoint.HeadMatches || a.Checkpoint.SignatureStatus != SignatureValid {
+		t.Fatalf("unexpected checkpoint attestation: %+v", a.Checkpoint)
+	}
+	fp, err := KeyFingerprint(pubPath)
+	if err != nil || a.Checkpoint.KeyFingerprint != fp || !strings.HasPrefix(fp, "SHA256:") {
+		t.Fatalf("key fingerprint = %q, want %q (err %v)", a.Checkpoint.KeyFingerprint, fp, err)
+	}
+
+	// A different key invalidates the signature but not the head match
+	_, otherPub := mustGenKeys(t, t.TempDir())
+	set, _ = Attest([]string{input}, cp, otherPub, "")
+	if set.Verified || set.Checkpoint().SignatureStatus != SignatureInvalid || !set.Checkpoint().HeadMatches {
+		t.Fatalf("expected invalid signature, got %+v", set.Checkpoint())
+	}
+
+	// Without a public key the signature is not checked, so the set is not verified
+	set, _ = Attest([]string{input}, cp, "", "")
+	if set.Verified || set.Checkpoint().SignatureStatus != SignatureNotChecked || set.Checkpoint().KeyFingerprint != "" {
+		t.Fatalf("expected unchecked signature and an unverified set, got verified=%v %+v", set.Verified, set.Checkpoint())
+	}
+	if !set.Checkpoint().HeadMatches || !set.Attestations[0].ChainIntact {
+		t.Fatalf("unchecked signature must not affect the head match or chain: %+v", set.Attestations[0])
+	}
+}
+
+func TestAttest_UnhashedAndStdin(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.jsonl")
+	if err := os.WriteFile(plain, []byte("{\"a\":1}\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	set, err := Attest([]string{plain}, "", "", "")
+	if err != nil {
+		t.Fatalf("attest: %v", err)
+	}
+	if set.Verified || set.Attestations[0].Hashed || set.Attestations[0].ChainIntact {
+		t.Fatalf("unhashed file must not verify: %+v", set.Attestations[0])
+	}
+
+	if _, err := Attest(nil, "", "", ""); err == nil {
+		t.Fatalf("expected error without input files")
+	}
+}
+
+func TestParseCheckpointInterval(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    CheckpointPolicy
+		wantErr bool
+	}{
+		{in: "", want: CheckpointPolicy{}},
+		{in: "none", want: CheckpointPolicy{}},
+		{in: "file_end", want: CheckpointPolicy{FileEnd: true}},
+		{in: "10000", want: CheckpointPolicy{Every: 10000, FileEnd: true}},
+		{in: "15m", want: CheckpointPolicy{Period: 15 * time.Minute, FileEnd: true}},
+		{in: "10000, 15m", want: CheckpointPolicy{Every: 10000, Period: 15 * time.Minute, FileEnd: true}},
+		{in: "0", wantErr: true},
+		{in: "-5m", wantErr: true},
+		{in: "10,20", wantErr: true},
+		{in: "hourly", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseCheckpointInterval(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseCheckpointInterval(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseCheckpointInterval(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestComputeChainWithCheckpoints_EveryN(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := mustGenKeys(t, dir)
+	cpDir := filepath.Join(dir, "checkpoints")
+
+	var in strings.Builder
+	for i := 0; i < 7; i++ {
+		in.WriteString(`{"n":` + string(rune('0'+i)) + "}\n")
+	}
+	cp := NewCheckpointer(cpDir, privPath, CheckpointPolicy{Every: 3, FileEnd: true}, 0)
+	var out bytes.Buffer
+	state, _, err := ComputeChainWithCheckpoints(strings.NewReader(in.String()), &out, nil, cp)
+	if err != nil {
+		t.Fatalf("compute: %v", err)
+	}
+	if err := cp.Write(state.LastChainIndex, state.LastHeadHash); err != nil {
+		t.Fatalf("file end checkpoint: %v", err)
+	}
+	// Writing the same index again is a no-op
+	if err := cp.Write(state.LastChainIndex, state.LastHeadHash); err != nil {
+		t.Fatalf("repeat checkpoint: %v", err)
+	}
+	if len(cp.Written) != 3 {
+		t.Fatalf("expected checkpoints at 3, 6 and 7, got %v", cp.Written)
+	}
+
+	m, err := LoadManifest(filepath.Join(cpDir, ManifestFile))
+	if err != nil {
+		t.Fatalf("load manifest: %v", err)
+	}
+	var indices []int
+	for _, e := range m.Checkpoints {
+		indices = append(indices, e.ChainIndex)
+	}
+	if len(indices) != 3 || indices[0] != 3 || indices[1] != 6 || indices[2] != 7 {
+		t.Fatalf("manifest indices = %v, want [3 6 7]", indices)
+	}
+
+	// Every checkpoint in range verifies against the hashed file
+	input := filepath.Join(dir, "hashed.jsonl")
+	if err := os.WriteFile(input, out.Bytes(), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	tampered, _, processed, results, _, err := VerifyManifest(input, filepath.Join(cpDir, ManifestFile), pubPath, "")
+	if err != nil {
+		t.Fatalf("verify manifest: %v", err)
+	}
+	if len(tampered) != 0 || processed != 7 || len(results) != 3 {
+		t.Fatalf("tampered=%v processed=%d results=%d", tampered, processed, len(results))
+	}
+	for _, r := range results {
+		if !r.OK() {
+			t.Fatalf("checkpoint %d failed: %+v", r.ChainIndex, r)
+		}
+	}
+
+	// Changing event 5 and rehashing the rest of the chain is internally
+	// consistent, but the checkpoints at 6 and 7 no longer match
+	var rehashed bytes.Buffer
+	forged, _, err := ComputeChain(strings.NewReader(strings.Replace(in.String(), `{"n":4}`, `{"n":9}`, 1)), &rehashed, nil)
+	if err != nil {
+		t.Fatalf("rehash: %v", err)
+	}
+	if err := os.WriteFile(input, rehashed.Bytes(), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	tampered, head, _, results, _, err := VerifyManifest(input, filepath.Join(cpDir, ManifestFile), pubPath, "")
+	if err != nil {
+		t.Fatalf("verify manifest: %v", err)
+	}
+	if len(tampered) != 0 || head != forged.LastHeadHash {
+		t.Fatalf("rehashed chain should be internally consistent")
+	}
+	if !results[0].OK() || results[1].OK() || results[1].HeadMatches || results[2].OK() {
+		t.Fatalf("expected checkpoints 6 and 7 to fail, got %+v", results)
+	}
+}
+
+func TestCheckpointer_Period(t *testing.T) {
+	now := time.Date(2025, 9, 1, 10, 0, 0, 0, time.UTC)
+	cp := NewCheckpointer(t.TempDir(), "", CheckpointPolicy{Period: 15 * time.Minute}, 0)
+	cp.now = func() time.Time { return now }
+	cp.lastTime = now
+
+	if cp.Due(1) {
+		t.Fatalf("checkpoint should not be due before the period elapsed")
+	}
+	now = now.Add(15 * time.Minute)
+	if !cp.Due(2) {
+		t.Fatalf("checkpoint should be due after the period elapsed")
+	}
+	if cp.Due(0) {
+		t.Fatalf("checkpoint should never be due without new events")
+	}
+}
+
+func TestRunVerifyPhase_PeriodicCheckpointsAndManifest(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := mustGenKeys(t, dir)
+	inputFile := filepath.Join(dir, "input.jsonl")
+	outputFile := filepath.Join(dir, "output.jsonl")
+	cpDir := filepath.Join(dir, "checkpoints")
+
+	var events []map[string]interface{}
+	for i := 0; i < 5; i++ {
+		events = append(events, map[string]interface{}{"id": i, "msg": "test"})
+	}
+	writeTestEvents(t, inputFile, events)
+
+	cfg := &config.Config{
+		Hashing: config.HashingCfg{
+			StateFile:          filepath.Join(dir, "state.json"),
+			CheckpointDir:      cpDir,
+			CheckpointInterval: "2",
+		},
+	}
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: inputFile, OutputFile: outputFile, PrivateKeyPath: privPath}); err != nil {
+		t.Fatalf("hash mode: %v", err)
+	}
+	m, err := LoadManifest(filepath.Join(cpDir, ManifestFile))
+	if err != nil || len(m.Checkpoints) != 3 {
+		t.Fatalf("expected checkpoints at 2, 4 and 5, got %+v (err %v)", m, err)
+	}
+
+	err = RunVerifyPhase(cfg, VerifyArgs{InputFile: outputFile, ManifestPath: filepath.Join(cpDir, ManifestFile), PublicKeyPath: pubPath})
+	if err != nil {
+		t.Fatalf("verify mode: %v", err)
+	}
+
+	// Manifest verification needs a public key and excludes --checkpoint-path
+	err = RunVerifyPhase(cfg, VerifyArgs{InputFile: outputFile, ManifestPath: filepath.Join(cpDir, ManifestFile)})
+	if err == nil || !strings.Contains(err.Error(), "requires --public-key") {
+		t.Fatalf("expected public key error, got %v", err)
+	}
+	err = RunVerifyPhase(cfg, VerifyArgs{InputFile: outputFile, ManifestPath: "m.json", CheckpointPath: "c.json", PublicKeyPath: pubPath})
+	if err == nil {
+		t.Fatalf("expected error for --manifest with --checkpoint-path")
+	}
+
+	cfg.Hashing.CheckpointInterval = "often"
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: inputFile, OutputFile: outputFile}); err == nil {
+		t.Fatalf("expected error for invalid checkpoint_interval")
+	}
+}
+
+func TestVerifyCheckpointDir_LastGoodBeforeDivergence(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := mustGenKeys(t, dir)
+	cpDir := filepath.Join(dir, "checkpoints")
+
+	var in strings.Builder
+	for i := 0; i < 7; i++ {
+		in.WriteString(`{"n":` + string(rune('0'+i)) + "}\n")
+	}
+	cp := NewCheckpointer(cpDir, privPath, CheckpointPolicy{Every: 2, FileEnd: true}, 0)
+	var out bytes.Buffer
+	state, _, err := ComputeChainWithCheckpoints(strings.NewReader(in.String()), &out, nil, cp)
+	if err != nil {
+		t.Fatalf("compute: %v", err)
+	}
+	if err := cp.Write(state.LastChainIndex, state.LastHeadHash); err != nil {
+		t.Fatalf("file end checkpoint: %v", err)
+	}
+	input := filepath.Join(dir, "hashed.jsonl")
+	if err := os.WriteFile(input, out.Bytes(), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// Untouched file: checkpoints 2, 4, 6 and 7 all verify
+	tampered, _, _, res, err := VerifyCheckpointDir(input, cpDir, pubPath, "")
+	if err != nil {
+		t.Fatalf("verify dir: %v", err)
+	}
+	if len(tampered) != 0 || len(res.Results) != 4 || len(res.Failed()) != 0 || res.Divergence != 0 {
+		t.Fatalf("expected 4 good checkpoints, got tampered=%v %+v", tampered, res)
+	}
+	if res.LastGood == nil || res.LastGood.ChainIndex != 7 {
+		t.Fatalf("last good checkpoint = %+v, want index 7", res.LastGood)
+	}
+
+	// Editing event 5 in place breaks its hash and every recomputed head after it,
+	// although the stored hashes at 6 and 7 are unchanged
+	edited := strings.Replace(out.String(), `"n":4`, `"n":9`, 1)
+	if err := os.WriteFile(input, []byte(edited), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	tampered, _, _, res, err = VerifyCheckpointDir(input, cpDir, pubPath, "")
+	if err != nil {
+		t.Fatalf("verify dir: %v", err)
+	}
+	if len(tampered) != 1 || tampered[0] != 5 {
+		t.Fatalf("tampered = %v, want [5]", tampered)
+	}
+	if len(res.Failed()) != 2 || res.Divergence != 5 || res.LastGood == nil || res.LastGood.ChainIndex != 4 {
+		t.Fatalf("expected checkpoints 6 and 7 to fail with last good 4, got %+v", res)
+	}
+
+	// Rehashing the forged chain hides it from chain verification, but not from
+	// the checkpoints
+	var rehashed bytes.Buffer
+	if _, _, err := ComputeChain(strings.NewReader(strings.Replace(in.String(), `{"n":4}`, `{"n":9}`, 1)), &rehashed, nil); err != nil {
+		t.Fatalf("rehash: %v", err)
+	}
+	if err := os.WriteFile(input, rehashed.Bytes(), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	tampered, _, _, res, err = VerifyCheckpointDir(input, cpDir, pubPath, "")
+	if err != nil {
+		t.Fatalf("verify dir: %v", err)
+	}
+	if len(tampered) != 0 || res.Divergence != 6 || res.LastGood == nil || res.LastGood.ChainIndex != 4 {
+		t.Fatalf("expected divergence at checkpoint 6 after last good 4, got tampered=%v %+v", tampered, res)
+	}
+}
+
+func TestLastGoodCheckpoint(t *testing.T) {
+	results := []CheckpointResult{
+		{ChainIndex: 10, HeadMatches: true, SignatureValid: true},
+		{ChainIndex: 20, HeadMatches: true, SignatureValid: true},
+		{ChainIndex: 30, HeadMatches: false, SignatureValid: true},
+	}
+	good, div := LastGoodCheckpoint(results, nil)
+	if good == nil || good.ChainIndex != 20 || div != 30 {
+		t.Fatalf("got last good %+v, divergence %d; want 20 and 30", good, div)
+	}
+	// A tampered event before a matching checkpoint moves the divergence earlier
+	good, div = LastGoodCheckpoint(results, []int{15})
+	if good == nil || good.ChainIndex != 10 || div != 15 {
+		t.Fatalf("got last good %+v, divergence %d; want 10 and 15", good, div)
+	}
+	good, div = LastGoodCheckpoint(results, []int{5})
+	if good != nil || div != 5 {
+		t.Fatalf("got last good %+v, divergence %d; want none and 5", good, div)
+	}
+	// Tampered indices in detection order: the earliest one is the divergence
+	good, div = LastGoodCheckpoint(results, []int{25, 12, 18})
+	if good == nil || good.ChainIndex != 10 || div != 12 {
+		t.Fatalf("got last good %+v, divergence %d; want 10 and 12", good, div)
+	}
+}
+
+func TestRunVerifyPhase_CheckpointDir(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := mustGenKeys(t, dir)
+	inputFile := filepath.Join(dir, "input.jsonl")
+	outputFile := filepath.Join(dir, "output.jsonl")
+	cpDir := filepath.Join(dir, "checkpoints")
+	runLog := filepath.Join(dir, "run.log")
+
+	var events []map[string]interface{}
+	for i := 0; i < 6; i++ {
+		events = append(events, map[string]interface{}{"id": i, "msg": "test"})
+	}
+	writeTestEvents(t, inputFile, events)
+
+	cfg := &config.Config{
+		Hashing: config.HashingCfg{
+			StateFile:          filepath.Join(dir, "state.json"),
+			CheckpointDir:      cpDir,
+			CheckpointInterval: "2",
+		},
+	}
+	cfg.Logging.RunLog = runLog
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: inputFile, OutputFile: outputFile, PrivateKeyPath: privPath}); err != nil {
+		t.Fatalf("hash mode: %v", err)
+	}
+
+	// Tamper with event 4: checkpoint 2 is the last good one
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	lines[3] = strings.Replace(lines[3], `"msg":"test"`, `"msg":"edited"`, 1)
+	if err := os.WriteFile(outputFile, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: outputFile, CheckpointDir: cpDir, PublicKeyPath: pubPath}); err != nil {
+		t.Fatalf("verify mode: %v", err)
+	}
+
+	logData, err := os.ReadFile(runLog)
+	if err != nil {
+		t.Fatalf("read run log: %v", err)
+	}
+	logLines := strings.Split(strings.TrimSpace(string(logData)), "\n")
+	var summary VerifySummary
+	if err := json.Unmarshal([]byte(logLines[len(logLines)-1]), &summary); err != nil {
+		t.Fatalf("decode run log: %v", err)
+	}
+	if summary.Status != "fail" || summary.LastGoodIndex != 2 || summary.FirstDivergenceIndex != 4 || len(summary.FailedCheckpoints) != 2 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	// A checkpoint directory needs a public key and excludes the other checkpoint sources
+	err = RunVerifyPhase(cfg, VerifyArgs{InputFile: outputFile, CheckpointDir: cpDir})
+	if err == nil || !strings.Contains(err.Error(), "requires --public-key") {
+		t.Fatalf("expected public key error, got %v", err)
+	}
+	err = RunVerifyPhase(cfg, VerifyArgs{InputFile: outputFile, CheckpointDir: cpDir, ManifestPath: filepath.Join(cpDir, ManifestFile), PublicKeyPath: pubPath})
+	if err == nil {
+		t.Fatalf("expected error for --checkpoint-dir with --manifest")
+	}
+	err = RunVerifyPhase(cfg, VerifyArgs{InputFile: outputFile, CheckpointDir: filepath.Join(dir, "missing"), PublicKeyPath: pubPath})
+	if err == nil {
+		t.Fatalf("expected error for missing checkpoint directory")
+	}
+}
+
+func TestRunVerifyPhase_TruncatedTail(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := mustGenKeys(t, dir)
+	inputFile := filepath.Join(dir, "input.jsonl")
+	outputFile := filepath.Join(dir, "output.jsonl")
+	cpDir := filepath.Join(dir, "checkpoints")
+	runLog := filepath.Join(dir, "run.log")
+
+	var events []map[string]interface{}
+	for i := 0; i < 6; i++ {
+		events = append(events, map[string]interface{}{"id": i, "msg": "test"})
+	}
+	writeTestEvents(t, inputFile, events)
+
+	cfg := &config.Config{
+		Hashing: config.HashingCfg{
+			StateFile:          filepath.Join(dir, "state.json"),
+			CheckpointDir:      cpDir,
+			CheckpointInterval: "2",
+		},
+	}
+	cfg.Logging.RunLog = runLog
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: inputFile, OutputFile: outputFile, PrivateKeyPath: privPath}); err != nil {
+		t.Fatalf("hash mode: %v", err)
+	}
+
+	// Cut the last two events: the remaining chain is intact, but the
+	// checkpoint at 6 signs events the file no longer holds
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	truncated := strings.Join(lines[:4], "\n") + "\n"
+	if err := os.WriteFile(outputFile, []byte(truncated), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	for _, args := range []VerifyArgs{
+		{InputFile: outputFile, ManifestPath: filepath.Join(cpDir, ManifestFile), PublicKeyPath: pubPath},
+		{InputFile: outputFile, CheckpointDir: cpDir, PublicKeyPath: pubPath},
+	} {
+		if err := RunVerifyPhase(cfg, args); err != nil {
+			t.Fatalf("verify mode: %v", err)
+		}
+		logData, err := os.ReadFile(runLog)
+		if err != nil {
+			t.Fatalf("read run log: %v", err)
+		}
+		logLines := strings.Split(strings.TrimSpace(string(logData)), "\n")
+		var summary VerifySummary
+		if err := json.Unmarshal([]byte(logLines[len(logLines)-1]), &summary); err != nil {
+			t.Fatalf("decode run log: %v", err)
+		}
+		if summary.Status != "fail" || summary.LastGoodIndex != 4 || summary.FirstDivergenceIndex != 5 {
+			t.Fatalf("unexpected summary for %+v: %+v", args, summary)
+		}
+		if len(summary.Findings) != 1 {
+			t.Fatalf("findings = %+v, want one truncated finding", summary.Findings)
+		}
+		f := summary.Findings[0]
+		if f.Type != FindingTruncated || f.ChainIndex != 5 || f.Count != 2 || f.Offset != int64(len(truncated)) {
+			t.Fatalf("finding = %+v, want 2 events truncated from index 5", f)
+		}
+	}
+}
+
+// writeKeyPair writes a PKCS#8 private key and PKIX public key under dir
+func writeKeyPair(t *testing.T, dir, name string, key crypto.Signer) (privPath, pubPath string) {
+	t.Helper()
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal pkcs8: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("marshal pkix: %v", err)
+	}
+	privPath = filepath.Join(dir, name+"-private.pem")
+	pubPath = filepath.Join(dir, name+"-public.pem")
+	if err := os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), 0600); err != nil {
+		t.Fatalf("write priv: %v", err)
+	}
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644); err != nil {
+		t.Fatalf("write pub: %v", err)
+	}
+	return privPath, pubPath
+}
+
+func TestCheckpoint_Algorithms(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("gen ed25519: %v", err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("gen rsa: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("gen ecdsa: %v", err)
+	}
+
+	keys := map[string]crypto.Signer{AlgEd25519: edKey, AlgRSAPSSSHA256: rsaKey, AlgECDSAP256SHA256: ecKey}
+	pubs := map[string]string{}
+	for alg, key := range keys {
+		privPath, pubPath := writeKeyPair(t, dir, alg, key)
+		pubs[alg] = pubPath
+
+		path, err := WriteCheckpoint(filepath.Join(dir, alg), 3, "abcd", privPath)
+		if err != nil {
+			t.Fatalf("%s: write checkpoint: %v", alg, err)
+		}
+		sc, err := LoadCheckpoint(path)
+		if err != nil {
+			t.Fatalf("%s: load: %v", alg, err)
+		}
+		fp, _ := KeyFingerprint(pubPath)
+		if sc.Checkpoint.Algorithm != alg || sc.Checkpoint.KeyID != fp {
+			t.Fatalf("%s: recorded algorithm %q key %q, want key %q", alg, sc.Checkpoint.Algorithm, sc.Checkpoint.KeyID, fp)
+		}
+		ok, err := VerifyCheckpoint(path, pubPath, "abcd")
+		if err != nil || !ok {
+			t.Fatalf("%s: verify = %v, %v", alg, ok, err)
+		}
+	}
+
+	// A checkpoint is never verified under another algorithm
+	path, err := WriteCheckpoint(filepath.Join(dir, "mixed"), 3, "abcd", filepath.Join(dir, AlgEd25519+"-private.pem"))
+	if err != nil {
+		t.Fatalf("write checkpoint: %v", err)
+	}
+	if ok, err := VerifyCheckpoint(path, pubs[AlgRSAPSSSHA256], "abcd"); ok || err == nil {
+		t.Fatalf("expected algorithm mismatch, got %v, %v", ok, err)
+	}
+
+	// The algorithm and key ID are covered by the signature
+	sc, _ := LoadCheckpoint(path)
+	sc.Checkpoint.KeyID = "relabelled"
+	v, err := LoadFileVerifier(pubs[AlgEd25519])
+	if err != nil {
+		t.Fatalf("load verifier: %v", err)
+	}
+	if ok, _ := VerifySignedCheckpoint(sc, v); ok {
+		t.Fatalf("relabelled key id should not verify")
+	}
+
+	// RSA keys below 2048 bits are rejected
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("gen rsa: %v", err)
+	}
+	smallPriv, _ := writeKeyPair(t, dir, "small", small)
+	if _, err := LoadFileSigner(smallPriv); err == nil {
+		t.Fatalf("expected error for 1024-bit RSA key")
+	}
+}
+
+func TestCheckpoint_LegacyWithoutAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := mustGenKeys(t, dir)
+	signer, err := LoadFileSigner(privPath)
+	if err != nil {
+		t.Fatalf("load signer: %v", err)
+	}
+
+	// Checkpoints written before algorithms were recorded sign only index, head and time
+	cp := Checkpoint{ChainIndex: 1, HeadHash: "abcd", CreatedAt: time.Now().UTC()}
+	canon, _ := canonicalizeCheckpoint(cp)
+	sig, err := signer.Sign([]byte(canon))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	b, _ := json.Marshal(SignedCheckpoint{Checkpoint: cp, Signature: base64.StdEncoding.EncodeToString(sig)})
+	path := filepath.Join(dir, "legacy.json")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if ok, err := VerifyCheckpoint(path, pubPath, "abcd"); err != nil || !ok {
+		t.Fatalf("legacy checkpoint verify = %v, %v", ok, err)
+	}
+}
+
+// TestExternalSignerHelper is not a real test: it is run as the external signer
+// command by TestExternalSigner_Command.
+func TestExternalSignerHelper(t *testing.T) {
+	keyPath := os.Getenv("AUDITR_TEST_SIGNER_KEY")
+	if keyPath == "" {
+		return
+	}
+	signer, err := LoadFileSigner(keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	msg, _ := io.ReadAll(os.Stdin)
+	sig, err := signer.Sign(msg)
+	if err != nil || os.Getenv("AUDITR_SIGN_ALGORITHM") != AlgEd25519 {
+		fmt.Fprintln(os.Stderr, "signing failed")
+		os.Exit(2)
+	}
+	fmt.Println(base64.StdEncoding.EncodeToString(sig))
+	os.Exit(0)
+}
+
+func TestExternalSigner_Command(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	privPath, pubPath := writeKeyPair(t, dir, "hsm", edKey)
+	t.Setenv("AUDITR_TEST_SIGNER_KEY", privPath)
+
+	cfg := &config.Config{Signing: config.SigningCfg{External: config.ExternalSignerCfg{
+		Command:   []string{os.Args[0], "-test.run=^TestExternalSignerHelper$"},
+		Algorithm: AlgEd25519,
+		KeyID:     "hsm:slot0/auditr",
+	}}}
+	signer, err := NewSigner(cfg, "")
+	if err != nil {
+		t.Fatalf("new signer: %v", err)
+	}
+	path, err := WriteCheckpointWithSigner(dir, 7, "abcd", signer)
+	if err != nil {
+		t.Fatalf("write checkpoint: %v", err)
+	}
+	sc, _ := LoadCheckpoint(path)
+	if sc.Checkpoint.Algorithm != AlgEd25519 || sc.Checkpoint.KeyID != "hsm:slot0/auditr" {
+		t.Fatalf("recorded %q / %q", sc.Checkpoint.Algorithm, sc.Checkpoint.KeyID)
+	}
+	if ok, err := VerifyCheckpoint(path, pubPath, "abcd"); err != nil || !ok {
+		t.Fatalf("verify = %v, %v", ok, err)
+	}
+
+	// A failing command fails the checkpoint
+	t.Setenv("AUDITR_TEST_SIGNER_KEY", filepath.Join(dir, "missing.pem"))
+	if _, err := WriteCheckpointWithSigner(dir, 8, "abcd", signer); err == nil || !strings.Contains(err.Error(), "external signer") {
+		t.Fatalf("expected external signer error, got %v", err)
+	}
+}
+
+func TestExternalSigner_Socket(t *testing.T) {
+	dir := t.TempDir()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	privPath, pubPath := writeKeyPair(t, dir, "kms", edKey)
+	fileSigner, err := LoadFileSigner(privPath)
+	if err != nil {
+		t.Fatalf("load signer: %v", err)
+	}
+
+	// Minimal signing daemon speaking the JSON line protocol
+	sock := filepath.Join(dir, "signer.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var req externalSignRequest
+			_ = json.NewDecoder(conn).Decode(&req)
+			msg, _ := base64.StdEncoding.DecodeString(req.Message)
+			resp := externalSignResponse{}
+			if req.KeyID != "kms-key-1" {
+				resp.Error = "unknown key " + req.KeyID
+			} else {
+				sig, _ := fileSigner.Sign(msg)
+				resp.Signature = base64.StdEncoding.EncodeToString(sig)
+			}
+			_ = json.NewEncoder(conn).Encode(resp)
+			conn.Close()
+		}
+	}()
+
+	signer := &ExternalSigner{Socket: sock, Alg: AlgEd25519, ID: "kms-key-1"}
+	path, err := WriteCheckpointWithSigner(dir, 2, "abcd", signer)
+	if err != nil {
+		t.Fatalf("write checkpoint: %v", err)
+	}
+	if ok, err := VerifyCheckpoint(path, pubPath, "abcd"); err != nil || !ok {
+		t.Fatalf("verify = %v, %v", ok, err)
+	}
+
+	signer.ID = "other"
+	if _, err := signer.Sign([]byte("x")); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Fatalf("expected signer error, got %v", err)
+	}
+}
+
+func TestNewSigner_Config(t *testing.T) {
+	ext := func(e config.ExternalSignerCfg) *config.Config {
+		return &config.Config{Signing: config.SigningCfg{External: e}}
+	}
+	if _, err := NewSigner(ext(config.ExternalSignerCfg{Command: []string{"sign"}, Algorithm: "md5", KeyID: "k"}), ""); err == nil {
+		t.Fatalf("expected error for unknown algorithm")
+	}
+	if _, err := NewSigner(ext(config.ExternalSignerCfg{Command: []string{"sign"}, Algorithm: AlgEd25519}), ""); err == nil {
+		t.Fatalf("expected error for missing key_id")
+	}
+	if _, err := NewSigner(ext(config.ExternalSignerCfg{Command: []string{"sign"}, Socket: "s", Algorithm: AlgEd25519, KeyID: "k"}), ""); err == nil {
+		t.Fatalf("expected error for command and socket")
+	}
+	if _, err := NewSigner(&config.Config{}, ""); err == nil || !strings.Contains(err.Error(), "signing key not provided") {
+		t.Fatalf("expected missing key error, got %v", err)
+	}
+}
+
+// writeCheckpointAt writes a checkpoint signed as if created at t
+func writeCheckpointAt(t *testing.T, path string, signer Signer, index int, head string, at time.Time) {
+	t.Helper()
+	cp := Checkpoint{ChainIndex: index, HeadHash: head, CreatedAt: at.UTC(), Algorithm: signer.Algorithm(), KeyID: signer.KeyID()}
+	canon, err := canonicalizeCheckpoint(cp)
+	if err != nil {
+		t.Fatalf("canonicalize: %v", err)
+	}
+	sig, err := signer.Sign([]byte(canon))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	b, _ := json.Marshal(SignedCheckpoint{Checkpoint: cp, Signature: base64.StdEncoding.EncodeToString(sig)})
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestSaveKeyringWithKeyPair_RemovesKeysOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	key, err := GenerateKey(AlgEd25519)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	kr := &Keyring{}
+	if _, err := kr.Add(key.Public(), time.Now().UTC()); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	priv, pub, err := WriteKeyPair(filepath.Join(dir, "keys"), "auditr", key)
+	if err != nil {
+		t.Fatalf("write key pair: %v", err)
+	}
+
+	// A directory in the keyring's place can't be replaced
+	krPath := filepath.Join(dir, "keyring.json")
+	if err := os.Mkdir(krPath, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := SaveKeyringWithKeyPair(krPath, kr, priv, pub); err == nil {
+		t.Fatalf("expected error saving the keyring")
+	}
+	for _, p := range []string{priv, pub} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected %s removed after the failed save, stat error = %v", p, err)
+		}
+	}
+
+	// A saved keyring keeps the key files
+	priv, pub, err = WriteKeyPair(filepath.Join(dir, "keys"), "auditr", key)
+	if err != nil {
+		t.Fatalf("write key pair: %v", err)
+	}
+	if err := SaveKeyringWithKeyPair(filepath.Join(dir, "saved.json"), kr, priv, pub); err != nil {
+		t.Fatalf("save keyring: %v", err)
+	}
+	if _, err := os.Stat(priv); err != nil {
+		t.Errorf("expected private key kept: %v", err)
+	}
+}
+
+func TestKeyring_RotationKeepsOldCheckpointsVerifiable(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	rotation := start.Add(30 * 24 * time.Hour)
+
+	oldKey, err := GenerateKey(AlgEd25519)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	oldPriv, _, err := WriteKeyPair(dir, "auditr", oldKey)
+	if err != nil {
+		t.Fatalf("write key pair: %v", err)
+	}
+	if _, _, err := WriteKeyPair(dir, "auditr", oldKey); err == nil {
+		t.Fatalf("expected error when overwriting a private key")
+	}
+	oldSigner, err := LoadFileSigner(oldPriv)
+	if err != nil {
+		t.Fatalf("load signer: %v", err)
+	}
+
+	kr := &Keyring{}
+	if _, err := kr.Add(oldKey.Public(), start); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	newKey, err := GenerateKey(AlgECDSAP256SHA256)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if _, err := kr.Add(newKey.Public(), rotation); err == nil {
+		t.Fatalf("expected Add to refuse a second active key")
+	}
+	newPriv, _, err := WriteKeyPair(dir, "auditr", newKey)
+	if err != nil {
+		t.Fatalf("write key pair: %v", err)
+	}
+	newSigner, _ := LoadFileSigner(newPriv)
+	if _, err := kr.Rotate(newSigner, newKey.Public(), rotation); err == nil {
+		t.Fatalf("expected Rotate to require the active key")
+	}
+	entry, err := kr.Rotate(oldSigner, newKey.Public(), rotation)
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if entry.Endorsement == nil || entry.Endorsement.KeyID != oldSigner.KeyID() || kr.Active().KeyID != newSigner.KeyID() {
+		t.Fatalf("unexpected keyring after rotation: %+v", kr)
+	}
+	krPath := filepath.Join(dir, "keyring.json")
+	if err := SaveKeyring(krPath, kr); err != nil {
+		t.Fatalf("save keyring: %v", err)
+	}
+
+	// Checkpoints from either side of the rotation verify against the keyring
+	before := filepath.Join(dir, "before.json")
+	after := filepath.Join(dir, "after.json")
+	writeCheckpointAt(t, before, oldSigner, 10, "aaaa", rotation.Add(-time.Hour))
+	writeCheckpointAt(t, after, newSigner, 20, "bbbb", rotation.Add(time.Hour))
+	if ok, err := VerifyCheckpoint(before, krPath, "aaaa"); err != nil || !ok {
+		t.Fatalf("pre-rotation checkpoint: %v, %v", ok, err)
+	}
+	if ok, err := VerifyCheckpoint(after, krPath, "bbbb"); err != nil || !ok {
+		t.Fatalf("post-rotation checkpoint: %v, %v", ok, err)
+	}
+	a := AttestCheckpoint(after, krPath, "", "bbbb")
+	if a.SignatureStatus != SignatureValid || a.KeyFingerprint != newSigner.KeyID() {
+		t.Fatalf("attestation with keyring: %+v", a)
+	}
+
+	// The retired key can't sign after the rotation
+	late := filepath.Join(dir, "late.json")
+	writeCheckpointAt(t, late, oldSigner, 30, "cccc", rotation.Add(time.Hour))
+	if ok, err := VerifyCheckpoint(late, krPath, "cccc"); ok || err == nil || !strings.Contains(err.Error(), "not valid at") {
+		t.Fatalf("expected retired key to be rejected, got %v, %v", ok, err)
+	}
+
+	// A key added to the file without an endorsement breaks the keyring
+	rogueKey, _ := GenerateKey(AlgEd25519)
+	rogue, _ := newKeyringKey(rogueKey.Public(), rotation.Add(2*time.Hour))
+	forged := *kr
+	forged.Keys = append(append([]KeyringKey{}, kr.Keys...), *rogue)
+	end := rotation.Add(2 * time.Hour)
+	forged.Keys[1].NotAfter = &end
+	if err := forged.VerifyEndorsements(); err == nil {
+		t.Fatalf("expected unendorsed key to fail")
+	}
+	forged.Keys[2].Endorsement = &KeyEndorsement{KeyID: newSigner.KeyID(), Signature: entry.Endorsement.Signature}
+	if err := forged.VerifyEndorsements(); err == nil {
+		t.Fatalf("expected replayed endorsement to fail")
+	}
+}
+
+func TestCheckpoint_Timestamped(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := mustGenKeys(t, dir)
+	signer, err := LoadFileSigner(privPath)
+	if err != nil {
+		t.Fatalf("load signer: %v", err)
+	}
+	tsa, err := timestamp.NewLocalTSA()
+	if err != nil {
+		t.Fatalf("local TSA: %v", err)
+	}
+	srv := httptest.NewServer(tsa)
+	defer srv.Close()
+	tsaCert := filepath.Join(dir, "tsa.pem")
+	if err := os.WriteFile(tsaCert, tsa.CertificatePEM(), 0644); err != nil {
+		t.Fatalf("write TSA cert: %v", err)
+	}
+
+	cpDir := filepath.Join(dir, "checkpoints")
+	client := &timestamp.Client{URL: srv.URL}
+	path, err := WriteTimestampedCheckpoint(cpDir, 5, "head5", signer, client)
+	if err != nil {
+		t.Fatalf("write timestamped checkpoint: %v", err)
+	}
+	if ok, err := VerifyCheckpoint(path, pubPath, "head5"); err != nil || !ok {
+		t.Fatalf("verify checkpoint: ok=%v err=%v", ok, err)
+	}
+	tok, err := VerifyCheckpointTimestamp(path, tsaCert)
+	if err != nil {
+		t.Fatalf("verify timestamp: %v", err)
+	}
+	if d := time.Since(tok.GenTime); d < -time.Minute || d > time.Minute {
+		t.Errorf("unexpected token time %v", tok.GenTime)
+	}
+
+	// A token from an untrusted TSA only passes the integrity check
+	other, err := timestamp.NewLocalTSA()
+	if err != nil {
+		t.Fatalf("local TSA: %v", err)
+	}
+	otherCert := filepath.Join(dir, "other-tsa.pem")
+	if err := os.WriteFile(otherCert, other.CertificatePEM(), 0644); err != nil {
+		t.Fatalf("write TSA cert: %v", err)
+	}
+	if _, err := VerifyCheckpointTimestamp(path, otherCert); err == nil {
+		t.Error("expected timestamp from another TSA to be untrusted")
+	}
+
+	// A token moved over from another checkpoint doesn't cover this one
+	path6, err := WriteTimestampedCheckpoint(cpDir, 6, "head6", signer, client)
+	if err != nil {
+		t.Fatalf("write timestamped checkpoint: %v", err)
+	}
+	sc5, _ := LoadCheckpoint(path)
+	sc6, _ := LoadCheckpoint(path6)
+	sc5.TimestampToken = sc6.TimestampToken
+	b, _ := json.Marshal(sc5)
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if ok, err := VerifyCheckpoint(path, pubPath, "head5"); ok || err == nil {
+		t.Errorf("expected swapped timestamp token to fail, ok=%v err=%v", ok, err)
+	}
+
+	// CreatedAt must agree with the TSA's time
+	tsa.Now = func() time.Time { return time.Now().Add(time.Hour) }
+	path7, err := WriteTimestampedCheckpoint(cpDir, 7, "head7", signer, client)
+	if err != nil {
+		t.Fatalf("write timestamped checkpoint: %v", err)
+	}
+	if _, err := VerifyCheckpoint(path7, pubPath, "head7"); err == nil || !strings.Contains(err.Error(), "away from its timestamp") {
+		t.Errorf("expected clock skew error, got %v", err)
+	}
+}
+
+func TestRunVerifyPhase_TSACert(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := mustGenKeys(t, dir)
+	inputFile := filepath.Join(dir, "input.jsonl")
+	outputFile := filepath.Join(dir, "output.jsonl")
+	cpDir := filepath.Join(dir, "checkpoints")
+	runLog := filepath.Join(dir, "run.log")
+
+	tsa, err := timestamp.NewLocalTSA()
+	if err != nil {
+		t.Fatalf("local TSA: %v", err)
+	}
+	srv := httptest.NewServer(tsa)
+	defer srv.Close()
+	tsaCert := filepath.Join(dir, "tsa.pem")
+	if err := os.WriteFile(tsaCert, tsa.CertificatePEM(), 0644); err != nil {
+		t.Fatalf("write TSA cert: %v", err)
+	}
+
+	var events []map[string]interface{}
+	for i := 0; i < 6; i++ {
+		events = append(events, map[string]interface{}{"id": i, "msg": "test"})
+	}
+	writeTestEvents(t, inputFile, events)
+
+	cfg := &config.Config{
+		Hashing: config.HashingCfg{
+			StateFile:          filepath.Join(dir, "state.json"),
+			CheckpointDir:      cpDir,
+			CheckpointInterval: "3",
+		},
+		Timestamping: config.TimestampingCfg{URL: srv.URL},
+	}
+	cfg.Logging.RunLog = runLog
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: inputFile, OutputFile: outputFile, PrivateKeyPath: privPath}); err != nil {
+		t.Fatalf("hash mode: %v", err)
+	}
+
+	lastSummary := func() VerifySummary {
+		t.Helper()
+		logData, err := os.ReadFile(runLog)
+		if err != nil {
+			t.Fatalf("read run log: %v", err)
+		}
+		logLines := strings.Split(strings.TrimSpace(string(logData)), "\n")
+		var summary VerifySummary
+		if err := json.Unmarshal([]byte(logLines[len(logLines)-1]), &summary); err != nil {
+			t.Fatalf("decode run log: %v", err)
+		}
+		return summary
+	}
+
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: outputFile, CheckpointDir: cpDir, PublicKeyPath: pubPath, TSACertPath: tsaCert}); err != nil {
+		t.Fatalf("verify mode: %v", err)
+	}
+	if s := lastSummary(); s.Status != "pass" || s.TimestampsVerified != 2 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+
+	// A checkpoint without a token fails when timestamps are required
+	f, err := os.Open(outputFile)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, head, _, err := VerifyChain(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("verify chain: %v", err)
+	}
+	plain, err := WriteCheckpoint(filepath.Join(dir, "plain"), 6, head, privPath)
+	if err != nil {
+		t.Fatalf("write checkpoint: %v", err)
+	}
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: outputFile, CheckpointPath: plain, PublicKeyPath: pubPath}); err != nil {
+		t.Fatalf("verify mode: %v", err)
+	}
+	if s := lastSummary(); s.Status != "pass" {
+		t.Fatalf("expected untimestamped checkpoint to pass without --tsa-cert, got %+v", s)
+	}
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: outputFile, CheckpointPath: plain, PublicKeyPath: pubPath, TSACertPath: tsaCert}); err != nil {
+		t.Fatalf("verify mode: %v", err)
+	}
+	if s := lastSummary(); s.Status != "fail" || len(s.FailedCheckpoints) != 1 {
+		t.Fatalf("expected untimestamped checkpoint to fail with --tsa-cert, got %+v", s)
+	}
+
+	// --tsa-cert needs a checkpoint to check
+	err = RunVerifyPhase(cfg, VerifyArgs{InputFile: outputFile, TSACertPath: tsaCert})
+	if err == nil || !strings.Contains(err.Error(), "--tsa-cert") {
+		t.Fatalf("expected --tsa-cert usage error, got %v", err)
+	}
+
+	// Attestations name the TSA only when its certificate is trusted
+	m, err := LoadManifest(filepath.Join(cpDir, ManifestFile))
+	if err != nil || len(m.Checkpoints) == 0 {
+		t.Fatalf("load manifest: %v, %v", m, err)
+	}
+	stamped := filepath.Join(cpDir, m.Checkpoints[len(m.Checkpoints)-1].File)
+	if a := AttestCheckpoint(stamped, pubPath, "", head); a.TimestampedAt == nil || a.TimestampTrusted || a.TimestampAuthority != "" || a.Error != "" {
+		t.Fatalf("expected an untrusted timestamp without a TSA certificate, got %+v", a)
+	}
+	if a := AttestCheckpoint(stamped, pubPath, tsaCert, head); a.TimestampedAt == nil || !a.TimestampTrusted || a.TimestampAuthority == "" || a.Error != "" {
+		t.Fatalf("expected a trusted timestamp with the TSA certificate, got %+v", a)
+	}
+	other, err := timestamp.NewLocalTSA()
+	if err != nil {
+		t.Fatalf("local TSA: %v", err)
+	}
+	otherCert := filepath.Join(dir, "other-tsa.pem")
+	if err := os.WriteFile(otherCert, other.CertificatePEM(), 0644); err != nil {
+		t.Fatalf("write TSA cert: %v", err)
+	}
+	if a := AttestCheckpoint(stamped, pubPath, otherCert, head); a.TimestampTrusted || a.Error == "" {
+		t.Fatalf("expected a timestamp from another TSA to be rejected, got %+v", a)
+	}
+}
+
+func TestMerkle_AuditPaths(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		var leaves [][]byte
+		for i := 0; i < n; i++ {
+			leaves = append(leaves, merkleLeafHash(fmt.Sprintf(`{"id":%d}`, i)))
+		}
+		root := merkleRoot(leaves)
+		for m := 0; m < n; m++ {
+			path := merkleAuditPath(m, leaves)
+			got, err := merkleRootFromPath(leaves[m], m, n, path)
+			if err != nil || !bytes.Equal(got, root) {
+				t.Fatalf("n=%d m=%d: root mismatch (err=%v)", n, m, err)
+			}
+			if n > 1 {
+				if got, err := merkleRootFromPath(leaves[m], (m+1)%n, n, path); err == nil && bytes.Equal(got, root) {
+					t.Fatalf("n=%d m=%d: proof verified at the wrong index", n, m)
+				}
+			}
+		}
+	}
+}
+
+func TestComputeMerkleChain_VerifyAndTamper(t *testing.T) {
+	var in bytes.Buffer
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&in, `{"event_id":"evt-%d","msg":"test"}`+"\n", i)
+	}
+	var out bytes.Buffer
+	state, processed, err := ComputeMerkleChain(&in, &out, nil, 4, nil)
+	if err != nil {
+		t.Fatalf("ComputeMerkleChain: %v", err)
+	}
+	if processed != 10 || state.LastChainIndex != 10 || state.LastMerkleBatch != 3 {
+		t.Fatalf("unexpected state %+v (processed %d)", state, processed)
+	}
+
+	tampered, head, n, err := VerifyChain(bytes.NewReader(out.Bytes()))
+	if err != nil || len(tampered) != 0 || n != 10 {
+		t.Fatalf("verify: tampered=%v n=%d err=%v", tampered, n, err)
+	}
+	if head != state.LastHeadHash {
+		t.Fatalf("head %s, want batch head %s", head, state.LastHeadHash)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var evt map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[4]), &evt); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if evt["merkle_batch"] != float64(2) || evt["hash_chain_index"] != float64(5) {
+		t.Fatalf("unexpected Merkle fields in %v", evt)
+	}
+
+	// Edit event 6: it is flagged and the recomputed head of its batch moves
+	lines[5] = strings.Replace(lines[5], `"msg":"test"`, `"msg":"edited"`, 1)
+	_, _, _, before, _, _, _ := VerifyChainHeads(bytes.NewReader(out.Bytes()), map[int]bool{8: true})
+	tampered, _, _, after, _, _, err := VerifyChainHeads(strings.NewReader(strings.Join(lines, "\n")), map[int]bool{8: true})
+	if err != nil {
+		t.Fatalf("verify tampered: %v", err)
+	}
+	if len(tampered) != 1 || tampered[0] != 6 {
+		t.Fatalf("tampered = %v, want [6]", tampered)
+	}
+	if before[8] == "" || before[8] == after[8] {
+		t.Fatalf("recomputed batch head should change: before=%q after=%q", before[8], after[8])
+	}
+}
+
+func TestInclusionProof(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := mustGenKeys(t, dir)
+	inputFile := filepath.Join(dir, "input.jsonl")
+	outputFile := filepath.Join(dir, "output.jsonl")
+	cpDir := filepath.Join(dir, "checkpoints")
+
+	var events []map[string]interface{}
+	for i := 1; i <= 10; i++ {
+		events = append(events, map[string]interface{}{"event_id": fmt.Sprintf("evt-%d", i), "msg": "test"})
+	}
+	writeTestEvents(t, inputFile, events)
+
+	cfg := &config.Config{
+		Hashing: config.HashingCfg{
+			StateFile:          filepath.Join(dir, "state.json"),
+			CheckpointDir:      cpDir,
+			CheckpointInterval: "8",
+			MerkleBatchSize:    4,
+		},
+	}
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: inputFile, OutputFile: outputFile, PrivateKeyPath: privPath}); err != nil {
+		t.Fatalf("hash mode: %v", err)
+	}
+	paths, _ := filepath.Glob(filepath.Join(cpDir, "checkpoint-*.json"))
+	if len(paths) != 2 {
+		t.Fatalf("expected checkpoints at 8 and 10, got %v", paths)
+	}
+
+	// Checkpoints sign batch heads, so directory verification still works
+	tampered, _, _, res, err := VerifyCheckpointDir(outputFile, cpDir, pubPath, "")
+	if err != nil || len(tampered) != 0 || len(res.Failed()) != 0 || len(res.Results) != 2 {
+		t.Fatalf("checkpoint dir: tampered=%v res=%+v err=%v", tampered, res, err)
+	}
+
+	proof, err := BuildInclusionProof(outputFile, "evt-2", paths)
+	if err != nil {
+		t.Fatalf("build proof: %v", err)
+	}
+	if proof.Checkpoint.Checkpoint.ChainIndex != 8 || len(proof.RootChain) != 1 || proof.LeafIndex != 1 || proof.TreeSize != 4 {
+		t.Fatalf("unexpected proof %+v", proof)
+	}
+
+	// Round-trip through JSON as an auditor would receive it
+	b, _ := json.Marshal(proof)
+	proofPath := filepath.Join(dir, "proof.json")
+	if err := os.WriteFile(proofPath, b, 0644); err != nil {
+		t.Fatalf("write proof: %v", err)
+	}
+	loaded, err := LoadInclusionProof(proofPath)
+	if err != nil {
+		t.Fatalf("load proof: %v", err)
+	}
+	if err := VerifyInclusionProof(loaded, pubPath); err != nil {
+		t.Fatalf("verify proof: %v", err)
+	}
+
+	// The last, partial batch is covered by the file end checkpoint
+	last, err := BuildInclusionProof(outputFile, "evt-10", paths)
+	if err != nil {
+		t.Fatalf("build proof: %v", err)
+	}
+	if last.Checkpoint.Checkpoint.ChainIndex != 10 || len(last.RootChain) != 0 || last.TreeSize != 2 {
+		t.Fatalf("unexpected proof %+v", last)
+	}
+	if err := VerifyInclusionProof(last, pubPath); err != nil {
+		t.Fatalf("verify proof: %v", err)
+	}
+
+	// An edited event or a different key fails
+	loaded.Event["msg"] = "edited"
+	if err := VerifyInclusionProof(loaded, pubPath); err == nil {
+		t.Error("expected proof of an edited event to fail")
+	}
+	_, otherPub := mustGenKeys(t, t.TempDir())
+	if err := VerifyInclusionProof(proof, otherPub); err == nil {
+		t.Error("expected proof to fail with another public key")
+	}
+	if _, err := BuildInclusionProof(outputFile, "missing", paths); err == nil {
+		t.Error("expected error for unknown event id")
+	}
+}
+
+// hashedLines hashes n test events as a linear chain and returns the lines
+func hashedLines(t *testing.T, n int) []string {
+	t.Helper()
+	var in bytes.Buffer
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&in, `{"event_id":"evt-%d","msg":"test"}`+"\n", i)
+	}
+	var out bytes.Buffer
+	if _, _, err := ComputeChain(&in, &out, nil); err != nil {
+		t.Fatalf("ComputeChain: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+// lineOffset returns the byte offset of line i
+func lineOffset(lines []string, i int) int64 {
+	var off int64
+	for _, l := range lines[:i] {
+		off += int64(len(l)) + 1
+	}
+	return off
+}
+
+func TestCheckChain_Findings(t *testing.T) {
+	orig := hashedLines(t, 8)
+
+	// Event 3 edited and rehashed on its own: only the next link breaks
+	var evt map[string]interface{}
+	if err := json.Unmarshal([]byte(orig[2]), &evt); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	evt["msg"] = "edited"
+	canon, err := Canonicalize(evt)
+	if err != nil {
+		t.Fatalf("canonicalize: %v", err)
+	}
+	sum := sha256.Sum256([]byte(evt["hash_prev"].(string) + "|" + canon))
+	evt["hash"] = hex.EncodeToString(sum[:])
+	rehashed, _ := json.Marshal(evt)
+
+	edit := func(lines []string) []string {
+		out := append([]string(nil), lines...)
+		out[2] = strings.Replace(out[2], `"msg":"test"`, `"msg":"edited"`, 1)
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  []TamperFinding
+	}{
+		{"intact", orig, nil},
+		{"modified payload", edit(orig),
+			[]TamperFinding{{Type: FindingModified, ChainIndex: 3, Offset: lineOffset(orig, 2)}}},
+		{"rewritten hash", append(append(append([]string(nil), orig[:2]...), string(rehashed)), orig[3:]...),
+			[]TamperFinding{{Type: FindingModified, ChainIndex: 3, Offset: lineOffset(orig, 2)}}},
+		{"deleted", append(append([]string(nil), orig[:3]...), orig[5:]...),
+			[]TamperFinding{{Type: FindingDeleted, ChainIndex: 4, Count: 2, Offset: lineOffset(orig, 3)}}},
+		{"inserted duplicate", append(append(append([]string(nil), orig[:5]...), orig[1]), orig[5:]...),
+			[]TamperFinding{{Type: FindingInserted, ChainIndex: 2, Offset: lineOffset(orig, 5)}}},
+		{"inserted unhashed", append(append(append([]string(nil), orig[:5]...), `{"event_id":"forged"}`), orig[5:]...),
+			[]TamperFinding{{Type: FindingInserted, ChainIndex: 0, Offset: lineOffset(orig, 5)}}},
+		{"reordered", append(append(append([]string(nil), orig[:3]...), orig[4], orig[3]), orig[5:]...),
+			[]TamperFinding{{Type: FindingReordered, ChainIndex: 4, Offset: lineOffset(orig, 4)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, err := CheckChain(strings.NewReader(strings.Join(tt.lines, "\n")+"\n"), nil, "")
+			if err != nil {
+				t.Fatalf("CheckChain: %v", err)
+			}
+			if len(check.Findings) != len(tt.want) {
+				t.Fatalf("findings = %v, want %v", check.Findings, tt.want)
+			}
+			for i, f := range check.Findings {
+				w := tt.want[i]
+				if f.Type != w.Type || f.ChainIndex != w.ChainIndex || f.Count != w.Count || f.Offset != w.Offset {
+					t.Errorf("finding %d = %+v, want %+v", i, f, w)
+				}
+			}
+			if len(tt.want) > 0 && len(check.Tampered) == 0 {
+				t.Errorf("tampered events not reported")
+			}
+		})
+	}
+
+	// Merkle mode: a deleted event leaves a gap in the chain indices, and the
+	// broken link to the next batch is not reported separately
+	var in, out bytes.Buffer
+	for i := 1; i <= 12; i++ {
+		fmt.Fprintf(&in, `{"event_id":"evt-%d","msg":"test"}`+"\n", i)
+	}
+	if _, _, err := ComputeMerkleChain(&in, &out, nil, 4, nil); err != nil {
+		t.Fatalf("ComputeMerkleChain: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	lines = append(lines[:5], lines[6:]...)
+	check, err := CheckChain(strings.NewReader(strings.Join(lines, "\n")), nil, "")
+	if err != nil {
+		t.Fatalf("CheckChain merkle: %v", err)
+	}
+	if len(check.Findings) != 1 || check.Findings[0].Type != FindingDeleted || check.Findings[0].ChainIndex != 6 {
+		t.Fatalf("merkle findings = %v, want deleted at 6", check.Findings)
+	}
+}
+
+func TestTruncationFinding(t *testing.T) {
+	if f := TruncationFinding(Checkpoint{ChainIndex: 8}, 8, 100); f != nil {
+		t.Fatalf("checkpoint at the last index reported as truncation: %v", f)
+	}
+	f := TruncationFinding(Checkpoint{ChainIndex: 10}, 7, 100)
+	if f == nil || f.Type != FindingTruncated || f.ChainIndex != 8 || f.Count != 3 || f.Offset != 100 {
+		t.Fatalf("unexpected truncation finding %+v", f)
+	}
+}
+
+func TestCanonicalize_JCSVectors(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "jcs_vectors.json"))
+	if err != nil {
+		t.Fatalf("read vectors: %v", err)
+	}
+	var file struct {
+		Vectors []struct {
+			Name      string          `json:"name"`
+			Event     json.RawMessage `json:"event"`
+			Canonical string          `json:"canonical"`
+			SHA256    string          `json:"sha256"`
+		} `json:"vectors"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		t.Fatalf("decode vectors: %v", err)
+	}
+	if len(file.Vectors) == 0 {
+		t.Fatal("no vectors")
+	}
+	for _, v := range file.Vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			var evt map[string]interface{}
+			if err := json.Unmarshal(v.Event, &evt); err != nil {
+				t.Fatalf("decode event: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := encodeJCS(&buf, deepCopyWithoutHashFields(evt)); err != nil {
+				t.Fatalf("encodeJCS: %v", err)
+			}
+			if buf.String() != v.Canonical {
+				t.Fatalf("canonical\n got %s\nwant %s", buf.String(), v.Canonical)
+			}
+			// Events that record canon_version 2 canonicalize the same way
+			if evt["canon_version"] == float64(CanonVersionJCS) {
+				if got, err := Canonicalize(evt); err != nil || got != v.Canonical {
+					t.Fatalf("Canonicalize = %s (err %v)", got, err)
+				}
+			}
+			sum := sha256.Sum256([]byte(v.Canonical))
+			if hex.EncodeToString(sum[:]) != v.SHA256 {
+				t.Fatalf("sha256 of canonical does not match the vector")
+			}
+		})
+	}
+}
+
+func TestCanonicalize_Schemes(t *testing.T) {
+	evt := map[string]interface{}{"ts": "2025-10-01T12:00:00+02:00", "n": 1.0}
+
+	// Events without canon_version keep the legacy rules (timestamps normalized)
+	legacy, err := Canonicalize(evt)
+	if err != nil || legacy != `{"n":1,"ts":"2025-10-01T10:00:00Z"}` {
+		t.Fatalf("legacy canonical = %s (err %v)", legacy, err)
+	}
+	evt["canon_version"] = float64(CanonVersionJCS)
+	jcs, err := Canonicalize(evt)
+	if err != nil || jcs != `{"canon_version":2,"n":1,"ts":"2025-10-01T12:00:00+02:00"}` {
+		t.Fatalf("jcs canonical = %s (err %v)", jcs, err)
+	}
+
+	evt["canon_version"] = float64(9)
+	if _, err := Canonicalize(evt); err == nil {
+		t.Fatal("expected error for unknown canon_version")
+	}
+	evt["canon_version"] = float64(CanonVersionJCS)
+	evt["hash_alg"] = "md5"
+	if _, err := Canonicalize(evt); err == nil {
+		t.Fatal("expected error for unsupported hash_alg")
+	}
+
+	if v, err := ParseCanonicalization("JCS"); err != nil || v != CanonVersionJCS {
+		t.Fatalf("ParseCanonicalization(JCS) = %d, %v", v, err)
+	}
+	if v, err := ParseCanonicalization(""); err != nil || v != CanonVersionLegacy {
+		t.Fatalf("ParseCanonicalization(\"\") = %d, %v", v, err)
+	}
+	if _, err := ParseCanonicalization("c14n"); err == nil {
+		t.Fatal("expected error for unknown canonicalization")
+	}
+}
+
+func TestComputeChain_JCS(t *testing.T) {
+	// The published chain vector verifies
+	f, err := os.Open(filepath.Join("testdata", "jcs_chain.ndjson"))
+	if err != nil {
+		t.Fatalf("open chain vector: %v", err)
+	}
+	defer f.Close()
+	tampered, head, n, err := VerifyChain(f)
+	if err != nil || len(tampered) != 0 || n != 3 {
+		t.Fatalf("chain vector: tampered=%v n=%d err=%v", tampered, n, err)
+	}
+	if head != "e2798a5a88b9261eab0d378d9691d1621c9cee857a7b6cb46e904b0c3c92ef38" {
+		t.Fatalf("chain vector head = %s", head)
+	}
+
+	// New events record the scheme, and downgrading it breaks the chain
+	var out bytes.Buffer
+	st := &ChainState{LastHeadHash: zeroHashForTest(), CanonVersion: CanonVersionJCS}
+	state, _, err := ComputeChain(strings.NewReader(`{"ts":"2025-10-01T12:00:00+02:00"}`+"\n"), &out, st)
+	if err != nil {
+		t.Fatalf("ComputeChain: %v", err)
+	}
+	if state.CanonVersion != CanonVersionJCS {
+		t.Fatalf("state canon_version = %d", state.CanonVersion)
+	}
+	line := out.String()
+	if !strings.Contains(line, `"canon_version":2`) || !strings.Contains(line, `"hash_alg":"sha256"`) {
+		t.Fatalf("scheme not recorded: %s", line)
+	}
+	downgraded := strings.Replace(line, `"canon_version":2`, `"canon_version":1`, 1)
+	if tampered, _, _, err := VerifyChain(strings.NewReader(downgraded)); err != nil || len(tampered) != 1 {
+		t.Fatalf("downgrade: tampered=%v err=%v", tampered, err)
+	}
+}
+
+func TestStreamKey(t *testing.T) {
+	if StreamKey("", nil) != nil {
+		t.Fatalf("expected nil stream key without a name or fields")
+	}
+	evt := map[string]interface{}{"db_system": "postgres", "meta": map[string]interface{}{"host": "db1"}}
+	if got := StreamKey("audit", []string{"db_system"})(evt); got != "audit" {
+		t.Fatalf("fixed name: got %q", got)
+	}
+	if got := StreamKey("", []string{"db_system", "meta.host", "source"})(evt); got != "postgres/db1/unknown" {
+		t.Fatalf("fields: got %q", got)
+	}
+
+	// Distinct names never share a directory, even once sanitized
+	a, b := StreamDir("cp", "postgres/db1"), StreamDir("cp", "postgres_db1")
+	if a == b || !strings.HasPrefix(a, filepath.Join("cp", StreamsDir, "postgres_db1-")) {
+		t.Fatalf("stream dirs: %s, %s", a, b)
+	}
+	if StreamDir("cp", "") != "cp" {
+		t.Fatalf("unnamed chain must use the base directory")
+	}
+}
+
+func TestRunVerifyPhase_NamedStreams(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := mustGenKeys(t, dir)
+	cpDir := filepath.Join(dir, "checkpoints")
+	runLog := filepath.Join(dir, "run.log")
+	stateFile := filepath.Join(dir, "state.json")
+	cfg := &config.Config{
+		Hashing: config.HashingCfg{
+			StateFile:     stateFile,
+			CheckpointDir: cpDir,
+			StreamBy:      []string{"db_system", "host"},
+		},
+	}
+	cfg.Logging.RunLog = runLog
+	lastSummary := func() VerifySummary {
+		t.Helper()
+		data, err := os.ReadFile(runLog)
+		if err != nil {
+			t.Fatalf("read run log: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		var s VerifySummary
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &s); err != nil {
+			t.Fatalf("decode run log: %v", err)
+		}
+		return s
+	}
+
+	// Two runs interleave two sources; each stream keeps its own indices
+	var outputs []string
+	for run, events := range [][]map[string]interface{}{
+		{{"db_system": "postgres", "host": "a", "id": 1}, {"db_system": "mysql", "host": "b", "id": 2}, {"db_system": "postgres", "host": "a", "id": 3}},
+		{{"db_system": "mysql", "host": "b", "id": 4}, {"db_system": "postgres", "host": "a", "id": 5}},
+	} {
+		in := filepath.Join(dir, fmt.Sprintf("in%d.jsonl", run))
+		out := filepath.Join(dir, fmt.Sprintf("out%d.jsonl", run))
+		writeTestEvents(t, in, events)
+		if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: in, OutputFile: out, Checkpoint: true, PrivateKeyPath: privPath}); err != nil {
+			t.Fatalf("hash run %d: %v", run, err)
+		}
+		outputs = append(outputs, out)
+	}
+	store, err := LoadStateStore(stateFile)
+	if err != nil {
+		t.Fatalf("load state store: %v", err)
+	}
+	if store.Stream("postgres/a").LastChainIndex != 3 || store.Stream("mysql/b").LastChainIndex != 2 || store.LastChainIndex != 0 {
+		t.Fatalf("unexpected state store %+v", store)
+	}
+	if paths, _ := filepath.Glob(filepath.Join(StreamDir(cpDir, "postgres/a"), "checkpoint-*.json")); len(paths) != 2 {
+		t.Fatalf("expected 2 postgres/a checkpoints, got %v", paths)
+	}
+
+	// Both files together verify against every stream's checkpoints
+	var all []byte
+	for _, out := range outputs {
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		all = append(all, data...)
+	}
+	allFile := filepath.Join(dir, "all.jsonl")
+	if err := os.WriteFile(allFile, all, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: allFile, CheckpointDir: cpDir, PublicKeyPath: pubPath}); err != nil {
+		t.Fatalf("verify mode: %v", err)
+	}
+	if s := lastSummary(); s.Status != "pass" || s.CheckpointsChecked != 4 {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+
+	// Verifying one stream flags the other stream's events as spliced
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: outputs[0], Stream: "postgres/a"}); err != nil {
+		t.Fatalf("verify stream: %v", err)
+	}
+	s := lastSummary()
+	if s.Status != "fail" || len(s.Findings) != 1 || s.Findings[0].Type != FindingSpliced || s.Findings[0].Stream != "mysql/b" {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+
+	// Named streams are linear chains only
+	err = RunVerifyPhase(cfg, VerifyArgs{InputFile: allFile, OutputFile: filepath.Join(dir, "merkle.jsonl"), MerkleBatchSize: 2})
+	if err == nil || !strings.Contains(err.Error(), "linear chain") {
+		t.Fatalf("expected merkle error, got %v", err)
+	}
+}
+
+func TestCheckChain_Streams(t *testing.T) {
+	h := &StreamHasher{StreamOf: StreamKey("", []string{"src"})}
+	var out bytes.Buffer
+	input := `{"src":"a","n":1}` + "\n" + `{"src":"b","n":2}` + "\n" + `{"src":"a","n":3}` + "\n"
+	if _, err := h.Compute(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Compute: %v", err)
+	}
+	check, err := CheckChain(strings.NewReader(out.String()), map[int]bool{1: true, 2: true}, "")
+	if err != nil || len(check.Tampered) != 0 || len(check.Streams) != 2 {
+		t.Fatalf("intact: %+v err=%v", check, err)
+	}
+	if a := check.Streams["a"]; a.Last != 2 || a.Head != h.States["a"].LastHeadHash || a.Heads[2] != a.Head {
+		t.Fatalf("stream a: %+v", a)
+	}
+
+	// Moving an event to another stream breaks its hash, collides with that
+	// stream's first index and cuts the start off its own stream
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	lines[0] = strings.Replace(lines[0], `"hash_stream":"a"`, `"hash_stream":"b"`, 1)
+	check, err = CheckChain(strings.NewReader(strings.Join(lines, "\n")), nil, "")
+	if err != nil || len(check.Tampered) != 3 {
+		t.Fatalf("moved: tampered=%v err=%v", check.Tampered, err)
+	}
+	if len(check.Findings) == 0 || check.Findings[0].Type != FindingModified || check.Findings[0].Stream != "b" {
+		t.Fatalf("unexpected findings %v", check.Findings)
+	}
+}
+
+func TestLockState(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	lock, err := LockState(statePath)
+	if err != nil {
+		t.Fatalf("LockState: %v", err)
+	}
+	if _, err := LockState(statePath); err == nil || !strings.Contains(err.Error(), "locked by another run") {
+		t.Fatalf("expected lock conflict, got %v", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	lock, err = LockState(statePath)
+	if err != nil {
+		t.Fatalf("LockState after unlock: %v", err)
+	}
+	lock.Unlock()
+}
+
+func TestRecoverInterruptedRun(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	start := &StateStore{ChainState: ChainState{LastChainIndex: 2, LastHeadHash: zeroHashForTest()}}
+
+	// An interrupted run wrote two events and part of a third
+	var out bytes.Buffer
+	st := start.ChainState
+	if _, _, err := ComputeChain(strings.NewReader(`{"id":1}`+"\n"+`{"id":2}`+"\n"+`{"id":3}`+"\n"), &out, &st); err != nil {
+		t.Fatalf("ComputeChain: %v", err)
+	}
+	lines := strings.SplitAfter(out.String(), "\n")
+	partial := lines[0] + lines[1] + lines[2][:20]
+	interrupted := filepath.Join(dir, "interrupted.jsonl")
+	crash := func(output string) {
+		t.Helper()
+		if err := os.WriteFile(interrupted, []byte(partial), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		if err := SaveStateStore(statePath, start); err != nil {
+			t.Fatalf("SaveStateStore: %v", err)
+		}
+		if err := BeginRun(statePath, RunRecord{Output: output, State: start}); err != nil {
+			t.Fatalf("BeginRun: %v", err)
+		}
+	}
+
+	// No run record: nothing to recover
+	if rec, err := RecoverInterruptedRun(statePath, ""); rec != nil || err != nil {
+		t.Fatalf("expected no recovery, got %+v, %v", rec, err)
+	}
+
+	// A new output keeps the complete events and rolls the state forward
+	crash(interrupted)
+	rec, err := RecoverInterruptedRun(statePath, filepath.Join(dir, "next.jsonl"))
+	if err != nil || rec.Action != RecoveryRepaired || rec.Events != 2 || rec.TruncatedBytes != 20 {
+		t.Fatalf("repair: %+v, %v", rec, err)
+	}
+	if data, _ := os.ReadFile(interrupted); string(data) != lines[0]+lines[1] {
+		t.Fatalf("output not truncated to its last complete event: %q", data)
+	}
+	state, err := LoadState(statePath)
+	if err != nil || state.LastChainIndex != 4 {
+		t.Fatalf("repaired state: %+v, %v", state, err)
+	}
+	if r, _ := LoadRunRecord(statePath); r != nil {
+		t.Fatalf("run record not removed after repair")
+	}
+
+	// Writing the same output again retries the run from its start state
+	crash(interrupted)
+	SaveStateStore(statePath, &StateStore{ChainState: ChainState{LastChainIndex: 9, LastHeadHash: zeroHashForTest()}})
+	rec, err = RecoverInterruptedRun(statePath, interrupted)
+	if err != nil || rec.Action != RecoveryRetry {
+		t.Fatalf("retry: %+v, %v", rec, err)
+	}
+	if state, _ := LoadState(statePath); state.LastChainIndex != 2 {
+		t.Fatalf("retry must restore the start state, got %+v", state)
+	}
+
+	// Outputs that don't continue the recorded chain, or went to stdout, are rejected
+	crash(interrupted)
+	os.WriteFile(interrupted, []byte(strings.Replace(lines[0], `"id":1`, `"id":7`, 1)), 0644)
+	if _, err := RecoverInterruptedRun(statePath, ""); err == nil || !strings.Contains(err.Error(), "does not match its hash") {
+		t.Fatalf("expected rejection of a modified output, got %v", err)
+	}
+	crash("")
+	if _, err := RecoverInterruptedRun(statePath, ""); err == nil || !strings.Contains(err.Error(), "stdout") {
+		t.Fatalf("expected rejection of a stdout run, got %v", err)
+	}
+}
+
+func TestRecoverInterruptedRun_DiscardsCheckpoints(t *testing.T) {
+	dir := t.TempDir()
+	privPath, pubPath := mustGenKeys(t, dir)
+	statePath := filepath.Join(dir, "state.json")
+	cpDir := filepath.Join(dir, "checkpoints")
+	output := filepath.Join(dir, "hashed.jsonl")
+	start := &StateStore{ChainState: ChainState{LastHeadHash: zeroHashForTest()}}
+	cfg := &config.Config{Hashing: config.HashingCfg{StateFile: statePath}}
+	cfg.Logging.RunLog = filepath.Join(dir, "run.log")
+
+	// A run checkpoints every 2 events, then is interrupted with event 4 only
+	// partly on disk: the checkpoint at 4 signs a head the output doesn't hold
+	crash := func() {
+		t.Helper()
+		if err := SaveStateStore(statePath, start); err != nil {
+			t.Fatalf("SaveStateStore: %v", err)
+		}
+		if err := BeginRun(statePath, RunRecord{Output: output, State: start}); err != nil {
+			t.Fatalf("BeginRun: %v", err)
+		}
+		cp := NewCheckpointer(cpDir, privPath, CheckpointPolicy{Every: 2}, 0)
+		cp.StatePath = statePath
+		var out bytes.Buffer
+		st := start.ChainState
+		if _, _, err := ComputeChainWithCheckpoints(strings.NewReader("{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n{\"id\":4}\n"), &out, &st, cp); err != nil {
+			t.Fatalf("ComputeChainWithCheckpoints: %v", err)
+		}
+		if len(cp.Written) != 2 {
+			t.Fatalf("expected checkpoints at 2 and 4, got %v", cp.Written)
+		}
+		lines := strings.SplitAfter(out.String(), "\n")
+		if err := os.WriteFile(output, []byte(lines[0]+lines[1]+lines[2]+lines[3][:20]), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	verifyDir := func(want int) {
+		t.Helper()
+		for _, args := range []VerifyArgs{
+			{InputFile: output, CheckpointDir: cpDir, PublicKeyPath: pubPath},
+			{InputFile: output, ManifestPath: filepath.Join(cpDir, ManifestFile), PublicKeyPath: pubPath},
+		} {
+			if err := RunVerifyPhase(cfg, args); err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			logData, _ := os.ReadFile(cfg.Logging.RunLog)
+			logLines := strings.Split(strings.TrimSpace(string(logData)), "\n")
+			var summary VerifySummary
+			if err := json.Unmarshal([]byte(logLines[len(logLines)-1]), &summary); err != nil {
+				t.Fatalf("decode run log: %v", err)
+			}
+			if summary.Status != "pass" || summary.CheckpointsChecked != want || len(summary.Findings) != 0 {
+				t.Fatalf("verify %+v after recovery: %+v", args, summary)
+			}
+		}
+		m, err := LoadManifest(filepath.Join(cpDir, ManifestFile))
+		if err != nil || len(m.Checkpoints) != want {
+			t.Fatalf("manifest = %+v, %v; want %d checkpoints", m, err, want)
+		}
+	}
+
+	// A repair keeps events 1-3: the checkpoint at 2 stays, the one at 4 goes
+	crash()
+	rec, err := RecoverInterruptedRun(statePath, filepath.Join(dir, "next.jsonl"))
+	if err != nil || rec.Action != RecoveryRepaired || rec.Events != 3 || len(rec.DiscardedCheckpoints) != 1 {
+		t.Fatalf("repair: %+v, %v", rec, err)
+	}
+	if _, err := os.Stat(rec.DiscardedCheckpoints[0]); !os.IsNotExist(err) {
+		t.Fatalf("discarded checkpoint still exists: %v", err)
+	}
+	verifyDir(1)
+
+	// A retry rolls back to the run's start: every checkpoint of the run goes
+	os.RemoveAll(cpDir)
+	crash()
+	rec, err = RecoverInterruptedRun(statePath, output)
+	if err != nil || rec.Action != RecoveryRetry || len(rec.DiscardedCheckpoints) != 2 {
+		t.Fatalf("retry: %+v, %v", rec, err)
+	}
+	if paths, _ := filepath.Glob(filepath.Join(cpDir, "checkpoint-*.json")); len(paths) != 0 {
+		t.Fatalf("checkpoints left after retry: %v", paths)
+	}
+	if m, _ := LoadManifest(filepath.Join(cpDir, ManifestFile)); len(m.Checkpoints) != 0 {
+		t.Fatalf("manifest entries left after retry: %+v", m.Checkpoints)
+	}
+}
+
+func TestRunVerifyPhase_InterruptedRunRepaired(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Hashing: config.HashingCfg{StateFile: filepath.Join(dir, "state.json")}}
+	input := filepath.Join(dir, "input.jsonl")
+	writeTestEvents(t, input, []map[string]interface{}{{"id": 1}, {"id": 2}})
+	first := filepath.Join(dir, "first.jsonl")
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: input, OutputFile: first}); err != nil {
+		t.Fatalf("hash run: %v", err)
+	}
+	if r, _ := LoadRunRecord(cfg.Hashing.StateFile); r != nil {
+		t.Fatalf("run record left after a completed run")
+	}
+
+	// Cut the last event in half and pretend the run never finished
+	data, _ := os.ReadFile(first)
+	os.WriteFile(first, data[:len(data)-10], 0644)
+	BeginRun(cfg.Hashing.StateFile, RunRecord{Output: first, State: &StateStore{ChainState: ChainState{LastHeadHash: zeroHashForTest()}}})
+
+	second := filepath.Join(dir, "second.jsonl")
+	writeTestEvents(t, input, []map[string]interface{}{{"id": 2}})
+	if err := RunVerifyPhase(cfg, VerifyArgs{InputFile: input, OutputFile: second}); err != nil {
+		t.Fatalf("hash run after crash: %v", err)
+	}
+	a, _ := os.ReadFile(first)
+	b, _ := os.ReadFile(second)
+	tampered, _, n, err := VerifyChain(bytes.NewReader(append(a, b...)))
+	if err != nil || len(tampered) != 0 || n != 2 {
+		t.Fatalf("repaired chain: tampered=%v n=%d err=%v", tampered, n, err)
+	}
+}
@@ -0,0 +1,465 @@
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vaibhaw-/AuditR/internal/auditr/logger"
+)
+
+// errStateLocked reports that another run holds the chain state lock
+var errStateLocked = errors.New("chain state is locked by another run")
+
+// StateLock is an exclusive lock on a chain state file (<state_file>.lock).
+//
+// Hash mode holds it for the whole run, from reading the state to saving it, so
+// that two concurrent runs can't both continue the chain from the same head and
+// fork it.
+type StateLock struct {
+	f *os.File
+}
+
+// LockState takes the lock of a chain state file without waiting.
+//
+// Args:
+//   - statePath: Path of hashing.state_file
+//
+// Returns:
+//   - The held lock (Unlock releases it)
+//   - Error if another run holds the lock or the lock file can't be created
+func LockState(statePath string) (*StateLock, error) {
+	path := statePath + ".lock"
+	f, err := os.OpenFile(path, lockFileFlags, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("%w (remove %s if no run is active)", errStateLocked, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open state lock: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, errStateLocked) {
+			return nil, fmt.Errorf("%w (%s)", errStateLocked, path)
+		}
+		return nil, err
+	}
+	// Record the holder for whoever finds the lock taken
+	if err := f.Truncate(0); err == nil {
+		fmt.Fprintf(f, "%d\n", os.Getpid())
+	}
+	return &StateLock{f: f}, nil
+}
+
+// Unlock releases the lock and closes the lock file. An error means the lock
+// may still be held: on platforms without flock the lock file was left behind.
+func (l *StateLock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := unlockFile(l.f)
+	l.f = nil
+	return err
+}
+
+// RunRecord is the write-ahead record of a hash run in progress
+// (<state_file>.wal).
+//
+// It is written, and synced, before the first event is hashed and removed once
+// the new state is saved. Finding it on the next start means a run was
+// interrupted: the output it names may end in a partial line and the state file
+// doesn't cover the events it holds (see RecoverInterruptedRun).
+//
+// Fields:
+//   - StartedAt: When the run started
+//   - PID: Process of the run
+//   - Input / Output: Files of the run (absolute; empty for stdin / stdout)
+//   - Merkle: Whether the run hashed in Merkle mode
+//   - State: The state store when the run started
+//   - Checkpoints: Checkpoints the run has written (see RecordCheckpoint)
+type RunRecord struct {
+	StartedAt   time.Time       `json:"started_at"`
+	PID         int             `json:"pid"`
+	Input       string          `json:"input,omitempty"`
+	Output      string          `json:"output,omitempty"`
+	Merkle      bool            `json:"merkle,omitempty"`
+	State       *StateStore     `json:"state"`
+	Checkpoints []RunCheckpoint `json:"checkpoints,omitempty"`
+}
+
+// RunCheckpoint is a checkpoint written by a hash run in progress.
+//
+// Fields:
+//   - Path: Checkpoint file
+//   - Stream: Named chain of the checkpoint (empty for the unnamed chain)
+//   - ChainIndex: Chain index the checkpoint signs
+type RunCheckpoint struct {
+	Path       string `json:"path"`
+	Stream     string `json:"stream,omitempty"`
+	ChainIndex int    `json:"chain_index"`
+}
+
+// walPath returns the run record path of a state file
+func walPath(statePath string) string {
+	return statePath + ".wal"
+}
+
+// BeginRun writes the run record of a starting hash run.
+//
+// The record is written through a synced temporary file and rename, so after a
+// crash it is either complete or absent.
+//
+// Args:
+//   - statePath: Path of hashing.state_file
+//   - rec: Record of the run (StartedAt and PID are filled in)
+//
+// Returns:
+//   - Error if the record can't be written
+func BeginRun(statePath string, rec RunRecord) error {
+	rec.StartedAt = time.Now().UTC()
+	rec.PID = os.Getpid()
+	for _, p := range []*string{&rec.Input, &rec.Output} {
+		if *p != "" {
+			if abs, err := filepath.Abs(*p); err == nil {
+				*p = abs
+			}
+		}
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encode run record: %w", err)
+	}
+	if err := writeFileSync(walPath(statePath), append(b, '\n')); err != nil {
+		return fmt.Errorf("write run record: %w", err)
+	}
+	logger.L().Debugw("run record written", "path", walPath(statePath), "output", rec.Output)
+	return nil
+}
+
+// RecordCheckpoint adds a checkpoint written by the run in progress to its run
+// record, so that recovering the run can remove it if the events it signs are
+// not kept. Does nothing when no run is recorded.
+//
+// Args:
+//   - statePath: Path of hashing.state_file
+//   - cp: The checkpoint written
+//
+// Returns:
+//   - Error if the record can't be read or rewritten
+func RecordCheckpoint(statePath string, cp RunCheckpoint) error {
+	rec, err := LoadRunRecord(statePath)
+	if err != nil || rec == nil {
+		return err
+	}
+	if abs, err := filepath.Abs(cp.Path); err == nil {
+		cp.Path = abs
+	}
+	rec.Checkpoints = append(rec.Checkpoints, cp)
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encode run record: %w", err)
+	}
+	if err := writeFileSync(walPath(statePath), append(b, '\n')); err != nil {
+		return fmt.Errorf("write run record: %w", err)
+	}
+	return nil
+}
+
+// CompleteRun removes the run record once the run's state is saved
+func CompleteRun(statePath string) error {
+	if statePath == "" {
+		return nil
+	}
+	if err := os.Remove(walPath(statePath)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove run record: %w", err)
+	}
+	return nil
+}
+
+// LoadRunRecord reads the run record of a state file; returns nil if there is none.
+func LoadRunRecord(statePath string) (*RunRecord, error) {
+	b, err := os.ReadFile(walPath(statePath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read run record: %w", err)
+	}
+	var rec RunRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, fmt.Errorf("decode run record %s: %w", walPath(statePath), err)
+	}
+	if rec.State == nil {
+		rec.State = &StateStore{ChainState: ChainState{LastHeadHash: zeroHash()}}
+	}
+	return &rec, nil
+}
+
+// Recovery describes how RecoverInterruptedRun resolved an interrupted run.
+//
+// Fields:
+//   - Record: The interrupted run's record
+//   - Action: "retry" (state rolled back to the run's start) or "repaired"
+//     (output cut to its last complete event and the state rolled forward)
+//   - Events: Events of the interrupted output kept by a repair
+//   - TruncatedBytes: Bytes of a partial last line removed from the output
+//   - DiscardedCheckpoints: Checkpoints of the run that signed events not kept
+type Recovery struct {
+	Record               *RunRecord
+	Action               string
+	Events               int
+	TruncatedBytes       int64
+	DiscardedCheckpoints []string
+}
+
+// Recovery actions
+const (
+	RecoveryRetry    = "retry"
+	RecoveryRepaired = "repaired"
+)
+
+// RecoverInterruptedRun resolves the run record left by an interrupted hash run
+// before a new run starts. The caller must hold the state lock.
+//
+// A new run writing to the interrupted run's output replaces that file, so it
+// is a retry: the state is rolled back to the one recorded when the interrupted
+// run started, and the partial output is overwritten.
+//
+// Otherwise the interrupted output is kept and repaired by inspecting its tail:
+// a partial last line is cut off and every complete event must continue the
+// recorded chains, which the state is then rolled forward to. The events after
+// the last one kept were not hashed and must be hashed again by the new run.
+//
+// Either way, the checkpoints the interrupted run wrote beyond the chain
+// positions kept are removed with their manifest entries: they sign heads that
+// no longer exist, and a later verification would report them as divergences.
+//
+// The run is rejected when the output can't be inspected (it went to stdout or
+// is gone), was hashed in Merkle mode, or doesn't continue the recorded chains.
+// After checking the output by hand, removing <state_file>.wal accepts the
+// current state file as it is.
+//
+// Args:
+//   - statePath: Path of hashing.state_file
+//   - output: Output of the new run (empty for stdout)
+//
+// Returns:
+//   - How the run was recovered (nil if no run was interrupted)
+//   - Error if the interrupted run is rejected or recovery fails
+func RecoverInterruptedRun(statePath, output string) (*Recovery, error) {
+	log := logger.L()
+	rec, err := LoadRunRecord(statePath)
+	if err != nil || rec == nil {
+		return nil, err
+	}
+	log.Warnw("interrupted hash run found", "run_record", walPath(statePath), "started_at", rec.StartedAt, "pid", rec.PID, "output", rec.Output)
+	rejected := func(reason string) error {
+		return fmt.Errorf("interrupted hash run (started %s, output %q) can't be recovered: %s; check its output, then remove %s to keep the current state",
+			rec.StartedAt.Format(time.RFC3339), rec.Output, reason, walPath(statePath))
+	}
+
+	// Writing to the same file again is a retry of the interrupted run
+	if output != "" && rec.Output != "" {
+		if abs, err := filepath.Abs(output); err == nil && abs == rec.Output {
+			discarded, err := discardCheckpoints(rec, rec.State)
+			if err != nil {
+				return nil, err
+			}
+			if err := SaveStateStore(statePath, rec.State); err != nil {
+				return nil, err
+			}
+			if err := CompleteRun(statePath); err != nil {
+				return nil, err
+			}
+			log.Warnw("interrupted hash run retried: state rolled back to its start", "output", rec.Output,
+				"index", rec.State.LastChainIndex, "discarded_checkpoints", len(discarded))
+			return &Recovery{Record: rec, Action: RecoveryRetry, DiscardedCheckpoints: discarded}, nil
+		}
+	}
+
+	if rec.Output == "" {
+		return nil, rejected("its output went to stdout")
+	}
+	if rec.Merkle {
+		return nil, rejected("Merkle mode output can't be resumed mid-batch; hash the input again to the same --output")
+	}
+	store, events, size, err := repairOutput(rec.Output, rec.State)
+	if err != nil {
+		return nil, rejected(err.Error())
+	}
+	fi, err := os.Stat(rec.Output)
+	if err != nil {
+		return nil, rejected(err.Error())
+	}
+	cut := fi.Size() - size
+	if cut > 0 {
+		if err := os.Truncate(rec.Output, size); err != nil {
+			return nil, fmt.Errorf("truncate interrupted output: %w", err)
+		}
+	}
+	discarded, err := discardCheckpoints(rec, store)
+	if err != nil {
+		return nil, err
+	}
+	if err := SaveStateStore(statePath, store); err != nil {
+		return nil, err
+	}
+	if err := CompleteRun(statePath); err != nil {
+		return nil, err
+	}
+	log.Warnw("interrupted hash run repaired: hash the input after these events again", "output", rec.Output,
+		"events", events, "truncated_bytes", cut, "index", store.LastChainIndex, "streams", len(store.Streams),
+		"discarded_checkpoints", len(discarded))
+	return &Recovery{Record: rec, Action: RecoveryRepaired, Events: events, TruncatedBytes: cut, DiscardedCheckpoints: discarded}, nil
+}
+
+// discardCheckpoints removes the checkpoints an interrupted run wrote beyond the
+// last committed event of their stream, and their manifest entries.
+//
+// Args:
+//   - rec: The interrupted run's record
+//   - committed: State store the recovery keeps
+//
+// Returns:
+//   - Paths of the removed checkpoints
+//   - Error if a checkpoint or manifest can't be updated
+func discardCheckpoints(rec *RunRecord, committed *StateStore) ([]string, error) {
+	var discarded []string
+	byDir := make(map[string]map[string]bool)
+	for _, cp := range rec.Checkpoints {
+		if cp.ChainIndex <= committed.Stream(cp.Stream).LastChainIndex {
+			continue
+		}
+		if err := os.Remove(cp.Path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("remove checkpoint of interrupted run: %w", err)
+		}
+		dir := filepath.Dir(cp.Path)
+		if byDir[dir] == nil {
+			byDir[dir] = make(map[string]bool)
+		}
+		byDir[dir][filepath.Base(cp.Path)] = true
+		discarded = append(discarded, cp.Path)
+		logger.L().Debugw("checkpoint of interrupted run removed", "path", cp.Path, "stream", cp.Stream, "index", cp.ChainIndex)
+	}
+	for dir, files := range byDir {
+		if err := removeManifestEntries(dir, files); err != nil {
+			return nil, err
+		}
+	}
+	return discarded, nil
+}
+
+// repairOutput checks that an interrupted run's output continues the chains of
+// the state it started from.
+//
+// Args:
+//   - path: The interrupted output
+//   - start: State store when the run started
+//
+// Returns:
+//   - State store after the output's last complete event
+//   - Number of complete events
+//   - Size of the output up to the end of its last complete event
+//   - Error if the output can't be read or doesn't continue the chains
+func repairOutput(path string, start *StateStore) (*StateStore, int, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	defer f.Close()
+
+	// Work on a copy so a rejected output leaves the record untouched
+	store := &StateStore{ChainState: start.ChainState, Streams: make(map[string]*ChainState, len(start.Streams))}
+	for name := range start.Streams {
+		store.Streams[name] = start.Stream(name)
+	}
+
+	r := bufio.NewReader(f)
+	var size int64
+	events := 0
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// A line without its newline was cut off mid-write
+			return store, events, size, nil
+		}
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if err := continueChain(store, bytes.TrimSpace(line)); err != nil {
+			return nil, 0, 0, fmt.Errorf("event at byte offset %d: %w", size, err)
+		}
+		size += int64(len(line))
+		events++
+	}
+}
+
+// continueChain checks that one hashed event follows its chain's state and
+// advances the state past it
+func continueChain(store *StateStore, line []byte) error {
+	var evt map[string]interface{}
+	if err := json.Unmarshal(line, &evt); err != nil {
+		return fmt.Errorf("decode event: %w", err)
+	}
+	if isMerkleEvent(evt) {
+		return fmt.Errorf("event was hashed in Merkle mode")
+	}
+	name, _ := evt["hash_stream"].(string)
+	st := &store.ChainState
+	if name != "" {
+		if store.Streams[name] == nil {
+			store.Streams[name] = store.Stream(name)
+		}
+		st = store.Streams[name]
+	}
+
+	prev, _ := evt["hash_prev"].(string)
+	got, _ := evt["hash"].(string)
+	idx, _ := evt["hash_chain_index"].(float64)
+	if int(idx) != st.LastChainIndex+1 || prev != st.LastHeadHash {
+		return fmt.Errorf("chain index %v does not continue chain index %d", evt["hash_chain_index"], st.LastChainIndex)
+	}
+	canon, err := Canonicalize(evt)
+	if err != nil {
+		return fmt.Errorf("canonicalize: %w", err)
+	}
+	sum := sha256.Sum256([]byte(prev + "|" + canon))
+	if hex.EncodeToString(sum[:]) != got {
+		return fmt.Errorf("chain index %d does not match its hash", int(idx))
+	}
+	st.LastChainIndex = int(idx)
+	st.LastHeadHash = got
+	return nil
+}
+
+// writeFileSync writes a file through a synced temporary file and rename
+func writeFileSync(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}